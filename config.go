@@ -1,12 +1,96 @@
 package main
 
-import "time"
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+)
 
 type flags struct {
-	AnnotationsPrefix string
-	Kubeconfig        string
-	ResyncPeriodS     string
-	ResyncPeriod      time.Duration
-	StatusAddr        string
-	AllowAll          bool
+	AnnotationsPrefix    string
+	SecretPrefix         string
+	ConfigMapPrefix      string
+	ServiceAccountPrefix string
+	RolePrefix           string
+	DynamicPrefix        string
+	LegacyPrefixes       string
+	Resources            string
+	DynamicResources     string
+	Kubeconfig           string
+	ClusterName          string
+	Clusters             string
+	ClustersKubeconfig   string
+	VaultAddr            string
+	VaultTokenFile       string
+	AWSRegion            string
+	ExternalNamespaces   string
+	ExternalRefreshS     string
+	ExternalRefresh      time.Duration
+	ResyncPeriodS        string
+	ResyncPeriod         time.Duration
+	ReconcilePeriodS     string
+	ReconcilePeriod      time.Duration
+	StatusAddr           string
+	LogLevel             string
+	LogFormat            string
+	Logger               logr.Logger
+	QueryAddr            string
+	DedupWindowS         string
+	DedupWindow          time.Duration
+	AllowAll             bool
+	OwnerReferences      bool
+	Workers              int
+	Bundles              bool
+	ReplicationRules     bool
+	RuleStatusPeriodS    string
+	RuleStatusPeriod     time.Duration
+	ProjectClusterRoles  bool
+	Instance             string
+	ShardsNamespace      string
+	ShardsLeaseS         string
+	ShardsLease          time.Duration
+	PropagateAnnotations string
+	PropagateLabels      string
+	TargetMetadata       string
+	PatternOptions       string
+	NamespaceAllow       string
+	NamespaceDeny        string
+	WatchNamespaces      string
+	AdoptExisting        bool
+	SealedSecretsCert    string
+	RejectExpiredCerts   bool
+	PullSecretsToAll     bool
+	PullSecretsSelector  string
+	PullSecretsPatchSA   bool
+	WebhookAddr          string
+	WebhookCert          string
+	WebhookKey           string
+	WebhookUsername      string
+	SourceStatusPeriodS  string
+	SourceStatusPeriod   time.Duration
+	ServerSideApply      bool
+	ConflictRetries      int
+	NamespaceDebounceS   string
+	NamespaceDebounce    time.Duration
+	Concurrency          int
+	Paused               bool
+	DryRun               bool
+	StartupOrphans       string
+	CacheTransform       string
+	LogDiffs             bool
+	LogDiffValues        bool
+	AuditLog             string
+	WriteQPS             float64
+	WriteBurst           int
+	KubeAPIQPS           float64
+	KubeAPIBurst         int
+	ListPageSize         int64
+	NamespaceWriteQPS    float64
+	NamespaceWriteBurst  int
+	MaxTargetsPerSource  int
+	Impersonate          bool
+	ReplicateAs          string
+	RulesFile            string
+	RulesPeriodS         string
+	RulesPeriod          time.Duration
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+)
+
+// runExplain prints the decisions of the replicator of the kind on an object,
+// as a source and as a target, without writing anything
+func runExplain(args []string, replicators []replicate.Replicator) {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: explain <kind> <namespace>/<name>\n")
+		os.Exit(2)
+	}
+
+	for _, repl := range replicators {
+		trace, ok, err := repl.Explain(args[0], args[1])
+		if err != nil {
+			panic(err)
+		} else if !ok {
+			continue
+		}
+
+		for _, line := range trace {
+			fmt.Println(line)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "no replicator for kind %s, see --resources\n", args[0])
+	os.Exit(2)
+}
@@ -0,0 +1,19 @@
+package liveness
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+)
+
+// DryRunHandler implements a HTTP response handler that reports the writes
+// which would have been made to each target in dry-run mode
+type DryRunHandler struct{}
+
+func (h *DryRunHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	_ = enc.Encode(replicate.DryRunActions())
+}
@@ -9,7 +9,8 @@ import (
 )
 
 type response struct {
-	NotReady []string `json:"notReady"`
+	NotReady   []string              `json:"notReady"`
+	Collisions []replicate.Collision `json:"collisions"`
 }
 
 // Handler implements a HTTP response handler that reports on the current
@@ -32,9 +33,20 @@ func (h *Handler) notReadyComponents() []string {
 	return notReady
 }
 
+func (h *Handler) collisions() []replicate.Collision {
+	collisions := make([]replicate.Collision, 0)
+
+	for i := range h.Replicators {
+		collisions = append(collisions, h.Replicators[i].Collisions()...)
+	}
+
+	return collisions
+}
+
 func (h *Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	r := response{
-		NotReady: h.notReadyComponents(),
+		NotReady:   h.notReadyComponents(),
+		Collisions: h.collisions(),
 	}
 
 	if len(r.NotReady) > 0 {
@@ -8,19 +8,72 @@ import (
 	"github.com/mittwald/kubernetes-replicator/replicate"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
 )
 
 type MockReplicator struct {
 	synced bool
 }
 
-func (r *MockReplicator) Run() {
+func (r *MockReplicator) Start() {
 }
 
 func (r *MockReplicator) Synced() bool {
 	return r.synced
 }
 
+func (r *MockReplicator) Collisions() []replicate.Collision {
+	return nil
+}
+
+func (r *MockReplicator) SimulateNamespace(namespace *v1.Namespace) []replicate.SimulatedTarget {
+	return nil
+}
+
+func (r *MockReplicator) Orphans(action replicate.OrphanAction) ([]replicate.Orphan, error) {
+	return nil, nil
+}
+
+func (r *MockReplicator) Replications() []replicate.Replication {
+	return nil
+}
+
+func (r *MockReplicator) Topology() replicate.Topology {
+	return replicate.Topology{}
+}
+
+func (r *MockReplicator) MatchNamespaces(pattern string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *MockReplicator) StartupReport() *replicate.StartupReport {
+	return nil
+}
+
+func (r *MockReplicator) Resolve(kind string, raw []byte) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (r *MockReplicator) Validate(kind string, raw []byte) error {
+	return nil
+}
+
+func (r *MockReplicator) Stamp(kind string, raw []byte, oldRaw []byte, username string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (r *MockReplicator) RenameLegacy(kind string, raw []byte) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (r *MockReplicator) Explain(kind string, key string) ([]string, bool, error) {
+	return nil, false, nil
+}
+
+func (r *MockReplicator) MigrateAnnotations() ([]replicate.Migration, error) {
+	return nil, nil
+}
+
 func buildReqRes(t *testing.T) (*http.Request, *httptest.ResponseRecorder) {
 	req, err := http.NewRequest("GET", "/status", nil)
 	res := httptest.NewRecorder()
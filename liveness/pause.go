@@ -0,0 +1,30 @@
+package liveness
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+)
+
+type pauseResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// PauseHandler implements a HTTP response handler that pauses or resumes the replication
+// on POST requests, and reports whether it is paused
+type PauseHandler struct {
+	// true to pause the replication, false to resume it
+	Pause bool
+}
+
+func (h *PauseHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		replicate.Pause(h.Pause)
+	}
+
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	_ = enc.Encode(&pauseResponse{Paused: replicate.Paused()})
+}
@@ -0,0 +1,32 @@
+package liveness
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+)
+
+// ReportHandler implements a HTTP response handler that returns the reports
+// of the initial synchronization of the replicators
+type ReportHandler struct {
+	Replicators []replicate.Replicator
+}
+
+func (h *ReportHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	reports := make([]*replicate.StartupReport, 0)
+	status := http.StatusOK
+
+	for i := range h.Replicators {
+		if report := h.Replicators[i].StartupReport(); report != nil {
+			reports = append(reports, report)
+		} else {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	res.WriteHeader(status)
+
+	enc := json.NewEncoder(res)
+	_ = enc.Encode(&reports)
+}
@@ -0,0 +1,55 @@
+package liveness
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SimulateHandler implements a HTTP response handler that reports the targets
+// which would be created in a namespace, given by the "name" and "labels" parameters
+type SimulateHandler struct {
+	Replicators []replicate.Replicator
+}
+
+func (h *SimulateHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+	if name == "" {
+		http.Error(res, "missing namespace name", http.StatusBadRequest)
+		return
+	}
+
+	namespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{},
+		},
+	}
+
+	// labels are given as "key=value,key=value"
+	for _, label := range strings.Split(req.URL.Query().Get("labels"), ",") {
+		if label == "" {
+			continue
+		}
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) == 2 {
+			namespace.Labels[parts[0]] = parts[1]
+		} else {
+			namespace.Labels[parts[0]] = ""
+		}
+	}
+
+	targets := make([]replicate.SimulatedTarget, 0)
+	for i := range h.Replicators {
+		targets = append(targets, h.Replicators[i].SimulateNamespace(namespace)...)
+	}
+
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	_ = enc.Encode(&targets)
+}
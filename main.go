@@ -1,13 +1,24 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"flag"
-	"log"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/mittwald/kubernetes-replicator/liveness"
+	"github.com/mittwald/kubernetes-replicator/query"
 	"github.com/mittwald/kubernetes-replicator/replicate"
+	"github.com/mittwald/kubernetes-replicator/webhook"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -15,21 +26,338 @@ import (
 
 var f flags
 
+// the replicators started with --resources, by resource
+var replicatorKinds = map[string]struct {
+	create func(kubernetes.Interface, replicate.ReplicatorOptions) replicate.Replicator
+	// the prefix of the annotations of this kind
+	prefix *string
+}{
+	"secrets":         {replicate.NewSecretReplicator, &f.SecretPrefix},
+	"configmaps":      {replicate.NewConfigMapReplicator, &f.ConfigMapPrefix},
+	"serviceaccounts": {replicate.NewServiceAccountReplicator, &f.ServiceAccountPrefix},
+	"roles":           {replicate.NewRoleReplicator, &f.RolePrefix},
+	"rolebindings":    {replicate.NewRoleBindingReplicator, &f.RolePrefix},
+}
+
 func init() {
 	var err error
 	flag.StringVar(&f.AnnotationsPrefix, "prefix", "v1.kubernetes-replicator.olli.com/", "prefix for all annotations")
+	flag.StringVar(&f.SecretPrefix, "secret-prefix", "", "prefix for the annotations of secrets, overrides --prefix")
+	flag.StringVar(&f.ConfigMapPrefix, "configmap-prefix", "", "prefix for the annotations of config maps, overrides --prefix")
+	flag.StringVar(&f.ServiceAccountPrefix, "serviceaccount-prefix", "", "prefix for the annotations of service accounts, overrides --prefix")
+	flag.StringVar(&f.RolePrefix, "role-prefix", "", "prefix for the annotations of roles and role bindings, overrides --prefix")
+	flag.StringVar(&f.DynamicPrefix, "dynamic-prefix", "", "prefix for the annotations of the --dynamic-resources, overrides --prefix")
+	flag.StringVar(&f.LegacyPrefixes, "legacy-prefixes", "", "comma separated previous prefixes whose annotations are still read, while only the current prefix is written")
+	flag.StringVar(&f.Resources, "resources", "secrets,configmaps,serviceaccounts,roles,rolebindings", "comma separated resources to replicate, among secrets, configmaps, serviceaccounts, roles and rolebindings")
+	flag.StringVar(&f.DynamicResources, "dynamic-resources", "", "comma separated resources replicated with the dynamic client, as resource.version.group, ex: certificates.v1alpha2.cert-manager.io")
 	flag.StringVar(&f.Kubeconfig, "kubeconfig", "", "path to Kubernetes config file")
+	flag.StringVar(&f.ClusterName, "cluster-name", "", "name of this cluster, written on the objects pushed to other clusters")
+	flag.StringVar(&f.Clusters, "clusters", "", "comma separated contexts of the clusters the sources can be pushed to or pulled from, as context or name=context")
+	flag.StringVar(&f.ClustersKubeconfig, "clusters-kubeconfig", "", "path to Kubernetes config file with the contexts of --clusters, defaults to --kubeconfig")
+	flag.StringVar(&f.VaultAddr, "vault-addr", os.Getenv("VAULT_ADDR"), "address of the Vault server the secrets and config maps can be replicated from with replicate-from: vault:<path> (disabled when empty)")
+	flag.StringVar(&f.VaultTokenFile, "vault-token-file", "", "file containing the Vault token, read again for each request, defaults to the VAULT_TOKEN environment variable")
+	flag.StringVar(&f.AWSRegion, "aws-region", os.Getenv("AWS_REGION"), "region of AWS Secrets Manager and Parameter Store, the secrets and config maps can be replicated from with replicate-from: aws-sm:<secret> or aws-ssm:<parameter> (disabled when empty)")
+	flag.StringVar(&f.ExternalNamespaces, "external-sources-allowed-namespaces", "", "comma separated namespaces or namespace patterns the external sources can be replicated to (follows --allow-all when empty)")
+	flag.StringVar(&f.ExternalRefreshS, "external-sources-refresh", "5m", "how often the data of the external sources is read again (0 to only read it on the resyncs)")
 	flag.StringVar(&f.ResyncPeriodS, "resync-period", "30m", "resynchronization period")
+	flag.StringVar(&f.ReconcilePeriodS, "reconcile-period", "0", "period of the reconciliations, listing the objects to repair the targets which drifted from their source (0 to disable)")
 	flag.StringVar(&f.StatusAddr, "status-addr", ":9102", "listen address for status and monitoring server")
+	flag.StringVar(&f.LogLevel, "log-level", "info", "level of the logs: error, info or debug")
+	flag.StringVar(&f.LogFormat, "log-format", "text", "format of the logs: text or json")
+	flag.StringVar(&f.QueryAddr, "query-addr", "", "listen address for the API answering queries on the replication graph (disabled when empty)")
 	flag.BoolVar(&f.AllowAll, "allow-all", false, "allow replication of all secrets by default (CAUTION: only use when you know what you're doing)")
+	flag.BoolVar(&f.OwnerReferences, "set-owner-references", false, "set the source as owner of its targets in the same namespace, and a finalizer deleting the other targets with the source")
+	flag.BoolVar(&f.Bundles, "bundles", false, "replicate the ReplicationBundle custom resources (the CRD must be installed)")
+	flag.BoolVar(&f.ReplicationRules, "replication-rules", false, "replicate the sources selected by the ReplicationRule custom resources (the CRD must be installed)")
+	flag.StringVar(&f.RuleStatusPeriodS, "rule-status-period", "30s", "period of the writes of the status of the ReplicationRule custom resources, with the conditions of their targets (0 to disable)")
+	flag.BoolVar(&f.ProjectClusterRoles, "project-cluster-roles", false, "project the cluster roles and cluster role bindings annotated with replicate-to-namespaces into roles and role bindings of these namespaces")
+	flag.IntVar(&f.Workers, "workers", 1, "number of workers processing the changes of the objects, per kind")
+	flag.StringVar(&f.SealedSecretsCert, "sealed-secrets-cert", "", "certificate of the sealed-secrets controller, as fetched by kubeseal --fetch-cert, the secrets replicated to the namespaces annotated with replicate-sealed are written as sealed secrets with")
+	flag.BoolVar(&f.RejectExpiredCerts, "reject-expired-certs", false, "do not replicate the TLS secrets whose certificate expired")
+	flag.BoolVar(&f.AdoptExisting, "adopt-existing", false, "let replicate-to overwrite the existing objects which were not replicated, recording their original data (the sources can override it)")
+	flag.BoolVar(&f.PullSecretsToAll, "replicate-pull-secrets-to-all", false, "replicate the docker registry secrets annotated with replicate-pull-secret to all the namespaces")
+	flag.StringVar(&f.PullSecretsSelector, "pull-secrets-namespace-selector", "", "label selector restricting the namespaces the pull secrets are replicated to")
+	flag.BoolVar(&f.PullSecretsPatchSA, "pull-secrets-patch-default-sa", false, "add the replicated pull secrets to the image pull secrets of the default service account of their namespace")
+	flag.StringVar(&f.WebhookAddr, "webhook-addr", "", "listen address for the admission webhook filling the objects created with replicate-from (disabled when empty)")
+	flag.StringVar(&f.WebhookCert, "webhook-cert", "", "TLS certificate file of the admission webhook")
+	flag.StringVar(&f.WebhookKey, "webhook-key", "", "TLS key file of the admission webhook")
+	flag.StringVar(&f.WebhookUsername, "webhook-username", "", "user the replicator writes as, whose writes are not stamped with their requester by the admission webhook (defaults to the service account of the in-cluster configuration)")
+	flag.StringVar(&f.SourceStatusPeriodS, "source-status-period", "0", "period of the writes of the replication-targets-status annotation on the sources, summarizing their targets (0 to disable)")
+	flag.StringVar(&f.NamespaceDebounceS, "namespace-debounce", "0", "window during which the new namespaces are collected, so that each source watching them is processed once by the workers (0 to process each namespace right away)")
+	flag.IntVar(&f.Concurrency, "concurrency", 1, "number of targets of a source written in parallel, the writes of a target being serialized")
+	flag.IntVar(&f.MaxTargetsPerSource, "max-targets-per-source", 0, "maximum number of targets of a source, above which none is replicated, overridden by the replicate-max-targets annotation of the source (0 for no limit)")
+	flag.BoolVar(&f.Impersonate, "impersonate", false, "write the targets as the service account of their namespace named by the replicate-as annotation of their source, or by --replicate-as, instead of with the rights of the replicator")
+	flag.StringVar(&f.ReplicateAs, "replicate-as", "", "with --impersonate, service account of the target namespaces the targets are written as when their source has no replicate-as annotation (empty to write them with the rights of the replicator)")
+	flag.IntVar(&f.ConflictRetries, "conflict-retries", 3, "number of retries with the latest version of a target after a conflict while writing it")
+	flag.BoolVar(&f.LogDiffs, "log-diffs", false, "log the keys changed by the writes of the existing secrets and config maps, without their values")
+	flag.BoolVar(&f.LogDiffValues, "log-diff-values", false, "with --log-diffs, also log the values of the keys changed in the config maps, never the ones of the secrets")
+	flag.Float64Var(&f.KubeAPIQPS, "kube-api-qps", 0, "maximum requests per second to the API, reads included (0 for the default of the client: 5)")
+	flag.IntVar(&f.KubeAPIBurst, "kube-api-burst", 0, "maximum burst of requests to the API above --kube-api-qps (0 for the default of the client: 10)")
+	flag.Int64Var(&f.ListPageSize, "list-page-size", 0, "number of objects of each page of the lists of the objects, read from etcd instead of the cache of the API server (0 to list them at once)")
+	flag.Float64Var(&f.WriteQPS, "write-qps", 0, "maximum writes per second to the API, overall (0 for no limit)")
+	flag.IntVar(&f.WriteBurst, "write-burst", 10, "maximum burst of writes to the API above --write-qps")
+	flag.Float64Var(&f.NamespaceWriteQPS, "namespace-write-qps", 0, "maximum writes per second to the API in each target namespace (0 for no limit)")
+	flag.IntVar(&f.NamespaceWriteBurst, "namespace-write-burst", 5, "maximum burst of writes to the API in each target namespace above --namespace-write-qps")
+	flag.StringVar(&f.AuditLog, "audit-log", "", "file where a JSON line is appended for each write of the targets, with its source, versions and rationale (\"-\" for stdout)")
+	flag.BoolVar(&f.DryRun, "dry-run", false, "only log the writes which would be made, without making them, and report them at /dry-run")
+	flag.StringVar(&f.CacheTransform, "cache-transform", "none", "how the objects are reduced before being cached: none, strip (the managed field sets and the last applied configurations of the objects not replicated), or metadata (also the data of the secrets and config maps not replicated, read from the API when needed)")
+	flag.StringVar(&f.StartupOrphans, "startup-orphans", "report", "what to do with the replicated targets whose source is gone or does not target them anymore, found after the initial synchronization: report, delete or clear")
+	flag.BoolVar(&f.Paused, "paused", false, "start with the replication paused, the caches being kept up-to-date without writing anything (toggled with SIGUSR1, /pause and /resume)")
+	flag.BoolVar(&f.ServerSideApply, "server-side-apply", false, "replicate the secrets and config maps with server-side apply, without overwriting the fields managed by others on the targets")
+	flag.StringVar(&f.PropagateAnnotations, "propagate-annotations", "", "comma separated annotations or annotation patterns copied from the sources to their targets")
+	flag.StringVar(&f.PropagateLabels, "propagate-labels", "", "comma separated labels or label patterns copied from the sources to their targets")
+	flag.StringVar(&f.TargetMetadata, "target-metadata", "replace", "how the labels and annotations of the existing targets are updated: replace drops the ones not set from the source, merge keeps them")
+	flag.StringVar(&f.RulesFile, "rules-file", "", "YAML file of replication rules, replicating the sources they select without annotating them (read again when it changes)")
+	flag.StringVar(&f.RulesPeriodS, "rules-period", "30s", "how often the rules file is checked for changes (0 to only read it at startup)")
+	flag.StringVar(&f.NamespaceAllow, "namespace-allow", "", "comma separated namespaces or namespace patterns where targets can be installed (all when empty)")
+	flag.StringVar(&f.NamespaceDeny, "namespace-deny", "", "comma separated namespaces or namespace patterns where targets can never be installed, ex: kube-.*")
+	flag.StringVar(&f.WatchNamespaces, "watch-namespaces", "", "comma separated namespaces the objects are watched in, each namespace on its own, instead of all the namespaces at once")
+	flag.StringVar(&f.PatternOptions, "pattern-options", "", "default options of the namespace patterns, comma separated: case-insensitive, unanchored")
+	flag.StringVar(&f.DedupWindowS, "dedup-window", "0", "time window in which identical log lines and events are collapsed, should exceed the resync period (0 to disable)")
+	flag.StringVar(&f.Instance, "instance", "", "instance of the replicator, only objects annotated with this instance are managed")
+	flag.StringVar(&f.ShardsNamespace, "shards-namespace", "", "namespace of the leases of the instances, enables the assignment of the objects without instance annotation to the active instances")
+	flag.StringVar(&f.ShardsLeaseS, "shards-lease-duration", "30s", "duration of the leases of the instances")
 	flag.Parse()
 
 	replicate.PrefixAnnotations(f.AnnotationsPrefix)
+	if f.LegacyPrefixes != "" {
+		prefixes := []string{}
+		for _, prefix := range strings.Split(f.LegacyPrefixes, ",") {
+			if prefix = strings.TrimSpace(prefix); prefix != "" {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		replicate.ReadLegacyPrefixes(prefixes)
+	}
+
+	if f.StartupOrphans == "report" {
+		f.StartupOrphans = ""
+	} else if f.StartupOrphans != "delete" && f.StartupOrphans != "clear" {
+		panic(fmt.Errorf("invalid startup orphans action '%s': expected report, delete or clear", f.StartupOrphans))
+	}
+
+	if f.CacheTransform == "none" {
+		f.CacheTransform = ""
+	} else if f.CacheTransform != "strip" && f.CacheTransform != "metadata" {
+		panic(fmt.Errorf("invalid cache transform '%s': expected none, strip or metadata", f.CacheTransform))
+	}
+
+	if f.LogFormat != "text" && f.LogFormat != "json" {
+		panic(fmt.Errorf("invalid log format '%s': expected text or json", f.LogFormat))
+	}
+	f.Logger, err = replicate.NewLogger(f.LogLevel, f.LogFormat == "json")
+	if err != nil {
+		panic(err)
+	}
+	replicate.SetLogger(f.Logger)
+
+	if err := replicate.PatternOptions(f.PatternOptions); err != nil {
+		panic(err)
+	}
+
+	if err := replicate.PropagateAnnotations(strings.Split(f.PropagateAnnotations, ",")); err != nil {
+		panic(err)
+	}
+
+	if err := replicate.PropagateLabels(strings.Split(f.PropagateLabels, ",")); err != nil {
+		panic(err)
+	}
+
+	if err := replicate.SetTargetMetadata(f.TargetMetadata); err != nil {
+		panic(err)
+	}
+
+	if f.SealedSecretsCert != "" {
+		certificate, err := ioutil.ReadFile(f.SealedSecretsCert)
+		if err != nil {
+			panic(err)
+		}
+		if err := replicate.SealSecrets(certificate); err != nil {
+			panic(err)
+		}
+	}
+
+	replicate.RejectExpiredCerts(f.RejectExpiredCerts)
+	replicate.ServerSideApply(f.ServerSideApply)
+	replicate.Pause(f.Paused)
+	replicate.DryRun(f.DryRun)
+	replicate.LimitWrites(float32(f.WriteQPS), f.WriteBurst, float32(f.NamespaceWriteQPS), f.NamespaceWriteBurst)
+	if err := replicate.AuditLog(f.AuditLog); err != nil {
+		panic(err)
+	}
+	replicate.LogDiffs(f.LogDiffs, f.LogDiffValues)
+
+	if f.PullSecretsToAll {
+		if err := replicate.ReplicatePullSecrets(f.PullSecretsSelector, f.PullSecretsPatchSA); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := replicate.RestrictNamespaces(strings.Split(f.NamespaceAllow, ","), strings.Split(f.NamespaceDeny, ",")); err != nil {
+		panic(err)
+	}
+
+	if f.WatchNamespaces != "" {
+		namespaces := []string{}
+		for _, namespace := range strings.Split(f.WatchNamespaces, ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		replicate.WatchNamespaces(namespaces)
+	}
+
+	f.RuleStatusPeriod, err = time.ParseDuration(f.RuleStatusPeriodS)
+	if err != nil {
+		panic(err)
+	}
+
+	f.RulesPeriod, err = time.ParseDuration(f.RulesPeriodS)
+	if err != nil {
+		panic(err)
+	}
+
+	if f.RulesFile != "" {
+		f.Logger.Info("reading replication rules", "file", f.RulesFile)
+		if err := replicate.LoadRules(f.RulesFile, f.RulesPeriod); err != nil {
+			panic(err)
+		}
+	}
 
 	f.ResyncPeriod, err = time.ParseDuration(f.ResyncPeriodS)
 	if err != nil {
 		panic(err)
 	}
+
+	f.ReconcilePeriod, err = time.ParseDuration(f.ReconcilePeriodS)
+	if err != nil {
+		panic(err)
+	}
+
+	f.ExternalRefresh, err = time.ParseDuration(f.ExternalRefreshS)
+	if err != nil {
+		panic(err)
+	}
+
+	f.SourceStatusPeriod, err = time.ParseDuration(f.SourceStatusPeriodS)
+	if err != nil {
+		panic(err)
+	}
+
+	f.NamespaceDebounce, err = time.ParseDuration(f.NamespaceDebounceS)
+	if err != nil {
+		panic(err)
+	}
+
+	f.DedupWindow, err = time.ParseDuration(f.DedupWindowS)
+	if err != nil {
+		panic(err)
+	}
+
+	replicate.DeduplicateWindow(f.DedupWindow)
+
+	f.ShardsLease, err = time.ParseDuration(f.ShardsLeaseS)
+	if err != nil {
+		panic(err)
+	}
+
+	if f.ShardsNamespace != "" && f.Instance == "" {
+		panic(fmt.Errorf("--shards-namespace requires --instance"))
+	}
+
+	if f.Clusters != "" && f.ClusterName == "" {
+		panic(fmt.Errorf("--clusters requires --cluster-name"))
+	}
+}
+
+// Returns the clients of the clusters of --clusters, by name
+func clusterClients() map[string]kubernetes.Interface {
+	kubeconfig := f.ClustersKubeconfig
+	if kubeconfig == "" {
+		kubeconfig = f.Kubeconfig
+	}
+
+	clients := map[string]kubernetes.Interface{}
+	for _, cluster := range strings.Split(f.Clusters, ",") {
+		if cluster == "" {
+			continue
+		}
+		name, context := cluster, cluster
+		if parts := strings.SplitN(cluster, "=", 2); len(parts) == 2 {
+			name, context = parts[0], parts[1]
+		}
+
+		f.Logger.Info("using context for cluster", "context", context, "cluster", name)
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: context},
+		).ClientConfig()
+		if err != nil {
+			panic(err)
+		}
+		clients[name] = kubernetes.NewForConfigOrDie(config)
+	}
+	return clients
+}
+
+// Returns the external sources configured by the flags, by scheme, which cannot be the name of a cluster
+func externalSources(clusters map[string]kubernetes.Interface) map[string]replicate.ExternalSource {
+	sources := map[string]replicate.ExternalSource{}
+	if f.VaultAddr != "" {
+		f.Logger.Info("replicating from vault", "addr", f.VaultAddr)
+		sources["vault"] = replicate.NewVaultSource(f.VaultAddr, vaultToken)
+	}
+	if f.AWSRegion != "" {
+		f.Logger.Info("replicating from AWS Secrets Manager and Parameter Store", "region", f.AWSRegion)
+		credentials := replicate.NewAWSCredentials(f.AWSRegion)
+		sources["aws-sm"] = replicate.NewAWSSecretsManagerSource(credentials)
+		sources["aws-ssm"] = replicate.NewAWSParameterStoreSource(credentials)
+	}
+
+	for scheme := range sources {
+		if _, ok := clusters[scheme]; ok {
+			panic(fmt.Errorf("cluster %s conflicts with the %s external source", scheme, scheme))
+		}
+	}
+	return sources
+}
+
+// Returns the Vault token, from --vault-token-file or the VAULT_TOKEN environment variable
+func vaultToken() (string, error) {
+	if f.VaultTokenFile == "" {
+		return os.Getenv("VAULT_TOKEN"), nil
+	}
+	token, err := ioutil.ReadFile(f.VaultTokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// Returns the user of the bearer token of the configuration, from the subject of the service account token,
+// or an empty string if it is not a service account token
+func tokenUsername(config *rest.Config) string {
+	token := config.BearerToken
+	if token == "" && config.BearerTokenFile != "" {
+		if content, err := ioutil.ReadFile(config.BearerTokenFile); err == nil {
+			token = strings.TrimSpace(string(content))
+		}
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	claims := struct {
+		Subject string `json:"sub"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Subject
 }
 
 func main() {
@@ -38,10 +366,10 @@ func main() {
 	var client kubernetes.Interface
 
 	if f.Kubeconfig == "" {
-		log.Printf("using in-cluster configuration")
+		f.Logger.Info("using in-cluster configuration")
 		config, err = rest.InClusterConfig()
 	} else {
-		log.Printf("using configuration from '%s'", f.Kubeconfig)
+		f.Logger.Info("using configuration from kubeconfig", "kubeconfig", f.Kubeconfig)
 		config, err = clientcmd.BuildConfigFromFlags("", f.Kubeconfig)
 	}
 
@@ -49,23 +377,192 @@ func main() {
 		panic(err)
 	}
 
+	if f.KubeAPIQPS > 0 {
+		config.QPS = float32(f.KubeAPIQPS)
+	}
+	if f.KubeAPIBurst > 0 {
+		config.Burst = f.KubeAPIBurst
+	}
+
 	client = kubernetes.NewForConfigOrDie(config)
 
-	secretRepl := replicate.NewSecretReplicator(client, f.ResyncPeriod, f.AllowAll)
-	configMapRepl := replicate.NewConfigMapReplicator(client, f.ResyncPeriod, f.AllowAll)
+	if f.WebhookUsername == "" {
+		f.WebhookUsername = tokenUsername(config)
+	}
+	replicate.SetUsername(f.WebhookUsername)
+
+	clusters := clusterClients()
+	replicate.ConfigureClusters(f.ClusterName, clusters)
+	replicate.ConfigureExternalSources(externalSources(clusters), f.ExternalNamespaces, f.ExternalRefresh)
+
+	metrics := replicate.NewMetrics()
+
+	var shards *replicate.Shards
+	if f.ShardsNamespace != "" {
+		shards = replicate.NewShards(client, f.ShardsNamespace, f.Instance, f.ShardsLease)
+	}
+
+	var clients *replicate.ClientFactory
+	if f.Impersonate {
+		clients = replicate.NewClientFactory(config, f.ReplicateAs)
+	}
+
+	replicators := []replicate.Replicator{}
+	for _, resource := range strings.Split(f.Resources, ",") {
+		if resource == "" {
+			continue
+		}
+		kind, ok := replicatorKinds[resource]
+		if !ok {
+			panic(fmt.Errorf("unknown resource '%s'", resource))
+		}
+		replicators = append(replicators, kind.create(client, replicate.ReplicatorOptions{
+			ResyncPeriod:        f.ResyncPeriod,
+			AllowAll:            f.AllowAll,
+			AnnotationsPrefix:   *kind.prefix,
+			Metrics:             metrics,
+			Instance:            f.Instance,
+			Shards:              shards,
+			Workers:             f.Workers,
+			Logger:              f.Logger,
+			ReconcilePeriod:     f.ReconcilePeriod,
+			OwnerReferences:     f.OwnerReferences,
+			AdoptExisting:       f.AdoptExisting,
+			SourceStatusPeriod:  f.SourceStatusPeriod,
+			ConflictRetries:     f.ConflictRetries,
+			NamespaceDebounce:   f.NamespaceDebounce,
+			Concurrency:         f.Concurrency,
+			MaxTargetsPerSource: f.MaxTargetsPerSource,
+			Clients:             clients,
+			StartupOrphans:      replicate.OrphanAction(f.StartupOrphans),
+			CacheTransform:      replicate.CacheTransform(f.CacheTransform),
+			ListPageSize:        f.ListPageSize,
+		}))
+	}
+
+	dynamicClient := dynamic.NewForConfigOrDie(config)
+	for _, resource := range strings.Split(f.DynamicResources, ",") {
+		if resource == "" {
+			continue
+		}
+		gvr, _ := schema.ParseResourceArg(resource)
+		if gvr == nil {
+			panic(fmt.Errorf("invalid dynamic resource '%s': expected resource.version.group", resource))
+		}
+		replicators = append(replicators, replicate.NewDynamicReplicator(dynamicClient, *gvr, client, replicate.ReplicatorOptions{
+			ResyncPeriod:        f.ResyncPeriod,
+			AllowAll:            f.AllowAll,
+			AnnotationsPrefix:   f.DynamicPrefix,
+			Metrics:             metrics,
+			Instance:            f.Instance,
+			Shards:              shards,
+			Workers:             f.Workers,
+			Logger:              f.Logger,
+			OwnerReferences:     f.OwnerReferences,
+			AdoptExisting:       f.AdoptExisting,
+			SourceStatusPeriod:  f.SourceStatusPeriod,
+			ConflictRetries:     f.ConflictRetries,
+			NamespaceDebounce:   f.NamespaceDebounce,
+			Concurrency:         f.Concurrency,
+			MaxTargetsPerSource: f.MaxTargetsPerSource,
+			Clients:             clients,
+			StartupOrphans:      replicate.OrphanAction(f.StartupOrphans),
+			CacheTransform:      replicate.CacheTransform(f.CacheTransform),
+			ListPageSize:        f.ListPageSize,
+		}))
+	}
+
+	// the commands see the objects assigned to the instance, without registering it
+	if shards != nil && (flag.Arg(0) == "orphans" || flag.Arg(0) == "explain") {
+		if err := shards.Load(); err != nil {
+			panic(fmt.Errorf("could not list the instances: %s", err))
+		}
+	}
+	if flag.Arg(0) == "orphans" {
+		runOrphans(flag.Args()[1:], replicators)
+		return
+	}
+	if flag.Arg(0) == "explain" {
+		runExplain(flag.Args()[1:], replicators)
+		return
+	}
+	if flag.Arg(0) == "migrate-annotations" {
+		if f.LegacyPrefixes == "" {
+			panic(fmt.Errorf("migrate-annotations requires --legacy-prefixes"))
+		}
+		runMigrate(replicators, f.DryRun)
+		return
+	}
+
+	f.Logger.Info("starting replicators", "prefix", f.AnnotationsPrefix)
+
+	if shards != nil {
+		f.Logger.Info("registering instance", "instance", f.Instance, "namespace", f.ShardsNamespace)
+		shards.Start()
+	}
+
+	for _, repl := range replicators {
+		repl.Start()
+	}
+
+	// SIGUSR1 pauses or resumes the replication
+	pauseSignals := make(chan os.Signal, 1)
+	signal.Notify(pauseSignals, syscall.SIGUSR1)
+	go func() {
+		for range pauseSignals {
+			replicate.TogglePause()
+		}
+	}()
 
-	log.Printf("Starting replicators with prefix \"%s\"", f.AnnotationsPrefix)
+	if f.Bundles {
+		bundles := replicate.NewBundleController(dynamicClient, f.ResyncPeriod)
+		bundles.Start()
+	}
 
-	secretRepl.Start()
+	if f.ReplicationRules {
+		rules := replicate.NewRuleController(dynamicClient, f.ResyncPeriod, f.RuleStatusPeriod)
+		rules.Start()
+	}
 
-	configMapRepl.Start()
+	if f.ProjectClusterRoles {
+		projections := replicate.NewProjectionController(client, f.ResyncPeriod)
+		projections.Start()
+	}
 
 	h := liveness.Handler{
-		Replicators: []replicate.Replicator{secretRepl, configMapRepl},
+		Replicators: replicators,
+	}
+
+	if f.QueryAddr != "" {
+		f.Logger.Info("starting query API", "addr", f.QueryAddr)
+		go func() {
+			err := http.ListenAndServe(f.QueryAddr, query.NewHandler(h.Replicators))
+			f.Logger.Error(err, "could not serve the query API", "addr", f.QueryAddr)
+			os.Exit(1)
+		}()
+	}
+
+	if f.WebhookAddr != "" {
+		f.Logger.Info("starting admission webhook", "addr", f.WebhookAddr)
+		go func() {
+			err := http.ListenAndServeTLS(f.WebhookAddr, f.WebhookCert, f.WebhookKey, webhook.NewHandler(h.Replicators, f.Logger.WithValues("component", "webhook")))
+			f.Logger.Error(err, "could not serve the admission webhook", "addr", f.WebhookAddr)
+			os.Exit(1)
+		}()
 	}
 
-	log.Printf("starting liveness monitor at %s", f.StatusAddr)
+	f.Logger.Info("starting liveness monitor", "addr", f.StatusAddr)
 
 	http.Handle("/healthz", &h)
+	http.Handle("/metrics", metrics)
+	http.Handle("/startup-report", &liveness.ReportHandler{
+		Replicators: replicators,
+	})
+	http.Handle("/simulate-namespace", &liveness.SimulateHandler{
+		Replicators: replicators,
+	})
+	http.Handle("/dry-run", &liveness.DryRunHandler{})
+	http.Handle("/pause", &liveness.PauseHandler{Pause: true})
+	http.Handle("/resume", &liveness.PauseHandler{Pause: false})
 	http.ListenAndServe(f.StatusAddr, nil)
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+)
+
+// runMigrate renames the annotations of the objects under the legacy prefixes
+// to the current prefix, and prints the migrated objects with a summary
+func runMigrate(replicators []replicate.Replicator, dryRun bool) {
+	done := "migrated"
+	if dryRun {
+		done = "would be migrated"
+	}
+
+	migrated, failed := 0, 0
+	for _, repl := range replicators {
+		migrations, err := repl.MigrateAnnotations()
+		if err != nil {
+			panic(err)
+		}
+
+		for _, m := range migrations {
+			if m.Error != "" {
+				fmt.Printf("%s %s: %s (failed: %s)\n", m.Kind, m.Object, strings.Join(m.Renamed, ", "), m.Error)
+				failed++
+			} else {
+				fmt.Printf("%s %s: %s (%s)\n", m.Kind, m.Object, strings.Join(m.Renamed, ", "), done)
+				migrated++
+			}
+		}
+	}
+
+	fmt.Printf("%d objects %s, %d failed\n", migrated, done, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
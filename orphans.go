@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+)
+
+// runOrphans lists the objects with replication annotations whose source
+// does not exist or does not target them anymore, and optionally removes them
+func runOrphans(args []string, replicators []replicate.Replicator) {
+	fs := flag.NewFlagSet("orphans", flag.ExitOnError)
+	deleteOrphans := fs.Bool("delete", false, "delete the orphans")
+	clearOrphans := fs.Bool("clear", false, "remove the replicated data of the orphans, keeping them")
+	fs.Parse(args)
+
+	action := replicate.OrphanReport
+	if *deleteOrphans && *clearOrphans {
+		panic(fmt.Errorf("--delete and --clear cannot be used together"))
+	} else if *deleteOrphans {
+		action = replicate.OrphanDelete
+	} else if *clearOrphans {
+		action = replicate.OrphanClear
+	}
+
+	done := map[replicate.OrphanAction]string{
+		replicate.OrphanDelete: "deleted",
+		replicate.OrphanClear:  "cleared",
+	}
+
+	failed := false
+	for _, repl := range replicators {
+		orphans, err := repl.Orphans(action)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, o := range orphans {
+			if o.Error != "" {
+				fmt.Printf("%s %s: %s (%s failed: %s)\n", o.Kind, o.Object, o.Reason, action, o.Error)
+				failed = true
+			} else if action != replicate.OrphanReport {
+				fmt.Printf("%s %s: %s (%s)\n", o.Kind, o.Object, o.Reason, done[action])
+			} else {
+				fmt.Printf("%s %s: %s\n", o.Kind, o.Object, o.Reason)
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
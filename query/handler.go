@@ -0,0 +1,148 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mittwald/kubernetes-replicator/replicate"
+)
+
+// Handler implements a HTTP API answering queries on the replication graph:
+//   - /consumers?source=<namespace>/<name>: the targets replicated from the source
+//   - /feeds?target=<namespace>/<name>: the sources replicated to the target
+//   - /namespaces?pattern=<pattern>: the existing namespaces matching a namespace pattern
+//   - /topology?format=json|dot: the whole graph, with the pending targets and the namespace patterns
+//
+// The optional "kind" parameter restricts the replications to a kind
+type Handler struct {
+	Replicators []replicate.Replicator
+}
+
+// NewHandler creates the routes of the API
+func NewHandler(replicators []replicate.Replicator) http.Handler {
+	h := &Handler{Replicators: replicators}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/consumers", h.consumers)
+	mux.HandleFunc("/feeds", h.feeds)
+	mux.HandleFunc("/namespaces", h.namespaces)
+	mux.HandleFunc("/topology", h.topology)
+	return mux
+}
+
+// Returns the replications of the requested kind matching the filter
+func (h *Handler) replications(req *http.Request, filter func(replicate.Replication) bool) []replicate.Replication {
+	kind := req.URL.Query().Get("kind")
+	replications := make([]replicate.Replication, 0)
+
+	for i := range h.Replicators {
+		for _, replication := range h.Replicators[i].Replications() {
+			if kind != "" && replication.Kind != kind {
+			} else if filter(replication) {
+				replications = append(replications, replication)
+			}
+		}
+	}
+
+	return replications
+}
+
+func (h *Handler) consumers(res http.ResponseWriter, req *http.Request) {
+	source := req.URL.Query().Get("source")
+	if source == "" {
+		http.Error(res, "missing source", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(res, h.replications(req, func(replication replicate.Replication) bool {
+		return replication.Source == source
+	}))
+}
+
+func (h *Handler) feeds(res http.ResponseWriter, req *http.Request) {
+	target := req.URL.Query().Get("target")
+	if target == "" {
+		http.Error(res, "missing target", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(res, h.replications(req, func(replication replicate.Replication) bool {
+		return replication.Target == target
+	}))
+}
+
+func (h *Handler) namespaces(res http.ResponseWriter, req *http.Request) {
+	pattern := req.URL.Query().Get("pattern")
+	if pattern == "" || len(h.Replicators) == 0 {
+		http.Error(res, "missing pattern", http.StatusBadRequest)
+		return
+	}
+
+	// all the replicators watch the same namespaces
+	namespaces, err := h.Replicators[0].MatchNamespaces(pattern)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(res, namespaces)
+}
+
+func (h *Handler) topology(res http.ResponseWriter, req *http.Request) {
+	kind := req.URL.Query().Get("kind")
+	topologies := make([]replicate.Topology, 0)
+	for i := range h.Replicators {
+		if topology := h.Replicators[i].Topology(); kind == "" || topology.Kind == kind {
+			topologies = append(topologies, topology)
+		}
+	}
+
+	switch format := req.URL.Query().Get("format"); format {
+	case "", "json":
+		writeJSON(res, topologies)
+	case "dot":
+		res.Header().Set("Content-Type", "text/vnd.graphviz")
+		res.WriteHeader(http.StatusOK)
+		_, _ = res.Write([]byte(dot(topologies)))
+	default:
+		http.Error(res, fmt.Sprintf("unknown format %s", format), http.StatusBadRequest)
+	}
+}
+
+// Renders the topologies as a graphviz graph: the replications are solid edges labeled with their annotation, the
+// pending targets are dashed edges, and the patterns are dotted edges to a node per pattern
+func dot(topologies []replicate.Topology) string {
+	b := &strings.Builder{}
+	b.WriteString("digraph replication {\n")
+	for _, topology := range topologies {
+		node := func(key string) string {
+			return fmt.Sprintf("%q", topology.Kind+" "+key)
+		}
+		for _, replication := range topology.Replications {
+			fmt.Fprintf(b, "  %s -> %s [label=%q];\n", node(replication.Source), node(replication.Target), replication.Annotation)
+		}
+		for _, replication := range topology.Pending {
+			fmt.Fprintf(b, "  %s -> %s [label=\"no namespace\", style=dashed];\n", node(replication.Source), node(replication.Target))
+		}
+		for _, pattern := range topology.Patterns {
+			namespaces := pattern.Namespace
+			if pattern.Selector != "" {
+				namespaces = "{" + pattern.Selector + "}"
+			}
+			fmt.Fprintf(b, "  %s [shape=box];\n", node(namespaces+"/"+pattern.Name))
+			fmt.Fprintf(b, "  %s -> %s [style=dotted];\n", node(pattern.Source), node(namespaces+"/"+pattern.Name))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeJSON(res http.ResponseWriter, value interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	_ = enc.Encode(value)
+}
@@ -0,0 +1,78 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the fields holding the data of the objects, recorded when a target is adopted
+var dataFields = []string{"type", "data", "binaryData", "secrets", "imagePullSecrets", "rules", "roleRef", "subjects", "spec"}
+
+// Returns true if the source can overwrite the existing object, which was not replicated
+// The "replicate-to-adopt-existing" annotation of the source overrides the global option
+func (r *replicatorProps) canAdopt(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, error) {
+	// the objects replicated by another source are never adopted
+	if _, ok := object.Annotations[r.ReplicatedByAnnotation]; ok {
+		return false, nil
+	}
+
+	val, ok := sourceObject.Annotations[r.ReplicateToAdoptExistingAnnotation]
+	if !ok {
+		return r.adoptExisting, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("source %s/%s has illformed annotation %s (%s): %s",
+			sourceObject.Namespace, sourceObject.Name, r.ReplicateToAdoptExistingAnnotation, val, err)
+	}
+	return b, nil
+}
+
+// Returns the meta of the adopted object, with its original data recorded as annotation
+func (r *replicatorProps) adopt(object interface{}, meta *metav1.ObjectMeta) (*metav1.ObjectMeta, error) {
+	original, err := originalData(object)
+	if err != nil {
+		return nil, err
+	}
+
+	copyMeta := meta.DeepCopy()
+	if copyMeta.Annotations == nil {
+		copyMeta.Annotations = map[string]string{}
+	}
+	copyMeta.Annotations[r.ReplicatedOriginalDataAnnotation] = original
+	return copyMeta, nil
+}
+
+// Returns the data fields of the object as JSON, so that they can be restored
+func originalData(object interface{}) (string, error) {
+	data, err := objectData(object)
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(data)
+	return string(raw), err
+}
+
+// Returns the {field => JSON} map of the data fields of the object
+func objectData(object interface{}) (map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	data := map[string]json.RawMessage{}
+	for _, field := range dataFields {
+		if value, ok := fields[field]; ok {
+			data[field] = value
+		}
+	}
+	return data, nil
+}
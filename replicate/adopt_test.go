@@ -0,0 +1,58 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCanAdopt(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{}}
+	existing := &metav1.ObjectMeta{Namespace: "other", Name: "source"}
+
+	if ok, _ := r.canAdopt(existing, source); ok {
+		t.Errorf("expected the existing object not to be adopted by default")
+	}
+
+	r.adoptExisting = true
+	if ok, _ := r.canAdopt(existing, source); !ok {
+		t.Errorf("expected the existing object to be adopted with the global option")
+	}
+
+	// the source overrides the global option
+	source.Annotations[r.ReplicateToAdoptExistingAnnotation] = "false"
+	if ok, _ := r.canAdopt(existing, source); ok {
+		t.Errorf("expected the source to forbid the adoption")
+	}
+	source.Annotations[r.ReplicateToAdoptExistingAnnotation] = "maybe"
+	if _, err := r.canAdopt(existing, source); err == nil {
+		t.Errorf("expected an error for an illformed annotation")
+	}
+
+	// the copies of other sources are never adopted
+	source.Annotations[r.ReplicateToAdoptExistingAnnotation] = "true"
+	replicated := &metav1.ObjectMeta{Namespace: "other", Name: "source", Annotations: map[string]string{
+		r.ReplicatedByAnnotation: "default/another",
+	}}
+	if ok, _ := r.canAdopt(replicated, source); ok {
+		t.Errorf("expected the copy of another source not to be adopted")
+	}
+}
+
+func TestOriginalData(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "source"},
+		Type:       v1.SecretTypeOpaque,
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+
+	data, err := originalData(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"data":{"key":"dmFsdWU="},"type":"Opaque"}`; data != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+}
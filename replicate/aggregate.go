@@ -0,0 +1,154 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// the index of the bundle targets by the namespace of their sources
+const replicateBundleIndex = "replicate-bundle-from"
+
+// Indexes the object by the namespace of its replicate-bundle-from annotation
+func (r *objectReplicator) indexBundleNamespaces(object interface{}) ([]string, error) {
+	if namespace, _, ok, err := r.bundleSelector(r.getMeta(object)); ok && err == nil {
+		return []string{namespace}, nil
+	}
+	return nil, nil
+}
+
+// Returns the namespace and the label selector of the sources of a bundle target,
+// from its replicate-bundle-from annotation formatted as "namespace/selector"
+// Returns false if the object is not a bundle target
+func (r *replicatorProps) bundleSelector(object *metav1.ObjectMeta) (string, labels.Selector, bool, error) {
+	val, ok := object.Annotations[r.ReplicateBundleFromAnnotation]
+	if !ok {
+		return "", nil, false, nil
+	}
+	parts := strings.SplitN(val, "/", 2)
+	if len(parts) != 2 || !validName.MatchString(parts[0]) {
+		return "", nil, true, fmt.Errorf("%s %s/%s has illformed annotation %s (%s): expected namespace/selector",
+			r.Name, object.Namespace, object.Name, r.ReplicateBundleFromAnnotation, val)
+	}
+	selector, err := labels.Parse(parts[1])
+	if err != nil {
+		return "", nil, true, fmt.Errorf("%s %s/%s has invalid selector on annotation %s (%s): %s",
+			r.Name, object.Namespace, object.Name, r.ReplicateBundleFromAnnotation, val, err)
+	}
+	return parts[0], selector, true, nil
+}
+
+// Returns the sources of the bundle target, sorted by name
+// The other bundle targets are never sources, so that the bundles cannot include each other
+func (r *objectReplicator) bundleSources(object *metav1.ObjectMeta) ([]string, error) {
+	namespace, selector, _, err := r.bundleSelector(object)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []string{}
+	for _, source := range r.objectStore.List() {
+		meta := r.getMeta(source)
+		if meta.Namespace != namespace || objectKey(meta) == objectKey(object) {
+			continue
+		} else if _, ok := meta.Annotations[r.ReplicateBundleFromAnnotation]; ok {
+			continue
+		} else if selector.Matches(labels.Set(meta.Labels)) {
+			sources = append(sources, objectKey(meta))
+		}
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
+// Returns the keys defined with different values by several sources, sorted
+func (r *objectReplicator) bundleConflicts(sourceObjects []interface{}) ([]string, error) {
+	overridden, err := r.overriddenKeys(sourceObjects)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := map[string]bool{}
+	for _, keys := range overridden {
+		for _, key := range keys {
+			conflicts[key] = true
+		}
+	}
+
+	keys := []string{}
+	for key := range conflicts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Replicates the merged data of all the sources selected by the replicate-bundle-from annotation into the object
+// The sources are merged in the order of their priorities then of their names, the last ones having priority on the
+// same keys, and the keys
+// defined with different values by several sources are reported
+func (r *objectReplicator) replicateBundle(object interface{}) error {
+	meta := r.getMeta(object)
+	key := objectKey(meta)
+	if !mergeableKinds[r.kind()] {
+		err := fmt.Errorf("%s %s has annotation %s: only secrets and config maps can bundle several sources",
+			r.Name, key, r.ReplicateBundleFromAnnotation)
+		r.errorf("", key, "%s", err)
+		r.reportError(err)
+		return err
+	}
+	sources, err := r.bundleSources(meta)
+	if err != nil {
+		r.errorf("", key, "%s", err)
+		r.reportError(err)
+		return err
+	}
+
+	if len(sources) == 0 {
+		r.infof("", key, "no source of bundle %s %s: clearing it", r.Name, key)
+		r.doClearObject(object)
+		r.setSourceMissing(object, fmt.Sprintf("no source matches %s", meta.Annotations[r.ReplicateBundleFromAnnotation]))
+		return nil
+	}
+
+	sourceObjects := []interface{}{}
+	for _, source := range sources {
+		if sourceObject, exists, err := r.objectStore.GetByKey(source); err == nil && exists {
+			sourceObjects = append(sourceObjects, sourceObject)
+		}
+	}
+	if conflicts, err := r.bundleConflicts(sourceObjects); err != nil {
+		return err
+	} else if len(conflicts) > 0 {
+		r.infof(strings.Join(sources, ","), key, "sources of bundle %s %s conflict on keys %s: the sources with the highest priority win",
+			r.Name, key, strings.Join(conflicts, ","))
+		r.recordEvent(object, v1.EventTypeWarning, "BundleConflict", "keys %s are defined by several sources, the sources of %s with the highest priority win",
+			strings.Join(conflicts, ","), strings.Join(sources, ","))
+	}
+
+	return r.replicateFromSources(object, sources)
+}
+
+// Processes again the bundle targets whose sources may include the object, which changed or was deleted
+func (r *objectReplicator) updateBundles(meta *metav1.ObjectMeta) {
+	// the bundles do not include each other
+	if _, ok := meta.Annotations[r.ReplicateBundleFromAnnotation]; ok {
+		return
+	}
+	objects, err := r.objectStore.ByIndex(replicateBundleIndex, meta.Namespace)
+	if err != nil {
+		r.errorf(objectKey(meta), "", "could not get bundles of namespace %s: %s", meta.Namespace, err)
+		return
+	}
+
+	for _, object := range objects {
+		bundleMeta := r.getMeta(object)
+		if r.isManaged(bundleMeta) {
+			r.debugf(objectKey(meta), objectKey(bundleMeta), "%s %s may be a source of bundle %s", r.Name, objectKey(meta), objectKey(bundleMeta))
+			r.replicateBundle(object)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestBundleSources(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps:   replicatorProps{Name: "config map", annotationNames: annotationsWithPrefix("")},
+		replicatorActions: ConfigMapActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	ca := map[string]string{"trust": "ca"}
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "certs", Name: "b-root", Labels: ca},
+		Data: map[string]string{"root.pem": "b", "shared.pem": "same"}})
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "certs", Name: "a-root", Labels: ca},
+		Data: map[string]string{"root.pem": "a", "shared.pem": "same"}})
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "certs", Name: "other"}})
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "remote", Labels: ca}})
+	bundle := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "certs", Name: "bundle", Labels: ca,
+		Annotations: map[string]string{r.ReplicateBundleFromAnnotation: "certs/trust=ca"}}}
+	r.objectStore.Add(bundle)
+
+	sources, err := r.bundleSources(&bundle.ObjectMeta)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(sources) != 2 || sources[0] != "certs/a-root" || sources[1] != "certs/b-root" {
+		t.Errorf("expected the sorted sources of the namespace, got %v", sources)
+	}
+	if bundles, _ := r.objectStore.ByIndex(replicateBundleIndex, "certs"); len(bundles) != 1 {
+		t.Errorf("expected the bundle to be indexed by the namespace of its sources, got %d", len(bundles))
+	}
+
+	sourceObjects := []interface{}{}
+	for _, source := range sources {
+		object, _, _ := r.objectStore.GetByKey(source)
+		sourceObjects = append(sourceObjects, object)
+	}
+	if conflicts, err := r.bundleConflicts(sourceObjects); err != nil || len(conflicts) != 1 || conflicts[0] != "root.pem" {
+		t.Errorf("expected a conflict on root.pem, got %v (%v)", conflicts, err)
+	}
+
+	for _, val := range []string{"certs", "certs/trust in (", "Certs/trust=ca"} {
+		bundle.Annotations[r.ReplicateBundleFromAnnotation] = val
+		if _, _, _, err := r.bundleSelector(&bundle.ObjectMeta); err == nil {
+			t.Errorf("expected an error for %q", val)
+		}
+	}
+}
@@ -1,28 +1,281 @@
 package replicate
 
-// Annotations that are used to control this controller's behaviour
-var (
-	ReplicateFromAnnotation         = "replicate-from"
-	ReplicateToAnnotation           = "replicate-to"
-	ReplicateToNamespacesAnnotation = "replicate-to-namespaces"
-	ReplicateOnceAnnotation         = "replicate-once"
-	ReplicateOnceVersionAnnotation  = "replicate-once-version"
-	ReplicatedAtAnnotation          = "replicated-at"
-	ReplicatedByAnnotation          = "replicated-by"
-	ReplicatedFromVersionAnnotation = "replicated-from-version"
-	ReplicationAllowed              = "replication-allowed"
-	ReplicationAllowedNamespaces    = "replication-allowed-namespaces"
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func PrefixAnnotations(prefix string){
-	ReplicateFromAnnotation         = prefix + ReplicateFromAnnotation
-	ReplicateToAnnotation           = prefix + ReplicateToAnnotation
-	ReplicateToNamespacesAnnotation = prefix + ReplicateToNamespacesAnnotation
-	ReplicateOnceAnnotation         = prefix + ReplicateOnceAnnotation
-	ReplicateOnceVersionAnnotation  = prefix + ReplicateOnceVersionAnnotation
-	ReplicatedAtAnnotation          = prefix + ReplicatedAtAnnotation
-	ReplicatedByAnnotation          = prefix + ReplicatedByAnnotation
-	ReplicatedFromVersionAnnotation = prefix + ReplicatedFromVersionAnnotation
-	ReplicationAllowed              = prefix + ReplicationAllowed
-	ReplicationAllowedNamespaces    = prefix + ReplicationAllowedNamespaces
+// The names of the annotations used to control this controller's behaviour, each field being tagged with the name
+// of its annotation without prefix: this is the only list of the annotations
+type annotationNames struct {
+	ReplicateFromAnnotation                      string `annotation:"replicate-from"`
+	ReplicateToAnnotation                        string `annotation:"replicate-to"`
+	ReplicateToNamespacesAnnotation              string `annotation:"replicate-to-namespaces"`
+	ReplicateAfterAnnotation                     string `annotation:"replicate-after"`
+	ReplicateOnceAnnotation                      string `annotation:"replicate-once"`
+	ReplicateOnceVersionAnnotation               string `annotation:"replicate-once-version"`
+	ReplicatedAtAnnotation                       string `annotation:"replicated-at"`
+	ReplicatedByAnnotation                       string `annotation:"replicated-by"`
+	ReplicatedAliasAnnotation                    string `annotation:"replicated-alias"`
+	ReplicatedFromVersionAnnotation              string `annotation:"replicated-from-version"`
+	ReplicationAllowed                           string `annotation:"replication-allowed"`
+	ReplicationAllowedNamespaces                 string `annotation:"replication-allowed-namespaces"`
+	ReplicatorInstanceAnnotation                 string `annotation:"replicator-instance"`
+	ReplicationRetryAnnotation                   string `annotation:"replication-retry"`
+	ReplicationDeniedAnnotation                  string `annotation:"replication-denied"`
+	ReplicateApprovalRequiredAnnotation          string `annotation:"replicate-approval-required"`
+	ReplicateApprovedVersionAnnotation           string `annotation:"replicate-approved-version"`
+	ReplicatePatternOptionsAnnotation            string `annotation:"replicate-pattern-options"`
+	ReplicateHashSuffixAnnotation                string `annotation:"replicate-hash-suffix"`
+	ReplicatedHashedNameAnnotation               string `annotation:"replicated-hashed-name"`
+	ReplicatedHashOfAnnotation                   string `annotation:"replicated-hash-of"`
+	ReplicatedByBundleAnnotation                 string `annotation:"replicated-by-bundle"`
+	ReplicateToNamespacesSelectorAnnotation      string `annotation:"replicate-to-namespaces-selector"`
+	ReplicateKeyMapAnnotation                    string `annotation:"replicate-key-map"`
+	ReplicateTemplateAnnotation                  string `annotation:"replicate-template"`
+	ReplicateToClustersAnnotation                string `annotation:"replicate-to-clusters"`
+	ReplicatedFromClusterAnnotation              string `annotation:"replicated-from-cluster"`
+	ReplicatedTargetsFinalizer                   string `annotation:"replicated-targets"`
+	ReplicateToAdoptExistingAnnotation           string `annotation:"replicate-to-adopt-existing"`
+	ReplicatedOriginalDataAnnotation             string `annotation:"replicated-original-data"`
+	ReplicateLabelsAnnotation                    string `annotation:"replicate-labels"`
+	ReplicationStrategyAnnotation                string `annotation:"replication-strategy"`
+	ReplicatedKeysAnnotation                     string `annotation:"replicated-keys"`
+	ReplicateAllToAnnotation                     string `annotation:"replicate-all-to"`
+	ReplicateAllSelectorAnnotation               string `annotation:"replicate-all-selector"`
+	ReplicatePullSecretAnnotation                string `annotation:"replicate-pull-secret"`
+	ReplicationStatusAnnotation                  string `annotation:"replication-status"`
+	ReplicationTargetsStatusAnnotation           string `annotation:"replication-targets-status"`
+	ReplicationDeletionPolicyAnnotation          string `annotation:"replication-deletion-policy"`
+	ReplicateSyncPeriodAnnotation                string `annotation:"replicate-sync-period"`
+	ReplicateSyncWindowAnnotation                string `annotation:"replicate-sync-window"`
+	ReplicatePausedAnnotation                    string `annotation:"replicate-paused"`
+	ReplicateMaxTargetsAnnotation                string `annotation:"replicate-max-targets"`
+	ReplicationAllowedNamespacesExclude          string `annotation:"replication-allowed-namespaces-exclude"`
+	ReplicationAllowedServiceAccounts            string `annotation:"replication-allowed-service-accounts"`
+	ReplicationRequestedByAnnotation             string `annotation:"replication-requested-by"`
+	ReplicateAsAnnotation                        string `annotation:"replicate-as"`
+	ReplicatedDataHashAnnotation                 string `annotation:"replicated-data-hash"`
+	ReplicateImmutableAnnotation                 string `annotation:"replicate-immutable"`
+	ReplicatedImmutableAnnotation                string `annotation:"replicated-immutable"`
+	ReplicateToNameAnnotation                    string `annotation:"replicate-to-name"`
+	ProjectedFromAnnotation                      string `annotation:"projected-from"`
+	ReplicateAsKindAnnotation                    string `annotation:"replicate-as-kind"`
+	ConvertedFromAnnotation                      string `annotation:"converted-from"`
+	ReplicateBundleFromAnnotation                string `annotation:"replicate-bundle-from"`
+	ReplicatePriorityAnnotation                  string `annotation:"replicate-priority"`
+	ReplicateSealedAnnotation                    string `annotation:"replicate-sealed"`
+	ReplicateEnforceAnnotation                   string `annotation:"replicate-enforce"`
+	ReplicateAttachToServiceAccountAnnotation    string `annotation:"replicate-attach-to-serviceaccount"`
+	ReplicatedAttachedToServiceAccountAnnotation string `annotation:"replicated-attached-to-serviceaccount"`
+}
+
+// the prefix of the annotations of the replicators created without one
+var defaultPrefix string
+
+// PrefixAnnotations sets the prefix of the annotations of the replicators created without one
+func PrefixAnnotations(prefix string) {
+	defaultPrefix = prefix
+}
+
+// the names of the annotations, without any prefix
+var unprefixedAnnotations = prefixedAnnotations("")
+
+// Returns the names of the annotations with the given prefix
+func prefixedAnnotations(prefix string) annotationNames {
+	var names annotationNames
+	value := reflect.ValueOf(&names).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		value.Field(i).SetString(prefix + value.Type().Field(i).Tag.Get("annotation"))
+	}
+	return names
+}
+
+// Returns the names of the annotations with the given prefix
+// An empty prefix returns the names with the prefix set by PrefixAnnotations
+func annotationsWithPrefix(prefix string) annotationNames {
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	return prefixedAnnotations(prefix)
+}
+
+// Returns true if the annotation is used to control this replicator's behaviour
+func (names *annotationNames) isReplicatorAnnotation(annotation string) bool {
+	value := reflect.ValueOf(names).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).String() == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+// patterns of the annotations of the sources which are copied to the targets
+var propagatedAnnotations []*regexp.Regexp
+
+// PropagateAnnotations sets which annotations of the sources are copied to their targets,
+// as a list of annotation names or patterns
+func PropagateAnnotations(patterns []string) error {
+	compiled, err := compilePatterns(patterns)
+	if err != nil {
+		return err
+	}
+
+	propagatedAnnotations = compiled
+	return nil
+}
+
+// Compiles a list of names or patterns
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := []*regexp.Regexp{}
+	for _, p := range patterns {
+		if p == "" {
+			continue
+		} else if pattern, err := regexp.Compile(`^(?:` + p + `)$`); err != nil {
+			return nil, fmt.Errorf("compilation error on pattern %s: %s", p, err)
+		} else {
+			compiled = append(compiled, pattern)
+		}
+	}
+	return compiled, nil
+}
+
+// Returns true if the key matches one of the patterns
+func matchPatterns(patterns []*regexp.Regexp, key string) bool {
+	for _, p := range patterns {
+		if p.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Copies the propagated annotations of the source to the target,
+// and removes the ones which are not on the source anymore
+func (names *annotationNames) propagateAnnotations(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) {
+	object.Annotations = names.propagateMatching(object.Annotations, sourceObject.Annotations, propagatedAnnotations)
+}
+
+// patterns of the labels of the sources which are copied to the targets
+var propagatedLabels []*regexp.Regexp
+
+// PropagateLabels sets which labels of the sources are copied to their targets,
+// as a list of label names or patterns
+func PropagateLabels(patterns []string) error {
+	compiled, err := compilePatterns(patterns)
+	if err != nil {
+		return err
+	}
+
+	propagatedLabels = compiled
+	return nil
+}
+
+// Copies the propagated labels of the source to the target,
+// and removes the ones which are not on the source anymore
+func (names *annotationNames) propagateLabels(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) {
+	object.Labels = names.propagateMatching(object.Labels, sourceObject.Labels, names.labelPatterns(sourceObject))
+}
+
+// Returns the patterns of the "replicate-labels" annotation of the source:
+// all the labels when "true", or a comma separated list of labels or label patterns
+func (names *annotationNames) sourceLabelPatterns(sourceObject *metav1.ObjectMeta) ([]*regexp.Regexp, error) {
+	val, ok := sourceObject.Annotations[names.ReplicateLabelsAnnotation]
+	if !ok {
+		return nil, nil
+	} else if b, err := strconv.ParseBool(val); err == nil {
+		if !b {
+			return nil, nil
+		}
+		return compilePatterns([]string{".*"})
+	}
+
+	patterns, err := compilePatterns(strings.Split(val, ","))
+	if err != nil {
+		return nil, fmt.Errorf("source %s/%s has illformed annotation %s (%s): %s",
+			sourceObject.Namespace, sourceObject.Name, names.ReplicateLabelsAnnotation, val, err)
+	}
+	return patterns, nil
+}
+
+// Returns the patterns of the labels propagated from the source, globally or by its annotation
+func (names *annotationNames) labelPatterns(sourceObject *metav1.ObjectMeta) []*regexp.Regexp {
+	// the illformed annotations are reported before the installation
+	patterns, _ := names.sourceLabelPatterns(sourceObject)
+	return append(append([]*regexp.Regexp{}, propagatedLabels...), patterns...)
+}
+
+// Copies the values of the source matching the patterns to the target,
+// and removes the matching ones which are not on the source anymore
+func (names *annotationNames) propagateMatching(values map[string]string, sourceValues map[string]string, patterns []*regexp.Regexp) map[string]string {
+	if len(patterns) == 0 {
+		return values
+	}
+
+	for key := range values {
+		if _, ok := sourceValues[key]; ok || names.isReplicatorAnnotation(key) {
+		} else if matchPatterns(patterns, key) {
+			delete(values, key)
+		}
+	}
+
+	for key, val := range sourceValues {
+		if !names.isReplicatorAnnotation(key) && matchPatterns(patterns, key) {
+			if values == nil {
+				values = map[string]string{}
+			}
+			values[key] = val
+		}
+	}
+
+	return values
+}
+
+// when true, the labels and annotations of the targets which are not set by the replicator are kept
+var mergeTargetMetadata bool
+
+// SetTargetMetadata sets how the labels and annotations of the existing targets are updated:
+// "replace" drops the ones which are not set from the source, "merge" keeps them
+func SetTargetMetadata(strategy string) error {
+	switch strategy {
+	case "replace":
+		mergeTargetMetadata = false
+	case "merge":
+		mergeTargetMetadata = true
+	default:
+		return fmt.Errorf("unknown target metadata strategy %s: expected replace or merge", strategy)
+	}
+	return nil
+}
+
+// Keeps the labels and annotations of the existing target, when the strategy is "merge"
+// The replicator annotations and the propagated values always come from the source, which wins the conflicts
+func (names *annotationNames) mergeTargetMetadata(object *metav1.ObjectMeta, targetObject *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) {
+	if !mergeTargetMetadata || targetObject == nil {
+		return
+	}
+
+	object.Labels = names.mergeMatching(object.Labels, targetObject.Labels, names.labelPatterns(sourceObject))
+	object.Annotations = names.mergeMatching(object.Annotations, targetObject.Annotations, propagatedAnnotations)
+}
+
+// Copies the values of the target which are not set yet, nor replicator annotations, nor matching the patterns
+func (names *annotationNames) mergeMatching(values map[string]string, targetValues map[string]string, patterns []*regexp.Regexp) map[string]string {
+	for key, val := range targetValues {
+		if _, ok := values[key]; ok || names.isReplicatorAnnotation(key) || matchPatterns(patterns, key) {
+			continue
+		}
+		if values == nil {
+			values = map[string]string{}
+		}
+		values[key] = val
+	}
+
+	return values
 }
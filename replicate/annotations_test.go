@@ -0,0 +1,119 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeTargetMetadata(t *testing.T) {
+	defer SetTargetMetadata("replace")
+	defer PropagateAnnotations(nil)
+	defer PropagateLabels(nil)
+	PropagateAnnotations([]string{"team"})
+	PropagateLabels([]string{"app"})
+
+	names := annotationsWithPrefix("")
+	source := &metav1.ObjectMeta{
+		Labels:      map[string]string{"app": "database"},
+		Annotations: map[string]string{"team": "backend"},
+	}
+	target := &metav1.ObjectMeta{
+		Labels: map[string]string{"app": "other", "managed-by": "argocd"},
+		Annotations: map[string]string{
+			"team":                       "frontend",
+			"owner":                      "someone",
+			names.ReplicateToAnnotation:  "default/stale",
+			names.ReplicatedAtAnnotation: "yesterday",
+		},
+	}
+
+	build := func() *metav1.ObjectMeta {
+		copy := &metav1.ObjectMeta{Annotations: map[string]string{names.ReplicatedAtAnnotation: "now"}}
+		names.propagateAnnotations(copy, source)
+		names.propagateLabels(copy, source)
+		names.mergeTargetMetadata(copy, target, source)
+		return copy
+	}
+
+	// the values of the target are dropped by default
+	copy := build()
+	if expected := map[string]string{"app": "database"}; !reflect.DeepEqual(copy.Labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, copy.Labels)
+	}
+
+	// the values of the target are kept, but the source wins the conflicts
+	if err := SetTargetMetadata("merge"); err != nil {
+		t.Fatal(err)
+	}
+	copy = build()
+	if expected := map[string]string{"app": "database", "managed-by": "argocd"}; !reflect.DeepEqual(copy.Labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, copy.Labels)
+	}
+	expected := map[string]string{"team": "backend", "owner": "someone", names.ReplicatedAtAnnotation: "now"}
+	if !reflect.DeepEqual(copy.Annotations, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, copy.Annotations)
+	}
+
+	// a propagated value removed from the source is removed from the target
+	delete(source.Labels, "app")
+	if copy = build(); copy.Labels["app"] != "" {
+		t.Errorf("expected label app to be removed, got %s", copy.Labels["app"])
+	}
+
+	if err := SetTargetMetadata("keep"); err == nil {
+		t.Errorf("expected an error for an unknown strategy")
+	}
+}
+
+func TestReplicateLabels(t *testing.T) {
+	defer PropagateLabels(nil)
+	PropagateLabels([]string{"app"})
+
+	names := annotationsWithPrefix("")
+	source := &metav1.ObjectMeta{
+		Labels:      map[string]string{"app": "database", "team": "backend", "tier": "data"},
+		Annotations: map[string]string{names.ReplicateLabelsAnnotation: "team"},
+	}
+
+	copy := &metav1.ObjectMeta{}
+	names.propagateLabels(copy, source)
+	if expected := map[string]string{"app": "database", "team": "backend"}; !reflect.DeepEqual(copy.Labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, copy.Labels)
+	}
+
+	source.Annotations[names.ReplicateLabelsAnnotation] = "true"
+	copy = &metav1.ObjectMeta{}
+	names.propagateLabels(copy, source)
+	if !reflect.DeepEqual(copy.Labels, source.Labels) {
+		t.Errorf("expected labels %v, got %v", source.Labels, copy.Labels)
+	}
+
+	source.Annotations[names.ReplicateLabelsAnnotation] = "false"
+	copy = &metav1.ObjectMeta{}
+	names.propagateLabels(copy, source)
+	if expected := map[string]string{"app": "database"}; !reflect.DeepEqual(copy.Labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, copy.Labels)
+	}
+
+	source.Annotations[names.ReplicateLabelsAnnotation] = "team,("
+	if _, err := names.sourceLabelPatterns(source); err == nil {
+		t.Errorf("expected an error for an illformed pattern")
+	}
+}
+
+func TestAnnotationsWithPrefix(t *testing.T) {
+	names := annotationsWithPrefix("secret-replicator/")
+	if names.ReplicateFromAnnotation != "secret-replicator/replicate-from" ||
+		names.ReplicatedAttachedToServiceAccountAnnotation != "secret-replicator/replicated-attached-to-serviceaccount" {
+		t.Errorf("expected all the annotations to be prefixed, got %+v", names)
+	}
+	if !names.isReplicatorAnnotation("secret-replicator/replicate-to") {
+		t.Errorf("expected the prefixed annotation to be a replicator annotation")
+	}
+	// the annotations of an instance configured with another prefix are not interpreted
+	if names.isReplicatorAnnotation("configmap-replicator/replicate-to") {
+		t.Errorf("expected the annotation of another prefix not to be a replicator annotation")
+	}
+}
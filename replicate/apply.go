@@ -0,0 +1,124 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// the field manager of the replicator, owning the replicated fields with server-side apply
+const fieldManager = "kubernetes-replicator"
+
+// when true, the secrets and config maps are replicated with server-side apply
+var serverSideApply bool
+
+// ServerSideApply sets whether the secrets and config maps are replicated with server-side apply,
+// so that the fields managed by others on the targets are not overwritten
+func ServerSideApply(enabled bool) {
+	serverSideApply = enabled
+}
+
+// the fields of the metadata which are never applied
+var notAppliedMetadata = []string{"creationTimestamp", "resourceVersion", "uid", "selfLink", "generation"}
+
+// Returns the metadata owned by the replicator on the target:
+// its own annotations, and the annotations and labels propagated from the source
+func (r *replicatorProps) appliedMeta(meta *metav1.ObjectMeta, sourceMeta *metav1.ObjectMeta) *metav1.ObjectMeta {
+	applied := &metav1.ObjectMeta{
+		Namespace:       meta.Namespace,
+		Name:            meta.Name,
+		Annotations:     map[string]string{},
+		OwnerReferences: meta.OwnerReferences,
+	}
+	for key, value := range meta.Annotations {
+		if r.isReplicatorAnnotation(key) {
+			applied.Annotations[key] = value
+		}
+	}
+	r.propagateAnnotations(applied, sourceMeta)
+	r.propagateLabels(applied, sourceMeta)
+	return applied
+}
+
+// Returns the apply configuration of the object, with the metadata owned by the replicator
+// Only the replicated keys of the data are owned when it is merged into the target
+func (r *replicatorProps) applyConfiguration(object interface{}, meta *metav1.ObjectMeta, apiVersion string, kind string) ([]byte, error) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	raw, err = json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return nil, err
+	}
+	for _, field := range notAppliedMetadata {
+		delete(metadata, field)
+	}
+	fields["metadata"] = metadata
+	fields["apiVersion"] = apiVersion
+	fields["kind"] = kind
+
+	if _, ok := meta.Annotations[r.ReplicatedKeysAnnotation]; ok {
+		keys := r.replicatedKeys(meta)
+		for _, field := range mergedFields {
+			if data, ok := fields[field].(map[string]interface{}); ok {
+				for key := range data {
+					if !keys[key] {
+						delete(data, key)
+					}
+				}
+			}
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Applies the object with the metadata owned by the replicator, and stores the result
+// The conflicts with the fields of the other managers are not forced, but returned as errors
+func (r *replicatorProps) apply(resource string, object interface{}, meta *metav1.ObjectMeta, apiVersion string, kind string, result runtime.Object) error {
+	body, err := r.applyConfiguration(object, meta, apiVersion, kind)
+	if err != nil {
+		return err
+	}
+
+	err = r.writer(meta).CoreV1().RESTClient().Patch(types.ApplyPatchType).
+		Namespace(meta.Namespace).
+		Resource(resource).
+		Name(meta.Name).
+		Param("fieldManager", fieldManager).
+		Body(body).
+		Do().
+		Into(result)
+	return applyError(err)
+}
+
+// Describes the conflicts of an apply with the fields of the other managers, if any
+func applyError(err error) error {
+	status, ok := err.(errors.APIStatus)
+	if !ok || status.Status().Reason != metav1.StatusReasonConflict || status.Status().Details == nil {
+		return err
+	}
+
+	conflicts := []string{}
+	for _, cause := range status.Status().Details.Causes {
+		conflicts = append(conflicts, fmt.Sprintf("%s (%s)", cause.Field, cause.Message))
+	}
+	if len(conflicts) == 0 {
+		return err
+	}
+	return fmt.Errorf("conflicts with the fields of other managers: %s", strings.Join(conflicts, ", "))
+}
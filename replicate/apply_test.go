@@ -0,0 +1,64 @@
+package replicate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestApplyConfiguration(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source"}
+	target := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "target", ResourceVersion: "10",
+			Annotations: map[string]string{
+				r.ReplicateFromAnnotation:  "default/source",
+				r.ReplicatedKeysAnnotation: "replicated",
+				"owner":                    "someone else",
+			}},
+		Data: map[string]string{"replicated": "value", "local": "value"},
+	}
+
+	// the annotations of the others are not owned
+	meta := r.appliedMeta(&target.ObjectMeta, source)
+	expected := map[string]string{r.ReplicateFromAnnotation: "default/source", r.ReplicatedKeysAnnotation: "replicated"}
+	if !reflect.DeepEqual(meta.Annotations, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, meta.Annotations)
+	}
+
+	raw, err := r.applyConfiguration(target, meta, "v1", "ConfigMap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := map[string]interface{}{}
+	json.Unmarshal(raw, &applied)
+	if applied["kind"] != "ConfigMap" || applied["apiVersion"] != "v1" {
+		t.Errorf("expected the kind of the object, got %v", applied)
+	}
+	if _, ok := applied["metadata"].(map[string]interface{})["resourceVersion"]; ok {
+		t.Errorf("expected no resource version, got %v", applied["metadata"])
+	}
+	// only the replicated keys of the merged data are owned
+	if data := applied["data"].(map[string]interface{}); !reflect.DeepEqual(data, map[string]interface{}{"replicated": "value"}) {
+		t.Errorf("expected only the replicated keys, got %v", data)
+	}
+}
+
+func TestApplyError(t *testing.T) {
+	err := errors.NewApplyConflict([]metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldManagerConflict, Field: ".data.password", Message: `conflict with "kubectl"`},
+	}, "Apply failed with 1 conflict")
+	if expected := `conflicts with the fields of other managers: .data.password (conflict with "kubectl")`; applyError(err).Error() != expected {
+		t.Errorf("expected %s, got %s", expected, applyError(err))
+	}
+
+	other := errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "target")
+	if applyError(other) != other {
+		t.Errorf("expected the other errors to be unchanged")
+	}
+}
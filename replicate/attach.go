@@ -0,0 +1,154 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Returns the service accounts of the annotation, sorted
+func serviceAccountNames(object *metav1.ObjectMeta, annotation string) []string {
+	names := map[string]bool{}
+	if val, ok := object.Annotations[annotation]; ok {
+		for _, name := range strings.Split(val, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names[name] = true
+			}
+		}
+	}
+	sorted := []string{}
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Records on the copy the service accounts of its namespace named by the "replicate-attach-to-serviceaccount"
+// annotation of its source, so that it is attached to them once written, and detached from them once deleted or cleared
+func (r *replicatorProps) recordServiceAccounts(secret *v1.Secret, sourceSecret *v1.Secret) {
+	accounts := serviceAccountNames(&sourceSecret.ObjectMeta, r.ReplicateAttachToServiceAccountAnnotation)
+	if len(accounts) > 0 && !isDockerConfig(sourceSecret) {
+		r.errorf(objectKey(sourceSecret), objectKey(secret), "secret %s/%s is not a docker registry secret: it is not attached to service accounts",
+			sourceSecret.Namespace, sourceSecret.Name)
+		accounts = nil
+	}
+
+	if len(accounts) == 0 {
+		delete(secret.Annotations, r.ReplicatedAttachedToServiceAccountAnnotation)
+		return
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[r.ReplicatedAttachedToServiceAccountAnnotation] = strings.Join(accounts, ",")
+}
+
+// Attaches the written copy to the service accounts recorded on it, and detaches it from the ones recorded
+// on its former version only
+// It is done once the copy is written, so that no service account refers to a copy which could not be written
+func (r *replicatorProps) attachToServiceAccounts(secret *v1.Secret, former *v1.Secret) error {
+	accounts := serviceAccountNames(&secret.ObjectMeta, r.ReplicatedAttachedToServiceAccountAnnotation)
+	attached := map[string]bool{}
+	for _, account := range accounts {
+		attached[account] = true
+	}
+	if former != nil {
+		for _, account := range serviceAccountNames(&former.ObjectMeta, r.ReplicatedAttachedToServiceAccountAnnotation) {
+			if attached[account] {
+				continue
+			}
+			if err := r.detachFromServiceAccount(secret, account); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, account := range accounts {
+		if err := r.attachToServiceAccount(secret, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Detaches the copy from all the service accounts recorded on it, once it is deleted or cleared
+// The service accounts which cannot be updated only keep a reference to a missing secret, which is logged
+func (r *replicatorProps) detachFromServiceAccounts(secret *v1.Secret) {
+	for _, account := range serviceAccountNames(&secret.ObjectMeta, r.ReplicatedAttachedToServiceAccountAnnotation) {
+		if err := r.detachFromServiceAccount(secret, account); err != nil {
+			r.errorf("", objectKey(secret), "%s", err)
+		}
+	}
+}
+
+// Adds the secret to the image pull secrets of the service account of its namespace
+func (r *replicatorProps) attachToServiceAccount(secret *v1.Secret, name string) error {
+	client := r.writer(&secret.ObjectMeta).CoreV1().ServiceAccounts(secret.Namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		account, err := client.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, ref := range account.ImagePullSecrets {
+			if ref.Name == secret.Name {
+				return nil
+			}
+		}
+
+		r.infof("", objectKey(secret), "attaching secret %s/%s to service account %s", secret.Namespace, secret.Name, name)
+		account = account.DeepCopy()
+		account.ImagePullSecrets = append(account.ImagePullSecrets, v1.LocalObjectReference{Name: secret.Name})
+		return r.patch(&secret.ObjectMeta, &patchWrite{kind: "serviceaccount", key: objectKey(account),
+			reason: fmt.Sprintf("secret %s is attached to it", objectKey(secret)),
+			write: func() error {
+				_, err := client.Update(account)
+				return err
+			}})
+	})
+	if err != nil && !isPaused(err) {
+		return fmt.Errorf("could not attach secret %s to service account %s of namespace %s: %s", secret.Name, name, secret.Namespace, err)
+	}
+	return err
+}
+
+// Removes the secret from the image pull secrets of the service account of its namespace, if it still exists
+func (r *replicatorProps) detachFromServiceAccount(secret *v1.Secret, name string) error {
+	client := r.writer(&secret.ObjectMeta).CoreV1().ServiceAccounts(secret.Namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		account, err := client.Get(name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		refs := []v1.LocalObjectReference{}
+		for _, ref := range account.ImagePullSecrets {
+			if ref.Name != secret.Name {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) == len(account.ImagePullSecrets) {
+			return nil
+		}
+
+		r.infof("", objectKey(secret), "detaching secret %s/%s from service account %s", secret.Namespace, secret.Name, name)
+		account = account.DeepCopy()
+		account.ImagePullSecrets = refs
+		return r.patch(&secret.ObjectMeta, &patchWrite{kind: "serviceaccount", key: objectKey(account),
+			reason: fmt.Sprintf("secret %s is detached from it", objectKey(secret)),
+			write: func() error {
+				_, err := client.Update(account)
+				return err
+			}})
+	})
+	if err != nil && !isPaused(err) {
+		return fmt.Errorf("could not detach secret %s from service account %s of namespace %s: %s", secret.Name, name, secret.Namespace, err)
+	}
+	return err
+}
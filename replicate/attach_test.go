@@ -0,0 +1,124 @@
+package replicate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestAttachToServiceAccounts(t *testing.T) {
+	// the service accounts of the namespace team-a, read and updated through the API
+	accounts := map[string]*v1.ServiceAccount{
+		"default": {ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "default"},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "other"}}},
+		"builder": {ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "builder"}},
+	}
+	// the first update of each service account conflicts, as if it were updated meanwhile
+	conflicted := map[string]bool{}
+	updates := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := path.Base(req.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPut {
+			if !conflicted[name] {
+				conflicted[name] = true
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonConflict, Code: http.StatusConflict})
+				return
+			}
+			updates++
+			account := &v1.ServiceAccount{}
+			json.NewDecoder(req.Body).Decode(account)
+			accounts[name] = account
+		}
+		json.NewEncoder(w).Encode(accounts[name])
+	}))
+	defer server.Close()
+	client := kubernetes.NewForConfigOrDie(&rest.Config{Host: server.URL})
+	r := &replicatorProps{
+		Name:            "secret",
+		annotationNames: annotationsWithPrefix(""),
+		client:          client,
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "registry",
+			Annotations: map[string]string{r.ReplicateAttachToServiceAccountAnnotation: "default, builder"}},
+		Type: v1.SecretTypeDockerConfigJson,
+	}
+	pullSecrets := func(name string) []v1.LocalObjectReference {
+		return accounts[name].ImagePullSecrets
+	}
+
+	target := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "registry", Annotations: map[string]string{}}}
+	r.recordServiceAccounts(target, source)
+	if target.Annotations[r.ReplicatedAttachedToServiceAccountAnnotation] != "builder,default" {
+		t.Errorf("expected the service accounts to be recorded, got %v", target.Annotations)
+	}
+	// the conflicts are retried
+	if err := r.attachToServiceAccounts(target, nil); err != nil {
+		t.Fatal(err)
+	}
+	if refs := pullSecrets("default"); len(refs) != 2 || refs[1].Name != "registry" {
+		t.Errorf("expected the copy to be attached after the other pull secrets, got %v", refs)
+	}
+	// attached only once
+	if err := r.attachToServiceAccounts(target, target); err != nil || len(pullSecrets("builder")) != 1 {
+		t.Errorf("expected the copy to be attached once, got %v %v", pullSecrets("builder"), err)
+	}
+
+	// the copy is detached from the service accounts not recorded anymore
+	former := target.DeepCopy()
+	source.Annotations[r.ReplicateAttachToServiceAccountAnnotation] = "default"
+	r.recordServiceAccounts(target, source)
+	if err := r.attachToServiceAccounts(target, former); err != nil {
+		t.Fatal(err)
+	}
+	if refs := pullSecrets("builder"); len(refs) != 0 {
+		t.Errorf("expected the copy to be detached, got %v", refs)
+	}
+
+	// a dry run only records the updates of the service accounts
+	r.actions = &dryRunActions{SecretActions}
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+	written := updates
+	former = target.DeepCopy()
+	source.Annotations[r.ReplicateAttachToServiceAccountAnnotation] = "builder"
+	r.recordServiceAccounts(target, source)
+	if err := r.attachToServiceAccounts(target, former); err != nil || updates != written {
+		t.Errorf("expected a dry run not to update the service accounts, got %d updates %v", updates-written, err)
+	}
+	if actions := DryRunActions(); len(actions) != 2 || actions[0].Kind != "serviceaccount" || actions[0].Target != "team-a/builder" ||
+		actions[1].Target != "team-a/default" {
+		t.Errorf("expected the updates of the service accounts to be recorded, got %v", actions)
+	}
+	r.actions = nil
+	*target = *former
+
+	r.detachFromServiceAccounts(target)
+	if refs := pullSecrets("default"); len(refs) != 1 || refs[0].Name != "other" {
+		t.Errorf("expected only the copy to be detached, got %v", refs)
+	}
+
+	// only the docker registry secrets are attached
+	source.Type = v1.SecretTypeOpaque
+	r.recordServiceAccounts(target, source)
+	if _, ok := target.Annotations[r.ReplicatedAttachedToServiceAccountAnnotation]; ok {
+		t.Errorf("expected an opaque secret not to be recorded, got %v", target.Annotations)
+	}
+	if err := r.attachToServiceAccounts(target, nil); err != nil || len(pullSecrets("default")) != 1 {
+		t.Errorf("expected an opaque secret not to be attached, got %v %v", pullSecrets("default"), err)
+	}
+}
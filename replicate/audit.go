@@ -0,0 +1,242 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuditRecord describes a write of a target, as recorded in the audit log
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	// create, update, clear or delete
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Source string `json:"source,omitempty"`
+	// the version of the source replicated to the target
+	SourceVersion string `json:"sourceVersion,omitempty"`
+	// the version of the target once written, or before its deletion
+	TargetVersion string `json:"targetVersion,omitempty"`
+	// why the target is written
+	Rationale string `json:"rationale"`
+	// the error of a failed write
+	Error string `json:"error,omitempty"`
+}
+
+// the sink of the audit log, nil when disabled
+var audit struct {
+	sync.Mutex
+	encoder *json.Encoder
+}
+
+// AuditLog appends a JSON line describing each write of the targets to the file, or to stdout for "-"
+// An empty path disables the audit log
+func AuditLog(path string) error {
+	var writer io.Writer
+	switch path {
+	case "":
+	case "-":
+		writer = os.Stdout
+	default:
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("could not open audit log %s: %s", path, err)
+		}
+		writer = file
+	}
+
+	audit.Lock()
+	defer audit.Unlock()
+	audit.encoder = nil
+	if writer != nil {
+		audit.encoder = json.NewEncoder(writer)
+	}
+	return nil
+}
+
+// Returns true if the writes are recorded in the audit log
+func auditEnabled() bool {
+	audit.Lock()
+	defer audit.Unlock()
+	return audit.encoder != nil
+}
+
+// Appends the record to the audit log
+func writeAudit(record AuditRecord) {
+	audit.Lock()
+	defer audit.Unlock()
+	if audit.encoder == nil {
+		return
+	}
+	if err := audit.encoder.Encode(&record); err != nil {
+		logf("could not write audit log of %s %s: %s", record.Kind, record.Target, err)
+	}
+}
+
+// Returns why the target is written with the data of the source: the annotations linking them
+func (r *replicatorProps) replicationRationale(targetMeta *metav1.ObjectMeta, sourceMeta *metav1.ObjectMeta) string {
+	if annotationRefersTo(targetMeta, r.ReplicateFromAnnotation, sourceMeta) {
+		return fmt.Sprintf("target has annotation %s=%s", r.ReplicateFromAnnotation, targetMeta.Annotations[r.ReplicateFromAnnotation])
+	}
+
+	reasons := []string{}
+	for _, annotation := range []string{r.ReplicateToAnnotation, r.ReplicateToNamespacesAnnotation,
+		r.ReplicateToNamespacesSelectorAnnotation, r.ReplicateAllToAnnotation, r.ReplicatePullSecretAnnotation} {
+		if val, ok := sourceMeta.Annotations[annotation]; ok {
+			reasons = append(reasons, fmt.Sprintf("%s=%s", annotation, val))
+		}
+	}
+	if len(reasons) == 0 {
+		return fmt.Sprintf("target is replicated from %s", objectKey(sourceMeta))
+	}
+	return fmt.Sprintf("source has annotation %s", strings.Join(reasons, ", "))
+}
+
+// Returns the source whose data is in the target, from its annotations
+func (r *replicatorProps) auditedSource(targetMeta *metav1.ObjectMeta) string {
+	if val, ok := targetMeta.Annotations[r.ReplicatedByAnnotation]; ok {
+		return val
+	} else if val, ok := resolveAnnotation(targetMeta, r.ReplicateFromAnnotation); ok && !strings.Contains(val, ",") {
+		return val
+	}
+	return ""
+}
+
+// the actions of a replicator recording their writes in the audit log
+// The writes of the metadata of the objects, such as their status or finalizers, are not recorded
+type auditActions struct {
+	replicatorActions
+}
+
+// Returns why the data of the source is removed from the target, which is cleared or deleted
+func (a *auditActions) removalRationale(r *replicatorProps, targetMeta *metav1.ObjectMeta, source string) string {
+	if source == "" {
+		return "target has no source anymore"
+	}
+	object, exists, err := r.objectStore.GetByKey(source)
+	if err != nil || !exists {
+		return fmt.Sprintf("source %s does not exist", source)
+	}
+
+	sourceMeta := a.getMeta(object)
+	if sourceMeta.DeletionTimestamp != nil {
+		return fmt.Sprintf("source %s is being deleted", source)
+	}
+	// the replication from the source was denied
+	if annotationRefersTo(targetMeta, r.ReplicateFromAnnotation, sourceMeta) {
+		if ok, err := r.isReplicationAllowed(targetMeta, sourceMeta); !ok {
+			return err.Error()
+		}
+	}
+	return fmt.Sprintf("source %s does not replicate to the target anymore", source)
+}
+
+func (a *auditActions) wrapped() replicatorActions {
+	return a.replicatorActions
+}
+
+// Returns the version of the target in the store, once written
+func (a *auditActions) storedVersion(r *replicatorProps, target string) string {
+	if object, exists, err := r.objectStore.GetByKey(target); err == nil && exists {
+		return a.getMeta(object).ResourceVersion
+	}
+	return ""
+}
+
+func (a *auditActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	err := a.replicatorActions.update(r, object, sourceObject)
+	meta, sourceMeta := a.getMeta(object), a.getMeta(sourceObject)
+	record := AuditRecord{
+		Action:        "update",
+		Target:        objectKey(meta),
+		Source:        objectKey(sourceMeta),
+		SourceVersion: sourceMeta.ResourceVersion,
+		TargetVersion: a.storedVersion(r, objectKey(meta)),
+		Rationale:     r.replicationRationale(meta, sourceMeta),
+	}
+	writeAudit(r.auditRecord(record, err))
+	return err
+}
+
+func (a *auditActions) clear(r *replicatorProps, object interface{}) error {
+	err := a.replicatorActions.clear(r, object)
+	meta := a.getMeta(object)
+	source := r.auditedSource(meta)
+	record := AuditRecord{
+		Action:        "clear",
+		Target:        objectKey(meta),
+		Source:        source,
+		TargetVersion: a.storedVersion(r, objectKey(meta)),
+		Rationale:     a.removalRationale(r, meta, source),
+	}
+	writeAudit(r.auditRecord(record, err))
+	return err
+}
+
+func (a *auditActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	err := a.replicatorActions.install(r, meta, sourceObject, dataObject)
+	sourceMeta := a.getMeta(sourceObject)
+	if objectKey(sourceMeta) == objectKey(meta) {
+		return err
+	}
+
+	record := AuditRecord{
+		Action:        "create",
+		Target:        objectKey(meta),
+		Source:        objectKey(sourceMeta),
+		SourceVersion: sourceMeta.ResourceVersion,
+		TargetVersion: a.storedVersion(r, objectKey(meta)),
+		Rationale:     r.replicationRationale(meta, sourceMeta),
+	}
+	if meta.ResourceVersion != "" {
+		record.Action = "update"
+	}
+	writeAudit(r.auditRecord(record, err))
+	return err
+}
+
+func (a *auditActions) delete(r *replicatorProps, object interface{}) error {
+	meta := a.getMeta(object)
+	source := r.auditedSource(meta)
+	record := AuditRecord{
+		Action:        "delete",
+		Target:        objectKey(meta),
+		Source:        source,
+		TargetVersion: meta.ResourceVersion,
+		Rationale:     a.removalRationale(r, meta, source),
+	}
+	err := a.replicatorActions.delete(r, object)
+	writeAudit(r.auditRecord(record, err))
+	return err
+}
+
+// Completes the record of a write with its kind, time and error
+func (a *auditActions) patch(r *replicatorProps, target *metav1.ObjectMeta, p *patchWrite) error {
+	err := patchThrough(a.replicatorActions, r, target, p)
+	record := r.auditRecord(AuditRecord{
+		Action:    "update",
+		Target:    p.key,
+		Source:    objectKey(target),
+		Rationale: p.reason,
+	}, err)
+	// the record is about the object of the other kind
+	record.Kind = p.kind
+	writeAudit(record)
+	return err
+}
+
+func (r *replicatorProps) auditRecord(record AuditRecord, err error) AuditRecord {
+	record.Time = time.Now().UTC()
+	record.Kind = r.kind()
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
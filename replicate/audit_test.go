@@ -0,0 +1,92 @@
+package replicate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the actions of the config maps, writing to the store only
+type storeActions struct {
+	*configMapActions
+}
+
+func (a *storeActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	configMap := object.(*v1.ConfigMap).DeepCopy()
+	configMap.ResourceVersion = "3"
+	return r.objectStore.Update(configMap)
+}
+
+func (a *storeActions) delete(r *replicatorProps, object interface{}) error {
+	return r.objectStore.Delete(object)
+}
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	if err := AuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer AuditLog("")
+
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+			objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		},
+		replicatorActions: &auditActions{&storeActions{ConfigMapActions}},
+	}
+	if _, ok := baseActions(r.replicatorActions).(*storeActions); !ok {
+		t.Errorf("expected the audited actions to be unwrapped")
+	}
+
+	source := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "2",
+		Annotations: map[string]string{r.ReplicationAllowed: "true"}}}
+	target := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "target", ResourceVersion: "1",
+		Annotations: map[string]string{r.ReplicateFromAnnotation: "default/source"}}}
+	r.objectStore.Add(source)
+	r.objectStore.Add(target)
+
+	r.update(&r.replicatorProps, target, source)
+	r.objectStore.Delete(source)
+	r.delete(&r.replicatorProps, target)
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %q", lines)
+	}
+
+	expected := []AuditRecord{{
+		Kind: "configmap", Action: "update", Target: "team-a/target", Source: "default/source",
+		SourceVersion: "2", TargetVersion: "3", Rationale: "target has annotation replicate-from=default/source",
+	}, {
+		Kind: "configmap", Action: "delete", Target: "team-a/target", Source: "default/source",
+		TargetVersion: "1", Rationale: "source default/source does not exist",
+	}}
+	for i, line := range lines {
+		record := AuditRecord{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("expected a JSON line, got %s: %s", line, err)
+		}
+		record.Time = expected[i].Time
+		if record != expected[i] {
+			t.Errorf("expected record %+v, got %+v", expected[i], record)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package replicate
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AWSCredentials signs the requests to the AWS APIs, with the credentials of the environment: either the keys of
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or the role of AWS_ROLE_ARN assumed with the web identity token of
+// AWS_WEB_IDENTITY_TOKEN_FILE, as set for the service accounts of EKS
+type AWSCredentials struct {
+	region string
+	// the endpoint of STS, to assume the role
+	stsEndpoint string
+	client      *http.Client
+
+	lock         sync.Mutex
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	// when the credentials of the assumed role expire, zero for the static keys
+	expiration time.Time
+	roleARN    string
+	tokenFile  string
+}
+
+// NewAWSCredentials reads the credentials of the region from the environment
+func NewAWSCredentials(region string) *AWSCredentials {
+	return &AWSCredentials{
+		region:       region,
+		stsEndpoint:  fmt.Sprintf("https://sts.%s.amazonaws.com", region),
+		client:       &http.Client{Timeout: 30 * time.Second},
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		roleARN:      os.Getenv("AWS_ROLE_ARN"),
+		tokenFile:    os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"),
+	}
+}
+
+// the response of STS to AssumeRoleWithWebIdentity
+type stsResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// Returns the keys and the session token signing the requests, assuming the role again before its credentials expire
+func (c *AWSCredentials) get() (string, string, string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.roleARN == "" || c.tokenFile == "" || time.Now().Add(5*time.Minute).Before(c.expiration) {
+		return c.accessKey, c.secretKey, c.sessionToken, nil
+	}
+
+	token, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		return "", "", "", err
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {c.roleARN},
+		"RoleSessionName":  {"kubernetes-replicator"},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	response, err := c.client.Get(fmt.Sprintf("%s/?%s", c.stsEndpoint, query.Encode()))
+	if err != nil {
+		return "", "", "", err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", "", "", err
+	} else if response.StatusCode != http.StatusOK {
+		return "", "", "", fmt.Errorf("could not assume role %s: %s", c.roleARN, response.Status)
+	}
+	result := stsResponse{}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return "", "", "", fmt.Errorf("could not assume role %s: %s", c.roleARN, err)
+	}
+
+	c.accessKey = result.Credentials.AccessKeyID
+	c.secretKey = result.Credentials.SecretAccessKey
+	c.sessionToken = result.Credentials.SessionToken
+	c.expiration = result.Credentials.Expiration
+	return c.accessKey, c.secretKey, c.sessionToken, nil
+}
+
+// Returns the HMAC-SHA256 of the data with the key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Returns the hex encoded SHA256 of the data
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the query of the URL as signed: the parameters sorted by name then value, encoded as in RFC 3986
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := []string{}
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// Encodes the string as in RFC 3986, the spaces as %20 rather than +
+func awsEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// Signs the request of the service with the version 4 of the AWS signatures
+func (c *AWSCredentials) sign(request *http.Request, service string, body []byte, now time.Time) error {
+	accessKey, secretKey, sessionToken, err := c.get()
+	if err != nil {
+		return err
+	} else if accessKey == "" || secretKey == "" {
+		return fmt.Errorf("no AWS credentials")
+	}
+
+	date := now.UTC().Format("20060102T150405Z")
+	request.Header.Set("X-Amz-Date", date)
+	if sessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	// the host, the content type and the AWS headers are signed
+	names := []string{"host"}
+	for name := range request.Header {
+		if name = strings.ToLower(name); name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	headers := ""
+	for _, name := range names {
+		value := request.Header.Get(name)
+		if name == "host" {
+			value = request.URL.Host
+		}
+		headers += fmt.Sprintf("%s:%s\n", name, strings.TrimSpace(value))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{request.Method, path, canonicalQuery(request.URL), headers, signedHeaders, hexSHA256(body)}, "\n")
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date[:8], c.region, service)
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", date, scope, hexSHA256([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date[:8])
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, toSign))))
+	return nil
+}
+
+// AWSSource reads the secrets of AWS Secrets Manager, or the parameters of AWS Systems Manager Parameter Store
+// The secrets whose value is a JSON object have a key per field, the other secrets and the parameters have a single
+// "value" key
+type AWSSource struct {
+	// the service of the API, secretsmanager or ssm
+	service     string
+	endpoint    string
+	credentials *AWSCredentials
+	client      *http.Client
+}
+
+// NewAWSSecretsManagerSource creates the source reading the secrets of AWS Secrets Manager
+func NewAWSSecretsManagerSource(credentials *AWSCredentials) *AWSSource {
+	return &AWSSource{
+		service:     "secretsmanager",
+		endpoint:    fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", credentials.region),
+		credentials: credentials,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewAWSParameterStoreSource creates the source reading the parameters of AWS Systems Manager Parameter Store
+func NewAWSParameterStoreSource(credentials *AWSCredentials) *AWSSource {
+	return &AWSSource{
+		service:     "ssm",
+		endpoint:    fmt.Sprintf("https://ssm.%s.amazonaws.com", credentials.region),
+		credentials: credentials,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Calls the action of the API of the service, returning false if the secret or parameter does not exist
+func (a *AWSSource) call(target string, input interface{}, output interface{}) (bool, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return false, err
+	}
+	request, err := http.NewRequest(http.MethodPost, a.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", target)
+	if err := a.credentials.sign(request, a.service, body, time.Now()); err != nil {
+		return false, err
+	}
+
+	response, err := a.client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return false, err
+	}
+	if response.StatusCode != http.StatusOK {
+		failure := struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}{}
+		json.Unmarshal(raw, &failure)
+		// the type may be qualified by a namespace, as "namespace#type"
+		if kind := failure.Type[strings.LastIndex(failure.Type, "#")+1:]; kind == "ResourceNotFoundException" || kind == "ParameterNotFound" {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s failed: %s %s %s", target, response.Status, failure.Type, failure.Message)
+	}
+	return true, json.Unmarshal(raw, output)
+}
+
+// Get returns the data of the latest version of the secret or parameter
+// The version of a parameter is its ordered version, and the one of a secret is its creation time
+func (a *AWSSource) Get(path string) (*ExternalData, error) {
+	if a.service == "ssm" {
+		output := struct {
+			Parameter struct {
+				Value   string
+				Version int64
+			}
+		}{}
+		if exists, err := a.call("AmazonSSM.GetParameter", map[string]interface{}{"Name": path, "WithDecryption": true}, &output); err != nil || !exists {
+			return nil, err
+		}
+		return &ExternalData{
+			Data:        map[string][]byte{"value": []byte(output.Parameter.Value)},
+			Version:     strconv.FormatInt(output.Parameter.Version, 10),
+			OnceVersion: strconv.FormatInt(output.Parameter.Version, 10),
+		}, nil
+	}
+
+	output := struct {
+		VersionId    string
+		SecretString *string
+		SecretBinary []byte
+	}{}
+	if exists, err := a.call("secretsmanager.GetSecretValue", map[string]interface{}{"SecretId": path}, &output); err != nil || !exists {
+		return nil, err
+	}
+	data := map[string][]byte{}
+	fields := map[string]json.RawMessage{}
+	if output.SecretString == nil {
+		data["value"] = output.SecretBinary
+	} else if err := json.Unmarshal([]byte(*output.SecretString), &fields); err == nil {
+		data = externalFields(fields)
+	} else {
+		data["value"] = []byte(*output.SecretString)
+	}
+	return &ExternalData{
+		Data:        data,
+		Version:     output.VersionId,
+		OnceVersion: output.VersionId,
+	}, nil
+}
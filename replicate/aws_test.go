@@ -0,0 +1,121 @@
+package replicate
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAWSSignature(t *testing.T) {
+	// the example of the documentation of the signatures
+	credentials := &AWSCredentials{region: "us-east-1",
+		accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	request, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if err := credentials.sign(request, "iam", nil, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if auth := request.Header.Get("Authorization"); auth != expected {
+		t.Errorf("expected %s, got %s", expected, auth)
+	}
+
+	if err := (&AWSCredentials{region: "us-east-1"}).sign(request, "iam", nil, time.Now()); err == nil {
+		t.Errorf("expected an error without credentials")
+	}
+}
+
+func TestAWSSignatureTestSuite(t *testing.T) {
+	// the requests of the test suite of the signatures, signed with its credentials
+	credentials := &AWSCredentials{region: "us-east-1",
+		accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	for name, test := range map[string]struct {
+		method, url, contentType, body, signedHeaders, signature string
+	}{
+		"get-vanilla": {http.MethodGet, "https://example.amazonaws.com/", "", "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		"get-vanilla-empty-query-key": {http.MethodGet, "https://example.amazonaws.com/?Param1=value1", "", "",
+			"host;x-amz-date", "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb"},
+		"get-vanilla-query-order-key-case": {http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "",
+			"host;x-amz-date", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		"get-vanilla-query-unreserved": {http.MethodGet, "https://example.amazonaws.com/?-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", "", "",
+			"host;x-amz-date", "9c3e54bfcdf0b19771a7f523ee5669cdf59bc7cc0884027167c21bb143a40197"},
+		"post-vanilla": {http.MethodPost, "https://example.amazonaws.com/", "", "",
+			"host;x-amz-date", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		"post-x-www-form-urlencoded": {http.MethodPost, "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1",
+			"content-type;host;x-amz-date", "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+		"post-x-www-form-urlencoded-parameters": {http.MethodPost, "https://example.amazonaws.com/", "application/x-www-form-urlencoded; charset=utf8", "Param1=value1",
+			"content-type;host;x-amz-date", "1a72ec8f64bd914b0e42e42607c7fbce7fb2c7465f63e3092b3b0d39fa77a6fe"},
+	} {
+		request, _ := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+		if test.contentType != "" {
+			request.Header.Set("Content-Type", test.contentType)
+		}
+		if err := credentials.sign(request, "service", []byte(test.body), time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+		expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=" + test.signedHeaders + ", Signature=" + test.signature
+		if auth := request.Header.Get("Authorization"); auth != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, auth)
+		}
+	}
+}
+
+func TestAWSSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body := make([]byte, req.ContentLength)
+		req.Body.Read(body)
+		switch fmt.Sprintf("%s %s", req.Header.Get("X-Amz-Target"), body) {
+		case `secretsmanager.GetSecretValue {"SecretId":"prod/database"}`:
+			fmt.Fprint(w, `{"VersionId": "d6d1b757", "SecretString": "{\"user\":\"admin\",\"port\":5432}", "CreatedDate": 1.7e9}`)
+		case `secretsmanager.GetSecretValue {"SecretId":"prod/token"}`:
+			fmt.Fprint(w, `{"VersionId": "a3c5e7f9", "SecretString": "token", "CreatedDate": 1.7e9}`)
+		case `AmazonSSM.GetParameter {"Name":"/prod/url","WithDecryption":true}`:
+			fmt.Fprint(w, `{"Parameter": {"Value": "https://example.com", "Version": 3}}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type": "ResourceNotFoundException", "message": "not found"}`)
+		}
+	}))
+	defer server.Close()
+
+	credentials := &AWSCredentials{region: "eu-west-1", accessKey: "key", secretKey: "secret"}
+	secrets := NewAWSSecretsManagerSource(credentials)
+	secrets.endpoint = server.URL
+	parameters := NewAWSParameterStoreSource(credentials)
+	parameters.endpoint = server.URL
+
+	data, err := secrets.Get("prod/database")
+	if err != nil || data == nil {
+		t.Fatalf("expected the secret to exist, got %v", err)
+	}
+	if string(data.Data["user"]) != "admin" || string(data.Data["port"]) != "5432" {
+		t.Errorf("expected a key per field of the secret, got %v", data.Data)
+	}
+	if data.Version != "d6d1b757" || data.OnceVersion != "d6d1b757" {
+		t.Errorf("expected the version id, got %s and %s", data.Version, data.OnceVersion)
+	}
+	if data, err := secrets.Get("prod/token"); err != nil || data == nil || string(data.Data["value"]) != "token" {
+		t.Errorf("expected the value of the secret, got %v %v", data, err)
+	}
+	if data, err := secrets.Get("prod/missing"); err != nil || data != nil {
+		t.Errorf("expected a missing secret, got %v", err)
+	}
+
+	data, err = parameters.Get("/prod/url")
+	if err != nil || data == nil {
+		t.Fatalf("expected the parameter to exist, got %v", err)
+	}
+	if string(data.Data["value"]) != "https://example.com" || data.OnceVersion != "3" {
+		t.Errorf("expected the value and the version of the parameter, got %v %s", data.Data, data.OnceVersion)
+	}
+}
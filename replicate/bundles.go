@@ -0,0 +1,434 @@
+package replicate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the resource of the ReplicationBundle custom resource
+var bundleResource = schema.GroupVersionResource{
+	Group:    "replicator.olli.com",
+	Version:  "v1alpha1",
+	Resource: "replicationbundles",
+}
+
+// ReplicationBundle groups several sources replicated together to the same namespaces
+type ReplicationBundle struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              BundleSpec   `json:"spec"`
+	Status            BundleStatus `json:"status,omitempty"`
+}
+
+// BundleSource is a source of a bundle, in the namespace of the bundle
+type BundleSource struct {
+	// the kind of the source, as used in annotations, ex: "secret" or "configmap"
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// BundleSpec describes the sources of a bundle, and the namespaces they are replicated to
+type BundleSpec struct {
+	Sources []BundleSource `json:"sources"`
+	// the names or patterns of the target namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// the labels of the target namespaces
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// the names or patterns of the namespaces never targeted
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+}
+
+// BundleStatus reports the replication of a bundle
+type BundleStatus struct {
+	// true when all the sources are replicated to all the target namespaces
+	Ready   bool   `json:"ready"`
+	Message string `json:"message,omitempty"`
+	// the namespaces where the bundle is replicated
+	Namespaces         []string `json:"namespaces,omitempty"`
+	ObservedGeneration int64    `json:"observedGeneration,omitempty"`
+}
+
+// BundleController replicates the ReplicationBundle custom resources
+// It relies on the replicators of the kinds of the sources
+type BundleController struct {
+	client     dynamic.Interface
+	lock       sync.Mutex
+	store      cache.Store
+	controller cache.Controller
+}
+
+// the running bundle controller, notified when sources change
+var bundles *BundleController
+
+// NewBundleController creates the controller of the bundles
+func NewBundleController(client dynamic.Interface, resyncPeriod time.Duration) *BundleController {
+	b := &BundleController{client: client}
+
+	b.store, b.controller = cache.NewInformer(
+		scopedListWatch(func(namespace string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+					return client.Resource(bundleResource).Namespace(namespace).List(lo)
+				},
+				WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+					return client.Resource(bundleResource).Namespace(namespace).Watch(lo)
+				},
+			}
+		}),
+		&unstructured.Unstructured{},
+		resyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    b.bundleChanged,
+			UpdateFunc: func(old interface{}, new interface{}) { b.bundleChanged(new) },
+			DeleteFunc: b.bundleDeleted,
+		},
+	)
+
+	return b
+}
+
+// Start runs the controller, and registers it to be notified of the changes of the sources
+func (b *BundleController) Start() {
+	bundles = b
+	logf("running bundle controller")
+	go b.controller.Run(wait.NeverStop)
+}
+
+// Synced returns true once all the bundles are listed
+func (b *BundleController) Synced() bool {
+	return b.controller.HasSynced()
+}
+
+// Notifies the bundle controller that an object changed
+// The bundles using it as a source, or owning it as a target, are processed again
+func notifyBundles(r *objectReplicator, meta *metav1.ObjectMeta) {
+	b := bundles
+	if b == nil {
+		return
+	}
+
+	owner := meta.Annotations[r.ReplicatedByBundleAnnotation]
+	// the bundles are processed asynchronously, as they may need the lock of this replicator
+	go func() {
+		for _, object := range b.store.List() {
+			bundle, err := parseBundle(object)
+			if err != nil {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s", bundle.Namespace, bundle.Name)
+			if key == owner {
+				b.bundleChanged(object)
+				continue
+			}
+
+			for _, s := range bundle.Spec.Sources {
+				if s.Kind == r.kind() && s.Name == meta.Name && bundle.Namespace == meta.Namespace {
+					b.bundleChanged(object)
+					break
+				}
+			}
+		}
+	}()
+}
+
+// Converts the custom resource to a bundle
+func parseBundle(object interface{}) (*ReplicationBundle, error) {
+	u, ok := object.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected bundle type %T", object)
+	}
+
+	bundle := &ReplicationBundle{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// Returns the replicator of the kind
+func replicatorOfKind(kind string) (*objectReplicator, bool) {
+	dependencies.Lock()
+	defer dependencies.Unlock()
+
+	r, ok := dependencies.replicators[kind]
+	return r, ok
+}
+
+// Returns all the replicators
+func registeredReplicators() []*objectReplicator {
+	dependencies.Lock()
+	defer dependencies.Unlock()
+
+	replicators := []*objectReplicator{}
+	for _, r := range dependencies.replicators {
+		replicators = append(replicators, r)
+	}
+	return replicators
+}
+
+// Returns true if the namespace matches one of the names or patterns
+func matchNamespace(patterns []string, namespace string) (bool, error) {
+	for _, ns := range patterns {
+		if ns == "" {
+		} else if validName.MatchString(ns) {
+			if ns == namespace {
+				return true, nil
+			}
+		} else if pattern, err := defaultPatternOptions.compile(ns); err != nil {
+			return false, fmt.Errorf("compilation error on namespace pattern %s: %s", ns, err)
+		} else if pattern.MatchString(namespace) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Returns the namespaces targeted by the bundle, sorted
+func (b *BundleController) targetNamespaces(bundle *ReplicationBundle, namespaces []interface{}) ([]string, error) {
+	var selector labels.Selector
+	if bundle.Spec.NamespaceSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(bundle.Spec.NamespaceSelector); err != nil {
+			return nil, fmt.Errorf("illformed namespace selector: %s", err)
+		}
+	}
+
+	targets := []string{}
+	for _, object := range namespaces {
+		namespace := object.(*v1.Namespace)
+		// the sources cannot be their own targets
+		if namespace.Name == bundle.Namespace {
+			continue
+		}
+
+		matched, err := matchNamespace(bundle.Spec.Namespaces, namespace.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !matched && selector != nil {
+			matched = selector.Matches(labels.Set(namespace.Labels))
+		}
+		if !matched {
+			continue
+		}
+
+		if excluded, err := matchNamespace(bundle.Spec.ExcludeNamespaces, namespace.Name); err != nil {
+			return nil, err
+			// the policy forbids targets in this namespace
+		} else if checkTargetNamespace(namespace.Name) != nil {
+		} else if !excluded {
+			targets = append(targets, namespace.Name)
+		}
+	}
+
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// a source of a bundle, resolved
+type bundleSource struct {
+	replicator *objectReplicator
+	object     interface{}
+	meta       *metav1.ObjectMeta
+}
+
+func (b *BundleController) bundleChanged(object interface{}) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	bundle, err := parseBundle(object)
+	if err != nil {
+		logf("could not parse bundle: %s", err)
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", bundle.Namespace, bundle.Name)
+	status := BundleStatus{ObservedGeneration: bundle.Generation}
+	status.Namespaces, status.Message = b.replicateBundle(key, bundle)
+	status.Ready = status.Message == ""
+	b.updateStatus(object.(*unstructured.Unstructured), bundle, status)
+}
+
+// Replicates all the sources of the bundle to the target namespaces
+// A namespace receives either all the sources, or none of them
+// Returns the namespaces where the bundle is replicated, and the reason why it is not ready
+func (b *BundleController) replicateBundle(key string, bundle *ReplicationBundle) ([]string, string) {
+	if len(bundle.Spec.Sources) == 0 {
+		return nil, "no source"
+	}
+	// all the sources must exist, to replicate them together
+	sources := []bundleSource{}
+	for _, s := range bundle.Spec.Sources {
+		r, ok := replicatorOfKind(s.Kind)
+		if !ok {
+			return nil, fmt.Sprintf("unknown kind %s", s.Kind)
+		}
+
+		sourceKey := fmt.Sprintf("%s/%s", bundle.Namespace, s.Name)
+		if object, exists, err := r.objectStore.GetByKey(sourceKey); err != nil {
+			return nil, err.Error()
+		} else if !exists {
+			logf("replication of bundle %s is delayed: %s %s does not exist", key, r.Name, sourceKey)
+			return nil, fmt.Sprintf("waiting for %s %s", s.Kind, sourceKey)
+		} else {
+			sources = append(sources, bundleSource{r, object, r.getMeta(object)})
+		}
+	}
+
+	if !sources[0].replicator.isManaged(&bundle.ObjectMeta) {
+		logf("bundle %s is managed by another instance", key)
+		return nil, ""
+	}
+
+	namespaces, err := b.targetNamespaces(bundle, sources[0].replicator.namespaceStore.List())
+	if err != nil {
+		logf("could not parse bundle %s: %s", key, err)
+		return nil, err.Error()
+	}
+
+	messages := []string{}
+	replicated := []string{}
+	// the targets of the bundle, to delete the others
+	desired := map[string]bool{}
+Namespaces:
+	for _, ns := range namespaces {
+		// check that all the targets can be replicated, before replicating any
+		for _, s := range sources {
+			target := fmt.Sprintf("%s/%s", ns, s.meta.Name)
+			desired[s.replicator.kind()+":"+target] = true
+			if object, exists, err := s.replicator.objectStore.GetByKey(target); err != nil {
+				messages = append(messages, err.Error())
+				continue Namespaces
+			} else if !exists {
+			} else if val := s.replicator.getMeta(object).Annotations[s.replicator.ReplicatedByBundleAnnotation]; val != key {
+				messages = append(messages, fmt.Sprintf("%s %s already exists and does not belong to the bundle", s.replicator.Name, target))
+				continue Namespaces
+			}
+		}
+
+		failed := false
+		for _, s := range sources {
+			if err := b.installTarget(key, ns, s); err != nil {
+				messages = append(messages, err.Error())
+				failed = true
+			}
+		}
+		if !failed {
+			replicated = append(replicated, ns)
+		}
+	}
+
+	// delete the targets which are not part of the bundle anymore
+	for _, r := range registeredReplicators() {
+		kind := r.kind()
+		for _, object := range r.objectStore.List() {
+			meta := r.getMeta(object)
+			target := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+			if meta.Annotations[r.ReplicatedByBundleAnnotation] == key && !desired[kind+":"+target] {
+				logf("bundle %s is not replicated to %s %s anymore: deleting target", key, r.Name, target)
+				if err := r.delete(&r.replicatorProps, object); err != nil {
+					messages = append(messages, err.Error())
+				}
+			}
+		}
+	}
+
+	return replicated, strings.Join(messages, "; ")
+}
+
+// Creates or updates the target of a source of the bundle in the namespace
+func (b *BundleController) installTarget(key string, namespace string, s bundleSource) error {
+	r := s.replicator
+	target := fmt.Sprintf("%s/%s", namespace, s.meta.Name)
+
+	var targetMeta *metav1.ObjectMeta
+	if object, exists, err := r.objectStore.GetByKey(target); err != nil {
+		return err
+	} else if exists {
+		targetMeta = r.getMeta(object)
+		// already up-to-date
+		if targetMeta.Annotations[r.ReplicatedFromVersionAnnotation] == s.meta.ResourceVersion {
+			return nil
+		}
+	}
+
+	copyMeta := metav1.ObjectMeta{
+		Namespace: namespace,
+		Name:      s.meta.Name,
+		Annotations: map[string]string{
+			r.ReplicatedAtAnnotation:          time.Now().Format(time.RFC3339),
+			r.ReplicatedByBundleAnnotation:    key,
+			r.ReplicatedFromVersionAnnotation: s.meta.ResourceVersion,
+		},
+	}
+	r.propagateAnnotations(&copyMeta, s.meta)
+	r.propagateLabels(&copyMeta, s.meta)
+	r.stampInstance(&copyMeta)
+	// Needs ResourceVersion for update
+	if targetMeta != nil {
+		copyMeta.ResourceVersion = targetMeta.ResourceVersion
+	}
+
+	logf("installing %s %s: bundle %s", r.Name, target, key)
+	return r.install(&r.replicatorProps, &copyMeta, s.object, s.object)
+}
+
+// Writes the status of the bundle, if it changed
+func (b *BundleController) updateStatus(u *unstructured.Unstructured, bundle *ReplicationBundle, status BundleStatus) {
+	if reflect.DeepEqual(bundle.Status, status) {
+		return
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		logf("could not convert status of bundle %s/%s: %s", bundle.Namespace, bundle.Name, err)
+		return
+	}
+
+	u = u.DeepCopy()
+	u.Object["status"] = content
+	if _, err := b.client.Resource(bundleResource).Namespace(bundle.Namespace).UpdateStatus(u, metav1.UpdateOptions{}); err != nil {
+		logf("could not update status of bundle %s/%s: %s", bundle.Namespace, bundle.Name, err)
+	}
+}
+
+func (b *BundleController) bundleDeleted(object interface{}) {
+	if tombstone, ok := object.(cache.DeletedFinalStateUnknown); ok {
+		object = tombstone.Obj
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	bundle, err := parseBundle(object)
+	if err != nil {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", bundle.Namespace, bundle.Name)
+	for _, r := range registeredReplicators() {
+		for _, object := range r.objectStore.List() {
+			meta := r.getMeta(object)
+			if meta.Annotations[r.ReplicatedByBundleAnnotation] == key {
+				logf("bundle %s deleted: deleting target %s %s/%s", key, r.Name, meta.Namespace, meta.Name)
+				r.delete(&r.replicatorProps, object)
+			}
+		}
+	}
+}
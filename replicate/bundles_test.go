@@ -0,0 +1,54 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBundleTargetNamespaces(t *testing.T) {
+	namespace := func(name string, labels map[string]string) interface{} {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	namespaces := []interface{}{
+		namespace("default", nil),
+		namespace("team-b", nil),
+		namespace("team-a", nil),
+		namespace("team-legacy", nil),
+		namespace("prod", map[string]string{"database": "true"}),
+		namespace("staging", map[string]string{"database": "false"}),
+	}
+
+	bundle := &ReplicationBundle{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "database"},
+		Spec: BundleSpec{
+			Namespaces: []string{"team-.*", "default"},
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"database": "true"},
+			},
+			ExcludeNamespaces: []string{"team-legacy"},
+		},
+	}
+
+	targets, err := (&BundleController{}).targetNamespaces(bundle, namespaces)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"prod", "team-a", "team-b"}; !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected %v, got %v", expected, targets)
+	}
+}
+
+func TestBundleTargetNamespacesInvalid(t *testing.T) {
+	bundle := &ReplicationBundle{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "database"},
+		Spec:       BundleSpec{Namespaces: []string{"team-("}},
+	}
+
+	namespaces := []interface{}{&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}}
+	if _, err := (&BundleController{}).targetNamespaces(bundle, namespaces); err == nil {
+		t.Errorf("expected an error for an illformed pattern")
+	}
+}
@@ -0,0 +1,205 @@
+package replicate
+
+import (
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CacheTransform is how the objects are reduced before being cached by the informers
+type CacheTransform string
+
+const (
+	// CacheFull caches the objects as read from the API
+	CacheFull CacheTransform = ""
+	// CacheStrip drops the field sets of the managed fields, and the last applied configuration of the objects
+	// not involved in any replication
+	CacheStrip CacheTransform = "strip"
+	// CacheMetadata also drops the data of the secrets and config maps not involved in any replication,
+	// which is read from the API when needed
+	CacheMetadata CacheTransform = "metadata"
+)
+
+// the annotation of kubectl apply, as large as the object itself
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// the annotation set on the cached objects whose data was dropped, to the hash of their data
+// It is only set in the cache, never written, so that a change of the data is still noticed
+const strippedDataAnnotation = "kubernetes-replicator/stripped-data-hash"
+
+// Wraps the lister watcher of the objects of the replicator, so that they are reduced before being cached
+func (r *objectReplicator) transformCache(listWatch cache.ListerWatcher) cache.ListerWatcher {
+	if r.cacheTransform == CacheFull {
+		return listWatch
+	}
+	// the metadata of the dynamic objects is a copy, which cannot be reduced in place
+	if _, ok := baseActions(r.replicatorActions).(*dynamicActions); ok {
+		return listWatch
+	}
+	return &transformListWatch{listWatch, r}
+}
+
+// Returns true if the object is a source or a target, or may become one, from its annotations and finalizers
+func (r *replicatorProps) isInvolved(meta *metav1.ObjectMeta) bool {
+	for annotation := range meta.Annotations {
+		if r.isReplicatorAnnotation(annotation) {
+			return true
+		}
+	}
+	return hasFinalizer(meta, r.ReplicatedTargetsFinalizer)
+}
+
+// Reduces the object before it is cached
+func (r *objectReplicator) transformCached(object runtime.Object) {
+	objectMeta := r.getMeta(object)
+	// the managers and the times of their writes are kept, but not the fields they manage
+	for i := range objectMeta.ManagedFields {
+		objectMeta.ManagedFields[i].Fields = nil
+	}
+	// the objects written by the replicator keep their annotations, as they are written whole
+	if r.isInvolved(objectMeta) {
+		return
+	}
+	delete(objectMeta.Annotations, lastAppliedAnnotation)
+	if r.cacheTransform != CacheMetadata {
+		return
+	}
+
+	hash, err := dataHash(object)
+	if err != nil {
+		return
+	}
+	switch typed := object.(type) {
+	case *v1.Secret:
+		typed.Data, typed.StringData = nil, nil
+	case *v1.ConfigMap:
+		typed.Data, typed.BinaryData = nil, nil
+	default:
+		return
+	}
+	if objectMeta.Annotations == nil {
+		objectMeta.Annotations = map[string]string{}
+	}
+	objectMeta.Annotations[strippedDataAnnotation] = hash
+}
+
+// Returns the object with its data, read from the API if it was dropped from the cache
+func (r *objectReplicator) unstripped(object interface{}) interface{} {
+	objectMeta := r.getMeta(object)
+	if _, ok := objectMeta.Annotations[strippedDataAnnotation]; !ok {
+		return object
+	}
+	key := objectKey(objectMeta)
+	latest, err := r.latestFromAPI(key)
+	if err != nil {
+		r.errorf(key, "", "could not read the data of %s %s: %s", r.Name, key, err)
+		return object
+	} else if latest == nil {
+		return object
+	}
+	return latest
+}
+
+// a lister watcher reducing the objects before they are cached
+type transformListWatch struct {
+	cache.ListerWatcher
+	replicator *objectReplicator
+}
+
+func (lw *transformListWatch) List(lo metav1.ListOptions) (runtime.Object, error) {
+	list, err := lw.ListerWatcher.List(lo)
+	if err != nil {
+		return list, err
+	}
+	err = meta.EachListItem(list, func(object runtime.Object) error {
+		lw.replicator.transformCached(object)
+		return nil
+	})
+	return list, err
+}
+
+func (lw *transformListWatch) Watch(lo metav1.ListOptions) (watch.Interface, error) {
+	w, err := lw.ListerWatcher.Watch(lo)
+	if err != nil {
+		return w, err
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		if event.Type != watch.Error {
+			lw.replicator.transformCached(event.Object)
+		}
+		return event, true
+	}), nil
+}
+
+// a store of the objects, reading from the API the data dropped from the cache of the objects it returns
+type strippedStore struct {
+	cache.Indexer
+	replicator *objectReplicator
+}
+
+func (s *strippedStore) GetByKey(key string) (interface{}, bool, error) {
+	object, exists, err := s.Indexer.GetByKey(key)
+	if err != nil || !exists {
+		return object, exists, err
+	}
+	return s.replicator.unstripped(object), true, nil
+}
+
+// Wraps the store of the objects of the replicator, when their data can be dropped from the cache
+func (r *objectReplicator) stripStore(store cache.Indexer) cache.Indexer {
+	if r.cacheTransform != CacheMetadata {
+		return store
+	}
+	return &strippedStore{store, r}
+}
+
+// Returns the object of the store as cached, without reading its dropped data from the API
+func (r *replicatorProps) cachedByKey(key string) (interface{}, bool, error) {
+	if store, ok := r.objectStore.(*strippedStore); ok {
+		return store.Indexer.GetByKey(key)
+	}
+	return r.objectStore.GetByKey(key)
+}
+
+// the actions of a replicator whose cache is reduced, which do not write the reduced metadata of the cached objects
+type cacheTransformActions struct {
+	replicatorActions
+}
+
+func (a *cacheTransformActions) wrapped() replicatorActions {
+	return a.replicatorActions
+}
+
+// Returns a copy of the object without the metadata reduced in the cache
+func (a *cacheTransformActions) restored(object interface{}) interface{} {
+	copy := object.(runtime.Object).DeepCopyObject()
+	restoreMeta(a.getMeta(copy))
+	return copy
+}
+
+// Removes the metadata reduced in the cache, the managed fields being kept as is by the API when omitted
+func restoreMeta(objectMeta *metav1.ObjectMeta) {
+	objectMeta.ManagedFields = nil
+	delete(objectMeta.Annotations, strippedDataAnnotation)
+}
+
+func (a *cacheTransformActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	return a.replicatorActions.update(r, a.restored(object), sourceObject)
+}
+
+func (a *cacheTransformActions) clear(r *replicatorProps, object interface{}) error {
+	return a.replicatorActions.clear(r, a.restored(object))
+}
+
+func (a *cacheTransformActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	copyMeta := meta.DeepCopy()
+	restoreMeta(copyMeta)
+	return a.replicatorActions.install(r, copyMeta, sourceObject, dataObject)
+}
+
+func (a *cacheTransformActions) patch(r *replicatorProps, target *metav1.ObjectMeta, p *patchWrite) error {
+	return patchThrough(a.replicatorActions, r, target, p)
+}
@@ -0,0 +1,78 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestTransformCached(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			cacheTransform:  CacheMetadata,
+		},
+		replicatorActions: SecretActions,
+	}
+	secret := func(annotations map[string]string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "secret", Annotations: annotations,
+				ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl", Fields: &metav1.Fields{}}}},
+			Data: map[string][]byte{"key": []byte("value")},
+		}
+	}
+
+	list := &v1.SecretList{Items: []v1.Secret{
+		*secret(map[string]string{lastAppliedAnnotation: "{}"}),
+		*secret(map[string]string{lastAppliedAnnotation: "{}", r.ReplicateToAnnotation: "team-a/secret"}),
+	}}
+	lw := r.transformCache(&cache.ListWatch{ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+		return list, nil
+	}})
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, _ := dataHash(secret(nil))
+	stripped, source := &list.Items[0], &list.Items[1]
+	if stripped.Data != nil || stripped.Annotations[strippedDataAnnotation] != hash {
+		t.Errorf("expected only the hash of the data to be cached, got %v %v", stripped.Data, stripped.Annotations)
+	}
+	if _, ok := stripped.Annotations[lastAppliedAnnotation]; ok {
+		t.Errorf("expected the last applied configuration to be dropped")
+	}
+	if stripped.ManagedFields[0].Manager != "kubectl" || stripped.ManagedFields[0].Fields != nil {
+		t.Errorf("expected only the managers to be kept, got %v", stripped.ManagedFields)
+	}
+	if source.Data == nil || source.Annotations[lastAppliedAnnotation] != "{}" {
+		t.Errorf("expected a source to be cached whole, got %v %v", source.Data, source.Annotations)
+	}
+	if source.ManagedFields[0].Fields != nil {
+		t.Errorf("expected the managed fields of a source to be reduced too")
+	}
+
+	// the reduced metadata is not written
+	restored := (&cacheTransformActions{SecretActions}).restored(stripped).(*v1.Secret)
+	if restored.ManagedFields != nil || restored.Annotations[strippedDataAnnotation] != "" {
+		t.Errorf("expected the reduced metadata to be removed, got %v", restored.ObjectMeta)
+	}
+	if stripped.Annotations[strippedDataAnnotation] == "" {
+		t.Errorf("expected the cached object to be left unchanged")
+	}
+
+	r.objectStore = r.stripStore(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}))
+	r.objectStore.Add(stripped)
+	// the client is not set, reading the data from the API would panic
+	if object, exists, err := r.cachedByKey("default/secret"); err != nil || !exists || object != stripped {
+		t.Errorf("expected the cached object, got %v %v %v", object, exists, err)
+	}
+
+	r.cacheTransform = CacheFull
+	if _, ok := r.transformCache(&cache.ListWatch{}).(*transformListWatch); ok {
+		t.Errorf("expected the objects to be cached whole by default")
+	}
+}
@@ -0,0 +1,79 @@
+package replicate
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// the kinds replicated by the "replicate-all-to" annotation of their namespace
+var cloneableKinds = map[string]bool{
+	"secret":    true,
+	"configmap": true,
+}
+
+// the objects created by kubernetes in each namespace, which are never cloned
+var notCloned = map[string]bool{
+	"kube-root-ca.crt": true,
+}
+
+// Returns the namespaces of the "replicate-all-to" annotation of the namespace of the object,
+// when the object is cloned with its namespace
+// The objects must match the "replicate-all-selector" annotation of the namespace, if any
+func (r *replicatorProps) namespaceClonedTo(object *metav1.ObjectMeta) (string, bool, error) {
+	if !cloneableKinds[r.kind()] || notCloned[object.Name] {
+		return "", false, nil
+	}
+	// the copies are never cloned again, nor the service account tokens
+	for _, annotation := range []string{r.ReplicatedByAnnotation, r.ReplicateFromAnnotation, v1.ServiceAccountNameKey} {
+		if _, ok := object.Annotations[annotation]; ok {
+			return "", false, nil
+		}
+	}
+
+	obj, exists, err := r.namespaceStore.GetByKey(object.Namespace)
+	if err != nil || !exists {
+		return "", false, err
+	}
+	namespace := obj.(*v1.Namespace)
+	patterns, ok := namespace.Annotations[r.ReplicateAllToAnnotation]
+	if !ok {
+		return "", false, nil
+	}
+
+	if val, ok := namespace.Annotations[r.ReplicateAllSelectorAnnotation]; ok {
+		selector, err := labels.Parse(val)
+		if err != nil {
+			return "", false, fmt.Errorf("namespace %s has illformed annotation %s (%s): %s",
+				namespace.Name, r.ReplicateAllSelectorAnnotation, val, err)
+		} else if !selector.Matches(labels.Set(object.Labels)) {
+			return "", false, nil
+		}
+	}
+	return patterns, true, nil
+}
+
+// Returns true if the cloning annotations of the namespace changed
+func (r *replicatorProps) cloneChanged(old *v1.Namespace, namespace *v1.Namespace) bool {
+	if !cloneableKinds[r.kind()] {
+		return false
+	}
+	for _, annotation := range []string{r.ReplicateAllToAnnotation, r.ReplicateAllSelectorAnnotation} {
+		if old.Annotations[annotation] != namespace.Annotations[annotation] {
+			return true
+		}
+	}
+	return false
+}
+
+// Processes again all the objects of the namespace, when its cloning annotations changed
+func (r *objectReplicator) cloneNamespace(namespace string) {
+	r.infof("", "", "cloning annotations of namespace %s changed: its %s objects are processed again", namespace, r.Name)
+	for _, object := range r.objectStore.List() {
+		if r.getMeta(object).Namespace == namespace {
+			r.enqueueAdded(object)
+		}
+	}
+}
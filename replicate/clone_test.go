@@ -0,0 +1,49 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNamespaceClonedTo(t *testing.T) {
+	r := &replicatorProps{
+		Name:            "secret",
+		annotationNames: annotationsWithPrefix(""),
+		namespaceStore:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+	}
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "templates", Annotations: map[string]string{
+		r.ReplicateAllToAnnotation:       "team-.*",
+		r.ReplicateAllSelectorAnnotation: "shared=true",
+	}}}
+	r.namespaceStore.Add(namespace)
+
+	object := &metav1.ObjectMeta{Namespace: "templates", Name: "registry", Labels: map[string]string{"shared": "true"}}
+	if patterns, ok, err := r.namespaceClonedTo(object); !ok || err != nil || patterns != "team-.*" {
+		t.Errorf("expected the object to be cloned to team-.*, got %s, %v, %v", patterns, ok, err)
+	}
+	targets, targetPatterns, err := r.getReplicationTargets(object)
+	if err != nil || len(targets) != 0 || len(targetPatterns) != 1 {
+		t.Errorf("expected a single target pattern, got %v, %v, %v", targets, targetPatterns, err)
+	}
+
+	// the objects not matching the selector are not cloned
+	object.Labels["shared"] = "false"
+	if _, ok, _ := r.namespaceClonedTo(object); ok {
+		t.Errorf("expected the object not matching the selector not to be cloned")
+	}
+
+	// the copies are not cloned again
+	copy := &metav1.ObjectMeta{Namespace: "templates", Name: "copy", Labels: map[string]string{"shared": "true"},
+		Annotations: map[string]string{r.ReplicatedByAnnotation: "other/copy"}}
+	if _, ok, _ := r.namespaceClonedTo(copy); ok {
+		t.Errorf("expected the copy not to be cloned")
+	}
+
+	namespace.Annotations[r.ReplicateAllSelectorAnnotation] = "shared in (true"
+	if _, _, err := r.namespaceClonedTo(object); err == nil {
+		t.Errorf("expected an error for an illformed selector")
+	}
+}
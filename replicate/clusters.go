@@ -0,0 +1,254 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the name of this cluster, written on the objects pushed to the other clusters
+var clusterName string
+
+// a {name => client} map of the other clusters
+var clusterClients = map[string]kubernetes.Interface{}
+
+// ConfigureClusters sets the name of this cluster, and the clients of the clusters
+// the sources can be pushed to with the "replicate-to-clusters" annotation,
+// or pulled from with the "replicate-from" annotation as "cluster:namespace/name"
+func ConfigureClusters(name string, clients map[string]kubernetes.Interface) {
+	clusterName = name
+	clusterClients = clients
+}
+
+// Returns the clusters of the "replicate-to-clusters" annotation
+func (r *replicatorProps) getClusters(object *metav1.ObjectMeta) map[string]bool {
+	clusters := map[string]bool{}
+	if val, ok := object.Annotations[r.ReplicateToClustersAnnotation]; ok {
+		for _, name := range strings.Split(val, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				clusters[name] = true
+			}
+		}
+	}
+	return clusters
+}
+
+// Returns true if the object of another cluster was pushed from the source in this cluster
+func (r *replicatorProps) isPushedFrom(object *metav1.ObjectMeta, source string) bool {
+	return object.Annotations[r.ReplicatedFromClusterAnnotation] == clusterName &&
+		object.Annotations[r.ReplicatedByAnnotation] == source
+}
+
+// Watches the objects of the other clusters, where the sources are pushed or pulled from
+// Each cluster has its own client and store, used by the actions as for this cluster
+func (r *objectReplicator) startClusters() {
+	r.clusters = map[string]*replicatorProps{}
+	for name, client := range clusterClients {
+		listWatch, objectType := r.listWatch(client)
+		if listWatch == nil {
+			continue
+		}
+		listWatch = r.readLegacy(listWatch)
+
+		cluster := name
+		remote := &replicatorProps{
+			Name:            r.Name,
+			annotationNames: r.annotationNames,
+			client:          client,
+			metrics:         r.metrics,
+			logger:          r.log().WithValues("cluster", cluster),
+		}
+		remote.objectStore, remote.objectController = cache.NewIndexerInformer(
+			listWatch,
+			objectType,
+			0,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(object interface{}) {
+					r.pulledChanged(cluster, object)
+				},
+				UpdateFunc: func(old interface{}, new interface{}) {
+					r.pulledChanged(cluster, new)
+					r.pushedChanged(cluster, new, false)
+				},
+				DeleteFunc: func(object interface{}) {
+					r.pulledChanged(cluster, object)
+					r.pushedChanged(cluster, object, true)
+				},
+			},
+			cache.Indexers{},
+		)
+		r.clusters[cluster] = remote
+
+		logf("running %s object controller for cluster %s", r.Name, cluster)
+		go remote.objectController.Run(wait.NeverStop)
+	}
+}
+
+// Returns the source of a "replicate-from" annotation, which is in another cluster
+// when formatted as "cluster:namespace/name", or in an external source when formatted as "scheme:path"
+func (r *objectReplicator) getSource(key string) (interface{}, bool, error) {
+	if source, path, ok := externalSourceOf(key); ok {
+		return r.getExternalSource(source, key, path)
+	}
+	parts := strings.SplitN(key, ":", 2)
+	if len(parts) != 2 {
+		return r.objectStore.GetByKey(key)
+	}
+
+	remote, ok := r.clusters[parts[0]]
+	if !ok {
+		return nil, false, fmt.Errorf("unknown cluster %s", parts[0])
+	} else if !remote.objectController.HasSynced() {
+		return nil, false, fmt.Errorf("cluster %s is not synced yet", parts[0])
+	}
+	return remote.objectStore.GetByKey(parts[1])
+}
+
+// Processes again the targets replicated from the object of another cluster, when it changed
+func (r *objectReplicator) pulledChanged(cluster string, object interface{}) {
+	if tombstone, ok := object.(cache.DeletedFinalStateUnknown); ok {
+		object = tombstone.Obj
+	}
+
+	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s:%s/%s", cluster, meta.Namespace, meta.Name)
+
+	r.lock.RLock()
+	targets := r.dependentsOf(key)
+	r.lock.RUnlock()
+
+	for _, target := range targets {
+		logf("%s %s changed: %s is processed again", r.Name, key, target)
+		r.queue.Add(target)
+	}
+}
+
+// Processes the source again when its copy in another cluster was changed or deleted
+func (r *objectReplicator) pushedChanged(cluster string, object interface{}, deleted bool) {
+	if tombstone, ok := object.(cache.DeletedFinalStateUnknown); ok {
+		object = tombstone.Obj
+	}
+
+	meta := r.getMeta(object)
+	source := meta.Annotations[r.ReplicatedByAnnotation]
+	if !r.isPushedFrom(meta, source) {
+		return
+	}
+	// the copy is up to date
+	if deleted {
+	} else if sourceObject, exists, err := r.objectStore.GetByKey(source); err != nil || !exists {
+	} else if meta.Annotations[r.ReplicatedFromVersionAnnotation] == r.getMeta(sourceObject).ResourceVersion {
+		return
+	}
+
+	logf("%s %s/%s changed in cluster %s", r.Name, meta.Namespace, meta.Name, cluster)
+	r.queue.Add(source)
+}
+
+// Pushes the object to the clusters of its "replicate-to-clusters" annotation,
+// and deletes its copies from the other clusters
+func (r *objectReplicator) pushToClusters(object interface{}) {
+	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+	clusters := r.getClusters(meta)
+
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := r.clusters[name]; !ok {
+			err := fmt.Errorf("source %s %s is pushed to unknown cluster %s", r.Name, key, name)
+			logf("%s", err)
+			r.reportError(err)
+		}
+	}
+
+	r.syncClusters(object, clusters)
+}
+
+// Installs the copies of the source in the clusters, and deletes them from the other clusters
+func (r *objectReplicator) syncClusters(sourceObject interface{}, clusters map[string]bool) {
+	sourceMeta := r.getMeta(sourceObject)
+	key := fmt.Sprintf("%s/%s", sourceMeta.Namespace, sourceMeta.Name)
+	// nothing is written while paused: the copies deleted from the other clusters have no source in their store,
+	// the actions could not find whether it is paused
+	if ok, err := r.isRunning(sourceMeta); !ok {
+		r.debugf(key, "", "replication of %s %s to the other clusters is skipped: %s", r.Name, key, err)
+		return
+	}
+
+	for name, remote := range r.clusters {
+		target := fmt.Sprintf("%s:%s", name, key)
+		// the copies cannot be found before the cluster is synced
+		if !remote.objectController.HasSynced() {
+			if clusters[name] {
+				r.track(target, key, "created", fmt.Errorf("cluster %s is not synced yet", name))
+			}
+			continue
+		}
+
+		object, exists, err := remote.objectStore.GetByKey(key)
+		if err != nil {
+			logf("could not get %s %s in cluster %s: %s", r.Name, key, name, err)
+			continue
+		}
+
+		var meta *metav1.ObjectMeta
+		if exists {
+			meta = r.getMeta(object)
+			if !r.isPushedFrom(meta, key) {
+				if clusters[name] {
+					err := fmt.Errorf("%s %s already exists in cluster %s and is not replicated from this cluster",
+						r.Name, key, name)
+					logf("%s", err)
+					r.reportError(err)
+				}
+				continue
+			}
+		}
+		// the copy is not wanted anymore
+		if !clusters[name] {
+			if exists {
+				logf("deleting %s %s from cluster %s", r.Name, key, name)
+				r.track(target, key, "deleted", r.delete(remote, object))
+			}
+			continue
+		}
+		// the copy is up to date
+		if exists && meta.Annotations[r.ReplicatedFromVersionAnnotation] == sourceMeta.ResourceVersion {
+			continue
+		}
+
+		copyMeta := metav1.ObjectMeta{
+			Namespace:   sourceMeta.Namespace,
+			Name:        sourceMeta.Name,
+			Annotations: map[string]string{},
+		}
+		copyMeta.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+		copyMeta.Annotations[r.ReplicatedByAnnotation] = key
+		copyMeta.Annotations[r.ReplicatedFromVersionAnnotation] = sourceMeta.ResourceVersion
+		r.setDataHash(&copyMeta, sourceObject)
+		r.setSynced(&copyMeta, sourceMeta.ResourceVersion)
+		copyMeta.Annotations[r.ReplicatedFromClusterAnnotation] = clusterName
+		r.propagateAnnotations(&copyMeta, sourceMeta)
+		r.propagateLabels(&copyMeta, sourceMeta)
+		r.mergeTargetMetadata(&copyMeta, meta, sourceMeta)
+		outcome := "created"
+		// Needs ResourceVersion for update
+		if exists {
+			copyMeta.ResourceVersion = meta.ResourceVersion
+			outcome = "updated"
+		}
+
+		logf("installing %s %s in cluster %s", r.Name, key, name)
+		r.track(target, key, outcome, r.install(remote, &copyMeta, sourceObject, sourceObject))
+	}
+}
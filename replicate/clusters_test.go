@@ -0,0 +1,67 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetClusters(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicateToClustersAnnotation: "prod-eu, prod-us,,",
+	}}
+
+	if clusters, expected := r.getClusters(source), map[string]bool{"prod-eu": true, "prod-us": true}; !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("expected %v, got %v", expected, clusters)
+	}
+
+	delete(source.Annotations, r.ReplicateToClustersAnnotation)
+	if clusters := r.getClusters(source); len(clusters) != 0 {
+		t.Errorf("expected no cluster, got %v", clusters)
+	}
+}
+
+func TestIsPushedFrom(t *testing.T) {
+	defer ConfigureClusters(clusterName, clusterClients)
+	ConfigureClusters("staging", nil)
+
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	copy := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicatedByAnnotation:          "default/source",
+		r.ReplicatedFromClusterAnnotation: "staging",
+	}}
+
+	if !r.isPushedFrom(copy, "default/source") {
+		t.Errorf("expected the copy to be pushed from default/source")
+	}
+	if r.isPushedFrom(copy, "default/other") {
+		t.Errorf("expected the copy not to be pushed from default/other")
+	}
+
+	// a copy pushed by another cluster is left alone
+	copy.Annotations[r.ReplicatedFromClusterAnnotation] = "dev"
+	if r.isPushedFrom(copy, "default/source") {
+		t.Errorf("expected the copy not to be pushed from this cluster")
+	}
+}
+
+func TestResolveRemoteSource(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{annotationNames: annotationsWithPrefix("")}}
+	target := &metav1.ObjectMeta{Namespace: "default", Name: "target", Annotations: map[string]string{
+		r.ReplicateFromAnnotation: "prod-eu:database",
+	}}
+
+	source, ok := resolveAnnotation(target, r.ReplicateFromAnnotation)
+	if !ok || source != "prod-eu:default/database" {
+		t.Errorf("expected prod-eu:default/database, got %s", source)
+	}
+	if !validSourcePath.MatchString(source) {
+		t.Errorf("expected %s to be a valid source", source)
+	}
+
+	if _, _, err := r.getSource(source); err == nil {
+		t.Errorf("expected an error for an unknown cluster")
+	}
+}
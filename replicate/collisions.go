@@ -0,0 +1,81 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Collision describes a target which cannot be replicated from a source,
+// as it already exists and belongs to another object
+type Collision struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Source string `json:"source"`
+	// the source which replicates to the target, empty if the target was not created by replication
+	Owner string `json:"owner,omitempty"`
+}
+
+type collisionKey struct {
+	source string
+	target string
+}
+
+// Records that the target cannot be replicated from the source, and reports it
+func (r *objectReplicator) collide(target string, targetMeta *metav1.ObjectMeta, sourceObject interface{}) {
+	sourceMeta := r.getMeta(sourceObject)
+	key := collisionKey{fmt.Sprintf("%s/%s", sourceMeta.Namespace, sourceMeta.Name), target}
+	owner := targetMeta.Annotations[r.ReplicatedByAnnotation]
+	// already reported
+	if val, ok := r.collisions[key]; ok && val == owner {
+		return
+	}
+
+	r.collisions[key] = owner
+	r.metrics.set("replicator_target_collisions", float64(len(r.collisions)), "kind", r.Name)
+
+	if owner == "" {
+		r.recordEvent(sourceObject, v1.EventTypeWarning, "TargetCollision",
+			"target %s already exists and was not created by replication", target)
+		return
+	}
+
+	r.recordEvent(sourceObject, v1.EventTypeWarning, "TargetCollision",
+		"target %s is already replicated from %s", target, owner)
+	if ownerObject, exists, err := r.objectStore.GetByKey(owner); err == nil && exists {
+		r.recordEvent(ownerObject, v1.EventTypeWarning, "TargetCollision",
+			"target %s is also targeted by %s", target, key.source)
+	}
+}
+
+// Forgets the collisions of a source, or of a target, or both
+// Empty strings match any source or target
+func (r *objectReplicator) resolveCollisions(source string, target string) {
+	for key := range r.collisions {
+		if (source == "" || key.source == source) && (target == "" || key.target == target) {
+			delete(r.collisions, key)
+		}
+	}
+	r.metrics.set("replicator_target_collisions", float64(len(r.collisions)), "kind", r.Name)
+}
+
+// Collisions returns all the targets which cannot be replicated, as they belong to another object
+func (r *objectReplicator) Collisions() []Collision {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	collisions := make([]Collision, 0, len(r.collisions))
+	for key, owner := range r.collisions {
+		collisions = append(collisions, Collision{r.Name, key.target, key.source, owner})
+	}
+
+	sort.Slice(collisions, func(i, j int) bool {
+		if collisions[i].Target != collisions[j].Target {
+			return collisions[i].Target < collisions[j].Target
+		}
+		return collisions[i].Source < collisions[j].Source
+	})
+	return collisions
+}
@@ -5,76 +5,307 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	semver "github.com/Masterminds/semver/v3"
+	"github.com/go-logr/logr"
+	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 )
 
 // pattern of a valid kubernetes name
 var validName = regexp.MustCompile(`^[0-9a-z.-]+$`)
 var validPath = regexp.MustCompile(`^[0-9a-z.-]+/[0-9a-z.-]+$`)
 
+// pattern of a valid source, optionally in another cluster
+var validSourcePath = regexp.MustCompile(`^([0-9a-z.-]+:)?[0-9a-z.-]+/[0-9a-z.-]+$`)
+
+// Returns true if all the comma separated paths are valid sources
+func validSourcePaths(val string) bool {
+	for _, path := range strings.Split(val, ",") {
+		if _, _, ok := externalSourceOf(path); ok {
+			continue
+		} else if !validSourcePath.MatchString(path) {
+			return false
+		}
+	}
+	return true
+}
+
 // a struct representing a pattern to match namespaces and generating targets
+// the namespaces are matched either by the regex, or by the labels selector
 type targetPattern struct {
 	namespace *regexp.Regexp
-	name      string
+	selector  labels.Selector
+	// returns the labels of a namespace, for the selector
+	labels func(namespace string) labels.Set
+	name   string
+	// renders the name of the targets in each namespace, from the replicate-to-name annotation of the source
+	nameTemplate *template.Template
+	// the path of the source, for the name template
+	source string
+}
+
+// if the pattern matches the given namespace name
+func (pattern targetPattern) matchNamespace(namespace string) bool {
+	if pattern.selector != nil {
+		return pattern.selector.Matches(pattern.labels(namespace))
+	}
+	return pattern.namespace.MatchString(namespace)
+}
+
+// returns the name of the target in the given namespace, or an empty string if the template renders no valid name
+func (pattern targetPattern) nameIn(namespace string) string {
+	if pattern.nameTemplate == nil {
+		return pattern.name
+	}
+	name, err := renderTargetName(pattern.nameTemplate, pattern.source, namespace, pattern.name)
+	if err != nil {
+		return ""
+	}
+	return name
 }
+
 // if the pattern matches the given target object
 func (pattern targetPattern) Match(object *metav1.ObjectMeta) bool {
-	return object.Name == pattern.name && pattern.namespace.MatchString(object.Namespace)
+	return pattern.matchNamespace(object.Namespace) && object.Name == pattern.nameIn(object.Namespace)
 }
+
 // if the pattern matches the given target path
 func (pattern targetPattern) MatchString(target string) bool {
 	parts := strings.SplitN(target, "/", 2)
-	return len(parts) == 2 && parts[1] == pattern.name && pattern.namespace.MatchString(parts[0])
+	return len(parts) == 2 && pattern.matchNamespace(parts[0]) && parts[1] == pattern.nameIn(parts[0])
 }
+
 // if the pattern matches the given namespace, returns a target path in this namespace
 func (pattern targetPattern) MatchNamespace(namespace string) string {
-	if pattern.namespace.MatchString(namespace) {
-		return fmt.Sprintf("%s/%s", namespace, pattern.name)
+	if !pattern.matchNamespace(namespace) {
+		return ""
+	} else if name := pattern.nameIn(namespace); name != "" {
+		return fmt.Sprintf("%s/%s", namespace, name)
 	} else {
 		return ""
 	}
 }
+
 // returns a slice of targets paths in the given namespaces when matching
 func (pattern targetPattern) Targets(namespaces []string) []string {
-	suffix := "/" + pattern.name
 	targets := []string{}
 	for _, ns := range namespaces {
-		if pattern.namespace.MatchString(ns) {
-			targets = append(targets, ns+suffix)
+		if target := pattern.MatchNamespace(ns); target != "" {
+			targets = append(targets, target)
 		}
 	}
 	return targets
 }
 
+// ReplicatorOptions configures a replicator at construction
+type ReplicatorOptions struct {
+	// the resynchronization period of the informers
+	ResyncPeriod time.Duration
+	// when true, "allowed" annotations are ignored
+	AllowAll bool
+	// the prefix of the annotations of this kind, the global prefix is used when empty
+	AnnotationsPrefix string
+	// the registry of the metrics, can be nil
+	Metrics *Metrics
+	// the instance of the replicator, only objects annotated for this instance are managed
+	Instance string
+	// assigns the objects without instance annotation to the instances, can be nil
+	Shards *Shards
+	// the number of workers processing the changes of the objects
+	Workers int
+	// the structured logger, the default logger is used when nil
+	Logger logr.Logger
+	// the period of the reconciliations repairing the targets which drifted, disabled when zero
+	ReconcilePeriod time.Duration
+	// sets the source as owner of the targets in its namespace, and a finalizer on it for the other targets
+	OwnerReferences bool
+	// when true, the existing objects which were not replicated can be overwritten by replicate-to
+	AdoptExisting bool
+	// the period of the writes of the status of the targets on their source, disabled when zero
+	SourceStatusPeriod time.Duration
+	// the number of times a write is retried with the latest version of the target after a conflict
+	ConflictRetries int
+	// the window during which the new namespaces are collected, before processing their sources once, disabled when zero
+	NamespaceDebounce time.Duration
+	// the number of targets of a source written in parallel
+	Concurrency int
+	// the maximum number of targets of a source, 0 for no limit
+	MaxTargetsPerSource int
+	// creates the clients impersonating the service accounts the targets are written as, can be nil
+	Clients *ClientFactory
+	// what to do with the replicated targets found orphaned after the initial synchronization
+	StartupOrphans OrphanAction
+	// how the objects are reduced before being cached
+	CacheTransform CacheTransform
+	// the number of objects of each page of the lists of the objects, 0 to list them at once
+	ListPageSize int64
+}
+
+// Returns the logger of the options, or the default logger
+func (options ReplicatorOptions) logger() logr.Logger {
+	if options.Logger == nil {
+		return logger
+	}
+	return options.Logger
+}
+
+// Returns the properties of a replicator of the named kind, configured by the options, with its state initialized
+func newReplicatorProps(name string, client kubernetes.Interface, options ReplicatorOptions) replicatorProps {
+	return replicatorProps{
+		Name:                name,
+		annotationNames:     annotationsWithPrefix(options.AnnotationsPrefix),
+		allowAll:            options.AllowAll,
+		instance:            options.Instance,
+		shards:              options.Shards,
+		client:              client,
+		metrics:             options.Metrics,
+		workers:             options.Workers,
+		reconcilePeriod:     options.ReconcilePeriod,
+		ownerReferences:     options.OwnerReferences,
+		adoptExisting:       options.AdoptExisting,
+		sourceStatusPeriod:  options.SourceStatusPeriod,
+		conflictRetries:     options.ConflictRetries,
+		namespaceDebounce:   options.NamespaceDebounce,
+		concurrency:         options.Concurrency,
+		maxTargetsPerSource: options.MaxTargetsPerSource,
+		clients:             options.Clients,
+		startupOrphans:      options.StartupOrphans,
+		cacheTransform:      options.CacheTransform,
+		listPageSize:        options.ListPageSize,
+
+		aliases: make(map[string]string),
+
+		collisions: make(map[collisionKey]string),
+		retries:    make(map[string]*retryState),
+		deleted:    make(map[string]interface{}),
+		updated:    make(map[string]interface{}),
+		syncedAt:   make(map[string]time.Time),
+
+		startupReport: &StartupReport{Kind: name, Orphans: []Orphan{}, Errors: []string{}},
+	}
+}
+
 type replicatorProps struct {
 	// displayed name for the resources
-	Name                string
+	Name string
+	// the names of the annotations for this kind
+	annotationNames
 	// when true, "allowed" annotations are ignored
-	allowAll            bool
+	allowAll bool
+	// the instance of the replicator, empty for objects without instance annotation
+	instance string
+	// assigns the objects without instance annotation to the instances, if not nil
+	shards *Shards
 	// the kubernetes client to use
-	client              kubernetes.Interface
-
-	// the store and controller for all the objects to watch replicate
-	objectStore         cache.Store
-	objectController    cache.Controller
+	client kubernetes.Interface
+	// the registry of the metrics
+	metrics *Metrics
+	// the structured logger, with the kind of the objects as field
+	logger logr.Logger
+	// the number of workers processing the queue
+	workers int
+	// the period of the reconciliations, disabled when zero
+	reconcilePeriod time.Duration
+	// when true, the targets are owned by their source
+	ownerReferences bool
+	// when true, the existing targets are adopted unless the source forbids it
+	adoptExisting bool
+	// the period of the writes of the status of the targets on their source, disabled when zero
+	sourceStatusPeriod time.Duration
+	// the number of times a write is retried with the latest version of the target after a conflict
+	conflictRetries int
+	// the window during which the sources watching the new namespaces are collected, disabled when zero
+	namespaceDebounce time.Duration
+	// the number of targets of a source written in parallel
+	concurrency int
+	// the maximum number of targets of a source, unless overridden by its annotation, 0 for no limit
+	maxTargetsPerSource int
+	// creates the clients writing the targets as service accounts, if not nil
+	clients *ClientFactory
+
+	// serializes the event handlers, to protect the derived state below
+	// the accessors which only read the state share it, while the handlers hold it exclusively
+	lock sync.RWMutex
+
+	// the store and controller for all the objects to watch replicate, indexed by the sources of their replicate-from annotation
+	objectStore      cache.Indexer
+	objectController cache.Controller
+	// lists the objects, populating the store
+	objectLister cache.ListerWatcher
 
 	// the store and controller for the namespaces
 	namespaceStore      cache.Store
 	namespaceController cache.Controller
-
-	// a {source => targets} map for the "replicate-from" annotation
-	targetsFrom         map[string][]string
-	// a {source => targets} map for the "replicate-to" annotation
-	targetsTo           map[string][]string
-
-	// a {source => targets} map for all the targeted objects
-	watchedTargets   map[string][]string
-	// a {source => targetPatterns} for all the targeted objects
-	watchedPatterns   map[string][]targetPattern
+	// lists the namespaces, populating the store
+	namespaceLister cache.ListerWatcher
+	// a namespace which does not exist yet, while simulating its creation
+	simulatedNamespace *v1.Namespace
+
+	// a {name => props} map of the other clusters, with their own client and store
+	clusters map[string]*replicatorProps
+
+	// the actions of the replicator with their wrappers, through which its actions write the objects of other kinds
+	actions replicatorActions
+
+	// the keys of the objects changed by the informer, processed by the workers
+	queue workqueue.RateLimitingInterface
+	// the backoff of the failing targets
+	rateLimiter workqueue.RateLimiter
+	// a {key => object} map of the deleted objects waiting in the queue
+	deleted map[string]interface{}
+	// a {key => object} map of the former versions of the updated objects waiting in the queue, guarded by the same lock
+	updated     map[string]interface{}
+	deletedLock sync.Mutex
+
+	// a {alias => source} map for the "replicated-alias" annotation
+	aliases map[string]string
+
+	// a {(source, target) => owner} map of the targets belonging to another object
+	collisions map[collisionKey]string
+	// a {target => state} map of the failing targets being retried
+	retries map[string]*retryState
+	// a {source => time} map of the last time all the targets of the sources were synchronized
+	syncedAt map[string]time.Time
+	// a {source => sync} map of the versions of the sources released to their targets, for the scheduled sources
+	syncs map[string]sourceSync
+	// a {target => recreation} map of the targets recreated after being deleted out-of-band
+	recreations map[string]recreation
+	// the targets whose data was modified since they were last processed, guarded by the status lock
+	editedTargets map[string]bool
+	// a {target => version} map of the versions of the sources waiting for their approval, guarded by the status lock
+	pendingApprovals map[string]string
+	// the TLS sources whose certificate expiry is exported as metric
+	certificates map[string]bool
+	// a {source => versions} map of the sources whose status annotation was written since their last change
+	statusVersions map[string]statusVersion
+	statusLock     sync.Mutex
+
+	// the targets being installed in parallel, and the lock held by the goroutines installing them outside of their writes
+	fanoutTargets map[string]bool
+	fanoutLock    sync.Mutex
+
+	// what to do with the replicated targets found orphaned after the initial synchronization
+	startupOrphans OrphanAction
+	// how the objects are reduced before being cached
+	cacheTransform CacheTransform
+	// the number of objects of each page of the lists of the objects, 0 to list them at once
+	listPageSize int64
+	// the report of the initial synchronization
+	startupReport *StartupReport
+	// true once the initial synchronization is reported
+	startupDone bool
+	// the keys of the objects listed at startup which are not processed yet, closing startupProcessed once empty
+	startupPending   map[string]bool
+	startupProcessed chan struct{}
+	startupLock      sync.Mutex
 }
 
 // Replicator describes the common interface that the secret and configmap
@@ -82,53 +313,113 @@ type replicatorProps struct {
 type Replicator interface {
 	Start()
 	Synced() bool
+	Collisions() []Collision
+	SimulateNamespace(namespace *v1.Namespace) []SimulatedTarget
+	Orphans(action OrphanAction) ([]Orphan, error)
+	Replications() []Replication
+	Topology() Topology
+	MatchNamespaces(pattern string) ([]string, error)
+	StartupReport() *StartupReport
+	Resolve(kind string, raw []byte) ([]byte, bool, error)
+	Validate(kind string, raw []byte) error
+	Stamp(kind string, raw []byte, oldRaw []byte, username string) ([]byte, bool, error)
+	RenameLegacy(kind string, raw []byte) ([]byte, bool, error)
+	Explain(kind string, key string) ([]string, bool, error)
+	MigrateAnnotations() ([]Migration, error)
+}
+
+// Returns true if the object is managed by this instance of the replicator
+func (r *replicatorProps) isManaged(object *metav1.ObjectMeta) bool {
+	if instance, ok := object.Annotations[r.ReplicatorInstanceAnnotation]; ok {
+		return instance == r.instance
+		// objects without annotation are shared between the instances
+	} else if r.shards != nil {
+		return r.shards.Owner(fmt.Sprintf("%s/%s", object.Namespace, object.Name)) == r.instance
+	} else {
+		return r.instance == ""
+	}
+}
+
+// Sets the instance of the replicator on a target
+func (r *replicatorProps) stampInstance(object *metav1.ObjectMeta) {
+	if r.instance == "" {
+		delete(object.Annotations, r.ReplicatorInstanceAnnotation)
+	} else {
+		object.Annotations[r.ReplicatorInstanceAnnotation] = r.instance
+	}
+}
+
+// the error of a source which does not allow the replication, unlike its invalid annotations
+type deniedError struct {
+	message string
+}
+
+func (e *deniedError) Error() string {
+	return e.message
+}
+
+func deniedf(format string, args ...interface{}) error {
+	return &deniedError{fmt.Sprintf(format, args...)}
+}
+
+// Returns true if the error is a denial of the replication by the source
+func isDenied(err error) bool {
+	_, ok := err.(*deniedError)
+	return ok
 }
 
 // Checks if replication is allowed in annotations of the source object
 // It means that replication-allowes and replications-allowed-namespaces are correct
 // Returns true if replication is allowed.
-// If replication is not allowed returns false with error message
+// If replication is not allowed returns false with error message, a denied error when the source denies it
 func (r *replicatorProps) isReplicationAllowed(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, error) {
-	annotationAllowed, ok := sourceObject.Annotations[ReplicationAllowed]
-	annotationAllowedNs, okNs := sourceObject.Annotations[ReplicationAllowedNamespaces]
-	// unless allowAll, explicit permission is required
-	if !r.allowAll && !ok && !okNs {
-		return false, fmt.Errorf("source %s/%s does not explicitely allow replication",
+	annotationAllowed, ok := sourceObject.Annotations[r.ReplicationAllowed]
+	_, okNs := sourceObject.Annotations[r.ReplicationAllowedNamespaces]
+	_, okExclude := sourceObject.Annotations[r.ReplicationAllowedNamespacesExclude]
+	// unless allowAll, explicit permission is required, from the annotations or from a rule
+	if !r.allowAll && !ok && !okNs && !okExclude && !r.ruleAllows(sourceObject, object.Namespace) {
+		return false, deniedf("source %s/%s does not explicitely allow replication",
 			sourceObject.Namespace, sourceObject.Name)
 	}
 	// check allow annotation
 	if ok {
 		if val, err := strconv.ParseBool(annotationAllowed); err != nil {
 			return false, fmt.Errorf("source %s/%s has illformed annotation %s (%s): %s",
-				sourceObject.Namespace, sourceObject.Name, ReplicationAllowed, annotationAllowed, err)
+				sourceObject.Namespace, sourceObject.Name, r.ReplicationAllowed, annotationAllowed, err)
 		} else if !val {
-			return false, fmt.Errorf("source %s/%s explicitely disallow replication",
+			return false, deniedf("source %s/%s explicitely disallow replication",
 				sourceObject.Namespace, sourceObject.Name)
 		}
 	}
 	// check allow-namespaces annotation
 	if okNs {
-		allowed := false
-		for _, ns := range strings.Split(annotationAllowedNs, ",") {
-			if ns == "" {
-			} else if validName.MatchString(ns) {
-				if ns == object.Namespace {
-					allowed = true
-				}
-			} else if ok, err := regexp.MatchString(`^(?:`+ns+`)$`, object.Namespace); ok {
-				allowed = true
-			} else if err != nil {
-				return false, fmt.Errorf("source %s/%s has compilation error on annotation %s (%s): %s",
-					sourceObject.Namespace, sourceObject.Name, ReplicationAllowedNamespaces, ns, err)
-			}
+		if allowed, err := r.matchesNamespaces(sourceObject, r.ReplicationAllowedNamespaces, object.Namespace); err != nil {
+			return false, err
+		} else if !allowed {
+			return false, deniedf("source %s/%s does not allow replication to namespace %s",
+				sourceObject.Namespace, sourceObject.Name, object.Namespace)
 		}
-		if !allowed {
-			return false, fmt.Errorf("source %s/%s does not allow replication to namespace %s",
+	}
+	// check allow-namespaces-exclude annotation, which wins over the allowed namespaces
+	if okExclude {
+		if excluded, err := r.matchesNamespaces(sourceObject, r.ReplicationAllowedNamespacesExclude, object.Namespace); err != nil {
+			return false, err
+		} else if excluded {
+			return false, deniedf("source %s/%s excludes replication to namespace %s",
 				sourceObject.Namespace, sourceObject.Name, object.Namespace)
 		}
 	}
+	// check allowed-service-accounts annotation, against the requester stamped on the target by the admission webhook
+	if _, ok := sourceObject.Annotations[r.ReplicationAllowedServiceAccounts]; ok {
+		if allowed, err := r.isRequesterAllowed(object, sourceObject); err != nil {
+			return false, err
+		} else if !allowed {
+			return false, deniedf("source %s/%s does not allow replication requested by %s",
+				sourceObject.Namespace, sourceObject.Name, r.requester(object))
+		}
+	}
 	// source cannot have "replicate-from" annotation
-	if val, ok := resolveAnnotation(sourceObject, ReplicateFromAnnotation); ok {
+	if val, ok := resolveAnnotation(sourceObject, r.ReplicateFromAnnotation); ok {
 		return false, fmt.Errorf("source %s/%s is already replicated from %s",
 			sourceObject.Namespace, sourceObject.Name, val)
 	}
@@ -136,60 +427,145 @@ func (r *replicatorProps) isReplicationAllowed(object *metav1.ObjectMeta, source
 	return true, nil
 }
 
+// Returns true if the namespace is one of the comma separated namespaces or namespace patterns of the annotation of the source
+func (r *replicatorProps) matchesNamespaces(sourceObject *metav1.ObjectMeta, annotation string, namespace string) (bool, error) {
+	matched := false
+	for _, ns := range strings.Split(sourceObject.Annotations[annotation], ",") {
+		if ns == "" {
+		} else if validName.MatchString(ns) {
+			if ns == namespace {
+				matched = true
+			}
+		} else if pattern, err := r.compilePattern(sourceObject, ns); err == nil {
+			if pattern.MatchString(namespace) {
+				matched = true
+			}
+		} else {
+			return false, fmt.Errorf("source %s/%s has compilation error on annotation %s (%s): %s",
+				sourceObject.Namespace, sourceObject.Name, annotation, ns, err)
+		}
+	}
+	return matched, nil
+}
+
+// Checks if the target requires an approval to receive the data of the source
+// The approval is required when the target or the source has the approval-required annotation,
+// and given by setting the approved-version annotation of the target to the version of the source
+// Returns true if no approval is required, or if this version of the source is approved
+func (r *replicatorProps) isApproved(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, error) {
+	required := false
+	for _, meta := range []*metav1.ObjectMeta{object, sourceObject} {
+		if val, ok := meta.Annotations[r.ReplicateApprovalRequiredAnnotation]; !ok {
+		} else if b, err := strconv.ParseBool(val); err != nil {
+			return false, fmt.Errorf("%s/%s has illformed annotation %s (%s): %s",
+				meta.Namespace, meta.Name, r.ReplicateApprovalRequiredAnnotation, val, err)
+		} else if b {
+			required = true
+		}
+	}
+
+	if !required {
+		return true, nil
+	} else if r.isSourceVersion(sourceObject, object.Annotations[r.ReplicateApprovedVersionAnnotation]) {
+		return true, nil
+	}
+
+	return false, fmt.Errorf("target %s/%s is waiting for the approval of version %s of source %s/%s",
+		object.Namespace, object.Name, sourceObject.ResourceVersion, sourceObject.Namespace, sourceObject.Name)
+}
+
+// Records that the target waits for the approval of the version of its source
+// Returns true if it was not already waiting for it, so that the wait is only reported once
+func (r *replicatorProps) awaitApproval(target string, version string) bool {
+	r.statusLock.Lock()
+	defer r.statusLock.Unlock()
+
+	if r.pendingApprovals == nil {
+		r.pendingApprovals = map[string]string{}
+	}
+	if pending, ok := r.pendingApprovals[target]; ok && pending == version {
+		return false
+	}
+	r.pendingApprovals[target] = version
+	return true
+}
+
+// Forgets the approval the target was waiting for, once it does not wait anymore
+func (r *replicatorProps) approvalDone(target string) {
+	r.statusLock.Lock()
+	defer r.statusLock.Unlock()
+	delete(r.pendingApprovals, target)
+}
+
 // Checks that data update is needed
 // Returns true if update is needed
 // If update is not needed returns false with error message
-func (r *replicatorProps) needsDataUpdate(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, bool, error) {
+// The hashes detect the targets modified since replicated, and the sources which changed but not their data
+func (r *replicatorProps) needsDataUpdate(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta, hashes dataHashes) (bool, bool, error) {
+	modified := r.isDataModified(object, hashes)
 	// target was "replicated" from a delete source, or never replicated
-	if targetVersion, ok := object.Annotations[ReplicatedFromVersionAnnotation]; !ok {
+	if targetVersion, ok := object.Annotations[r.ReplicatedFromVersionAnnotation]; !ok {
 		return true, false, nil
-	// target and source share the same version
-	} else if ok && targetVersion == sourceObject.ResourceVersion {
+		// target and source share the same version, and the target was not modified since
+	} else if ok && !modified && r.isSourceVersion(sourceObject, targetVersion) {
 		return false, false, fmt.Errorf("target %s/%s is already up-to-date", object.Namespace, object.Name)
+		// the source changed, but not its data
+	} else if r.onlyVersionChanged(object, sourceObject, hashes) {
+		return false, false, fmt.Errorf("target %s/%s already has the data of version %s of source %s/%s",
+			object.Namespace, object.Name, sourceObject.ResourceVersion, sourceObject.Namespace, sourceObject.Name)
 	}
 
 	hasOnce := false
 	// no once annotation, nothing to check
-	if annotationOnce, ok := sourceObject.Annotations[ReplicateOnceAnnotation]; !ok {
-	// once annotation is not a boolean
+	if annotationOnce, ok := sourceObject.Annotations[r.ReplicateOnceAnnotation]; !ok {
+		// once annotation is not a boolean
 	} else if once, err := strconv.ParseBool(annotationOnce); err != nil {
 		return false, false, fmt.Errorf("source %s/%s has illformed annotation %s: %s",
-			sourceObject.Namespace, sourceObject.Name, ReplicateOnceAnnotation, err)
-	// once annotation is present
+			sourceObject.Namespace, sourceObject.Name, r.ReplicateOnceAnnotation, err)
+		// once annotation is present
 	} else if once {
 		hasOnce = true
 	}
 	// no once annotation, nothing to check
-	if annotationOnce, ok := object.Annotations[ReplicateOnceAnnotation]; !ok {
-	// once annotation is not a boolean
+	if annotationOnce, ok := object.Annotations[r.ReplicateOnceAnnotation]; !ok {
+		// once annotation is not a boolean
 	} else if once, err := strconv.ParseBool(annotationOnce); err != nil {
 		return false, false, fmt.Errorf("target %s/%s has illformed annotation %s: %s",
-			object.Namespace, object.Name, ReplicateOnceAnnotation, err)
-	// once annotation is present
+			object.Namespace, object.Name, r.ReplicateOnceAnnotation, err)
+		// once annotation is present
 	} else if once {
 		hasOnce = true
 	}
 
 	if !hasOnce {
-	// no once version annotation in the source, only replicate once
-	} else if annotationVersion, ok := sourceObject.Annotations[ReplicateOnceVersionAnnotation]; !ok {
-	// once version annotation is not a valid version
+		// no once version annotation in the source, only replicate once
+	} else if annotationVersion, ok := sourceObject.Annotations[r.ReplicateOnceVersionAnnotation]; !ok {
+		// the versions of the external sources are identifiers of their latest version, the target is replicated again
+		// when it changes
+	} else if _, external := externalSources[sourceObject.Namespace]; external {
+		if object.Annotations[r.ReplicateOnceVersionAnnotation] != annotationVersion {
+			hasOnce = false
+		} else {
+			return false, true, fmt.Errorf("target %s/%s is already replicated once at version %s",
+				object.Namespace, object.Name, annotationVersion)
+		}
+		// once version annotation is not a valid version
 	} else if sourceVersion, err := semver.NewVersion(annotationVersion); err != nil {
 		return false, false, fmt.Errorf("source %s/%s has illformed annotation %s: %s",
-			sourceObject.Namespace, sourceObject.Name, ReplicateOnceVersionAnnotation, err)
-	// the source has a once version annotation but it is "0.0.0" anyway
+			sourceObject.Namespace, sourceObject.Name, r.ReplicateOnceVersionAnnotation, err)
+		// the source has a once version annotation but it is "0.0.0" anyway
 	} else if version0, _ := semver.NewVersion("0"); sourceVersion.Equal(version0) {
-	// no once version annotation in the target, should update
-	} else if annotationVersion, ok := object.Annotations[ReplicateOnceVersionAnnotation]; !ok {
+		// no once version annotation in the target, should update
+	} else if annotationVersion, ok := object.Annotations[r.ReplicateOnceVersionAnnotation]; !ok {
 		hasOnce = false
-	// once version annotation is not a valid version
+		// once version annotation is not a valid version
 	} else if targetVersion, err := semver.NewVersion(annotationVersion); err != nil {
 		return false, false, fmt.Errorf("target %s/%s has illformed annotation %s: %s",
-			object.Namespace, object.Name, ReplicateOnceVersionAnnotation, err)
-	// source version is greatwe than source version, should update
+			object.Namespace, object.Name, r.ReplicateOnceVersionAnnotation, err)
+		// source version is greatwe than source version, should update
 	} else if sourceVersion.GreaterThan(targetVersion) {
 		hasOnce = false
-	// source version is not greater than target version
+		// source version is not greater than target version
 	} else {
 		return false, true, fmt.Errorf("target %s/%s is already replicated once at version %s",
 			object.Namespace, object.Name, sourceVersion)
@@ -209,46 +585,70 @@ func (r *replicatorProps) needsDataUpdate(object *metav1.ObjectMeta, sourceObjec
 func (r *replicatorProps) needsFromAnnotationsUpdate(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, error) {
 	update := false
 	// check "from" annotation of the source
-	if source, sOk := resolveAnnotation(sourceObject, ReplicateFromAnnotation); !sOk {
+	if source, sOk := resolveAnnotation(sourceObject, r.ReplicateFromAnnotation); !sOk {
 		return false, fmt.Errorf("source %s/%s misses annotation %s",
-			sourceObject.Namespace, sourceObject.Name, ReplicateFromAnnotation)
+			sourceObject.Namespace, sourceObject.Name, r.ReplicateFromAnnotation)
 
-	} else if !validPath.MatchString(source) ||
-			source == fmt.Sprintf("%s/%s", sourceObject.Namespace, sourceObject.Name) {
+	} else if !validSourcePaths(source) ||
+		hasSource(strings.Split(source, ","), fmt.Sprintf("%s/%s", sourceObject.Namespace, sourceObject.Name)) {
 		return false, fmt.Errorf("source %s/%s has invalid annotation %s (%s)",
-			sourceObject.Namespace, sourceObject.Name, ReplicateFromAnnotation, source)
+			sourceObject.Namespace, sourceObject.Name, r.ReplicateFromAnnotation, source)
 
-	// check that target has the same annotation
-	} else if val, ok := object.Annotations[ReplicateFromAnnotation]; !ok || val != source {
+		// check that target has the same annotation
+	} else if val, ok := object.Annotations[r.ReplicateFromAnnotation]; !ok || val != source {
 		update = true
 	}
 
-	source, sOk := sourceObject.Annotations[ReplicateOnceAnnotation]
+	source, sOk := sourceObject.Annotations[r.ReplicateOnceAnnotation]
 	// check "once" annotation of the source
 	if sOk {
 		if _, err := strconv.ParseBool(source); err != nil {
 			return false, fmt.Errorf("source %s/%s has illformed annotation %s: %s",
-				sourceObject.Namespace, sourceObject.Name, ReplicateOnceAnnotation, err)
+				sourceObject.Namespace, sourceObject.Name, r.ReplicateOnceAnnotation, err)
 		}
 	}
 	// check that target has the same annotation
-	if val, ok := object.Annotations[ReplicateOnceAnnotation]; sOk != ok || ok && val != source {
+	if val, ok := object.Annotations[r.ReplicateOnceAnnotation]; sOk != ok || ok && val != source {
+		update = true
+	}
+	// the target must not keep allow annotations propagated by a former replication
+	if _, ok := object.Annotations[r.ReplicationAllowed]; ok {
+		update = true
+	} else if _, ok := object.Annotations[r.ReplicationAllowedNamespaces]; ok {
+		update = true
+	} else if _, ok := object.Annotations[r.ReplicationAllowedNamespacesExclude]; ok {
+		update = true
+	} else if _, ok := object.Annotations[r.ReplicationAllowedServiceAccounts]; ok {
 		update = true
 	}
 
 	return update, nil
 }
 
+// Checks that replication-allowed annotations of the target differ from the source
+// Returns true if update is needed
+// Returns an error if a source annotation is illformed, but update is still needed
+// as the illformed annotation revokes the replication from the target
 func (r *replicatorProps) needsAllowedAnnotationsUpdate(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, error) {
 	update := false
 
-	allowed, okA := sourceObject.Annotations[ReplicationAllowed]
-	if val, ok := object.Annotations[ReplicationAllowed]; ok != okA || ok && val != allowed {
+	allowed, okA := sourceObject.Annotations[r.ReplicationAllowed]
+	if val, ok := object.Annotations[r.ReplicationAllowed]; ok != okA || ok && val != allowed {
+		update = true
+	}
+
+	allowedNs, okNs := sourceObject.Annotations[r.ReplicationAllowedNamespaces]
+	if val, ok := object.Annotations[r.ReplicationAllowedNamespaces]; ok != okNs || ok && val != allowedNs {
+		update = true
+	}
+
+	excludedNs, okExclude := sourceObject.Annotations[r.ReplicationAllowedNamespacesExclude]
+	if val, ok := object.Annotations[r.ReplicationAllowedNamespacesExclude]; ok != okExclude || ok && val != excludedNs {
 		update = true
 	}
 
-	allowedNs, okNs := sourceObject.Annotations[ReplicationAllowedNamespaces]
-	if val, ok := object.Annotations[ReplicationAllowedNamespaces]; ok != okNs || ok && val != allowedNs {
+	accounts, okAccounts := sourceObject.Annotations[r.ReplicationAllowedServiceAccounts]
+	if val, ok := object.Annotations[r.ReplicationAllowedServiceAccounts]; ok != okAccounts || ok && val != accounts {
 		update = true
 	}
 
@@ -259,20 +659,20 @@ func (r *replicatorProps) needsAllowedAnnotationsUpdate(object *metav1.ObjectMet
 	// check allow annotation
 	if okA {
 		if _, err := strconv.ParseBool(allowed); err != nil {
-			return false, fmt.Errorf("source %s/%s has illformed annotation %s (%s): %s",
-				sourceObject.Namespace, sourceObject.Name, ReplicationAllowed, allowed, err)
+			return true, fmt.Errorf("source %s/%s has illformed annotation %s (%s): %s",
+				sourceObject.Namespace, sourceObject.Name, r.ReplicationAllowed, allowed, err)
 		}
 	}
-	// check allow-namespaces annotation
-	if okNs {
-		for _, ns := range strings.Split(allowedNs, ",") {
-			if ns == "" || validName.MatchString(ns) {
-			} else if _, err := regexp.Compile(`^(?:`+ns+`)$`); err != nil {
-				return false, fmt.Errorf("source %s/%s has compilation error on annotation %s (%s): %s",
-					sourceObject.Namespace, sourceObject.Name, ReplicationAllowedNamespaces, ns, err)
-			}
+	// check allow-namespaces and allow-namespaces-exclude annotations
+	for _, annotation := range []string{r.ReplicationAllowedNamespaces, r.ReplicationAllowedNamespacesExclude} {
+		if _, err := r.matchesNamespaces(sourceObject, annotation, ""); err != nil {
+			return true, err
 		}
 	}
+	// check allowed-service-accounts annotation
+	if _, err := r.isRequesterAllowed(sourceObject, sourceObject); err != nil {
+		return true, err
+	}
 
 	return true, nil
 }
@@ -283,11 +683,29 @@ func (r *replicatorProps) needsAllowedAnnotationsUpdate(object *metav1.ObjectMet
 // If replication is not allowed returns false with error message
 func (r *replicatorProps) isReplicatedBy(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, error) {
 	// make sure that the target object was created from the source
-	if annotationFrom, ok := object.Annotations[ReplicatedByAnnotation]; !ok {
+	if annotationFrom, ok := object.Annotations[r.ReplicatedByAnnotation]; !ok {
 		return false, fmt.Errorf("target %s/%s was not replicated",
 			object.Namespace, object.Name)
 
 	} else if annotationFrom != fmt.Sprintf("%s/%s", sourceObject.Namespace, sourceObject.Name) {
+		// the target may have been replicated by the source under a former name
+		aliases, err := r.getAliases(sourceObject)
+		if err != nil {
+			return false, err
+		}
+		for _, alias := range aliases {
+			if alias != annotationFrom {
+				continue
+			}
+			// the targets of a source which still exists cannot be taken over
+			if _, exists, err := r.cachedByKey(alias); err != nil {
+				return false, err
+			} else if exists {
+				return false, fmt.Errorf("target %s/%s is replicated from %s, which still exists",
+					object.Namespace, object.Name, alias)
+			}
+			return true, nil
+		}
 		return false, fmt.Errorf("target %s/%s was not replicated from %s/%s",
 			object.Namespace, object.Name, sourceObject.Namespace, sourceObject.Name)
 	}
@@ -295,7 +713,6 @@ func (r *replicatorProps) isReplicatedBy(object *metav1.ObjectMeta, sourceObject
 	return true, nil
 }
 
-
 // Checks if the object is replicated to the target
 // Returns an error only if the annotations are invalid
 func (r *replicatorProps) isReplicatedTo(object *metav1.ObjectMeta, targetObject *metav1.ObjectMeta) (bool, error) {
@@ -324,59 +741,99 @@ func (r *replicatorProps) isReplicatedTo(object *metav1.ObjectMeta, targetObject
 }
 
 // Returns everything needed to compute the desired targets
-// - targets: a slice of all fully qualified target. Items are unique, does not contain object itself
-// - targetPatterns: a slice of targetPattern, using regex to identify if a namespace is matched
-//                   two patterns can generate the same target, and even the object itself
+//   - targets: a slice of all fully qualified target. Items are unique, does not contain object itself
+//   - targetPatterns: a slice of targetPattern, using regex to identify if a namespace is matched
+//     two patterns can generate the same target, and even the object itself
 func (r *replicatorProps) getReplicationTargets(object *metav1.ObjectMeta) ([]string, []targetPattern, error) {
-	annotationTo, okTo := object.Annotations[ReplicateToAnnotation]
-	annotationToNs, okToNs := object.Annotations[ReplicateToNamespacesAnnotation]
-	if !okTo && !okToNs {
+	// the targets of a converted source are installed by the replicator of the other kind
+	if kind, err := r.convertedKind(object); err != nil {
+		return nil, nil, err
+	} else if kind != "" {
 		return nil, nil, nil
 	}
+	annotationTo, okTo := object.Annotations[r.ReplicateToAnnotation]
+	annotationToNs, okToNs := object.Annotations[r.ReplicateToNamespacesAnnotation]
+	annotationToSelector, okToSelector := object.Annotations[r.ReplicateToNamespacesSelectorAnnotation]
+	// without annotations, the object may be cloned with its namespace
+	if !okTo && !okToNs && !okToSelector {
+		// or replicated to all the namespaces as a pull secret
+		if pattern, selector, ok := r.pullSecretTargets(object); ok {
+			annotationToNs, okToNs = pattern, pattern != ""
+			annotationToSelector, okToSelector = selector, selector != ""
+			// or selected by a rule of the rules file
+		} else if rule := r.matchRule(object); rule != nil {
+			annotationToNs, okToNs = rule.TargetNamespaces, rule.TargetNamespaces != ""
+			annotationToSelector, okToSelector = rule.TargetSelector, rule.TargetSelector != ""
+		} else if patterns, ok, err := r.namespaceClonedTo(object); err != nil {
+			return nil, nil, err
+		} else if !ok {
+			return nil, nil, nil
+		} else {
+			annotationToNs, okToNs = patterns, true
+		}
+	}
 
 	key := fmt.Sprintf("%s/%s", object.Name, object.Namespace)
+	source := fmt.Sprintf("%s/%s", object.Namespace, object.Name)
+	nameTemplate, err := r.targetNameTemplate(object)
+	if err != nil {
+		return nil, nil, err
+	}
+	// renders the name of a target in a namespace, if the source has a name template
+	targetName := func(namespace string, name string) (string, error) {
+		if nameTemplate == nil {
+			return name, nil
+		} else if rendered, err := renderTargetName(nameTemplate, source, namespace, name); err != nil {
+			return "", fmt.Errorf("source %s has invalid template on annotation %s (%s): %s",
+				key, r.ReplicateToNameAnnotation, object.Annotations[r.ReplicateToNameAnnotation], err)
+		} else {
+			return rendered, nil
+		}
+	}
 	targets := []string{}
 	targetPatterns := []targetPattern{}
 	// cache of patterns, to reuse them as much as possible
 	compiledPatterns := map[string]*regexp.Regexp{}
-	for _, pattern := range r.watchedPatterns[key] {
-		compiledPatterns[pattern.namespace.String()] = pattern.namespace
-	}
 	// which qualified paths have already been seen (exclude the object itself)
 	seen := map[string]bool{key: true}
 	var names, namespaces, qualified map[string]bool
 	// no target explecitely provided, assumed that targets will have the same name
 	if !okTo {
 		names = map[string]bool{object.Name: true}
-	// split the targets, and check which one are qualified
+		// split the targets, and check which one are qualified
 	} else {
 		names = map[string]bool{}
 		qualified = map[string]bool{}
 		for _, n := range strings.Split(annotationTo, ",") {
 			if n == "" {
-			// a qualified name, with a namespace part
+				// an external destination, written by pushToExternal
+			} else if _, _, ok := externalDestinationOf(n); ok {
+				// a qualified name, with a namespace part
 			} else if strings.ContainsAny(n, "/") {
 				qualified[n] = true
-			// a valid name
+				// a valid name
 			} else if validName.MatchString(n) {
 				names[n] = true
-			// raise error
+				// raise error
 			} else {
 				return nil, nil, fmt.Errorf("source %s has invalid name on annotation %s (%s)",
-					key, ReplicateToAnnotation, n)
+					key, r.ReplicateToAnnotation, n)
 			}
 		}
 	}
 	// no target namespace provided, assume that the namespace is the same (or qualified in the name)
-	if !okToNs {
+	if !okToNs && !okToSelector {
 		namespaces = map[string]bool{object.Namespace: true}
-	// split the target namespaces
+		// only selected namespaces
+	} else if !okToNs {
+		namespaces = map[string]bool{}
+		// split the target namespaces
 	} else {
 		namespaces = map[string]bool{}
 		for _, ns := range strings.Split(annotationToNs, ",") {
 			if strings.ContainsAny(ns, "/") {
 				return nil, nil, fmt.Errorf("source %s has invalid namespace pattern on annotation %s (%s)",
-					key, ReplicateToNamespacesAnnotation, ns)
+					key, r.ReplicateToNamespacesAnnotation, ns)
 			} else if ns != "" {
 				namespaces[ns] = true
 			}
@@ -386,77 +843,159 @@ func (r *replicatorProps) getReplicationTargets(object *metav1.ObjectMeta) ([]st
 	for ns := range namespaces {
 		// this namespace is not a pattern
 		if validName.MatchString(ns) {
-			ns = ns + "/"
 			for n := range names {
-				full := ns + n
+				name, err := targetName(ns, n)
+				if err != nil {
+					return nil, nil, err
+				}
+				full := ns + "/" + name
 				if !seen[full] {
 					seen[full] = true
 					targets = append(targets, full)
 				}
 			}
-		// this namespace is a pattern
-		} else if pattern, err := regexp.Compile(`^(?:`+ns+`)$`); err == nil {
+			// this namespace is a pattern
+		} else if pattern, err := r.compilePattern(object, ns); err == nil {
 			compiledPatterns[ns] = pattern
 			ns = ns + "/"
 			for n := range names {
 				full := ns + n
 				if !seen[full] {
 					seen[full] = true
-					targetPatterns = append(targetPatterns, targetPattern{pattern, n})
+					targetPatterns = append(targetPatterns, targetPattern{namespace: pattern, name: n,
+						nameTemplate: nameTemplate, source: source})
 				}
 			}
-		// raise compilation error
+			// raise compilation error
 		} else {
 			return nil, nil, fmt.Errorf("source %s has compilation error on annotation %s (%s): %s",
-				key, ReplicateToNamespacesAnnotation, ns, err)
+				key, r.ReplicateToNamespacesAnnotation, ns, err)
+		}
+	}
+	// the namespaces selected by their labels
+	if okToSelector {
+		selector, err := labels.Parse(annotationToSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("source %s has invalid selector on annotation %s (%s): %s",
+				key, r.ReplicateToNamespacesSelectorAnnotation, annotationToSelector, err)
+		}
+		for n := range names {
+			targetPatterns = append(targetPatterns, targetPattern{selector: selector, labels: r.namespaceLabels, name: n,
+				nameTemplate: nameTemplate, source: source})
 		}
 	}
 	// for all the qualified names, check if the namespace part is a pattern
 	for q := range qualified {
 		if seen[q] {
-		// check that there is exactly one "/"
+			// check that there is exactly one "/"
 		} else if qs := strings.SplitN(q, "/", 3); len(qs) != 2 {
 			return nil, nil, fmt.Errorf("source %s has invalid path on annotation %s (%s)",
-				key, ReplicateToAnnotation, q)
-		// check that the name part is valid
+				key, r.ReplicateToAnnotation, q)
+			// check that the name part is valid
 		} else if n := qs[1]; !validName.MatchString(n) {
 			return nil, nil, fmt.Errorf("source %s has invalid name on annotation %s (%s)",
-				key, ReplicateToAnnotation, n)
-		// check if the namespace is a pattern
+				key, r.ReplicateToAnnotation, n)
+			// check if the namespace is a pattern
 		} else if ns := qs[0]; validName.MatchString(ns) {
-			targets = append(targets, q)
-		// check if this pattern is already compiled
+			name, err := targetName(ns, n)
+			if err != nil {
+				return nil, nil, err
+			}
+			if full := ns + "/" + name; !seen[full] {
+				seen[full] = true
+				targets = append(targets, full)
+			}
+			// check if this pattern is already compiled
 		} else if pattern, ok := compiledPatterns[ns]; ok {
-			targetPatterns = append(targetPatterns, targetPattern{pattern, n})
-		// check that the pattern compiles
-		} else if pattern, err := regexp.Compile(`^(?:`+ns+`)$`); err == nil {
+			targetPatterns = append(targetPatterns, targetPattern{namespace: pattern, name: n,
+				nameTemplate: nameTemplate, source: source})
+			// check that the pattern compiles
+		} else if pattern, err := r.compilePattern(object, ns); err == nil {
 			compiledPatterns[ns] = pattern
-			targetPatterns = append(targetPatterns, targetPattern{pattern, n})
-		// raise compilation error
+			targetPatterns = append(targetPatterns, targetPattern{namespace: pattern, name: n,
+				nameTemplate: nameTemplate, source: source})
+			// raise compilation error
 		} else {
 			return nil, nil, fmt.Errorf("source %s has compilation error on annotation %s (%s): %s",
-				key, ReplicateToAnnotation, ns, err)
+				key, r.ReplicateToAnnotation, ns, err)
 		}
 	}
 
 	return targets, targetPatterns, nil
 }
 
-// Returns an annotation as "namespace/name" format
+// Returns an annotation as "namespace/name" format, or "cluster:namespace/name" for another cluster
+// A comma separated list of paths is resolved as a comma separated list
 func resolveAnnotation(object *metav1.ObjectMeta, annotation string) (string, bool) {
-	if val, ok := object.Annotations[annotation]; !ok {
+	val, ok := object.Annotations[annotation]
+	if !ok {
 		return "", false
+	} else if !strings.Contains(val, ",") {
+		return resolvePath(object, val), true
+	}
+
+	paths := []string{}
+	for _, path := range strings.Split(val, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, resolvePath(object, path))
+		}
+	}
+	return strings.Join(paths, ","), true
+}
+
+// Returns a path as "namespace/name" format, or "cluster:namespace/name" for another cluster
+func resolvePath(object *metav1.ObjectMeta, val string) string {
+	if _, _, ok := externalSourceOf(val); ok {
+		return val
 	} else if strings.ContainsAny(val, "/") {
-		return val, true
+		return val
+	} else if parts := strings.SplitN(val, ":", 2); len(parts) == 2 {
+		return fmt.Sprintf("%s:%s/%s", parts[0], object.Namespace, parts[1])
 	} else {
-		return fmt.Sprintf("%s/%s", object.Namespace, val), true
+		return fmt.Sprintf("%s/%s", object.Namespace, val)
 	}
 }
 
+// Returns the former paths of the object, from the "replicated-alias" annotation
+// A name without namespace is assumed to be in the namespace of the object,
+// which is the only namespace an alias may point to
+func (r *replicatorProps) getAliases(object *metav1.ObjectMeta) ([]string, error) {
+	annotationAlias, ok := object.Annotations[r.ReplicatedAliasAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	key := fmt.Sprintf("%s/%s", object.Namespace, object.Name)
+	aliases := []string{}
+	for _, alias := range strings.Split(annotationAlias, ",") {
+		if alias == "" {
+			continue
+		} else if !strings.ContainsAny(alias, "/") {
+			alias = fmt.Sprintf("%s/%s", object.Namespace, alias)
+		}
+
+		if !validPath.MatchString(alias) || alias == key {
+			return nil, fmt.Errorf("source %s has invalid path on annotation %s (%s)",
+				key, r.ReplicatedAliasAnnotation, alias)
+		}
+		// nothing authorizes an object to take over the targets of a source of another namespace
+		if !strings.HasPrefix(alias, object.Namespace+"/") {
+			return nil, fmt.Errorf("source %s cannot declare an alias outside of its namespace on annotation %s (%s)",
+				key, r.ReplicatedAliasAnnotation, alias)
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, nil
+}
+
 // Returns true if the annotation from the object references the other object
 func annotationRefersTo(object *metav1.ObjectMeta, annotation string, reference *metav1.ObjectMeta) bool {
 	if val, ok := object.Annotations[annotation]; !ok {
 		return false
+	} else if strings.Contains(val, ",") {
+		val, _ = resolveAnnotation(object, annotation)
+		return hasSource(strings.Split(val, ","), fmt.Sprintf("%s/%s", reference.Namespace, reference.Name))
 	} else if v := strings.SplitN(val, "/", 2); len(v) == 2 {
 		return v[0] == reference.Namespace && v[1] == reference.Name
 	} else {
@@ -0,0 +1,140 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestReplicationAllowedNamespacesExclude(t *testing.T) {
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicationAllowedNamespacesExclude: "kube-.*,restricted",
+	}}
+
+	// the exclusion alone allows the other namespaces
+	for namespace, expected := range map[string]bool{"team-a": true, "kube-system": false, "restricted": false} {
+		target := &metav1.ObjectMeta{Namespace: namespace, Name: "target"}
+		if ok, err := r.isReplicationAllowed(target, source); ok != expected {
+			t.Errorf("expected replication to %s allowed: %v, got %v (%v)", namespace, expected, ok, err)
+		}
+	}
+
+	// the exclusion wins over the allowed namespaces
+	source.Annotations[r.ReplicationAllowedNamespaces] = "team-.*"
+	source.Annotations[r.ReplicationAllowedNamespacesExclude] = "team-b"
+	for namespace, expected := range map[string]bool{"team-a": true, "team-b": false, "other": false} {
+		target := &metav1.ObjectMeta{Namespace: namespace, Name: "target"}
+		if ok, err := r.isReplicationAllowed(target, source); ok != expected {
+			t.Errorf("expected replication to %s allowed: %v, got %v (%v)", namespace, expected, ok, err)
+		}
+	}
+
+	// the exclusion is propagated to the targets
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "target", Annotations: map[string]string{
+		r.ReplicationAllowedNamespaces: "team-.*",
+	}}
+	if update, err := r.needsAllowedAnnotationsUpdate(target, source); !update || err != nil {
+		t.Errorf("expected the exclusion to be copied to the target, got %v, %v", update, err)
+	}
+}
+
+func TestIsReplicatedByAlias(t *testing.T) {
+	r := &replicatorProps{
+		Name:            "secret",
+		annotationNames: annotationsWithPrefix(""),
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "renamed", Annotations: map[string]string{
+		r.ReplicatedAliasAnnotation: "former",
+	}}
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "target", Annotations: map[string]string{
+		r.ReplicatedByAnnotation: "default/former",
+	}}
+
+	// the targets of the former name are migrated once it is gone
+	if ok, err := r.isReplicatedBy(target, source); !ok {
+		t.Errorf("expected the target of the former name to be replicated by the source, got %v", err)
+	}
+
+	// but not taken over from a source which still exists
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "former"}})
+	if ok, _ := r.isReplicatedBy(target, source); ok {
+		t.Errorf("expected the target of an existing source not to be taken over")
+	}
+
+	target.Annotations[r.ReplicatedByAnnotation] = "default/other"
+	if ok, _ := r.isReplicatedBy(target, source); ok {
+		t.Errorf("expected the target of another source not to be replicated by the source")
+	}
+}
+
+func TestCrossNamespaceAliasIgnored(t *testing.T) {
+	r := &replicatorProps{
+		Name:            "secret",
+		annotationNames: annotationsWithPrefix(""),
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	// the former source is gone, but the namespace team-b is not allowed to claim its targets
+	source := &metav1.ObjectMeta{Namespace: "team-b", Name: "intruder", Annotations: map[string]string{
+		r.ReplicatedAliasAnnotation: "default/former",
+	}}
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "target", Annotations: map[string]string{
+		r.ReplicatedByAnnotation: "default/former",
+	}}
+
+	if aliases, err := r.getAliases(source); err == nil {
+		t.Errorf("expected an alias outside of the namespace of the source to be rejected, got %v", aliases)
+	}
+	if ok, _ := r.isReplicatedBy(target, source); ok {
+		t.Errorf("expected the target not to be taken over through a cross-namespace alias")
+	}
+}
+
+func TestReplicationDenied(t *testing.T) {
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "target"}
+
+	// only the denials of the source clear the data of the targets
+	for annotations, denied := range map[[2]string]bool{
+		{r.ReplicationAllowed, "false"}:            true,
+		{r.ReplicationAllowedNamespaces, "team-b"}: true,
+		{r.ReplicationAllowed, "maybe"}:            false,
+		{r.ReplicationAllowedNamespaces, "team-("}: false,
+	} {
+		source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+			annotations[0]: annotations[1],
+		}}
+		if ok, err := r.isReplicationAllowed(target, source); ok || isDenied(err) != denied {
+			t.Errorf("expected %s=%s to be denied: %v, got %v", annotations[0], annotations[1], denied, err)
+		}
+	}
+
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicationAllowed:      "true",
+		r.ReplicateFromAnnotation: "default/other",
+	}}
+	if ok, err := r.isReplicationAllowed(target, source); ok || isDenied(err) {
+		t.Errorf("expected a source replicated from another one not to be a denial, got %v", err)
+	}
+}
+
+func TestAwaitApproval(t *testing.T) {
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}
+
+	if !r.awaitApproval("team-a/target", "10") {
+		t.Errorf("expected the first wait for an approval to be reported")
+	}
+	if r.awaitApproval("team-a/target", "10") {
+		t.Errorf("expected the wait for the same version to be reported once")
+	}
+	if !r.awaitApproval("team-a/target", "11") {
+		t.Errorf("expected the wait for a new version to be reported")
+	}
+	r.approvalDone("team-a/target")
+	if !r.awaitApproval("team-a/target", "11") {
+		t.Errorf("expected the wait to be reported again once approved")
+	}
+}
@@ -1,7 +1,6 @@
 package replicate
 
 import (
-	"log"
 	"time"
 
 	"k8s.io/api/core/v1"
@@ -15,101 +14,174 @@ import (
 var ConfigMapActions *configMapActions = &configMapActions{}
 
 // NewConfigMapReplicator creates a new config map replicator
-func NewConfigMapReplicator(client kubernetes.Interface, resyncPeriod time.Duration, allowAll bool) Replicator {
+func NewConfigMapReplicator(client kubernetes.Interface, options ReplicatorOptions) Replicator {
 	repl := objectReplicator{
-		replicatorProps: replicatorProps{
-			Name:            "config map",
-			allowAll:        allowAll,
-			client:          client,
-
-			targetsFrom:     make(map[string][]string),
-			targetsTo:       make(map[string][]string),
-
-			watchedTargets:  make(map[string][]string),
-			watchedPatterns: make(map[string][]targetPattern),
-		},
+		replicatorProps:   newReplicatorProps("config map", client, options),
 		replicatorActions: ConfigMapActions,
 	}
-
-	namespaceStore, namespaceController := cache.NewInformer(
-		&cache.ListWatch{
-			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
-				list, err := client.CoreV1().Namespaces().List(lo)
-				if err != nil {
-					return list, err
-				}
-				// populate the store already, to avoid believing some items are deleted
-				copy := make([]interface{}, len(list.Items))
-				for index := range list.Items {
-					copy[index] = &list.Items[index]
-				}
-				repl.namespaceStore.Replace(copy, "init")
-				return list, err
-			},
-			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
-				return client.CoreV1().Namespaces().Watch(lo)
-			},
+	repl.queue, repl.rateLimiter = newQueue(repl.Name)
+	repl.logger = options.logger().WithValues("kind", repl.kind())
+
+	namespaces := repl.readLegacy(namespaceListWatch(client))
+	namespaceLister := &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := namespaces.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.NamespaceList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.namespaceStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return namespaces.Watch(lo)
 		},
+	}
+	namespaceStore, namespaceController := cache.NewInformer(
+		namespaceLister,
 		&v1.Namespace{},
-		resyncPeriod,
+		options.ResyncPeriod,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc:    repl.NamespaceAdded,
-			UpdateFunc: func(old interface{}, new interface{}) {},
-			DeleteFunc: func(obj interface{}) {},
+			UpdateFunc: repl.NamespaceUpdated,
+			DeleteFunc: repl.NamespaceDeleted,
 		},
 	)
 
 	repl.namespaceStore = namespaceStore
 	repl.namespaceController = namespaceController
-
-	objectStore, objectController := cache.NewInformer(
-		&cache.ListWatch{
-			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
-				list, err := client.CoreV1().ConfigMaps("").List(lo)
-				if err != nil {
-					return list, err
-				}
-				// populate the store already, to avoid believing some items are deleted
-				copy := make([]interface{}, len(list.Items))
-				for index := range list.Items {
-					copy[index] = &list.Items[index]
-				}
-				repl.objectStore.Replace(copy, "init")
-				return list, err
-			},
-			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
-				return client.CoreV1().ConfigMaps("").Watch(lo)
-			},
+	repl.namespaceLister = namespaceLister
+
+	objects, _ := ConfigMapActions.listWatch(client)
+	objects = repl.readLegacy(repl.pageLists(objects))
+	objects = repl.transformCache(objects)
+	objectListWatch := &cache.ListWatch{
+		// the pages are merged by pageLists, keeping the type of the list
+		DisableChunking: true,
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := objects.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.ConfigMapList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.objectStore.Replace(copy, "init")
+			return list, err
 		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return objects.Watch(lo)
+		},
+	}
+
+	objectStore, objectController := cache.NewIndexerInformer(
+		objectListWatch,
 		&v1.ConfigMap{},
-		resyncPeriod,
+		options.ResyncPeriod,
 		cache.ResourceEventHandlerFuncs{
-			AddFunc:    repl.ObjectAdded,
-			UpdateFunc: func(old interface{}, new interface{}) { repl.ObjectAdded(new) },
-			DeleteFunc: repl.ObjectDeleted,
+			AddFunc:    repl.enqueueAdded,
+			UpdateFunc: repl.enqueueUpdated,
+			DeleteFunc: repl.enqueueDeleted,
 		},
+		repl.indexers(),
 	)
 
-	repl.objectStore = objectStore
+	repl.objectStore = repl.stripStore(objectStore)
 	repl.objectController = objectController
+	repl.objectLister = objectListWatch
+
+	registerReplicator(&repl)
+	options.Metrics.addCollector(repl.collectStaleness)
+	if options.Shards != nil {
+		options.Shards.onChange(repl.resync)
+	}
 
 	return &repl
 }
 
-type configMapActions struct {}
+type configMapActions struct{}
 
 func (*configMapActions) getMeta(object interface{}) *metav1.ObjectMeta {
 	return &object.(*v1.ConfigMap).ObjectMeta
 }
 
-func (*configMapActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+// Watches the objects of the watched namespaces, of this cluster or of another one
+func (*configMapActions) listWatch(client kubernetes.Interface) (cache.ListerWatcher, runtime.Object) {
+	return scopedListWatch(func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			DisableChunking: true,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().ConfigMaps(namespace).List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().ConfigMaps(namespace).Watch(lo)
+			},
+		}
+	}), &v1.ConfigMap{}
+}
+
+func (a *configMapActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	sourceConfigMap := sourceObject.(*v1.ConfigMap)
+	replicated, err := a.replicated(r, object, sourceObject)
+	if err != nil {
+		return err
+	}
+	configMap := replicated.(*v1.ConfigMap)
+
+	r.infof(objectKey(sourceConfigMap), objectKey(configMap), "updating config map %s/%s", configMap.Namespace, configMap.Name)
+
+	var s *v1.ConfigMap
+	if serverSideApply && !r.isImmutable(&configMap.ObjectMeta) {
+		s = &v1.ConfigMap{}
+		err = r.apply("configmaps", configMap, r.appliedMeta(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta), "v1", "ConfigMap", s)
+	} else {
+		s, err = a.write(r, configMap)
+	}
+	if err != nil {
+		r.errorf(objectKey(sourceConfigMap), objectKey(configMap), "error while updating config map %s/%s: %s", configMap.Namespace, configMap.Name, err)
+		return err
+	}
+	r.logDiff(objectKey(sourceConfigMap), objectKey(configMap), configMapDiff(object.(*v1.ConfigMap), configMap))
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+// Returns a copy of the target with the data of the source, without writing it
+func (*configMapActions) replicated(r *replicatorProps, object interface{}, sourceObject interface{}) (interface{}, error) {
 	sourceConfigMap := sourceObject.(*v1.ConfigMap)
 	configMap := object.(*v1.ConfigMap).DeepCopy()
 
+	keyMap, err := r.getKeyMap(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	transform, err := r.getTransform(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := r.isMerged(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	existing := object.(*v1.ConfigMap)
+
 	if sourceConfigMap.Data != nil {
 		configMap.Data = make(map[string]string)
 		for key, value := range sourceConfigMap.Data {
-			configMap.Data[key] = value
+			newValue, err := transformValue(transform, key, []byte(value))
+			if err != nil {
+				return nil, err
+			}
+			configMap.Data[mapKey(keyMap, key)] = string(newValue)
 		}
 	} else {
 		configMap.Data = nil
@@ -120,54 +192,72 @@ func (*configMapActions) update(r *replicatorProps, object interface{}, sourceOb
 		for key, value := range sourceConfigMap.BinaryData {
 			newValue := make([]byte, len(value))
 			copy(newValue, value)
-			configMap.BinaryData[key] = newValue
+			configMap.BinaryData[mapKey(keyMap, key)] = newValue
 		}
 	} else {
 		configMap.BinaryData = nil
 	}
-
-	log.Printf("updating config map %s/%s", configMap.Namespace, configMap.Name)
-
-	configMap.Annotations[ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	configMap.Annotations[ReplicatedFromVersionAnnotation] = sourceConfigMap.ResourceVersion
-	if val, ok := sourceConfigMap.Annotations[ReplicateOnceVersionAnnotation]; ok {
-		configMap.Annotations[ReplicateOnceVersionAnnotation] = val
+	// the replicated keys are merged into the other keys of the target
+	if merged {
+		previous, keys := r.replicatedKeys(&configMap.ObjectMeta), map[string]bool{}
+		configMap.Data = mergeStrings(existing.Data, configMap.Data, previous, keys)
+		configMap.BinaryData = mergeBytes(existing.BinaryData, configMap.BinaryData, previous, keys)
+		r.setReplicatedKeys(&configMap.ObjectMeta, keys)
 	} else {
-		delete(configMap.Annotations, ReplicateOnceVersionAnnotation)
+		delete(configMap.Annotations, r.ReplicatedKeysAnnotation)
 	}
 
-	s, err := r.client.CoreV1().ConfigMaps(configMap.Namespace).Update(configMap)
-	if err != nil {
-		log.Printf("error while updating config map %s/%s: %s", configMap.Namespace, configMap.Name, err)
-		return err
+	configMap.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	configMap.Annotations[r.ReplicatedFromVersionAnnotation] = sourceConfigMap.ResourceVersion
+	r.setDataHash(&configMap.ObjectMeta, sourceConfigMap)
+	r.setSynced(&configMap.ObjectMeta, sourceConfigMap.ResourceVersion)
+	if val, ok := sourceConfigMap.Annotations[r.ReplicateOnceVersionAnnotation]; ok {
+		configMap.Annotations[r.ReplicateOnceVersionAnnotation] = val
+	} else {
+		delete(configMap.Annotations, r.ReplicateOnceVersionAnnotation)
 	}
+	r.propagateAnnotations(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta)
+	r.propagateLabels(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta)
+	r.propagateImmutable(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta)
 
-	r.objectStore.Update(s)
-	return nil
+	return configMap, nil
 }
 
-func (*configMapActions) clear(r *replicatorProps, object interface{}) error {
+func (a *configMapActions) clear(r *replicatorProps, object interface{}) error {
 	configMap := object.(*v1.ConfigMap).DeepCopy()
-	configMap.Data = nil
-	configMap.BinaryData = nil
+	// only the replicated keys are removed from the merged data
+	if _, ok := configMap.Annotations[r.ReplicatedKeysAnnotation]; ok {
+		previous := r.replicatedKeys(&configMap.ObjectMeta)
+		configMap.Data = mergeStrings(configMap.Data, nil, previous, map[string]bool{})
+		configMap.BinaryData = mergeBytes(configMap.BinaryData, nil, previous, map[string]bool{})
+		delete(configMap.Annotations, r.ReplicatedKeysAnnotation)
+	} else {
+		configMap.Data = nil
+		configMap.BinaryData = nil
+	}
 
-	log.Printf("clearing config map %s/%s", configMap.Namespace, configMap.Name)
+	r.infof("", objectKey(configMap), "clearing config map %s/%s", configMap.Namespace, configMap.Name)
 
-	configMap.Annotations[ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	delete(configMap.Annotations, ReplicatedFromVersionAnnotation)
-	delete(configMap.Annotations, ReplicateOnceVersionAnnotation)
+	configMap.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	delete(configMap.Annotations, r.ReplicatedFromVersionAnnotation)
+	delete(configMap.Annotations, r.ReplicatedDataHashAnnotation)
+	delete(configMap.Annotations, r.ReplicateOnceVersionAnnotation)
 
-	s, err := r.client.CoreV1().ConfigMaps(configMap.Namespace).Update(configMap)
+	// the cleared target is created again as mutable if it was immutable
+	delete(configMap.Annotations, r.ReplicatedImmutableAnnotation)
+
+	s, err := a.write(r, configMap)
 	if err != nil {
-		log.Printf("error while clearing config map %s/%s", configMap.Namespace, configMap.Name)
+		r.errorf("", objectKey(configMap), "error while clearing config map %s/%s", configMap.Namespace, configMap.Name)
 		return err
 	}
+	r.logDiff("", objectKey(configMap), configMapDiff(object.(*v1.ConfigMap), configMap))
 
 	r.objectStore.Update(s)
 	return nil
 }
 
-func (*configMapActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+func (a *configMapActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
 	sourceConfigMap := sourceObject.(*v1.ConfigMap)
 	configMap := v1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
@@ -179,11 +269,28 @@ func (*configMapActions) install(r *replicatorProps, meta *metav1.ObjectMeta, so
 
 	if dataObject != nil {
 		dataConfigMap := dataObject.(*v1.ConfigMap)
+		// the keys are renamed and transformed only when the data comes from the source
+		// the binary data is never transformed
+		var keyMap map[string]string
+		var transform valueTransform
+		var err error
+		if dataObject == sourceObject {
+			if keyMap, err = r.getKeyMap(meta, &sourceConfigMap.ObjectMeta); err != nil {
+				return err
+			}
+			if transform, err = r.getTransform(meta, &sourceConfigMap.ObjectMeta); err != nil {
+				return err
+			}
+		}
 
 		if dataConfigMap.Data != nil {
 			configMap.Data = make(map[string]string)
 			for key, value := range dataConfigMap.Data {
-				configMap.Data[key] = value
+				newValue, err := transformValue(transform, key, []byte(value))
+				if err != nil {
+					return err
+				}
+				configMap.Data[mapKey(keyMap, key)] = string(newValue)
 			}
 		}
 
@@ -192,33 +299,90 @@ func (*configMapActions) install(r *replicatorProps, meta *metav1.ObjectMeta, so
 			for key, value := range dataConfigMap.BinaryData {
 				newValue := make([]byte, len(value))
 				copy(newValue, value)
-				configMap.BinaryData[key] = newValue
+				configMap.BinaryData[mapKey(keyMap, key)] = newValue
 			}
 		}
+
+		// the replicated keys are merged into the other keys of the existing target
+		if dataObject != sourceObject {
+		} else if merged, err := r.isMerged(meta, &sourceConfigMap.ObjectMeta); err != nil {
+			return err
+		} else if merged {
+			existing := &v1.ConfigMap{}
+			if object, exists, err := r.objectStore.GetByKey(objectKey(meta)); err == nil && exists {
+				existing = object.(*v1.ConfigMap)
+			}
+			previous, keys := r.replicatedKeys(&existing.ObjectMeta), map[string]bool{}
+			configMap.Data = mergeStrings(existing.Data, configMap.Data, previous, keys)
+			configMap.BinaryData = mergeBytes(existing.BinaryData, configMap.BinaryData, previous, keys)
+			r.setReplicatedKeys(&configMap.ObjectMeta, keys)
+		}
 	}
 
-	// log.Printf("installing config map %s/%s", configMap.Namespace, configMap.Name)
+	r.propagateImmutable(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta)
+
+	// the data of the existing target, to log the diff once written
+	var existing *v1.ConfigMap
+	if object, exists, err := r.objectStore.GetByKey(objectKey(&configMap)); err == nil && exists && configMap.ResourceVersion != "" {
+		existing = object.(*v1.ConfigMap)
+	}
 
 	var s *v1.ConfigMap
 	var err error
-	if configMap.ResourceVersion == "" {
-		s, err = r.client.CoreV1().ConfigMaps(configMap.Namespace).Create(&configMap)
+	// only the replications are applied, not the writes of the status
+	if serverSideApply && dataObject == sourceObject && !r.isImmutable(&configMap.ObjectMeta) {
+		s = &v1.ConfigMap{}
+		err = r.apply("configmaps", &configMap, r.appliedMeta(&configMap.ObjectMeta, &sourceConfigMap.ObjectMeta), "v1", "ConfigMap", s)
 	} else {
-		s, err = r.client.CoreV1().ConfigMaps(configMap.Namespace).Update(&configMap)
+		s, err = a.write(r, &configMap)
 	}
 
 	if err != nil {
-		log.Printf("error while installing config map %s/%s: %s", configMap.Namespace, configMap.Name, err)
+		r.errorf(objectKey(sourceConfigMap), objectKey(&configMap), "error while installing config map %s/%s: %s", configMap.Namespace, configMap.Name, err)
 		return err
 	}
+	if existing != nil {
+		r.logDiff(objectKey(sourceConfigMap), objectKey(&configMap), configMapDiff(existing, &configMap))
+	}
 
 	r.objectStore.Update(s)
 	return nil
 }
 
+// Writes the config map, created if it has no version, with its immutable field if it is marked as immutable
+// An immutable config map which cannot be updated is deleted, and created again
+func (a *configMapActions) write(r *replicatorProps, configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	client := r.writer(&configMap.ObjectMeta).CoreV1().ConfigMaps(configMap.Namespace)
+	s := &v1.ConfigMap{}
+	var err error
+	if r.isImmutable(&configMap.ObjectMeta) {
+		err = r.writeImmutable("configmaps", configMap, &configMap.ObjectMeta, s)
+	} else if configMap.ResourceVersion == "" {
+		s, err = client.Create(configMap)
+	} else {
+		s, err = client.Update(configMap)
+	}
+	if configMap.ResourceVersion == "" || !isImmutableError(err) {
+		return s, err
+	}
+
+	r.infof("", objectKey(configMap), "config map %s/%s is immutable: deleting it to create it again", configMap.Namespace, configMap.Name)
+	options := metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &configMap.ResourceVersion,
+		},
+	}
+	if err := client.Delete(configMap.Name, &options); err != nil {
+		return nil, err
+	}
+	recreated := configMap.DeepCopy()
+	recreated.ObjectMeta = recreatedMeta(&configMap.ObjectMeta)
+	return a.write(r, recreated)
+}
+
 func (*configMapActions) delete(r *replicatorProps, object interface{}) error {
 	configMap := object.(*v1.ConfigMap)
-	log.Printf("deleting config map %s/%s", configMap.Namespace, configMap.Name)
+	r.infof("", objectKey(configMap), "deleting config map %s/%s", configMap.Namespace, configMap.Name)
 
 	options := metav1.DeleteOptions{
 		Preconditions: &metav1.Preconditions{
@@ -226,9 +390,9 @@ func (*configMapActions) delete(r *replicatorProps, object interface{}) error {
 		},
 	}
 
-	err := r.client.CoreV1().ConfigMaps(configMap.Namespace).Delete(configMap.Name, &options)
+	err := r.writer(&configMap.ObjectMeta).CoreV1().ConfigMaps(configMap.Namespace).Delete(configMap.Name, &options)
 	if err != nil {
-		log.Printf("error while deleting config map %s/%s: %s", configMap.Namespace, configMap.Name, err)
+		r.errorf("", objectKey(configMap), "error while deleting config map %s/%s: %s", configMap.Namespace, configMap.Name, err)
 		return err
 	}
 
@@ -0,0 +1,85 @@
+package replicate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// Returns the latest version of the target from the API, bypassing the store, and updates the store with it
+// Returns nil if the target does not exist anymore
+func (r *objectReplicator) latestFromAPI(key string) (interface{}, error) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid key %s", key)
+	}
+
+	var latest interface{}
+	if dynamic, ok := baseActions(r.replicatorActions).(*dynamicActions); ok {
+		object, err := dynamic.resource.Namespace(parts[0]).Get(parts[1], metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		} else if err == nil {
+			renameLegacy(object, r.legacyNames())
+			latest = object
+		}
+	} else {
+		listWatch, _ := r.listWatch(r.client)
+		list, err := r.readLegacy(listWatch).List(metav1.ListOptions{FieldSelector: fields.SelectorFromSet(fields.Set{
+			"metadata.namespace": parts[0],
+			"metadata.name":      parts[1],
+		}).String()})
+		if err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		} else if len(items) > 0 {
+			latest = items[0]
+		}
+	}
+
+	if latest != nil {
+		r.objectStore.Update(latest)
+	} else if cached, exists, err := r.objectStore.GetByKey(key); err == nil && exists {
+		r.objectStore.Delete(cached)
+	}
+	return latest, nil
+}
+
+// Runs a write of the target, and runs it again after a conflict with another write,
+// once refresh is called with the latest version of the target (nil if it was deleted)
+// Gives up after the configured number of retries, returning the conflict
+func (r *objectReplicator) retryOnConflict(key string, write func() error, refresh func(latest interface{}) error) error {
+	backoff := wait.Backoff{
+		Steps:    r.conflictRetries + 1,
+		Duration: 10 * time.Millisecond,
+		Factor:   5.0,
+		Jitter:   0.1,
+	}
+
+	return retry.RetryOnConflict(backoff, func() error {
+		err := write()
+		if !errors.IsConflict(err) {
+			return err
+		}
+
+		r.infof("", key, "conflict while writing %s %s: retrying with its latest version", r.Name, key)
+		latest, getErr := r.latestFromAPI(key)
+		if getErr != nil {
+			r.errorf("", key, "could not get the latest version of %s %s: %s", r.Name, key, getErr)
+			return getErr
+		} else if refreshErr := refresh(latest); refreshErr != nil {
+			return refreshErr
+		}
+		return err
+	})
+}
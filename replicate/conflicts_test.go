@@ -0,0 +1,105 @@
+package replicate
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// a dynamic resource only implementing Get, returning the latest version of the objects
+type latestResource struct {
+	dynamic.NamespaceableResourceInterface
+	objects map[string]*unstructured.Unstructured
+}
+
+func (l *latestResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &latestNamespacedResource{namespace: namespace, objects: l.objects}
+}
+
+type latestNamespacedResource struct {
+	dynamic.ResourceInterface
+	namespace string
+	objects   map[string]*unstructured.Unstructured
+}
+
+func (l *latestNamespacedResource) Get(name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if object, ok := l.objects[l.namespace+"/"+name]; ok {
+		return object, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "certificates"}, name)
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	latest := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "team", "name": "target", "resourceVersion": "11"},
+	}}
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "certificate",
+			conflictRetries: 2,
+			objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		},
+		replicatorActions: &dynamicActions{resource: &latestResource{
+			objects: map[string]*unstructured.Unstructured{"team/target": latest},
+		}},
+	}
+	conflict := errors.NewConflict(schema.GroupResource{Resource: "certificates"}, "target", fmt.Errorf("modified"))
+
+	// the write is retried with the latest version
+	writes, version := 0, "10"
+	err := r.retryOnConflict("team/target", func() error {
+		writes++
+		if version != "11" {
+			return conflict
+		}
+		return nil
+	}, func(object interface{}) error {
+		version = r.getMeta(object).ResourceVersion
+		return nil
+	})
+	if err != nil || writes != 2 {
+		t.Errorf("expected the write to succeed on its second attempt, got %d writes, %v", writes, err)
+	}
+	if _, exists, _ := r.objectStore.GetByKey("team/target"); !exists {
+		t.Errorf("expected the latest version to be stored")
+	}
+
+	// the conflicts are returned after the retries
+	writes = 0
+	err = r.retryOnConflict("team/target", func() error {
+		writes++
+		return conflict
+	}, func(interface{}) error { return nil })
+	if !errors.IsConflict(err) || writes != 3 {
+		t.Errorf("expected the conflict after 3 writes, got %d writes, %v", writes, err)
+	}
+
+	// the other errors are not retried
+	writes = 0
+	err = r.retryOnConflict("team/target", func() error {
+		writes++
+		return fmt.Errorf("forbidden")
+	}, func(interface{}) error { return nil })
+	if err == nil || writes != 1 {
+		t.Errorf("expected the error after a single write, got %d writes, %v", writes, err)
+	}
+
+	// the deleted targets are passed as nil
+	err = r.retryOnConflict("team/missing", func() error {
+		return conflict
+	}, func(object interface{}) error {
+		if object != nil {
+			return fmt.Errorf("expected no object")
+		}
+		return fmt.Errorf("deleted")
+	})
+	if err == nil || err.Error() != "deleted" {
+		t.Errorf("expected the refresh error, got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package replicate
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the kinds a source can be converted to, by kind of source
+var conversionKinds = map[string]string{
+	"secret":    "configmap",
+	"configmap": "secret",
+}
+
+// the sources converted to the other kind, to delete their targets once they are not converted anymore
+var conversions = struct {
+	sync.Mutex
+	// serializes the conversions
+	running sync.Mutex
+	sources map[string]bool
+}{sources: map[string]bool{}}
+
+// Returns the kind the targets of the source are converted to, from its replicate-as-kind annotation,
+// or an empty string when they have the kind of the source
+func (r *replicatorProps) convertedKind(object *metav1.ObjectMeta) (string, error) {
+	val, ok := object.Annotations[r.ReplicateAsKindAnnotation]
+	if !ok || strings.ToLower(val) == r.kind() {
+		return "", nil
+	} else if other, ok := conversionKinds[r.kind()]; !ok {
+		return "", fmt.Errorf("source %s/%s has annotation %s (%s): a %s cannot be converted",
+			object.Namespace, object.Name, r.ReplicateAsKindAnnotation, val, r.Name)
+	} else if kind := strings.ToLower(val); kind == other {
+		return kind, nil
+	}
+	return "", fmt.Errorf("source %s/%s has illformed annotation %s (%s): expected %s or %s",
+		object.Namespace, object.Name, r.ReplicateAsKindAnnotation, val, r.kind(), conversionKinds[r.kind()])
+}
+
+// Returns the reference of the source written in the converted-from annotation of its targets
+func (r *replicatorProps) conversionRef(object *metav1.ObjectMeta) string {
+	return fmt.Sprintf("%s:%s/%s", r.kind(), object.Namespace, object.Name)
+}
+
+// Notifies that an object of a kind which can be converted changed, or was deleted
+// A converted source is converted again, or its targets are deleted, and the source of a converted target is
+// converted again
+func notifyConversions(r *objectReplicator, object interface{}, deleted bool) {
+	if _, ok := replicatorOfKind(conversionKinds[r.kind()]); !ok {
+		return
+	}
+	meta := r.getMeta(object)
+
+	if ref, ok := meta.Annotations[r.ConvertedFromAnnotation]; ok {
+		if !deleted {
+			return
+		}
+		// the conversions are processed asynchronously, as they need the lock of the replicator of the source
+		go func() {
+			parts := strings.SplitN(ref, ":", 2)
+			if source, ok := replicatorOfKind(parts[0]); ok && len(parts) == 2 {
+				if sourceObject, exists, err := source.objectStore.GetByKey(parts[1]); err == nil && exists {
+					convertSource(source, sourceObject, false)
+				}
+			}
+		}()
+		return
+	}
+
+	ref := r.conversionRef(meta)
+	conversions.Lock()
+	converted := conversions.sources[ref]
+	conversions.Unlock()
+	if _, ok := meta.Annotations[r.ReplicateAsKindAnnotation]; ok || converted {
+		go convertSource(r, object, deleted)
+	}
+}
+
+// Notifies that a namespace was created, so that the converted sources are converted again
+func notifyConversionsOfNamespace(r *objectReplicator) {
+	// without replicator of the other kind, no source is converted
+	if _, ok := replicatorOfKind(conversionKinds[r.kind()]); !ok {
+		return
+	}
+	go func() {
+		for _, object := range r.objectStore.List() {
+			if _, ok := r.getMeta(object).Annotations[r.ReplicateAsKindAnnotation]; ok {
+				convertSource(r, object, false)
+			}
+		}
+	}()
+}
+
+// Installs the converted targets of the source with the replicator of the other kind, and deletes the ones which
+// are not targeted anymore, or all of them if the source is deleted
+func convertSource(r *objectReplicator, object interface{}, deleted bool) {
+	conversions.running.Lock()
+	defer conversions.running.Unlock()
+
+	meta := r.getMeta(object)
+	ref := r.conversionRef(meta)
+	target, ok := replicatorOfKind(conversionKinds[r.kind()])
+	if !ok {
+		return
+	}
+
+	desired := map[string]bool{}
+	kind, err := r.convertedKind(meta)
+	if err != nil {
+		r.errorf(objectKey(meta), "", "%s", err)
+		return
+	}
+	if !deleted && kind != "" {
+		conversions.Lock()
+		conversions.sources[ref] = true
+		conversions.Unlock()
+
+		for _, key := range r.convertedTargets(meta) {
+			desired[key] = true
+			if err := convertTarget(r, target, object, key); err != nil {
+				r.errorf(objectKey(meta), key, "could not convert %s %s to %s %s: %s", r.Name, objectKey(meta), target.Name, key, err)
+			}
+		}
+	} else {
+		conversions.Lock()
+		delete(conversions.sources, ref)
+		conversions.Unlock()
+	}
+
+	for _, existing := range target.objectStore.List() {
+		existingMeta := target.getMeta(existing)
+		if existingMeta.Annotations[target.ConvertedFromAnnotation] == ref && !desired[objectKey(existingMeta)] {
+			r.infof(objectKey(meta), objectKey(existingMeta), "%s %s is not converted to %s %s anymore: deleting it",
+				r.Name, objectKey(meta), target.Name, objectKey(existingMeta))
+			target.delete(&target.replicatorProps, existing)
+		}
+	}
+}
+
+// Returns the paths of the converted targets of the source, in the existing namespaces
+func (r *objectReplicator) convertedTargets(meta *metav1.ObjectMeta) []string {
+	// the targets are computed as if they had the kind of the source
+	unconverted := meta.DeepCopy()
+	delete(unconverted.Annotations, r.ReplicateAsKindAnnotation)
+	r.lock.RLock()
+	targets, patterns, err := r.getReplicationTargets(unconverted)
+	r.lock.RUnlock()
+	if err != nil {
+		r.errorf(objectKey(meta), "", "%s", err)
+		return nil
+	}
+
+	namespaces := r.namespaceStore.ListKeys()
+	for _, p := range patterns {
+		targets = append(targets, p.Targets(namespaces)...)
+	}
+
+	paths := []string{}
+	for _, key := range targets {
+		namespace := strings.SplitN(key, "/", 2)[0]
+		if r.namespaceActive(namespace) && checkTargetNamespace(namespace) == nil {
+			paths = append(paths, key)
+		}
+	}
+	return paths
+}
+
+// Creates or updates the converted target of the source
+// An existing object which is not converted from the source is left untouched
+func convertTarget(r *objectReplicator, target *objectReplicator, object interface{}, key string) error {
+	meta := r.getMeta(object)
+	ref := r.conversionRef(meta)
+	parts := strings.SplitN(key, "/", 2)
+
+	copyMeta := metav1.ObjectMeta{
+		Namespace: parts[0],
+		Name:      parts[1],
+		Annotations: map[string]string{
+			target.ReplicatedAtAnnotation:          time.Now().Format(time.RFC3339),
+			target.ConvertedFromAnnotation:         ref,
+			target.ReplicatedFromVersionAnnotation: meta.ResourceVersion,
+		},
+	}
+	if existing, exists, err := target.objectStore.GetByKey(key); err != nil {
+		return err
+	} else if exists {
+		existingMeta := target.getMeta(existing)
+		if existingMeta.Annotations[target.ConvertedFromAnnotation] != ref {
+			return fmt.Errorf("%s %s already exists and is not converted from %s", target.Name, key, ref)
+			// already up-to-date
+		} else if existingMeta.Annotations[target.ReplicatedFromVersionAnnotation] == meta.ResourceVersion {
+			return nil
+		}
+		// Needs ResourceVersion for update
+		copyMeta.ResourceVersion = existingMeta.ResourceVersion
+	}
+	target.propagateAnnotations(&copyMeta, meta)
+	target.propagateLabels(&copyMeta, meta)
+	target.stampInstance(&copyMeta)
+
+	converted := convertObject(object)
+	return target.install(&target.replicatorProps, &copyMeta, converted, converted)
+}
+
+// Returns the config map with the data of the secret, or the secret with the data of the config map
+// The values of a secret which are not valid UTF-8 are converted to binary data
+// The converted object keeps the metadata of the source, so that the keys are renamed and transformed as usual
+func convertObject(object interface{}) interface{} {
+	switch source := object.(type) {
+	case *v1.Secret:
+		configMap := &v1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+			ObjectMeta: *source.ObjectMeta.DeepCopy(),
+		}
+		for key, value := range source.Data {
+			if utf8.Valid(value) {
+				if configMap.Data == nil {
+					configMap.Data = map[string]string{}
+				}
+				configMap.Data[key] = string(value)
+			} else {
+				if configMap.BinaryData == nil {
+					configMap.BinaryData = map[string][]byte{}
+				}
+				configMap.BinaryData[key] = append([]byte{}, value...)
+			}
+		}
+		return configMap
+	case *v1.ConfigMap:
+		secret := &v1.Secret{
+			TypeMeta:   metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+			ObjectMeta: *source.ObjectMeta.DeepCopy(),
+			Type:       v1.SecretTypeOpaque,
+		}
+		if len(source.Data) > 0 || len(source.BinaryData) > 0 {
+			secret.Data = map[string][]byte{}
+		}
+		for key, value := range source.Data {
+			secret.Data[key] = []byte(value)
+		}
+		for key, value := range source.BinaryData {
+			secret.Data[key] = append([]byte{}, value...)
+		}
+		return secret
+	}
+	return nil
+}
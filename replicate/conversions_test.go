@@ -0,0 +1,54 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertedKind(t *testing.T) {
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{}}
+
+	for val, expected := range map[string]string{"ConfigMap": "configmap", "secret": ""} {
+		source.Annotations[r.ReplicateAsKindAnnotation] = val
+		if kind, err := r.convertedKind(source); err != nil || kind != expected {
+			t.Errorf("expected kind %q for %q, got %q (%v)", expected, val, kind, err)
+		}
+	}
+
+	source.Annotations[r.ReplicateAsKindAnnotation] = "role"
+	if _, err := r.convertedKind(source); err == nil {
+		t.Errorf("expected an error for an unknown kind")
+	}
+	// the converted sources have no target of their own kind
+	source.Annotations[r.ReplicateAsKindAnnotation] = "configmap"
+	source.Annotations[r.ReplicateToNamespacesAnnotation] = "team-a"
+	if targets, patterns, err := r.getReplicationTargets(source); err != nil || len(targets) != 0 || len(patterns) != 0 {
+		t.Errorf("expected no target, got %v and %v (%v)", targets, patterns, err)
+	}
+}
+
+func TestConvertObject(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source"},
+		Type:       v1.SecretTypeTLS,
+		Data:       map[string][]byte{"tls.crt": []byte("certificate"), "raw": {0xff, 0xfe}},
+	}
+	configMap := convertObject(secret).(*v1.ConfigMap)
+	if configMap.Name != "source" || configMap.Data["tls.crt"] != "certificate" {
+		t.Errorf("expected the text data in the config map, got %v", configMap.Data)
+	}
+	if len(configMap.BinaryData["raw"]) != 2 || len(configMap.Data) != 1 {
+		t.Errorf("expected the invalid UTF-8 in the binary data, got %v", configMap.BinaryData)
+	}
+
+	converted := convertObject(configMap).(*v1.Secret)
+	if converted.Type != v1.SecretTypeOpaque || string(converted.Data["tls.crt"]) != "certificate" || len(converted.Data["raw"]) != 2 {
+		t.Errorf("expected an opaque secret with all the data, got %v", converted.Data)
+	}
+	if empty := convertObject(&v1.ConfigMap{}).(*v1.Secret); empty.Data != nil {
+		t.Errorf("expected no data, got %v", empty.Data)
+	}
+}
@@ -0,0 +1,78 @@
+package replicate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// collapses the identical messages emitted within a time window
+type deduplicator struct {
+	lock      sync.Mutex
+	window    time.Duration
+	lastPurge time.Time
+	// a {message => emission} map of the messages emitted within the window
+	seen map[string]*emission
+}
+
+type emission struct {
+	// when the message was last emitted
+	last time.Time
+	// how many times the message was suppressed since
+	repeated int
+}
+
+// the deduplicators of the log lines and of the events
+var logLines = &deduplicator{seen: map[string]*emission{}}
+var events = &deduplicator{seen: map[string]*emission{}}
+
+// DeduplicateWindow sets the time window in which identical log lines and events are collapsed
+// A zero window disables deduplication
+func DeduplicateWindow(window time.Duration) {
+	for _, d := range []*deduplicator{logLines, events} {
+		d.lock.Lock()
+		d.window = window
+		d.lock.Unlock()
+	}
+}
+
+// Returns true if the message must be emitted,
+// along with the number of times it was suppressed since its last emission
+func (d *deduplicator) emit(message string) (bool, int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.window == 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	// forget the messages out of the window, to not grow forever
+	if now.Sub(d.lastPurge) > d.window {
+		for m, e := range d.seen {
+			if now.Sub(e.last) > d.window {
+				delete(d.seen, m)
+			}
+		}
+		d.lastPurge = now
+	}
+
+	e, ok := d.seen[message]
+	if !ok {
+		d.seen[message] = &emission{last: now}
+		return true, 0
+	} else if now.Sub(e.last) <= d.window {
+		e.repeated++
+		return false, e.repeated
+	}
+
+	repeated := e.repeated
+	e.last = now
+	e.repeated = 0
+	return true, repeated
+}
+
+// Logs a message, unless it was already logged within the deduplication window
+func logf(format string, args ...interface{}) {
+	logWith(logger, false, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,35 @@
+package replicate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduplicatorDisabled(t *testing.T) {
+	d := &deduplicator{seen: map[string]*emission{}}
+
+	for i := 0; i < 3; i++ {
+		if ok, repeated := d.emit("message"); !ok || repeated != 0 {
+			t.Errorf("expected message to be emitted, got %v (%d)", ok, repeated)
+		}
+	}
+}
+
+func TestDeduplicatorCollapsesWithinWindow(t *testing.T) {
+	d := &deduplicator{window: time.Hour, seen: map[string]*emission{}}
+
+	if ok, _ := d.emit("message"); !ok {
+		t.Errorf("expected first message to be emitted")
+	}
+	if ok, _ := d.emit("message"); ok {
+		t.Errorf("expected identical message to be suppressed")
+	}
+	if ok, _ := d.emit("other message"); !ok {
+		t.Errorf("expected other message to be emitted")
+	}
+	// pretend the window is over
+	d.seen["message"].last = time.Now().Add(-2 * time.Hour)
+	if ok, repeated := d.emit("message"); !ok || repeated != 1 {
+		t.Errorf("expected message to be emitted after the window with 1 repetition, got %v (%d)", ok, repeated)
+	}
+}
@@ -0,0 +1,72 @@
+package replicate
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// the targets of replicate-to are deleted and the targets of replicate-from are cleared (default)
+	DeletionPolicyDelete = "delete"
+	// the targets keep the data of the deleted source
+	DeletionPolicyRetain = "retain"
+	// the targets of replicate-to and replicate-from are cleared
+	DeletionPolicyClear = "clear"
+)
+
+// Returns what happens to the targets of the source once it is deleted
+func (r *replicatorProps) deletionPolicy(sourceMeta *metav1.ObjectMeta) (string, error) {
+	val, ok := sourceMeta.Annotations[r.ReplicationDeletionPolicyAnnotation]
+	if !ok {
+		return DeletionPolicyDelete, nil
+	}
+
+	switch val {
+	case DeletionPolicyDelete, DeletionPolicyRetain, DeletionPolicyClear:
+		return val, nil
+	default:
+		return DeletionPolicyDelete, fmt.Errorf("%s/%s has illformed annotation %s (%s): expected delete, retain or clear",
+			sourceMeta.Namespace, sourceMeta.Name, r.ReplicationDeletionPolicyAnnotation, val)
+	}
+}
+
+// Returns the deletion policy of the source, the targets being deleted when it is illformed
+func (r *objectReplicator) sourceDeletionPolicy(sourceMeta *metav1.ObjectMeta) string {
+	policy, err := r.deletionPolicy(sourceMeta)
+	if err != nil {
+		r.errorf(objectKey(sourceMeta), "", "could not parse %s %s: %s", r.Name, objectKey(sourceMeta), err)
+	}
+	return policy
+}
+
+// Deletes, clears or keeps the target of the replicate-to annotation of the deleted source, following its policy
+func (r *objectReplicator) removeTarget(key string, sourceObject interface{}, policy string) (bool, error) {
+	switch policy {
+	case DeletionPolicyRetain:
+		r.infof(objectKey(r.getMeta(sourceObject)), key, "%s %s is retained after the deletion of its source", r.Name, key)
+		return false, nil
+	case DeletionPolicyClear:
+		return r.clearTarget(key, sourceObject)
+	default:
+		return r.deleteObject(key, sourceObject)
+	}
+}
+
+// Clears the data of the target of the replicate-to annotation of the source, instead of deleting it
+func (r *objectReplicator) clearTarget(key string, sourceObject interface{}) (bool, error) {
+	sourceMeta := r.getMeta(sourceObject)
+
+	object, meta, err := r.objectFromStore(key)
+	if err != nil {
+		r.errorf("", key, "could not get %s %s: %s", r.Name, key, err)
+		return false, err
+	}
+
+	// make sure replication is allowed
+	if ok, err := r.isReplicatedBy(meta, sourceMeta); !ok {
+		r.infof("", key, "clearing of %s %s is cancelled: %s", r.Name, key, err)
+		return false, err
+	}
+	return true, r.doClearObject(object)
+}
@@ -0,0 +1,35 @@
+package replicate
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDeletionPolicy(t *testing.T) {
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix(""), ownerReferences: true}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", UID: types.UID("1234"), Annotations: map[string]string{}}
+
+	if policy, err := r.deletionPolicy(source); err != nil || policy != DeletionPolicyDelete {
+		t.Errorf("expected the targets to be deleted by default, got %s, %v", policy, err)
+	}
+	for _, val := range []string{DeletionPolicyDelete, DeletionPolicyRetain, DeletionPolicyClear} {
+		source.Annotations[r.ReplicationDeletionPolicyAnnotation] = val
+		if policy, err := r.deletionPolicy(source); err != nil || policy != val {
+			t.Errorf("expected policy %s, got %s, %v", val, policy, err)
+		}
+	}
+	source.Annotations[r.ReplicationDeletionPolicyAnnotation] = "orphan"
+	if policy, err := r.deletionPolicy(source); err == nil || policy != DeletionPolicyDelete {
+		t.Errorf("expected an error and the targets to be deleted, got %s, %v", policy, err)
+	}
+
+	// the retained targets are not owned, so that the garbage collector keeps them
+	source.Annotations[r.ReplicationDeletionPolicyAnnotation] = DeletionPolicyRetain
+	target := &metav1.ObjectMeta{Namespace: "default", Name: "target"}
+	r.setOwner(target, source, nil)
+	if len(target.OwnerReferences) != 0 {
+		t.Errorf("expected a retained target not to be owned, got %v", target.OwnerReferences)
+	}
+}
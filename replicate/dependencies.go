@@ -0,0 +1,196 @@
+package replicate
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// an install of a target waiting for one of its dependencies to exist or to be updated
+type pendingInstall struct {
+	replicator *objectReplicator
+	// the source to install the target from, or empty to process the target again
+	source string
+	target string
+}
+
+var dependencies = struct {
+	sync.Mutex
+	// the replicators by kind, to resolve dependencies across kinds
+	replicators map[string]*objectReplicator
+	// a {dependency => installs} map of the installs waiting for a dependency
+	pending map[string][]pendingInstall
+}{
+	replicators: map[string]*objectReplicator{},
+	pending:     map[string][]pendingInstall{},
+}
+
+// the kind of the replicator, as used in annotations
+func (r *replicatorProps) kind() string {
+	return strings.Replace(r.Name, " ", "", -1)
+}
+
+// Registers the replicator, so that other kinds can depend on it
+func registerReplicator(r *objectReplicator) {
+	dependencies.Lock()
+	defer dependencies.Unlock()
+
+	dependencies.replicators[r.kind()] = r
+}
+
+// Returns the dependencies of the object for the target, as "kind:namespace/name"
+// The dependencies are in the namespace of the target, a kind without name refers to the name of the target
+func (r *replicatorProps) getDependencies(object *metav1.ObjectMeta, target string) ([]string, error) {
+	annotationAfter, ok := object.Annotations[r.ReplicateAfterAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	targetSplit := strings.SplitN(target, "/", 2)
+	deps := []string{}
+	for _, dep := range strings.Split(annotationAfter, ",") {
+		kind := r.kind()
+		name := dep
+		if parts := strings.SplitN(dep, ":", 2); len(parts) == 2 {
+			kind = parts[0]
+			name = parts[1]
+			if name == "" && len(targetSplit) == 2 {
+				name = targetSplit[1]
+			}
+		}
+
+		if dep == "" {
+		} else if !validName.MatchString(name) {
+			return nil, fmt.Errorf("%s/%s has invalid name on annotation %s (%s)",
+				object.Namespace, object.Name, r.ReplicateAfterAnnotation, dep)
+		} else {
+			deps = append(deps, fmt.Sprintf("%s:%s/%s", kind, targetSplit[0], name))
+		}
+	}
+
+	return deps, nil
+}
+
+// Checks that all the dependencies of the object exist in the namespace of the target,
+// and that the ones created by replication are up-to-date with their sources
+// If not, the install of the target from the source is registered to be retried once the dependency changes
+// An empty source means that the target is processed again
+func (r *objectReplicator) dependenciesReady(object *metav1.ObjectMeta, source string, target string) (bool, error) {
+	deps, err := r.getDependencies(object, target)
+	if err != nil || len(deps) == 0 {
+		return err == nil, err
+	}
+
+	dependencies.Lock()
+	defer dependencies.Unlock()
+
+	for _, dep := range deps {
+		parts := strings.SplitN(dep, ":", 2)
+		replicator, ok := dependencies.replicators[parts[0]]
+		if !ok {
+			return false, fmt.Errorf("%s/%s has unknown kind on annotation %s (%s)",
+				object.Namespace, object.Name, r.ReplicateAfterAnnotation, parts[0])
+		}
+
+		var waiting error
+		if depObject, exists, err := replicator.objectStore.GetByKey(parts[1]); err != nil {
+			return false, err
+		} else if !exists {
+			waiting = fmt.Errorf("target %s is waiting for %s %s", target, parts[0], parts[1])
+		} else if !replicator.isUpToDate(replicator.getMeta(depObject)) {
+			waiting = fmt.Errorf("target %s is waiting for %s %s to be updated", target, parts[0], parts[1])
+		}
+
+		if waiting != nil {
+			install := pendingInstall{r, source, target}
+			for _, p := range dependencies.pending[dep] {
+				if p == install {
+					install.replicator = nil
+				}
+			}
+			if install.replicator != nil {
+				dependencies.pending[dep] = append(dependencies.pending[dep], install)
+			}
+			return false, waiting
+		}
+	}
+
+	return true, nil
+}
+
+// Returns false if the object was replicated from a source which changed since
+func (r *objectReplicator) isUpToDate(object *metav1.ObjectMeta) bool {
+	version, ok := object.Annotations[r.ReplicatedFromVersionAnnotation]
+	if !ok {
+		return true
+	}
+
+	source, ok := resolveAnnotation(object, r.ReplicateFromAnnotation)
+	if !ok {
+		source, ok = object.Annotations[r.ReplicatedByAnnotation]
+	}
+	if !ok {
+		return true
+	}
+
+	// the version of several merged sources is the list of their versions
+	versions, dataVersions := []string{}, []string{}
+	for _, source := range strings.Split(source, ",") {
+		sourceObject, exists, err := r.getSource(source)
+		if err != nil || !exists {
+			return true
+		}
+		sourceMeta := r.getMeta(sourceObject)
+		// sources replicated once are not updated
+		if _, ok := sourceMeta.Annotations[r.ReplicateOnceAnnotation]; ok {
+			return true
+		}
+		versions = append(versions, sourceMeta.ResourceVersion)
+		dataVersions = append(dataVersions, r.dataVersion(sourceMeta))
+	}
+	return strings.Join(versions, ",") == version || strings.Join(dataVersions, ",") == version
+}
+
+// Retries the installs which were waiting for the object to exist
+func (r *replicatorProps) installDependents(key string) {
+	dep := fmt.Sprintf("%s:%s", r.kind(), key)
+
+	dependencies.Lock()
+	installs, ok := dependencies.pending[dep]
+	delete(dependencies.pending, dep)
+	dependencies.Unlock()
+
+	// the installs are done asynchronously, as they may need the lock of this replicator
+	if ok {
+		for _, p := range installs {
+			if p.source == "" {
+				go p.replicator.retry(p.target)
+			} else {
+				go p.replicator.retryInstall(p.source, p.target)
+			}
+		}
+	}
+}
+
+// Installs the target of a source, if the source still replicates to it
+func (r *objectReplicator) retryInstall(source string, target string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sourceObject, sourceMeta, err := r.objectFromStore(source)
+	if err != nil {
+		logf("could not install %s %s: %s", r.Name, target, err)
+		return
+	}
+
+	parts := strings.SplitN(target, "/", 2)
+	targetMeta := &metav1.ObjectMeta{Namespace: parts[0], Name: parts[1]}
+	if ok, err := r.isReplicatedTo(sourceMeta, targetMeta); err != nil {
+		logf("could not parse %s %s: %s", r.Name, source, err)
+	} else if ok {
+		logf("%s %s is replicated to %s", r.Name, source, target)
+		r.installObject(target, nil, sourceObject)
+	}
+}
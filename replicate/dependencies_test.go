@@ -0,0 +1,66 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDependenciesAcrossKinds(t *testing.T) {
+	secrets := &objectReplicator{replicatorProps: replicatorProps{
+		Name:            "secret",
+		annotationNames: annotationsWithPrefix(""),
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}, replicatorActions: SecretActions}
+	configMaps := &objectReplicator{replicatorProps: replicatorProps{
+		Name:            "config map",
+		annotationNames: annotationsWithPrefix(""),
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}, replicatorActions: ConfigMapActions}
+	registerReplicator(secrets)
+	registerReplicator(configMaps)
+	defer func() {
+		dependencies.Lock()
+		delete(dependencies.replicators, secrets.kind())
+		delete(dependencies.replicators, configMaps.kind())
+		dependencies.pending = map[string][]pendingInstall{}
+		dependencies.Unlock()
+	}()
+
+	// the secret of the same name has been updated, but not its copy yet
+	secrets.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app", ResourceVersion: "2",
+		Annotations: map[string]string{secrets.ReplicateToNamespacesAnnotation: "team-a"}}})
+	target := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "app", ResourceVersion: "5",
+		Annotations: map[string]string{
+			secrets.ReplicatedByAnnotation:          "default/app",
+			secrets.ReplicatedFromVersionAnnotation: "1",
+		}}}
+	secrets.objectStore.Add(target)
+
+	// the config map referencing it waits for the copy of the secret to be updated
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "app", Annotations: map[string]string{
+		configMaps.ReplicateAfterAnnotation: "secret:",
+	}}
+	if ok, err := configMaps.dependenciesReady(source, "default/app", "team-a/app"); ok {
+		t.Errorf("expected the config map to wait for the outdated secret")
+	} else if err == nil {
+		t.Errorf("expected the reason of the delay")
+	}
+	dependencies.Lock()
+	pending := dependencies.pending["secret:team-a/app"]
+	dependencies.Unlock()
+	if expected := (pendingInstall{configMaps, "default/app", "team-a/app"}); len(pending) != 1 || pending[0] != expected {
+		t.Errorf("expected the config map to be installed once the secret changes, got %v", pending)
+	}
+
+	// it is installed once the secret is up-to-date
+	updated := target.DeepCopy()
+	updated.ResourceVersion = "6"
+	updated.Annotations[secrets.ReplicatedFromVersionAnnotation] = "2"
+	secrets.objectStore.Update(updated)
+	if ok, err := configMaps.dependenciesReady(source, "default/app", "team-a/app"); !ok {
+		t.Errorf("expected the config map to be installed after the updated secret, got %s", err)
+	}
+}
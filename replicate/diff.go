@@ -0,0 +1,100 @@
+package replicate
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+)
+
+// when true, the keys changed by the writes of the secrets and config maps are logged
+var logDiffs bool
+
+// when true, the diffs of the config maps show their values
+var diffValues bool
+
+// LogDiffs sets whether the keys changed by the writes of the existing secrets and config maps are logged
+// The values of the secrets are never logged, the values of the config maps only when showValues is true
+func LogDiffs(enabled bool, showValues bool) {
+	logDiffs = enabled
+	diffValues = showValues
+}
+
+// Returns the kubectl-style diff of the data, one line per changed key, sorted by key
+// The values are only shown when showValues is true, the changed keys are marked with "~" otherwise
+func dataDiff(old map[string][]byte, new map[string][]byte, showValues bool) []string {
+	keys := make([]string, 0, len(old)+len(new))
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, key := range keys {
+		oldValue, inOld := old[key]
+		newValue, inNew := new[key]
+		switch {
+		case inOld && inNew && bytes.Equal(oldValue, newValue):
+		case !showValues && inOld && inNew:
+			lines = append(lines, fmt.Sprintf("~ %s", key))
+		case !showValues && inOld:
+			lines = append(lines, fmt.Sprintf("- %s", key))
+		case !showValues:
+			lines = append(lines, fmt.Sprintf("+ %s", key))
+		default:
+			if inOld {
+				lines = append(lines, fmt.Sprintf("- %s: %s", key, oldValue))
+			}
+			if inNew {
+				lines = append(lines, fmt.Sprintf("+ %s: %s", key, newValue))
+			}
+		}
+	}
+	return lines
+}
+
+// Returns the string data as bytes, to be compared as binary data
+func stringsAsBytes(data map[string]string) map[string][]byte {
+	if data == nil {
+		return nil
+	}
+	converted := make(map[string][]byte, len(data))
+	for key, value := range data {
+		converted[key] = []byte(value)
+	}
+	return converted
+}
+
+// Returns the diff of the keys of the secrets, without their values
+// A nil secret has no keys, as a target being created
+func secretDiff(old *v1.Secret, new *v1.Secret) []string {
+	if old == nil {
+		old = &v1.Secret{}
+	}
+	return dataDiff(old.Data, new.Data, false)
+}
+
+// Returns the diff of the keys of the config maps, with the values of their data when enabled
+// The binary data is never shown, a nil config map has no keys
+func configMapDiff(old *v1.ConfigMap, new *v1.ConfigMap) []string {
+	if old == nil {
+		old = &v1.ConfigMap{}
+	}
+	lines := dataDiff(stringsAsBytes(old.Data), stringsAsBytes(new.Data), diffValues)
+	return append(lines, dataDiff(old.BinaryData, new.BinaryData, false)...)
+}
+
+// Logs the diff of the data of the target being written, when enabled and not empty
+func (r *replicatorProps) logDiff(source string, target string, lines []string) {
+	if !logDiffs || len(lines) == 0 {
+		return
+	}
+	r.infof(source, target, "diff of %s %s:\n%s", r.Name, target, strings.Join(lines, "\n"))
+}
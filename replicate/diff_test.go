@@ -0,0 +1,46 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestDataDiff(t *testing.T) {
+	old := map[string][]byte{"kept": []byte("a"), "changed": []byte("b"), "removed": []byte("c")}
+	new := map[string][]byte{"kept": []byte("a"), "changed": []byte("B"), "added": []byte("d")}
+
+	expected := []string{"+ added", "~ changed", "- removed"}
+	if lines := dataDiff(old, new, false); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected diff %v, got %v", expected, lines)
+	}
+	expected = []string{"+ added: d", "- changed: b", "+ changed: B", "- removed: c"}
+	if lines := dataDiff(old, new, true); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected diff %v, got %v", expected, lines)
+	}
+}
+
+func TestSecretAndConfigMapDiff(t *testing.T) {
+	defer LogDiffs(false, false)
+
+	oldSecret := &v1.Secret{Data: map[string][]byte{"password": []byte("old")}}
+	newSecret := &v1.Secret{Data: map[string][]byte{"password": []byte("new")}}
+	LogDiffs(true, true)
+	// the values of the secrets are never shown
+	if lines := secretDiff(oldSecret, newSecret); !reflect.DeepEqual(lines, []string{"~ password"}) {
+		t.Errorf("expected the password to be redacted, got %v", lines)
+	}
+
+	oldConfigMap := &v1.ConfigMap{Data: map[string]string{"level": "info"}, BinaryData: map[string][]byte{"bin": {0}}}
+	newConfigMap := &v1.ConfigMap{Data: map[string]string{"level": "debug"}, BinaryData: map[string][]byte{"bin": {1}}}
+	expected := []string{"- level: info", "+ level: debug", "~ bin"}
+	if lines := configMapDiff(oldConfigMap, newConfigMap); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected diff %v, got %v", expected, lines)
+	}
+	LogDiffs(true, false)
+	expected = []string{"~ level", "~ bin"}
+	if lines := configMapDiff(oldConfigMap, newConfigMap); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected diff %v, got %v", expected, lines)
+	}
+}
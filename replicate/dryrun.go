@@ -0,0 +1,176 @@
+package replicate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// when true, the writes are recorded instead of being made
+var dryRun bool
+
+// DryRun sets whether the replicators only record the writes they would make, without making them
+// The decisions are the same, but neither the targets, the sources, the events nor the state are written
+func DryRun(enabled bool) {
+	dryRun = enabled
+}
+
+// DryRunAction describes a write which would have been made in dry-run mode
+type DryRunAction struct {
+	Kind string `json:"kind"`
+	// create, update, clear or delete
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Source string `json:"source,omitempty"`
+	// the data fields of the target which would change
+	Changed []string `json:"changed,omitempty"`
+}
+
+// the latest action recorded for each target, as nothing is written the same actions are recorded again on each resync
+var dryRunRecords = struct {
+	sync.Mutex
+	actions map[string]DryRunAction
+}{
+	actions: map[string]DryRunAction{},
+}
+
+// DryRunActions returns the latest write which would have been made to each target, sorted by kind and target
+func DryRunActions() []DryRunAction {
+	dryRunRecords.Lock()
+	defer dryRunRecords.Unlock()
+
+	actions := make([]DryRunAction, 0, len(dryRunRecords.actions))
+	for _, action := range dryRunRecords.actions {
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].Kind != actions[j].Kind {
+			return actions[i].Kind < actions[j].Kind
+		}
+		return actions[i].Target < actions[j].Target
+	})
+	return actions
+}
+
+// Records the action, and logs it unless it was already recorded as is
+func recordDryRun(action DryRunAction) {
+	dryRunRecords.Lock()
+	defer dryRunRecords.Unlock()
+
+	key := action.Kind + ":" + action.Target
+	if last, ok := dryRunRecords.actions[key]; ok && reflect.DeepEqual(last, action) {
+		return
+	}
+	dryRunRecords.actions[key] = action
+
+	message := fmt.Sprintf("dry run: would %s %s %s", action.Action, action.Kind, action.Target)
+	if action.Source != "" {
+		message += fmt.Sprintf(" from %s", action.Source)
+	}
+	if len(action.Changed) > 0 {
+		message += fmt.Sprintf(", changing %s", strings.Join(action.Changed, ", "))
+	}
+	logf("%s", message)
+}
+
+// Returns the data fields which differ between the objects, a nil object having no data
+func changedData(from interface{}, to interface{}) []string {
+	fromData, toData := map[string]json.RawMessage{}, map[string]json.RawMessage{}
+	if from != nil {
+		if data, err := objectData(from); err == nil {
+			fromData = data
+		}
+	}
+	if to != nil {
+		if data, err := objectData(to); err == nil {
+			toData = data
+		}
+	}
+
+	changed := []string{}
+	for _, field := range dataFields {
+		if !bytes.Equal(fromData[field], toData[field]) {
+			changed = append(changed, field)
+		}
+	}
+	return changed
+}
+
+// the actions of a replicator in dry-run mode, which record the writes instead of making them
+// The actions which do not write, such as the listing of the objects, are the ones of the replicator
+type dryRunActions struct {
+	replicatorActions
+}
+
+func (a *dryRunActions) wrapped() replicatorActions {
+	return a.replicatorActions
+}
+
+func (a *dryRunActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	recordDryRun(DryRunAction{
+		Kind:    r.kind(),
+		Action:  "update",
+		Target:  objectKey(a.getMeta(object)),
+		Source:  objectKey(a.getMeta(sourceObject)),
+		Changed: changedData(object, sourceObject),
+	})
+	return nil
+}
+
+func (a *dryRunActions) clear(r *replicatorProps, object interface{}) error {
+	recordDryRun(DryRunAction{
+		Kind:    r.kind(),
+		Action:  "clear",
+		Target:  objectKey(a.getMeta(object)),
+		Changed: changedData(object, nil),
+	})
+	return nil
+}
+
+func (a *dryRunActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	action := DryRunAction{
+		Kind:   r.kind(),
+		Action: "create",
+		Target: objectKey(meta),
+	}
+	// the objects writing their own metadata, such as their status or finalizers, have no source
+	if sourceMeta := a.getMeta(sourceObject); objectKey(sourceMeta) != action.Target {
+		action.Source = objectKey(sourceMeta)
+	}
+
+	var current interface{}
+	if meta.ResourceVersion != "" {
+		action.Action = "update"
+		if object, exists, err := r.objectStore.GetByKey(action.Target); err == nil && exists {
+			current = object
+		}
+	}
+	action.Changed = changedData(current, dataObject)
+	recordDryRun(action)
+	return nil
+}
+
+func (a *dryRunActions) patch(r *replicatorProps, target *metav1.ObjectMeta, p *patchWrite) error {
+	recordDryRun(DryRunAction{
+		Kind:   p.kind,
+		Action: "update",
+		Target: p.key,
+		Source: objectKey(target),
+	})
+	return nil
+}
+
+func (a *dryRunActions) delete(r *replicatorProps, object interface{}) error {
+	recordDryRun(DryRunAction{
+		Kind:   r.kind(),
+		Action: "delete",
+		Target: objectKey(a.getMeta(object)),
+	})
+	return nil
+}
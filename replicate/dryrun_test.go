@@ -0,0 +1,72 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDryRun(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+			objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		},
+		// the client is not set, any write would panic
+		replicatorActions: &dryRunActions{ConfigMapActions},
+	}
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "2"},
+		Data:       map[string]string{"key": "new"},
+	}
+	target := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "target", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "old"},
+	}
+	r.objectStore.Add(source)
+	r.objectStore.Add(target)
+
+	if err := r.update(&r.replicatorProps, target, source); err != nil {
+		t.Errorf("expected the update to be recorded, got %s", err)
+	}
+	if err := r.install(&r.replicatorProps, &metav1.ObjectMeta{Namespace: "team-b", Name: "target"}, source, source); err != nil {
+		t.Errorf("expected the creation to be recorded, got %s", err)
+	}
+	if err := r.delete(&r.replicatorProps, target); err != nil {
+		t.Errorf("expected the deletion to be recorded, got %s", err)
+	}
+
+	// the latest action of each target is kept
+	expected := []DryRunAction{
+		{Kind: "configmap", Action: "delete", Target: "team-a/target"},
+		{Kind: "configmap", Action: "create", Target: "team-b/target", Source: "default/source", Changed: []string{"data"}},
+	}
+	if actions := DryRunActions(); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected actions %v, got %v", expected, actions)
+	}
+	if target.Data["key"] != "old" {
+		t.Errorf("expected the target to be left unchanged")
+	}
+}
+
+func TestChangedData(t *testing.T) {
+	from := &v1.ConfigMap{Data: map[string]string{"key": "value"}}
+	to := &v1.ConfigMap{Data: map[string]string{"key": "value"}, BinaryData: map[string][]byte{"bin": []byte("value")}}
+
+	if changed := changedData(from, to); !reflect.DeepEqual(changed, []string{"binaryData"}) {
+		t.Errorf("expected binaryData to change, got %v", changed)
+	}
+	if changed := changedData(from, nil); !reflect.DeepEqual(changed, []string{"data"}) {
+		t.Errorf("expected data to be cleared, got %v", changed)
+	}
+}
@@ -0,0 +1,302 @@
+package replicate
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewDynamicReplicator creates a new replicator of the resource, with the dynamic client
+// The kubernetes client is used for the namespaces and the events
+func NewDynamicReplicator(client dynamic.Interface, gvr schema.GroupVersionResource, kubeClient kubernetes.Interface, options ReplicatorOptions) Replicator {
+	name := gvr.Resource
+	if gvr.Group != "" {
+		name = fmt.Sprintf("%s.%s", gvr.Resource, gvr.Group)
+	}
+	resource := client.Resource(gvr)
+
+	repl := objectReplicator{
+		replicatorProps:   newReplicatorProps(name, kubeClient, options),
+		replicatorActions: &dynamicActions{resource: resource, gvr: gvr},
+	}
+	repl.queue, repl.rateLimiter = newQueue(repl.Name)
+	repl.logger = options.logger().WithValues("kind", repl.kind())
+
+	namespaces := repl.readLegacy(namespaceListWatch(kubeClient))
+	namespaceLister := &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := namespaces.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.NamespaceList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.namespaceStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return namespaces.Watch(lo)
+		},
+	}
+	namespaceStore, namespaceController := cache.NewInformer(
+		namespaceLister,
+		&v1.Namespace{},
+		options.ResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    repl.NamespaceAdded,
+			UpdateFunc: repl.NamespaceUpdated,
+			DeleteFunc: repl.NamespaceDeleted,
+		},
+	)
+
+	repl.namespaceStore = namespaceStore
+	repl.namespaceController = namespaceController
+	repl.namespaceLister = namespaceLister
+
+	objects := scopedListWatch(func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			DisableChunking: true,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return resource.Namespace(namespace).List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return resource.Namespace(namespace).Watch(lo)
+			},
+		}
+	})
+	objects = repl.readLegacy(repl.pageLists(objects))
+	objects = repl.transformCache(objects)
+	objectListWatch := &cache.ListWatch{
+		// the pages are merged by pageLists, keeping the type of the list
+		DisableChunking: true,
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := objects.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*unstructured.UnstructuredList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.objectStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return objects.Watch(lo)
+		},
+	}
+
+	objectStore, objectController := cache.NewIndexerInformer(
+		objectListWatch,
+		&unstructured.Unstructured{},
+		options.ResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    repl.enqueueAdded,
+			UpdateFunc: repl.enqueueUpdated,
+			DeleteFunc: repl.enqueueDeleted,
+		},
+		repl.indexers(),
+	)
+
+	repl.objectStore = repl.stripStore(objectStore)
+	repl.objectController = objectController
+	repl.objectLister = objectListWatch
+
+	registerReplicator(&repl)
+	options.Metrics.addCollector(repl.collectStaleness)
+	if options.Shards != nil {
+		options.Shards.onChange(repl.resync)
+	}
+
+	return &repl
+}
+
+// the fields of the objects which are not replicated
+var dynamicIgnoredFields = map[string]bool{
+	"apiVersion": true,
+	"kind":       true,
+	"metadata":   true,
+	"status":     true,
+}
+
+// the actions of a resource without typed client
+type dynamicActions struct {
+	resource dynamic.NamespaceableResourceInterface
+	// the resource, for the clients impersonating the service accounts
+	gvr schema.GroupVersionResource
+}
+
+// Returns a copy of the metadata of the object, as they cannot be referenced in an unstructured object
+func (*dynamicActions) getMeta(object interface{}) *metav1.ObjectMeta {
+	meta := &metav1.ObjectMeta{}
+	if content, ok := object.(*unstructured.Unstructured).Object["metadata"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, meta); err != nil {
+			logWith(logger, true, fmt.Sprintf("could not read the metadata of %s/%s: %s",
+				object.(*unstructured.Unstructured).GetNamespace(), object.(*unstructured.Unstructured).GetName(), err))
+		}
+	}
+	return meta
+}
+
+// The dynamic objects are not replicated across clusters
+func (*dynamicActions) listWatch(client kubernetes.Interface) (cache.ListerWatcher, runtime.Object) {
+	return nil, nil
+}
+
+// Copies the replicated fields of the source object
+func copyDynamicFields(object *unstructured.Unstructured, sourceObject *unstructured.Unstructured) {
+	for field := range object.Object {
+		if !dynamicIgnoredFields[field] {
+			delete(object.Object, field)
+		}
+	}
+	for field, value := range sourceObject.Object {
+		if !dynamicIgnoredFields[field] {
+			object.Object[field] = runtime.DeepCopyJSONValue(value)
+		}
+	}
+}
+
+// Sets the metadata of the object
+func setDynamicMeta(object *unstructured.Unstructured, meta *metav1.ObjectMeta) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(meta)
+	if err != nil {
+		return err
+	}
+	object.Object["metadata"] = content
+	return nil
+}
+
+func (a *dynamicActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	source := sourceObject.(*unstructured.Unstructured)
+	target := object.(*unstructured.Unstructured).DeepCopy()
+
+	copyDynamicFields(target, source)
+
+	r.infof(objectKey(source), objectKey(target), "updating %s %s/%s", r.Name, target.GetNamespace(), target.GetName())
+
+	meta := a.getMeta(target)
+	sourceMeta := a.getMeta(source)
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	meta.Annotations[r.ReplicatedFromVersionAnnotation] = sourceMeta.ResourceVersion
+	r.setDataHash(meta, source)
+	r.setSynced(meta, sourceMeta.ResourceVersion)
+	if val, ok := sourceMeta.Annotations[r.ReplicateOnceVersionAnnotation]; ok {
+		meta.Annotations[r.ReplicateOnceVersionAnnotation] = val
+	} else {
+		delete(meta.Annotations, r.ReplicateOnceVersionAnnotation)
+	}
+	r.propagateAnnotations(meta, sourceMeta)
+	r.propagateLabels(meta, sourceMeta)
+	if err := setDynamicMeta(target, meta); err != nil {
+		return err
+	}
+
+	s, err := a.writer(r, a.getMeta(target)).Namespace(target.GetNamespace()).Update(target, metav1.UpdateOptions{})
+	if err != nil {
+		r.errorf(objectKey(source), objectKey(target), "error while updating %s %s/%s: %s", r.Name, target.GetNamespace(), target.GetName(), err)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (a *dynamicActions) clear(r *replicatorProps, object interface{}) error {
+	target := object.(*unstructured.Unstructured).DeepCopy()
+	copyDynamicFields(target, &unstructured.Unstructured{})
+
+	r.infof("", objectKey(target), "clearing %s %s/%s", r.Name, target.GetNamespace(), target.GetName())
+
+	meta := a.getMeta(target)
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	delete(meta.Annotations, r.ReplicatedFromVersionAnnotation)
+	delete(meta.Annotations, r.ReplicatedDataHashAnnotation)
+	delete(meta.Annotations, r.ReplicateOnceVersionAnnotation)
+	if err := setDynamicMeta(target, meta); err != nil {
+		return err
+	}
+
+	s, err := a.writer(r, a.getMeta(target)).Namespace(target.GetNamespace()).Update(target, metav1.UpdateOptions{})
+	if err != nil {
+		r.errorf("", objectKey(target), "error while clearing %s %s/%s", r.Name, target.GetNamespace(), target.GetName())
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (a *dynamicActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	source := sourceObject.(*unstructured.Unstructured)
+	target := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	target.SetAPIVersion(source.GetAPIVersion())
+	target.SetKind(source.GetKind())
+	if err := setDynamicMeta(target, meta); err != nil {
+		return err
+	}
+
+	if dataObject != nil {
+		copyDynamicFields(target, dataObject.(*unstructured.Unstructured))
+	}
+
+	r.infof(objectKey(source), objectKey(target), "installing %s %s/%s", r.Name, target.GetNamespace(), target.GetName())
+
+	var s *unstructured.Unstructured
+	var err error
+	if target.GetResourceVersion() == "" {
+		s, err = a.writer(r, a.getMeta(target)).Namespace(target.GetNamespace()).Create(target, metav1.CreateOptions{})
+	} else {
+		s, err = a.writer(r, a.getMeta(target)).Namespace(target.GetNamespace()).Update(target, metav1.UpdateOptions{})
+	}
+
+	if err != nil {
+		r.errorf(objectKey(source), objectKey(target), "error while installing %s %s/%s: %s", r.Name, target.GetNamespace(), target.GetName(), err)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (a *dynamicActions) delete(r *replicatorProps, object interface{}) error {
+	target := object.(*unstructured.Unstructured)
+	r.infof("", objectKey(target), "deleting %s %s/%s", r.Name, target.GetNamespace(), target.GetName())
+
+	resourceVersion := target.GetResourceVersion()
+	options := metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &resourceVersion,
+		},
+	}
+
+	err := a.writer(r, a.getMeta(target)).Namespace(target.GetNamespace()).Delete(target.GetName(), &options)
+	if err != nil {
+		r.errorf("", objectKey(target), "error while deleting %s %s/%s: %s", r.Name, target.GetNamespace(), target.GetName(), err)
+		return err
+	}
+
+	r.objectStore.Delete(target)
+	return nil
+}
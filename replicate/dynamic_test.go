@@ -0,0 +1,57 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCopyDynamicFields(t *testing.T) {
+	source := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1alpha2",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"namespace": "default", "name": "source"},
+		"spec":       map[string]interface{}{"secretName": "tls", "dnsNames": []interface{}{"example.com"}},
+		"status":     map[string]interface{}{"ready": true},
+	}}
+	target := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "tenant-a", "name": "target"},
+		"previous": "value",
+	}}
+
+	copyDynamicFields(target, source)
+	if _, ok := target.Object["previous"]; ok {
+		t.Errorf("expected the previous fields to be removed")
+	}
+	if !reflect.DeepEqual(target.Object["spec"], source.Object["spec"]) {
+		t.Errorf("expected spec %v, got %v", source.Object["spec"], target.Object["spec"])
+	}
+	if _, ok := target.Object["status"]; ok {
+		t.Errorf("expected the status not to be copied")
+	}
+	if target.GetName() != "target" || target.GetNamespace() != "tenant-a" {
+		t.Errorf("expected the metadata to be kept, got %s/%s", target.GetNamespace(), target.GetName())
+	}
+
+	source.Object["spec"].(map[string]interface{})["secretName"] = "modified"
+	if target.Object["spec"].(map[string]interface{})["secretName"] != "tls" {
+		t.Errorf("expected the fields to be copied, not shared")
+	}
+}
+
+func TestDynamicGetMeta(t *testing.T) {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"namespace":       "default",
+			"name":            "source",
+			"resourceVersion": "42",
+			"annotations":     map[string]interface{}{"key": "value"},
+		},
+	}}
+
+	meta := (&dynamicActions{}).getMeta(object)
+	if meta.Namespace != "default" || meta.Name != "source" || meta.ResourceVersion != "42" || meta.Annotations["key"] != "value" {
+		t.Errorf("unexpected metadata %v", meta)
+	}
+}
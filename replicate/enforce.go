@@ -0,0 +1,96 @@
+package replicate
+
+import (
+	"strconv"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Remembers the targets whose data was modified by an update, to revert them if their source is enforced
+// Must be called with the status lock held
+func (r *objectReplicator) markEdited(old interface{}, new interface{}) {
+	meta := r.getMeta(new)
+	if _, ok := meta.Annotations[r.ReplicatedByAnnotation]; !ok {
+		if _, ok := meta.Annotations[r.ReplicateFromAnnotation]; !ok {
+			return
+		}
+	}
+	oldHash, err := dataHash(old)
+	if err != nil {
+		return
+	} else if newHash, err := dataHash(new); err != nil || newHash == oldHash {
+		return
+	}
+
+	if r.editedTargets == nil {
+		r.editedTargets = map[string]bool{}
+	}
+	r.editedTargets[objectKey(meta)] = true
+}
+
+// Reverts the target if it was modified since its replication, and its source is annotated to be enforced
+// Returns true if the target was reverted
+func (r *objectReplicator) enforceTarget(object interface{}) bool {
+	meta := r.getMeta(object)
+	key := objectKey(meta)
+	r.statusLock.Lock()
+	edited := r.editedTargets[key]
+	delete(r.editedTargets, key)
+	r.statusLock.Unlock()
+	if !edited {
+		return false
+	}
+
+	source, replicatedTo := meta.Annotations[r.ReplicatedByAnnotation], true
+	if source == "" {
+		source, _ = resolveAnnotation(meta, r.ReplicateFromAnnotation)
+		replicatedTo = false
+	}
+	sourceObject, exists, err := r.objectStore.GetByKey(source)
+	if err != nil || !exists {
+		return false
+	}
+	sourceMeta := r.getMeta(sourceObject)
+	if enforced, _ := strconv.ParseBool(sourceMeta.Annotations[r.ReplicateEnforceAnnotation]); !enforced {
+		return false
+	}
+	// only the targets which still claim to be up to date were modified by hand
+	if !r.isSourceVersion(sourceMeta, meta.Annotations[r.ReplicatedFromVersionAnnotation]) {
+		return false
+	} else if !r.isVerbatimCopy(meta, sourceMeta) {
+		return false
+	}
+	targetHash, err := dataHash(object)
+	if err != nil {
+		return false
+	} else if sourceHash, err := dataHash(sourceObject); err != nil || targetHash == sourceHash {
+		return false
+	}
+
+	manager := editManager(meta)
+	r.drift(source, key, "enforced")
+	r.recordEvent(object, v1.EventTypeWarning, "ReplicaEditReverted",
+		"%s %s was modified by %s, reverting it to the data of %s", r.Name, key, manager, source)
+	r.driftCorrected(source, key, "enforced", r.revertDrift(key, object, sourceObject, replicatedTo))
+	return true
+}
+
+// Returns the manager which wrote the object last, other than the replicator
+func editManager(meta *metav1.ObjectMeta) string {
+	manager, last := "an unknown manager", (*metav1.Time)(nil)
+	for i, entry := range meta.ManagedFields {
+		if entry.Manager == fieldManager || entry.Manager == "" {
+			continue
+		}
+		// the applied fields have no time, the updated ones win
+		at := &metav1.Time{}
+		if entry.Time != nil {
+			at = meta.ManagedFields[i].Time
+		}
+		if last == nil || !at.Before(last) {
+			manager, last = entry.Manager, at
+		}
+	}
+	return manager
+}
@@ -0,0 +1,80 @@
+package replicate
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestEnforceTarget(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+		},
+		// the client is not set, any write would panic
+		replicatorActions: &dryRunActions{ConfigMapActions},
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+
+	source := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "2",
+		Annotations: map[string]string{r.ReplicateEnforceAnnotation: "true"}},
+		Data: map[string]string{"key": "value"}}
+	old := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "target", ResourceVersion: "5",
+		Annotations: map[string]string{r.ReplicateFromAnnotation: "default/source", r.ReplicatedFromVersionAnnotation: "2"}},
+		Data: map[string]string{"key": "value"}}
+	edited := old.DeepCopy()
+	edited.ResourceVersion = "6"
+	edited.Data["key"] = "edited"
+	r.objectStore.Add(source)
+	r.objectStore.Add(edited)
+
+	// only the updates changing the data are reverted
+	r.markEdited(old, old)
+	if r.enforceTarget(old) {
+		t.Errorf("expected an unmodified target not to be reverted")
+	}
+
+	source.Annotations[r.ReplicateEnforceAnnotation] = "false"
+	r.markEdited(old, edited)
+	if r.enforceTarget(edited) {
+		t.Errorf("expected the target of a source not enforced not to be reverted")
+	}
+	if r.enforceTarget(edited) {
+		t.Errorf("expected the edit to be forgotten once processed")
+	}
+
+	source.Annotations[r.ReplicateEnforceAnnotation] = "true"
+	r.markEdited(old, edited)
+	if !r.enforceTarget(edited) {
+		t.Errorf("expected the target of an enforced source to be reverted")
+	}
+	if actions := DryRunActions(); len(actions) != 1 || actions[0].Target != "team-a/target" {
+		t.Errorf("expected the update of the target, got %v", actions)
+	}
+}
+
+func TestEditManager(t *testing.T) {
+	at := func(minutes int) *metav1.Time {
+		return &metav1.Time{Time: time.Date(2020, 1, 1, 0, minutes, 0, 0, time.UTC)}
+	}
+	meta := &metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{
+		{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, Time: at(1)},
+		{Manager: "helm", Operation: metav1.ManagedFieldsOperationUpdate, Time: at(2)},
+		{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate, Time: at(3)},
+	}}
+	if manager := editManager(meta); manager != "helm" {
+		t.Errorf("expected the last manager other than the replicator, got %s", manager)
+	}
+	if manager := editManager(&metav1.ObjectMeta{}); manager != "an unknown manager" {
+		t.Errorf("expected an unknown manager, got %s", manager)
+	}
+}
@@ -0,0 +1,58 @@
+package replicate
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+)
+
+// the component reported as source of the events
+const eventComponent = "kubernetes-replicator"
+
+// Records an event on the object, asynchronously
+func (r *replicatorProps) recordEvent(object interface{}, eventType string, reason string, messageFmt string, args ...interface{}) {
+	// nothing is written in dry-run mode
+	if dryRun {
+		return
+	}
+	ref, err := reference.GetReference(scheme.Scheme, object.(runtime.Object))
+	if err != nil {
+		r.errorf("", "", "could not record event %s: %s", reason, err)
+		return
+	}
+
+	message := fmt.Sprintf(messageFmt, args...)
+	// the same event was already recorded within the deduplication window
+	if ok, _ := events.emit(fmt.Sprintf("%s/%s %s %s %s",
+		ref.Namespace, ref.Name, eventType, reason, message)); !ok {
+		return
+	}
+
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", ref.Name, now.UnixNano()),
+			Namespace: ref.Namespace,
+		},
+		InvolvedObject: *ref,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: eventComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	go func() {
+		if _, err := r.client.CoreV1().Events(event.Namespace).Create(event); err != nil {
+			r.errorf("", "", "could not record event %s on %s %s/%s: %s",
+				reason, r.Name, ref.Namespace, ref.Name, err)
+		}
+	}()
+}
@@ -0,0 +1,141 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Explain traces the decisions of the replicator on an object of its kind, without writing anything: the targets of
+// the object as a source, and whether it is updated from its source as a target
+// Returns false if the kind is not the one of the replicator
+// If the replicator is not started, the namespaces and the objects are listed first
+func (r *objectReplicator) Explain(kind string, key string) ([]string, bool, error) {
+	if strings.ToLower(kind) != r.kind() {
+		return nil, false, nil
+	}
+	if err := r.listUnstarted(); err != nil {
+		return nil, true, err
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	object, exists, err := r.objectStore.GetByKey(key)
+	if err != nil {
+		return nil, true, err
+	} else if !exists {
+		return []string{fmt.Sprintf("%s %s does not exist", r.Name, key)}, true, nil
+	}
+	meta := r.getMeta(object)
+	if !r.isManaged(meta) {
+		return []string{fmt.Sprintf("%s %s is managed by another instance of the replicator", r.Name, key)}, true, nil
+	}
+
+	trace := r.explainTargets(object)
+	trace = append(trace, r.explainSource(object)...)
+	if len(trace) == 0 {
+		trace = append(trace, fmt.Sprintf("%s %s is neither a source nor a target", r.Name, key))
+	}
+	return trace, true, nil
+}
+
+// Traces the targets of the object as a source, and whether each of them is replicated
+func (r *objectReplicator) explainTargets(object interface{}) []string {
+	meta := r.getMeta(object)
+	key := objectKey(meta)
+	targets, targetPatterns, err := r.getReplicationTargets(meta)
+	if err != nil {
+		return []string{fmt.Sprintf("source %s has invalid annotations: %s", key, err)}
+	} else if len(targets) == 0 && len(targetPatterns) == 0 {
+		return nil
+	}
+
+	trace := []string{}
+	seen := map[string]bool{key: true}
+	explain := func(target string, origin string) {
+		if seen[target] {
+			return
+		}
+		seen[target] = true
+		parts := strings.SplitN(target, "/", 2)
+		targetMeta := &metav1.ObjectMeta{Namespace: parts[0], Name: parts[1]}
+		status := "would be created"
+		targetObject, exists, err := r.objectStore.GetByKey(target)
+		if err == nil && exists {
+			targetMeta = r.getMeta(targetObject)
+			status = "would be updated"
+		}
+
+		if _, exists, err := r.namespaceStore.GetByKey(parts[0]); err == nil && !exists {
+			status = fmt.Sprintf("is skipped: no namespace %s", parts[0])
+		} else if err := checkTargetNamespace(parts[0]); err != nil {
+			status = fmt.Sprintf("is cancelled: %s", err)
+		} else if ok, err := r.isReplicationAllowed(targetMeta, meta); !ok {
+			status = fmt.Sprintf("is cancelled: %s", err)
+		} else if targetMeta.ResourceVersion == "" {
+		} else if ok, err := r.isReplicatedBy(targetMeta, meta); ok {
+			if ok, _, err := r.needsDataUpdate(targetMeta, meta, r.dataHashes(targetObject, object)); !ok {
+				status = fmt.Sprintf("is skipped: %s", err)
+			}
+		} else if adopt, err2 := r.canAdopt(targetMeta, meta); err2 != nil {
+			status = fmt.Sprintf("is cancelled: %s", err2)
+		} else if !adopt {
+			status = fmt.Sprintf("is cancelled: %s", err)
+		} else {
+			status = "would adopt the existing target"
+		}
+		trace = append(trace, fmt.Sprintf("source %s replicates to %s (%s): %s", key, target, origin, status))
+	}
+
+	// the targets are collected in maps
+	sort.Strings(targets)
+	for _, target := range targets {
+		explain(target, "replicate-to")
+	}
+	namespaces := r.namespaceStore.ListKeys()
+	for _, pattern := range targetPatterns {
+		matched := pattern.Targets(namespaces)
+		if len(matched) == 0 {
+			trace = append(trace, fmt.Sprintf("source %s has a pattern matching no namespace", key))
+		}
+		for _, target := range matched {
+			explain(target, "namespace pattern")
+		}
+	}
+	return trace
+}
+
+// Traces whether the object is updated from the source of its replicate-from annotation
+func (r *objectReplicator) explainSource(object interface{}) []string {
+	meta := r.getMeta(object)
+	key := objectKey(meta)
+	val, ok := resolveAnnotation(meta, r.ReplicateFromAnnotation)
+	if !ok {
+		return nil
+	} else if strings.Contains(val, ",") {
+		return []string{fmt.Sprintf("target %s merges the sources %s, which are not explained", key, val)}
+	}
+
+	sourceObject, exists, err := r.getSource(val)
+	if err != nil {
+		return []string{fmt.Sprintf("target %s is replicated from %s, which cannot be read: %s", key, val, err)}
+	} else if !exists {
+		return []string{fmt.Sprintf("target %s is replicated from %s, which does not exist: its data is cleared", key, val)}
+	}
+	sourceMeta := r.getMeta(sourceObject)
+
+	status := "would be updated"
+	if err := checkTargetNamespace(meta.Namespace); err != nil {
+		status = fmt.Sprintf("is cancelled: %s", err)
+	} else if ok, err := r.isReplicationAllowed(meta, sourceMeta); !ok {
+		status = fmt.Sprintf("is cancelled: %s, its data is cleared", err)
+	} else if ok, _, err := r.needsDataUpdate(meta, sourceMeta, r.dataHashes(object, sourceObject)); !ok {
+		status = fmt.Sprintf("is skipped: %s", err)
+	} else if ok, err := r.isApproved(meta, sourceMeta); !ok {
+		status = fmt.Sprintf("is delayed: %s", err)
+	}
+	return []string{fmt.Sprintf("target %s is replicated from %s: %s", key, val, status)}
+}
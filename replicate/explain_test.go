@@ -0,0 +1,57 @@
+package replicate
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestExplain(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:                "secret",
+			annotationNames:     annotationsWithPrefix(""),
+			objectController:    syncedController{},
+			namespaceStore:      cache.NewStore(cache.MetaNamespaceKeyFunc),
+			namespaceController: syncedController{},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "10",
+		Annotations: map[string]string{r.ReplicateToAnnotation: "team-a/source,team-b/source"}}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "target",
+		Annotations: map[string]string{r.ReplicateFromAnnotation: "default/source"}}})
+
+	trace, ok, err := r.Explain("Secret", "default/source")
+	if err != nil || !ok {
+		t.Fatalf("expected the source to be explained, got %v, %v", ok, err)
+	}
+	if len(trace) != 2 || !strings.Contains(trace[0], "does not explicitely allow replication") ||
+		!strings.Contains(trace[1], "no namespace team-b") {
+		t.Errorf("expected the targets to be denied and missing, got %v", trace)
+	}
+
+	trace, _, _ = r.Explain("Secret", "team-a/target")
+	if len(trace) != 1 || !strings.Contains(trace[0], "does not explicitely allow replication") {
+		t.Errorf("expected the target to be denied, got %v", trace)
+	}
+
+	r.allowAll = true
+	trace, _, _ = r.Explain("Secret", "team-a/target")
+	if len(trace) != 1 || !strings.HasSuffix(trace[0], "would be updated") {
+		t.Errorf("expected the target to be updated, got %v", trace)
+	}
+
+	if _, ok, _ := r.Explain("ConfigMap", "team-a/target"); ok {
+		t.Errorf("expected a config map not to be explained by the secret replicator")
+	}
+	if trace, _, _ := r.Explain("Secret", "team-a/missing"); len(trace) != 1 || !strings.Contains(trace[0], "does not exist") {
+		t.Errorf("expected the missing object to be reported, got %v", trace)
+	}
+}
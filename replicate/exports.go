@@ -0,0 +1,166 @@
+package replicate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExternalDestination writes the copies of the sources to a store outside of the clusters, such as Vault
+// The external sources implementing it are the destinations of the replicate-to annotation formatted as "scheme:path"
+type ExternalDestination interface {
+	// Put writes the data at the path
+	Put(path string, data map[string][]byte) error
+	// Delete deletes the data at the path
+	Delete(path string) error
+}
+
+// the copies written to the external destinations, to delete them once they are not targeted anymore
+var exports = struct {
+	sync.Mutex
+	// a {kind:source => {scheme:path => version}} map of the copies written so far
+	versions map[string]map[string]string
+}{versions: map[string]map[string]string{}}
+
+// Returns the external destination and the path of a target formatted as "scheme:path"
+// Returns false if the scheme is not the one of an external destination
+func externalDestinationOf(key string) (ExternalDestination, string, bool) {
+	source, path, ok := externalSourceOf(key)
+	if !ok {
+		return nil, "", false
+	}
+	destination, ok := source.(ExternalDestination)
+	return destination, path, ok
+}
+
+// Returns the sorted external destinations of the replicate-to annotation of the source
+func (r *replicatorProps) externalTargets(object *metav1.ObjectMeta) []string {
+	targets := []string{}
+	for _, target := range strings.Split(object.Annotations[r.ReplicateToAnnotation], ",") {
+		if _, _, ok := externalDestinationOf(target); ok {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Writes the data of the source to the external destinations of its replicate-to annotation, and deletes the copies
+// written to the other destinations, or all of them if the source is deleted
+// As for the targets in the namespaces, the source must allow the replication, the scheme of the destination
+// being matched as a namespace
+func (r *objectReplicator) pushToExternal(object interface{}, deleted bool) {
+	if !mergeableKinds[r.kind()] {
+		return
+	}
+	meta := r.getMeta(object)
+	key := objectKey(meta)
+	ref := r.kind() + ":" + key
+	// nothing is written while paused, the external destinations are not written by the actions
+	if ok, err := r.isRunning(meta); !ok {
+		r.debugf(key, "", "replication of %s %s to the external destinations is skipped: %s", r.Name, key, err)
+		return
+	}
+
+	exports.Lock()
+	versions := exports.versions[ref]
+	exports.Unlock()
+	desired := map[string]string{}
+	if !deleted {
+		for _, target := range r.externalTargets(meta) {
+			if versions[target] == meta.ResourceVersion {
+				desired[target] = meta.ResourceVersion
+				continue
+			}
+			// two sources would overwrite each other
+			if err := checkExternalTarget(ref, target); err != nil {
+				r.errorf(key, target, "replication of %s %s to %s is cancelled: %s", r.Name, key, target, err)
+				r.reportError(err)
+				continue
+			}
+			parts := strings.SplitN(target, ":", 2)
+			if ok, err := r.isReplicationAllowed(&metav1.ObjectMeta{Namespace: parts[0], Name: parts[1]}, meta); !ok {
+				r.infof(key, target, "replication of %s %s to %s is cancelled: %s", r.Name, key, target, err)
+				continue
+			}
+			if err := r.track(target, key, "updated", r.exportObject(object, target)); err != nil {
+				r.errorf(key, target, "could not write %s %s to %s: %s", r.Name, key, target, err)
+				// the former copy is kept, and written again when the source is processed again
+				if version, ok := versions[target]; ok {
+					desired[target] = version
+				}
+				continue
+			}
+			desired[target] = meta.ResourceVersion
+		}
+	}
+
+	for target := range versions {
+		if _, ok := desired[target]; ok {
+			continue
+		}
+		r.infof(key, target, "%s %s is not replicated to %s anymore: deleting it", r.Name, key, target)
+		destination, path, _ := externalDestinationOf(target)
+		if dryRun {
+			recordDryRun(DryRunAction{Kind: r.Name, Action: "delete", Target: target, Source: key})
+		} else if err := r.track(target, key, "deleted", destination.Delete(path)); err != nil {
+			r.errorf(key, target, "could not delete %s: %s", target, err)
+			// deleted again when the source is processed again
+			desired[target] = ""
+		}
+	}
+
+	exports.Lock()
+	defer exports.Unlock()
+	if len(desired) == 0 {
+		delete(exports.versions, ref)
+	} else {
+		exports.versions[ref] = desired
+	}
+}
+
+// Writes the data of the source to the external destination, unless it already has this data
+func (r *objectReplicator) exportObject(object interface{}, target string) error {
+	destination, path, _ := externalDestinationOf(target)
+	var data map[string][]byte
+	switch source := object.(type) {
+	case *v1.Secret:
+		data = source.Data
+	case *v1.ConfigMap:
+		data = convertObject(source).(*v1.Secret).Data
+	}
+	if data == nil {
+		data = map[string][]byte{}
+	}
+
+	// the destination is not written again after a restart if it has the data
+	if source, ok := destination.(ExternalSource); ok {
+		if existing, err := source.Get(path); err == nil && existing != nil && reflect.DeepEqual(existing.Data, data) {
+			return nil
+		}
+	}
+	key := objectKey(r.getMeta(object))
+	if dryRun {
+		recordDryRun(DryRunAction{Kind: r.Name, Action: "update", Target: target, Source: key})
+		return nil
+	}
+	r.infof(key, target, "writing %s %s to %s", r.Name, key, target)
+	return destination.Put(path, data)
+}
+
+// Returns an error if the external destination is written by several sources
+func checkExternalTarget(ref string, target string) error {
+	exports.Lock()
+	defer exports.Unlock()
+	for other, versions := range exports.versions {
+		if _, ok := versions[target]; ok && other != ref {
+			return fmt.Errorf("%s is already written by %s", target, other)
+		}
+	}
+	return nil
+}
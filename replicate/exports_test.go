@@ -0,0 +1,104 @@
+package replicate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPushToExternal(t *testing.T) {
+	// a vault server storing the secrets of the version 2 of the engine
+	lock := sync.Mutex{}
+	stored := map[string]json.RawMessage{}
+	writes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch req.Method {
+		case http.MethodPost:
+			body := struct {
+				Data json.RawMessage `json:"data"`
+			}{}
+			json.NewDecoder(req.Body).Decode(&body)
+			stored[req.URL.Path] = body.Data
+			writes++
+		case http.MethodDelete:
+			delete(stored, req.URL.Path)
+		default:
+			if data, ok := stored[req.URL.Path]; ok {
+				json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data, "metadata": map[string]int{"version": 1}}})
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}))
+	defer server.Close()
+	ConfigureExternalSources(map[string]ExternalSource{
+		"vault": NewVaultSource(server.URL, func() (string, error) { return "", nil }),
+	}, "", 0)
+	defer ConfigureExternalSources(map[string]ExternalSource{}, "", 0)
+
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			retries:         map[string]*retryState{},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.queue, r.rateLimiter = newQueue("secret")
+	defer r.queue.ShutDown()
+
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "1",
+			Annotations: map[string]string{r.ReplicateToAnnotation: "other,vault:secret/data/database"}},
+		Data: map[string][]byte{"password": []byte("secret")},
+	}
+	if targets := r.externalTargets(&source.ObjectMeta); len(targets) != 1 || targets[0] != "vault:secret/data/database" {
+		t.Errorf("expected the vault destination, got %v", targets)
+	}
+	if targets, _, err := r.getReplicationTargets(&source.ObjectMeta); err != nil || len(targets) != 1 {
+		t.Errorf("expected the destination to be ignored by the targets in the namespaces, got %v %v", targets, err)
+	}
+
+	// the source must allow the replication
+	r.pushToExternal(source, false)
+	if len(stored) != 0 {
+		t.Errorf("expected no write without permission, got %v", stored)
+	}
+	source.Annotations[r.ReplicationAllowedNamespaces] = "vault"
+	r.pushToExternal(source, false)
+	if data := string(stored["/v1/secret/data/database"]); data != `{"password":"secret"}` {
+		t.Errorf("expected the data of the source, got %s", data)
+	}
+
+	// the destination is written once for each version, if its data changed
+	r.pushToExternal(source, false)
+	delete(exports.versions, "secret:default/source")
+	source.ResourceVersion = "2"
+	r.pushToExternal(source, false)
+	if writes != 1 {
+		t.Errorf("expected a single write, got %d", writes)
+	}
+
+	// another source cannot write the same destination
+	other := source.DeepCopy()
+	other.Name = "other"
+	other.ResourceVersion = "3"
+	r.pushToExternal(other, false)
+	if writes != 1 {
+		t.Errorf("expected no write from another source, got %d", writes)
+	}
+
+	r.pushToExternal(source, true)
+	if len(stored) != 0 || len(exports.versions) != 0 {
+		t.Errorf("expected the copy to be deleted with the source, got %v", stored)
+	}
+}
@@ -0,0 +1,138 @@
+package replicate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ExternalSource reads the data of the sources stored outside of the clusters, such as in Vault
+type ExternalSource interface {
+	// Get returns the data at the path, or nil if nothing is stored at the path
+	Get(path string) (*ExternalData, error)
+}
+
+// ExternalData is the data of an external source, with its versions
+type ExternalData struct {
+	Data map[string][]byte
+	// changes with the data, so that the targets are only updated on changes
+	Version string
+	// the identifier of the version of the data, empty if the source has no versions
+	// the targets replicated once are replicated again when another version is stored
+	OnceVersion string
+}
+
+// a {scheme => source} map of the external sources, referred to by replicate-from as "scheme:path"
+var externalSources = map[string]ExternalSource{}
+
+// the namespaces the external sources can be replicated to, as for the replication-allowed-namespaces annotation
+// empty to follow --allow-all
+var externalAllowedNamespaces string
+
+// how often the targets of the external sources are processed again, as their changes are not watched
+var externalRefresh time.Duration
+
+// ConfigureExternalSources registers the external sources by scheme, the namespaces their data can be replicated to,
+// and how often their data is read again
+func ConfigureExternalSources(sources map[string]ExternalSource, allowedNamespaces string, refresh time.Duration) {
+	externalSources = sources
+	externalAllowedNamespaces = allowedNamespaces
+	externalRefresh = refresh
+}
+
+// Returns the external source and the path of a source formatted as "scheme:path"
+// Returns false if the scheme is not the one of an external source
+func externalSourceOf(key string) (ExternalSource, string, bool) {
+	parts := strings.SplitN(key, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, "", false
+	}
+	source, ok := externalSources[parts[0]]
+	return source, parts[1], ok
+}
+
+// Returns the source read from the external source, as a secret or a config map of the scheme and path
+// The version of the data is the resource version of the source, and its ordered version is the replicate-once-version
+// annotation of the source
+func (r *objectReplicator) getExternalSource(source ExternalSource, key string, path string) (interface{}, bool, error) {
+	if !mergeableKinds[r.kind()] {
+		return nil, false, fmt.Errorf("external source %s: only secrets and config maps can be replicated from external sources", key)
+	}
+	data, err := source.Get(path)
+	if err != nil || data == nil {
+		return nil, false, err
+	}
+
+	secret := &v1.Secret{
+		TypeMeta: metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       strings.SplitN(key, ":", 2)[0],
+			Name:            path,
+			ResourceVersion: data.Version,
+			Annotations:     map[string]string{},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: data.Data,
+	}
+	if externalAllowedNamespaces != "" {
+		secret.Annotations[r.ReplicationAllowedNamespaces] = externalAllowedNamespaces
+	}
+	if data.OnceVersion != "" {
+		secret.Annotations[r.ReplicateOnceVersionAnnotation] = data.OnceVersion
+	}
+	if r.kind() == "configmap" {
+		return convertObject(secret), true, nil
+	}
+	return secret, true, nil
+}
+
+// Returns the data of the fields of a JSON object, the values which are not strings being written as JSON
+func externalFields(fields map[string]json.RawMessage) map[string][]byte {
+	data := map[string][]byte{}
+	for key, raw := range fields {
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			data[key] = []byte(value)
+		} else {
+			data[key] = []byte(raw)
+		}
+	}
+	return data
+}
+
+// Returns the version of the data, a hash changing with any key or value
+func externalVersion(data map[string][]byte) string {
+	// the keys are sorted by the JSON encoding
+	raw, _ := json.Marshal(data)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Processes again the targets of the external sources periodically, so that the changes of their data are replicated
+func (r *objectReplicator) runExternalSources() {
+	if len(externalSources) == 0 || externalRefresh <= 0 || !mergeableKinds[r.kind()] {
+		return
+	}
+
+	go wait.Until(func() {
+		if !r.Synced() {
+			return
+		}
+		for _, source := range r.objectStore.ListIndexFuncValues(replicateFromIndex) {
+			if _, _, ok := externalSourceOf(source); !ok {
+				continue
+			}
+			for _, target := range r.dependentsOf(source) {
+				r.debugf(source, target, "refreshing %s %s from external source %s", r.Name, target, source)
+				r.queue.Add(target)
+			}
+		}
+	}, externalRefresh, wait.NeverStop)
+}
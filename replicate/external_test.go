@@ -0,0 +1,161 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVaultSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch req.URL.Path {
+		case "/v1/secret/data/database":
+			fmt.Fprint(w, `{"data": {"data": {"password": "secret", "port": 5432}, "metadata": {"version": 2}}}`)
+		case "/v1/kv/database":
+			fmt.Fprint(w, `{"data": {"password": "secret"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	vault := NewVaultSource(server.URL, func() (string, error) { return "token", nil })
+	data, err := vault.Get("secret/data/database")
+	if err != nil || data == nil {
+		t.Fatalf("expected the secret to exist, got %v", err)
+	}
+	if string(data.Data["password"]) != "secret" || string(data.Data["port"]) != "5432" {
+		t.Errorf("expected the data of the version 2 of the engine, got %v", data.Data)
+	}
+	if data.OnceVersion != "2" {
+		t.Errorf("expected the version of the secret, got %s", data.OnceVersion)
+	}
+	other, err := vault.Get("kv/database")
+	if err != nil || other == nil || string(other.Data["password"]) != "secret" {
+		t.Errorf("expected the data of the version 1 of the engine, got %v %v", other, err)
+	} else if data.Version == other.Version || other.OnceVersion != "" {
+		t.Errorf("expected the versions of different data to differ, without ordered version")
+	}
+	if data, err := vault.Get("secret/data/missing"); err != nil || data != nil {
+		t.Errorf("expected a missing secret, got %v", err)
+	}
+
+	denied := NewVaultSource(server.URL, func() (string, error) { return "other", nil })
+	if _, err := denied.Get("secret/data/database"); err == nil {
+		t.Errorf("expected an error without permission")
+	}
+}
+
+func TestExternalSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"data": {"data": {"password": "secret"}, "metadata": {"version": 1}}}`)
+	}))
+	defer server.Close()
+	ConfigureExternalSources(map[string]ExternalSource{
+		"vault": NewVaultSource(server.URL, func() (string, error) { return "", nil }),
+	}, "team-.*", 0)
+	defer ConfigureExternalSources(map[string]ExternalSource{}, "", 0)
+
+	r := &objectReplicator{
+		replicatorProps:   replicatorProps{Name: "config map", annotationNames: annotationsWithPrefix("")},
+		replicatorActions: ConfigMapActions,
+	}
+	if !validSourcePaths("vault:secret/data/database,default/common") {
+		t.Errorf("expected the external source to be a valid source")
+	}
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "target",
+		Annotations: map[string]string{r.ReplicateFromAnnotation: "vault:secret"}}
+	if val, _ := resolveAnnotation(target, r.ReplicateFromAnnotation); val != "vault:secret" {
+		t.Errorf("expected the path of the external source unchanged, got %s", val)
+	}
+
+	object, exists, err := r.getSource("vault:secret/data/database")
+	if err != nil || !exists {
+		t.Fatalf("expected the external source to exist, got %v", err)
+	}
+	configMap := object.(*v1.ConfigMap)
+	if configMap.Data["password"] != "secret" || configMap.ResourceVersion == "" || configMap.Annotations[r.ReplicateOnceVersionAnnotation] != "1" {
+		t.Errorf("expected a config map with the data and its version, got %v", configMap)
+	}
+	if ok, err := r.isReplicationAllowed(target, &configMap.ObjectMeta); !ok {
+		t.Errorf("expected the replication to team-a to be allowed, got %s", err)
+	}
+	target.Namespace = "default"
+	if ok, _ := r.isReplicationAllowed(target, &configMap.ObjectMeta); ok {
+		t.Errorf("expected the replication to default to be denied")
+	}
+}
+
+func TestExternalSourceReplicatedOnce(t *testing.T) {
+	ConfigureExternalSources(map[string]ExternalSource{"aws-sm": NewAWSSecretsManagerSource(&AWSCredentials{})}, "", 0)
+	defer ConfigureExternalSources(map[string]ExternalSource{}, "", 0)
+
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}
+	// the version ids are not ordered, even when they look like semver versions
+	source := &metav1.ObjectMeta{Namespace: "aws-sm", Name: "prod/database", ResourceVersion: "12345678-aaaa",
+		Annotations: map[string]string{r.ReplicateOnceVersionAnnotation: "12345678-aaaa"}}
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "database", Annotations: map[string]string{
+		r.ReplicateOnceAnnotation:         "true",
+		r.ReplicatedFromVersionAnnotation: "22345678-bbbb",
+		r.ReplicateOnceVersionAnnotation:  "22345678-bbbb",
+	}}
+	if ok, _, err := r.needsDataUpdate(target, source, dataHashes{}); !ok {
+		t.Errorf("expected the target to be replicated again once another version is stored, got %s", err)
+	}
+
+	target.Annotations[r.ReplicateOnceVersionAnnotation] = "12345678-aaaa"
+	target.Annotations[r.ReplicatedFromVersionAnnotation] = "0"
+	if ok, once, _ := r.needsDataUpdate(target, source, dataHashes{}); ok || !once {
+		t.Errorf("expected the target not to be replicated again at the same version")
+	}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	// a vault server storing the secrets of the version 2 of the engine
+	stored := map[string]json.RawMessage{}
+	writes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodPost:
+			body := struct {
+				Data json.RawMessage `json:"data"`
+			}{}
+			json.NewDecoder(req.Body).Decode(&body)
+			stored[req.URL.Path] = body.Data
+			writes++
+		case http.MethodGet:
+			if data, ok := stored[req.URL.Path]; ok {
+				fmt.Fprintf(w, `{"data": {"data": %s, "metadata": {"version": %d}}}`, data, writes)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}))
+	defer server.Close()
+
+	vault := NewVaultSource(server.URL, func() (string, error) { return "token", nil })
+	data := map[string][]byte{"password": []byte("s3cr3t"), "config": []byte(`{"port": 5432}`), "empty": {}}
+	if err := vault.Put("secret/data/database", data); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := vault.Get("secret/data/database"); err != nil || read == nil || !reflect.DeepEqual(read.Data, data) {
+		t.Errorf("expected the data to be read as written, got %v %v", read, err)
+	}
+
+	// the binary values cannot be read back
+	if err := vault.Put("secret/data/binary", map[string][]byte{"key": {0xff, 0xfe}}); err == nil {
+		t.Errorf("expected the binary value to be rejected")
+	} else if writes != 1 {
+		t.Errorf("expected nothing to be written, got %d writes", writes)
+	}
+}
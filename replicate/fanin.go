@@ -0,0 +1,288 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the kinds whose targets can merge the data of several sources
+var mergeableKinds = map[string]bool{
+	"secret":    true,
+	"configmap": true,
+}
+
+// the fields of the data merged from several sources
+var mergedFields = []string{"data", "binaryData"}
+
+// Returns the sources of the "replicate-from" annotation, when the object merges several sources
+func (r *replicatorProps) mergedSources(object *metav1.ObjectMeta) []string {
+	if val, ok := resolveAnnotation(object, r.ReplicateFromAnnotation); ok && strings.Contains(val, ",") {
+		return strings.Split(val, ",")
+	}
+	return nil
+}
+
+// Returns true if the source is one of the sources
+func hasSource(sources []string, source string) bool {
+	for _, s := range sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Replicates the data of several sources into the object, the sources with the highest replicate-priority, then the
+// last sources, having priority on the same keys
+// The missing sources are ignored, and the object is cleared when none of them exists
+func (r *objectReplicator) replicateFromSources(object interface{}, sources []string) error {
+	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+	list := strings.Join(sources, ",")
+
+	if !mergeableKinds[r.kind()] {
+		err := fmt.Errorf("%s %s has several sources on annotation %s (%s): only secrets and config maps can merge several sources",
+			r.Name, key, r.ReplicateFromAnnotation, list)
+		r.errorf(list, key, "%s", err)
+		r.reportError(err)
+		return err
+	}
+
+	sourceObjects := []interface{}{}
+	for _, source := range sources {
+		sourceObject, exists, err := r.getSource(source)
+		if err != nil {
+			r.errorf(source, key, "could not get %s %s: %s", r.Name, source, err)
+			return err
+		} else if !exists {
+			r.infof(source, key, "source %s %s of %s does not exist", r.Name, source, key)
+			continue
+		}
+		// each source must allow the replication
+		if ok, err := r.isReplicationAllowed(meta, r.getMeta(sourceObject)); !ok && !isDenied(err) {
+			// the data of the target is kept while the annotations of the source are invalid
+			r.errorf(source, key, "replication of %s %s is cancelled: %s", r.Name, key, err)
+			return err
+		} else if !ok {
+			r.infof(source, key, "replication of %s %s is cancelled: %s", r.Name, key, err)
+			r.doClearObject(object)
+			r.setDenial(object, err.Error())
+			return err
+		}
+		sourceObjects = append(sourceObjects, sourceObject)
+	}
+
+	if len(sourceObjects) == 0 {
+		r.infof(list, key, "sources %s %s deleted: clearing target %s", r.Name, list, key)
+		r.doClearObject(object)
+		r.setSourceMissing(object, fmt.Sprintf("sources %s do not exist", list))
+		return nil
+	}
+
+	// the sources with the highest priority are merged last, to win on the same keys
+	sourceObjects, err := r.sortByPriority(sourceObjects)
+	if err != nil {
+		r.errorf(list, key, "%s", err)
+		r.reportError(err)
+		return err
+	}
+	merged, err := r.mergeSources(sourceObjects)
+	if err != nil {
+		r.errorf(list, key, "could not merge the sources of %s %s: %s", r.Name, key, err)
+		return err
+	}
+	// the overrides are reported once for each version of the sources
+	if meta.Annotations[r.ReplicatedFromVersionAnnotation] != r.getMeta(merged).ResourceVersion {
+		r.reportOverrides(object, sourceObjects)
+	}
+	return r.replicateObject(object, merged)
+}
+
+// Returns the priority of the source on the keys of a merged target, from its replicate-priority annotation, 0 by default
+func (r *replicatorProps) sourcePriority(object *metav1.ObjectMeta) (int, error) {
+	val, ok := object.Annotations[r.ReplicatePriorityAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	priority, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("source %s/%s has illformed annotation %s (%s): expected an integer",
+			object.Namespace, object.Name, r.ReplicatePriorityAnnotation, val)
+	}
+	return priority, nil
+}
+
+// Sorts the sources by increasing priority, the sources with the same priority keeping their order
+func (r *objectReplicator) sortByPriority(sourceObjects []interface{}) ([]interface{}, error) {
+	priorities := make([]int, len(sourceObjects))
+	for index, sourceObject := range sourceObjects {
+		priority, err := r.sourcePriority(r.getMeta(sourceObject))
+		if err != nil {
+			return nil, err
+		}
+		priorities[index] = priority
+	}
+
+	indexes := make([]int, len(sourceObjects))
+	for index := range indexes {
+		indexes[index] = index
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return priorities[indexes[i]] < priorities[indexes[j]]
+	})
+	sorted := make([]interface{}, len(sourceObjects))
+	for index, sourceIndex := range indexes {
+		sorted[index] = sourceObjects[sourceIndex]
+	}
+	return sorted, nil
+}
+
+// Returns the keys of each source whose value is overridden by a later source with another value, sorted
+func (r *objectReplicator) overriddenKeys(sourceObjects []interface{}) ([][]string, error) {
+	// the value of each key, and the index of the source defining it
+	values := map[string]json.RawMessage{}
+	owners := map[string]int{}
+	overridden := make([]map[string]bool, len(sourceObjects))
+	for index, sourceObject := range sourceObjects {
+		overridden[index] = map[string]bool{}
+		raw, err := json.Marshal(sourceObject)
+		if err != nil {
+			return nil, err
+		}
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		for _, field := range mergedFields {
+			data := map[string]json.RawMessage{}
+			if value, ok := fields[field]; ok {
+				if err := json.Unmarshal(value, &data); err != nil {
+					return nil, err
+				}
+			}
+			for name, value := range data {
+				if previous, ok := values[name]; ok && !reflect.DeepEqual(previous, value) {
+					overridden[owners[name]][name] = true
+				}
+				values[name] = value
+				owners[name] = index
+			}
+		}
+	}
+
+	keys := make([][]string, len(sourceObjects))
+	for index := range overridden {
+		for name := range overridden[index] {
+			keys[index] = append(keys[index], name)
+		}
+		sort.Strings(keys[index])
+	}
+	return keys, nil
+}
+
+// Reports the sources whose keys are overridden by other sources in the merged target, with an event on each
+// of them, so that the silent overrides are noticed
+func (r *objectReplicator) reportOverrides(object interface{}, sourceObjects []interface{}) {
+	key := objectKey(r.getMeta(object))
+	keys, err := r.overriddenKeys(sourceObjects)
+	if err != nil {
+		r.errorf("", key, "could not compare the sources of %s %s: %s", r.Name, key, err)
+		return
+	}
+	for index, sourceObject := range sourceObjects {
+		if len(keys[index]) == 0 {
+			continue
+		}
+		source := objectKey(r.getMeta(sourceObject))
+		r.infof(source, key, "keys %s of %s %s are overridden by other sources in %s",
+			strings.Join(keys[index], ","), r.Name, source, key)
+		r.recordEvent(sourceObject, v1.EventTypeWarning, "SourceOverridden",
+			"keys %s are overridden by other sources in %s %s", strings.Join(keys[index], ","), r.Name, key)
+	}
+}
+
+// Returns a source with the data of all the sources, the last ones having priority on the same keys
+// Its version is the list of the versions of the sources, and its labels and annotations are merged too
+// The replication was allowed by each source already, and an approval is required if a source requires it
+func (r *objectReplicator) mergeSources(sourceObjects []interface{}) (interface{}, error) {
+	data := map[string]map[string]json.RawMessage{}
+	for _, field := range mergedFields {
+		data[field] = map[string]json.RawMessage{}
+	}
+	versions := []string{}
+	labels := map[string]string{}
+	annotations := map[string]string{r.ReplicationAllowed: "true"}
+
+	var fields map[string]json.RawMessage
+	for _, sourceObject := range sourceObjects {
+		meta := r.getMeta(sourceObject)
+		versions = append(versions, meta.ResourceVersion)
+		for name, value := range meta.Labels {
+			labels[name] = value
+		}
+		for name, value := range meta.Annotations {
+			if name == r.ReplicateApprovalRequiredAnnotation {
+				// an approval is required as soon as a source requires it
+				if required, _ := strconv.ParseBool(annotations[name]); !required {
+					annotations[name] = value
+				}
+			} else if !r.isReplicatorAnnotation(name) {
+				annotations[name] = value
+			}
+		}
+
+		raw, err := json.Marshal(sourceObject)
+		if err != nil {
+			return nil, err
+		}
+		fields = map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		for _, field := range mergedFields {
+			values := map[string]json.RawMessage{}
+			if value, ok := fields[field]; ok {
+				if err := json.Unmarshal(value, &values); err != nil {
+					return nil, err
+				}
+			}
+			for name, value := range values {
+				data[field][name] = value
+			}
+		}
+	}
+
+	// the other fields come from the last source
+	for _, field := range mergedFields {
+		delete(fields, field)
+		if len(data[field]) > 0 {
+			raw, err := json.Marshal(data[field])
+			if err != nil {
+				return nil, err
+			}
+			fields[field] = raw
+		}
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	last := sourceObjects[len(sourceObjects)-1]
+	merged := reflect.New(reflect.TypeOf(last).Elem()).Interface()
+	if err := json.Unmarshal(raw, merged); err != nil {
+		return nil, err
+	}
+
+	meta := r.getMeta(merged)
+	meta.ResourceVersion = strings.Join(versions, ",")
+	meta.Labels = labels
+	meta.Annotations = annotations
+	return merged, nil
+}
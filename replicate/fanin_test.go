@@ -0,0 +1,119 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveSeveralSources(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	target := &metav1.ObjectMeta{Namespace: "default", Name: "target", Annotations: map[string]string{
+		r.ReplicateFromAnnotation: "common, other/database,prod-eu:tls",
+	}}
+
+	expected := []string{"default/common", "other/database", "prod-eu:default/tls"}
+	if sources := r.mergedSources(target); !reflect.DeepEqual(sources, expected) {
+		t.Errorf("expected %v, got %v", expected, sources)
+	}
+	if source := (&metav1.ObjectMeta{Namespace: "other", Name: "database"}); !annotationRefersTo(target, r.ReplicateFromAnnotation, source) {
+		t.Errorf("expected the annotation to refer to other/database")
+	}
+
+	target.Annotations[r.ReplicateFromAnnotation] = "common"
+	if sources := r.mergedSources(target); sources != nil {
+		t.Errorf("expected a single source, got %v", sources)
+	}
+}
+
+func TestMergeSources(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps:   replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")},
+		replicatorActions: SecretActions,
+	}
+	first := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "first", ResourceVersion: "10",
+			Labels:      map[string]string{"team": "backend"},
+			Annotations: map[string]string{r.ReplicationAllowed: "false", r.ReplicateApprovalRequiredAnnotation: "true"}},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{"user": []byte("admin"), "password": []byte("first")},
+	}
+	second := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "second", ResourceVersion: "20",
+			Labels: map[string]string{"team": "frontend"}},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{"password": []byte("second")},
+	}
+
+	merged, err := r.mergeSources([]interface{}{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := merged.(*v1.Secret)
+	// the last sources have priority
+	if expected := map[string][]byte{"user": []byte("admin"), "password": []byte("second")}; !reflect.DeepEqual(secret.Data, expected) {
+		t.Errorf("expected data %v, got %v", expected, secret.Data)
+	}
+	if secret.ResourceVersion != "10,20" {
+		t.Errorf("expected version 10,20, got %s", secret.ResourceVersion)
+	}
+	if secret.Labels["team"] != "frontend" {
+		t.Errorf("expected label team=frontend, got %s", secret.Labels["team"])
+	}
+	expected := map[string]string{r.ReplicationAllowed: "true", r.ReplicateApprovalRequiredAnnotation: "true"}
+	if !reflect.DeepEqual(secret.Annotations, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, secret.Annotations)
+	}
+}
+
+func TestSourcePriority(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps:   replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")},
+		replicatorActions: SecretActions,
+	}
+	first := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "first",
+			Annotations: map[string]string{r.ReplicatePriorityAnnotation: "10"}},
+		Data: map[string][]byte{"user": []byte("admin"), "password": []byte("first")},
+	}
+	second := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "second"},
+		Data:       map[string][]byte{"password": []byte("second"), "host": []byte("db")},
+	}
+	third := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "third"},
+		Data:       map[string][]byte{"host": []byte("db")},
+	}
+
+	// the source with the highest priority is merged last, the others keep their order
+	sorted, err := r.sortByPriority([]interface{}{first, second, third})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{second, third, first}; !reflect.DeepEqual(sorted, expected) {
+		t.Errorf("expected second, third, first, got %v", sorted)
+	}
+	merged, err := r.mergeSources(sorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := string(merged.(*v1.Secret).Data["password"]); password != "first" {
+		t.Errorf("expected the password of the first source, got %s", password)
+	}
+
+	// only the keys replaced with another value are overridden
+	keys, err := r.overriddenKeys(sorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]string{{"password"}, nil, nil}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+	first.Annotations[r.ReplicatePriorityAnnotation] = "high"
+	if _, err := r.sortByPriority([]interface{}{first, second}); err == nil {
+		t.Errorf("expected an error on an illformed priority")
+	}
+}
@@ -0,0 +1,62 @@
+package replicate
+
+import (
+	"sync"
+)
+
+// Installs the targets of a source, up to the configured concurrency in parallel
+// The targets are installed while holding the fan-out lock, which is only released
+// during their writes, so that a target is written by a single goroutine at a time
+// while the writes of different targets progress in parallel
+func (r *objectReplicator) forEachTarget(targets []string, install func(target string)) {
+	// the duplicated targets are installed once
+	seen := map[string]bool{}
+	unique := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if !seen[target] {
+			seen[target] = true
+			unique = append(unique, target)
+		}
+	}
+
+	if r.concurrency <= 1 || len(unique) <= 1 {
+		for _, target := range unique {
+			install(target)
+		}
+		return
+	}
+
+	r.fanoutTargets = seen
+	defer func() {
+		r.fanoutTargets = nil
+	}()
+
+	slots := make(chan struct{}, r.concurrency)
+	wg := sync.WaitGroup{}
+	for _, target := range unique {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(target string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			r.fanoutLock.Lock()
+			defer r.fanoutLock.Unlock()
+			install(target)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// Runs the write of the target, releasing the fan-out lock while the target is installed in parallel with others
+// The write must only use the API and the store
+func (r *objectReplicator) writeTarget(target string, write func() error) error {
+	if !r.fanoutTargets[target] {
+		return write()
+	}
+
+	r.fanoutLock.Unlock()
+	defer r.fanoutLock.Lock()
+	return write()
+}
@@ -0,0 +1,79 @@
+package replicate
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEachTarget(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "secret", concurrency: 4}}
+	targets := []string{"a/target", "b/target", "c/target", "d/target", "a/target"}
+
+	// the writes in flight wait for each other until the given number of them are, so that the writes
+	// which do not run in parallel time out, and returns the maximum number of writes in flight at once
+	installed := []string{}
+	inFlight := func(concurrency int) int {
+		r.concurrency = concurrency
+		installed = []string{}
+		lock := sync.Mutex{}
+		writing, maxWriting := 0, 0
+		release := make(chan struct{})
+		r.forEachTarget(targets, func(target string) {
+			installed = append(installed, target)
+			r.writeTarget(target, func() error {
+				lock.Lock()
+				writing++
+				if writing > maxWriting {
+					maxWriting = writing
+				}
+				wait := release
+				if writing == concurrency {
+					close(release)
+					release = make(chan struct{})
+				}
+				lock.Unlock()
+
+				select {
+				case <-wait:
+				case <-time.After(5 * time.Second):
+				}
+
+				lock.Lock()
+				writing--
+				lock.Unlock()
+				return nil
+			})
+		})
+		return maxWriting
+	}
+
+	if writing := inFlight(4); writing != 4 {
+		t.Errorf("expected the writes to run in parallel, got %d writes at once", writing)
+	}
+	sort.Strings(installed)
+	if expected := []string{"a/target", "b/target", "c/target", "d/target"}; !reflect.DeepEqual(installed, expected) {
+		t.Errorf("expected the targets %v to be installed once, got %v", expected, installed)
+	}
+	if writing := inFlight(2); writing != 2 {
+		t.Errorf("expected at most 2 writes at once, got %d", writing)
+	}
+	if r.fanoutTargets != nil {
+		t.Errorf("expected the targets to be forgotten once installed")
+	}
+
+	// without concurrency, the targets are installed one after another
+	r.concurrency = 1
+	installed = []string{}
+	r.forEachTarget(targets, func(target string) {
+		r.writeTarget(target, func() error {
+			installed = append(installed, target)
+			return nil
+		})
+	})
+	if len(installed) != 4 || installed[0] != "a/target" || installed[3] != "d/target" {
+		t.Errorf("expected the targets to be installed in order, got %v", installed)
+	}
+}
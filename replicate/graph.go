@@ -0,0 +1,130 @@
+package replicate
+
+import (
+	"sort"
+
+	"k8s.io/api/core/v1"
+)
+
+// Replication describes a source replicated to a target
+type Replication struct {
+	Kind   string `json:"kind"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// the annotation declaring the replication, "replicate-to" or "replicate-from"
+	Annotation string `json:"annotation"`
+}
+
+// Replications returns all the replications currently known by the replicator
+func (r *objectReplicator) Replications() []Replication {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	replications := []Replication{}
+	seen := map[Replication]bool{}
+	add := func(source string, targets []string, annotation string) {
+		for _, target := range targets {
+			replication := Replication{r.Name, source, target, annotation}
+			if !seen[replication] {
+				seen[replication] = true
+				replications = append(replications, replication)
+			}
+		}
+	}
+
+	for source, targets := range r.targets() {
+		add(source, targets, unprefixedAnnotations.ReplicateToAnnotation)
+	}
+	for source, targets := range r.dependents() {
+		add(source, targets, unprefixedAnnotations.ReplicateFromAnnotation)
+	}
+
+	sort.Slice(replications, func(i, j int) bool {
+		if replications[i].Source != replications[j].Source {
+			return replications[i].Source < replications[j].Source
+		}
+		return replications[i].Target < replications[j].Target
+	})
+	return replications
+}
+
+// Pattern describes a namespace pattern or selector of a source, whose matching namespaces receive a target
+type Pattern struct {
+	Source    string `json:"source"`
+	Namespace string `json:"namespace,omitempty"`
+	Selector  string `json:"selector,omitempty"`
+	// the name of the targets, or its template
+	Name string `json:"name"`
+}
+
+// Topology describes the whole replication graph of a replicator
+type Topology struct {
+	Kind         string        `json:"kind"`
+	Replications []Replication `json:"replications"`
+	// the targets of replicate-to which are not replicated, as their namespace does not exist
+	Pending []Replication `json:"pending"`
+	// the patterns of replicate-to-namespaces and replicate-to-namespaces-selector
+	Patterns []Pattern `json:"patterns"`
+}
+
+// Topology returns the replications, the pending targets and the patterns currently known by the replicator
+func (r *objectReplicator) Topology() Topology {
+	topology := Topology{Kind: r.Name, Replications: r.Replications(), Pending: []Replication{}, Patterns: []Pattern{}}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for source, watched := range r.watchedSources() {
+		replicated := map[string]bool{}
+		for _, target := range r.activeTargets(source, watched) {
+			replicated[target] = true
+		}
+		for _, target := range watched.targets {
+			if !replicated[target] {
+				topology.Pending = append(topology.Pending,
+					Replication{r.Name, source, target, unprefixedAnnotations.ReplicateToAnnotation})
+			}
+		}
+		for _, p := range watched.patterns {
+			pattern := Pattern{Source: source, Name: p.name}
+			if p.nameTemplate != nil {
+				pattern.Name = p.nameTemplate.Root.String()
+			}
+			if p.selector != nil {
+				pattern.Selector = p.selector.String()
+			} else {
+				pattern.Namespace = p.namespace.String()
+			}
+			topology.Patterns = append(topology.Patterns, pattern)
+		}
+	}
+
+	sort.Slice(topology.Pending, func(i, j int) bool {
+		if topology.Pending[i].Source != topology.Pending[j].Source {
+			return topology.Pending[i].Source < topology.Pending[j].Source
+		}
+		return topology.Pending[i].Target < topology.Pending[j].Target
+	})
+	sort.SliceStable(topology.Patterns, func(i, j int) bool {
+		return topology.Patterns[i].Source < topology.Patterns[j].Source
+	})
+	return topology
+}
+
+// MatchNamespaces returns the existing namespaces matching a namespace pattern of the annotations,
+// with the default pattern options
+func (r *objectReplicator) MatchNamespaces(pattern string) ([]string, error) {
+	compiled, err := defaultPatternOptions.compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := []string{}
+	for _, object := range r.namespaceStore.List() {
+		if namespace := object.(*v1.Namespace); compiled.MatchString(namespace.Name) {
+			namespaces = append(namespaces, namespace.Name)
+		}
+	}
+
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
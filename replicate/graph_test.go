@@ -0,0 +1,37 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestTopology(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.namespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source",
+		Annotations: map[string]string{r.ReplicateToAnnotation: "team-a/source,team-b/source,team-.*/copy"}}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "source",
+		Annotations: map[string]string{r.ReplicatedByAnnotation: "default/source"}}})
+
+	topology := r.Topology()
+	if len(topology.Replications) != 2 || topology.Replications[0].Target != "team-a/copy" || topology.Replications[1].Target != "team-a/source" {
+		t.Errorf("expected the replications to team-a, got %v", topology.Replications)
+	}
+	if len(topology.Pending) != 1 || topology.Pending[0].Target != "team-b/source" {
+		t.Errorf("expected the target in the missing namespace to be pending, got %v", topology.Pending)
+	}
+	if len(topology.Patterns) != 1 || topology.Patterns[0].Namespace != "^(?:team-.*)$" || topology.Patterns[0].Name != "copy" {
+		t.Errorf("expected the pattern of the source, got %v", topology.Patterns)
+	}
+}
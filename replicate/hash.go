@@ -0,0 +1,239 @@
+package replicate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the kinds of the objects, as used in owner references
+var ownerKinds = map[string]metav1.TypeMeta{
+	"secret":         {APIVersion: "v1", Kind: "Secret"},
+	"configmap":      {APIVersion: "v1", Kind: "ConfigMap"},
+	"serviceaccount": {APIVersion: "v1", Kind: "ServiceAccount"},
+	"role":           {APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+	"rolebinding":    {APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+}
+
+// Returns a short hash of the data of the object, as a name suffix
+func dataHash(object interface{}) (string, error) {
+	digest, err := dataDigest(object)
+	if err != nil {
+		return "", err
+	}
+	return digest[:10], nil
+}
+
+// Returns the SHA-256 of the data of the object, in hexadecimal
+func dataDigest(object interface{}) (string, error) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, field := range []string{"type", "data", "binaryData"} {
+		h.Write([]byte(field))
+		h.Write(fields[field])
+	}
+	// the fields of the other kinds, only when present to keep the hashes of secrets and config maps
+	for _, field := range []string{"secrets", "imagePullSecrets", "rules", "roleRef", "subjects", "spec"} {
+		if value, ok := fields[field]; ok {
+			h.Write([]byte(field))
+			h.Write(value)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Returns the SHA-256 of the data of an object without typed client: all its fields, but its metadata and status
+func dynamicDataDigest(object interface{}) (string, error) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", err
+	}
+	names := []string{}
+	for field := range fields {
+		if !dynamicIgnoredFields[field] {
+			names = append(names, field)
+		}
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, field := range names {
+		h.Write([]byte(field))
+		h.Write(fields[field])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Returns the hash of the data of the object, as recorded in the replicated-data-hash annotation of the targets
+// Returns an empty string if the data cannot be hashed
+func (r *replicatorProps) replicatedDataHash(object interface{}) string {
+	var digest string
+	var err error
+	if _, ok := ownerKinds[r.kind()]; ok {
+		digest, err = dataDigest(object)
+	} else {
+		digest, err = dynamicDataDigest(object)
+	}
+	if err != nil {
+		return ""
+	}
+	return digest
+}
+
+// Records the hash of the data of the source on the target, with the version of the source
+func (r *replicatorProps) setDataHash(object *metav1.ObjectMeta, sourceObject interface{}) {
+	if hash := r.replicatedDataHash(sourceObject); hash != "" {
+		object.Annotations[r.ReplicatedDataHashAnnotation] = hash
+	} else {
+		delete(object.Annotations, r.ReplicatedDataHashAnnotation)
+	}
+}
+
+// Returns true if the targets of the source have hashed copies
+func (r *replicatorProps) isHashSuffixed(object *metav1.ObjectMeta) (bool, error) {
+	val, ok := object.Annotations[r.ReplicateHashSuffixAnnotation]
+	if !ok {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("source %s/%s has illformed annotation %s (%s): %s",
+			object.Namespace, object.Name, r.ReplicateHashSuffixAnnotation, val, err)
+	}
+	return b, nil
+}
+
+// Creates the hashed copy of a target with the data of the source, owned by the target
+// A hashed copy is never updated, as its name changes with its data
+func (r *objectReplicator) installHashed(target *metav1.ObjectMeta, hashedName string, sourceObject interface{}) error {
+	targetKey := fmt.Sprintf("%s/%s", target.Namespace, target.Name)
+	key := fmt.Sprintf("%s/%s", target.Namespace, hashedName)
+
+	if object, exists, err := r.objectStore.GetByKey(key); err != nil {
+		return err
+	} else if !exists {
+	} else if val := r.getMeta(object).Annotations[r.ReplicatedHashOfAnnotation]; val != targetKey {
+		return fmt.Errorf("hashed copy %s of %s %s already exists and belongs to %s", key, r.Name, targetKey, val)
+	} else {
+		return nil
+	}
+
+	meta := metav1.ObjectMeta{
+		Namespace: target.Namespace,
+		Name:      hashedName,
+		Annotations: map[string]string{
+			r.ReplicatedAtAnnotation:     time.Now().Format(time.RFC3339),
+			r.ReplicatedHashOfAnnotation: targetKey,
+		},
+	}
+	// the hashed copies are deleted with their target
+	if target.UID != "" {
+		meta.OwnerReferences = []metav1.OwnerReference{r.ownerReference(target, sourceObject)}
+	}
+	r.stampInstance(&meta)
+
+	logf("installing %s %s: hashed copy of %s", r.Name, key, targetKey)
+	return r.install(&r.replicatorProps, &meta, sourceObject, sourceObject)
+}
+
+// Deletes the former hashed copies of the target, once it points to its current hashed copy
+func (r *objectReplicator) deleteFormerHashed(target *metav1.ObjectMeta, hashedName string) error {
+	targetKey := fmt.Sprintf("%s/%s", target.Namespace, target.Name)
+	objects, err := r.objectStore.ByIndex(cache.NamespaceIndex, target.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		meta := r.getMeta(object)
+		if meta.Name == hashedName || meta.Annotations[r.ReplicatedHashOfAnnotation] != targetKey {
+			continue
+		}
+		logf("deleting %s %s/%s: former hashed copy of %s", r.Name, meta.Namespace, meta.Name, targetKey)
+		if err := r.delete(&r.replicatorProps, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the hashes of the data of a target and of its source, empty when unknown
+type dataHashes struct {
+	// the hash of the current data of the target, only for the targets with the same data as their source
+	target string
+	source string
+}
+
+// Returns the hashes of the data of the target and of its source
+func (r *objectReplicator) dataHashes(object interface{}, sourceObject interface{}) dataHashes {
+	hashes := dataHashes{source: r.replicatedDataHash(sourceObject)}
+	// the token controller adds its secrets to the service accounts
+	if r.kind() != "serviceaccount" && r.isVerbatimCopy(r.getMeta(object), r.getMeta(sourceObject)) {
+		hashes.target = r.replicatedDataHash(object)
+	}
+	return hashes
+}
+
+// Returns true if the data of the target changed since it was replicated, when it is known
+func (r *replicatorProps) isDataModified(object *metav1.ObjectMeta, hashes dataHashes) bool {
+	hash, ok := object.Annotations[r.ReplicatedDataHashAnnotation]
+	return ok && hashes.target != "" && hashes.target != hash
+}
+
+// Returns true if the target has the data of the source, and the metadata propagated from it,
+// so that only the new version of the source has to be recorded on the target
+func (r *replicatorProps) hasSameData(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta, hashes dataHashes) bool {
+	if hashes.source == "" || object.Annotations[r.ReplicatedDataHashAnnotation] != hashes.source {
+		return false
+	} else if object.Annotations[r.ReplicateOnceVersionAnnotation] != sourceObject.Annotations[r.ReplicateOnceVersionAnnotation] {
+		return false
+	}
+
+	propagated := object.DeepCopy()
+	r.propagateAnnotations(propagated, sourceObject)
+	r.propagateLabels(propagated, sourceObject)
+	r.propagateImmutable(propagated, sourceObject)
+	return reflect.DeepEqual(propagated.Annotations, object.Annotations) && reflect.DeepEqual(propagated.Labels, object.Labels)
+}
+
+// Returns true if the source changed since the target was replicated, but neither its data nor the target
+func (r *replicatorProps) onlyVersionChanged(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta, hashes dataHashes) bool {
+	version, ok := object.Annotations[r.ReplicatedFromVersionAnnotation]
+	return ok && !r.isSourceVersion(sourceObject, version) && !r.isDataModified(object, hashes) &&
+		r.hasSameData(object, sourceObject, hashes)
+}
+
+// Records the version of the source on the target which already has its data, without replicating it again
+func (r *objectReplicator) recordSourceVersion(object interface{}, sourceMeta *metav1.ObjectMeta) error {
+	r.countNoop("write")
+	synced := &metav1.ObjectMeta{Annotations: map[string]string{}}
+	r.setSynced(synced, sourceMeta.ResourceVersion)
+	_, err := r.setStatusAnnotations(object, map[string]string{
+		r.ReplicatedFromVersionAnnotation: sourceMeta.ResourceVersion,
+		r.ReplicationStatusAnnotation:     synced.Annotations[r.ReplicationStatusAnnotation],
+	})
+	return err
+}
@@ -0,0 +1,115 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDataHashIgnoresMetadata(t *testing.T) {
+	a := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "a", ResourceVersion: "1"},
+		Data:       map[string][]byte{"key": []byte("value"), "other": []byte("value")},
+	}
+	b := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "b", ResourceVersion: "2"},
+		Data:       map[string][]byte{"other": []byte("value"), "key": []byte("value")},
+	}
+
+	hashA, err := dataHash(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	hashB, err := dataHash(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hashA != hashB || len(hashA) != 10 {
+		t.Errorf("expected identical hashes of 10 characters, got %s and %s", hashA, hashB)
+	}
+
+	b.Data["key"] = []byte("changed")
+	if hashC, _ := dataHash(b); hashC == hashA {
+		t.Errorf("expected hash to change with the data")
+	}
+}
+
+func TestReplicatedDataHash(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps:   replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")},
+		replicatorActions: SecretActions,
+	}
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "2", Annotations: map[string]string{}},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+	target := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "target", Annotations: map[string]string{
+			r.ReplicateFromAnnotation:         "default/source",
+			r.ReplicatedFromVersionAnnotation: "1",
+		}},
+		Data: map[string][]byte{"key": []byte("value")},
+	}
+	r.setDataHash(&target.ObjectMeta, source)
+
+	// the source changed, but not its data
+	hashes := r.dataHashes(target, source)
+	if !r.onlyVersionChanged(&target.ObjectMeta, &source.ObjectMeta, hashes) {
+		t.Errorf("expected only the version of the source to change")
+	}
+	if ok, _, err := r.needsDataUpdate(&target.ObjectMeta, &source.ObjectMeta, hashes); ok {
+		t.Errorf("expected no update of the data, got %v", err)
+	}
+
+	// the data of the source changed
+	source.Data["key"] = []byte("changed")
+	hashes = r.dataHashes(target, source)
+	if ok, _, _ := r.needsDataUpdate(&target.ObjectMeta, &source.ObjectMeta, hashes); !ok {
+		t.Errorf("expected an update of the data")
+	}
+
+	// the target was modified, while it claims to be up to date
+	source.Data["key"] = []byte("value")
+	target.Annotations[r.ReplicatedFromVersionAnnotation] = "2"
+	target.Data["key"] = []byte("modified")
+	hashes = r.dataHashes(target, source)
+	if !r.isDataModified(&target.ObjectMeta, hashes) {
+		t.Errorf("expected the target to be modified")
+	}
+	if ok, _, _ := r.needsDataUpdate(&target.ObjectMeta, &source.ObjectMeta, hashes); !ok {
+		t.Errorf("expected the modified target to be repaired")
+	}
+}
+
+func TestDeleteFormerHashed(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")},
+		// the client is not set, any write would panic
+		replicatorActions: &dryRunActions{SecretActions},
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+
+	target := &metav1.ObjectMeta{Namespace: "team", Name: "target"}
+	for _, object := range []*metav1.ObjectMeta{
+		{Namespace: "team", Name: "target-old", Annotations: map[string]string{r.ReplicatedHashOfAnnotation: "team/target"}},
+		{Namespace: "team", Name: "target-new", Annotations: map[string]string{r.ReplicatedHashOfAnnotation: "team/target"}},
+		{Namespace: "team", Name: "other-old", Annotations: map[string]string{r.ReplicatedHashOfAnnotation: "team/other"}},
+		{Namespace: "other", Name: "target-old", Annotations: map[string]string{r.ReplicatedHashOfAnnotation: "team/target"}},
+	} {
+		r.objectStore.Add(&v1.Secret{ObjectMeta: *object})
+	}
+
+	if err := r.deleteFormerHashed(target, "target-new"); err != nil {
+		t.Fatal(err)
+	}
+	if actions := DryRunActions(); len(actions) != 1 || actions[0].Action != "delete" || actions[0].Target != "team/target-old" {
+		t.Errorf("expected only the former hashed copy of the target to be deleted, got %v", actions)
+	}
+}
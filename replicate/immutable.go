@@ -0,0 +1,88 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Returns true if the targets of the source must be immutable, from its replicate-immutable annotation
+func (r *replicatorProps) immutableTargets(sourceObject *metav1.ObjectMeta) (bool, error) {
+	val, ok := sourceObject.Annotations[r.ReplicateImmutableAnnotation]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("source %s/%s has illformed annotation %s (%s): %s",
+			sourceObject.Namespace, sourceObject.Name, r.ReplicateImmutableAnnotation, val, err)
+	}
+	return b, nil
+}
+
+// Marks the target as immutable if its source requires it, as the typed clients do not know the immutable field
+func (r *replicatorProps) propagateImmutable(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) {
+	// the writes of the sources themselves, ex: of their status, keep their metadata
+	if objectKey(object) == objectKey(sourceObject) {
+		return
+	}
+	// the illformed annotations are rejected by the validating webhook, and replicate mutable targets
+	if immutable, _ := r.immutableTargets(sourceObject); immutable {
+		if object.Annotations == nil {
+			object.Annotations = map[string]string{}
+		}
+		object.Annotations[r.ReplicatedImmutableAnnotation] = "true"
+	} else {
+		delete(object.Annotations, r.ReplicatedImmutableAnnotation)
+	}
+}
+
+// Returns true if the target is written as immutable
+func (r *replicatorProps) isImmutable(object *metav1.ObjectMeta) bool {
+	return object.Annotations[r.ReplicatedImmutableAnnotation] == "true"
+}
+
+// Returns true if the write failed as the target is immutable
+func isImmutableError(err error) bool {
+	return errors.IsInvalid(err) && strings.Contains(err.Error(), "immutable")
+}
+
+// Writes the object with its immutable field, unknown to the typed clients: created if it has no version, or updated
+func (r *replicatorProps) writeImmutable(resource string, object runtime.Object, meta *metav1.ObjectMeta, result runtime.Object) error {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	fields["immutable"] = true
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	client := r.writer(meta).CoreV1().RESTClient()
+	if meta.ResourceVersion == "" {
+		return client.Post().Namespace(meta.Namespace).Resource(resource).Body(body).Do().Into(result)
+	}
+	return client.Put().Namespace(meta.Namespace).Resource(resource).Name(meta.Name).Body(body).Do().Into(result)
+}
+
+// Returns the metadata of a deleted target, to create it again
+func recreatedMeta(meta *metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace:       meta.Namespace,
+		Name:            meta.Name,
+		Labels:          meta.Labels,
+		Annotations:     meta.Annotations,
+		OwnerReferences: meta.OwnerReferences,
+		Finalizers:      meta.Finalizers,
+	}
+}
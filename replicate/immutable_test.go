@@ -0,0 +1,91 @@
+package replicate
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPropagateImmutable(t *testing.T) {
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicateImmutableAnnotation: "true",
+	}}
+	target := &metav1.ObjectMeta{Namespace: "team", Name: "target"}
+
+	r.propagateImmutable(target, source)
+	if !r.isImmutable(target) {
+		t.Errorf("expected the target to be immutable")
+	}
+	// the source itself is left mutable
+	r.propagateImmutable(source, source)
+	if r.isImmutable(source) {
+		t.Errorf("expected the source to be left mutable")
+	}
+
+	for _, val := range []string{"false", "yes"} {
+		source.Annotations[r.ReplicateImmutableAnnotation] = val
+		r.propagateImmutable(target, source)
+		if r.isImmutable(target) {
+			t.Errorf("expected the target to be mutable with %q", val)
+		}
+	}
+	if _, err := r.immutableTargets(source); err == nil {
+		t.Errorf("expected an illformed annotation")
+	}
+}
+
+func TestIsImmutableError(t *testing.T) {
+	kind := schema.GroupKind{Kind: "Secret"}
+	immutable := errors.NewInvalid(kind, "target", field.ErrorList{
+		field.Forbidden(field.NewPath("data"), "field is immutable when `immutable` is set"),
+	})
+	invalid := errors.NewInvalid(kind, "target", field.ErrorList{
+		field.Invalid(field.NewPath("metadata", "name"), "Target", "invalid name"),
+	})
+
+	for err, expected := range map[error]bool{
+		immutable:               true,
+		invalid:                 false,
+		fmt.Errorf("immutable"): false,
+		errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "target"): false,
+	} {
+		if isImmutableError(err) != expected {
+			t.Errorf("expected %v for error %s", expected, err)
+		}
+	}
+}
+
+func TestSecretTypeChanged(t *testing.T) {
+	r := &replicatorProps{
+		Name:            "secret",
+		annotationNames: annotationsWithPrefix(""),
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	a := &secretActions{}
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "target", ResourceVersion: "1"}})
+
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "target", ResourceVersion: "1"}}
+	for secretType, expected := range map[v1.SecretType]bool{
+		"":                  false,
+		v1.SecretTypeOpaque: false,
+		v1.SecretTypeTLS:    true,
+	} {
+		secret.Type = secretType
+		if a.typeChanged(r, secret) != expected {
+			t.Errorf("expected a change to type %q: %v", secretType, expected)
+		}
+	}
+
+	// the new secrets are created with their type
+	secret.ResourceVersion = ""
+	if a.typeChanged(r, secret) {
+		t.Errorf("expected no change for a new secret")
+	}
+}
@@ -0,0 +1,114 @@
+package replicate
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ClientFactory creates the clients writing the targets as a service account of their namespace,
+// so that the replicator writes them with the rights of the tenants instead of its own
+type ClientFactory struct {
+	config *rest.Config
+	// the service account the targets are written as, unless their source has a replicate-as annotation
+	// empty to write them with the rights of the replicator
+	defaultAccount string
+
+	lock sync.Mutex
+	// the {user => client} maps of the clients created so far
+	clients        map[string]kubernetes.Interface
+	dynamicClients map[string]dynamic.Interface
+}
+
+// NewClientFactory creates the clients impersonating the service accounts from the configuration of the replicator
+func NewClientFactory(config *rest.Config, defaultAccount string) *ClientFactory {
+	return &ClientFactory{
+		config:         config,
+		defaultAccount: defaultAccount,
+		clients:        map[string]kubernetes.Interface{},
+		dynamicClients: map[string]dynamic.Interface{},
+	}
+}
+
+// Returns the configuration of the replicator impersonating the user
+func (f *ClientFactory) impersonatingConfig(user string) *rest.Config {
+	config := rest.CopyConfig(f.config)
+	config.Impersonate = rest.ImpersonationConfig{UserName: user}
+	return config
+}
+
+// Returns the client impersonating the user, created once
+func (f *ClientFactory) client(user string) kubernetes.Interface {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if client, ok := f.clients[user]; ok {
+		return client
+	}
+	client := kubernetes.NewForConfigOrDie(f.impersonatingConfig(user))
+	f.clients[user] = client
+	return client
+}
+
+// Returns the dynamic client impersonating the user, created once
+func (f *ClientFactory) dynamicClient(user string) dynamic.Interface {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if client, ok := f.dynamicClients[user]; ok {
+		return client
+	}
+	client := dynamic.NewForConfigOrDie(f.impersonatingConfig(user))
+	f.dynamicClients[user] = client
+	return client
+}
+
+// Returns the user the target is written as: the service account of its namespace named by the replicate-as
+// annotation of its source, or the default service account of the factory
+// Returns an empty string when the target is written with the rights of the replicator
+func (r *replicatorProps) writeAs(meta *metav1.ObjectMeta) string {
+	if r.clients == nil {
+		return ""
+	}
+
+	account := r.clients.defaultAccount
+	source, ok := meta.Annotations[r.ReplicatedByAnnotation]
+	if !ok {
+		// the first source of the target decides, the sources of the other clusters are not known
+		val, _ := resolveAnnotation(meta, r.ReplicateFromAnnotation)
+		source = strings.Split(val, ",")[0]
+	}
+	if object, exists, err := r.objectStore.GetByKey(source); err == nil && exists {
+		if sourceMeta, err := apimeta.Accessor(object); err == nil {
+			// an empty annotation cannot opt out of the impersonation
+			if val := strings.TrimSpace(sourceMeta.GetAnnotations()[r.ReplicateAsAnnotation]); val != "" {
+				account = val
+			}
+		}
+	}
+
+	if account == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s%s:%s", serviceAccountPrefix, meta.Namespace, account)
+}
+
+// Returns the client writing the target, impersonating a service account of its namespace if configured
+func (r *replicatorProps) writer(meta *metav1.ObjectMeta) kubernetes.Interface {
+	if user := r.writeAs(meta); user != "" {
+		return r.clients.client(user)
+	}
+	return r.client
+}
+
+// Returns the resource writing the dynamic target, impersonating a service account of its namespace if configured
+func (a *dynamicActions) writer(r *replicatorProps, meta *metav1.ObjectMeta) dynamic.NamespaceableResourceInterface {
+	if user := r.writeAs(meta); user != "" {
+		return r.clients.dynamicClient(user).Resource(a.gvr)
+	}
+	return a.resource
+}
@@ -0,0 +1,55 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWriteAs(t *testing.T) {
+	r := &replicatorProps{
+		Name:            "secret",
+		annotationNames: annotationsWithPrefix(""),
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source"}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other",
+		Annotations: map[string]string{r.ReplicateAsAnnotation: "tenant-writer"}}})
+
+	pushed := &metav1.ObjectMeta{Namespace: "team-a", Name: "target",
+		Annotations: map[string]string{r.ReplicatedByAnnotation: "default/source"}}
+	pulled := &metav1.ObjectMeta{Namespace: "team-b", Name: "target",
+		Annotations: map[string]string{r.ReplicateFromAnnotation: "default/other,default/source"}}
+
+	if user := r.writeAs(pushed); user != "" {
+		t.Errorf("expected no impersonation without factory, got %s", user)
+	}
+	if r.writer(pushed) != r.client {
+		t.Errorf("expected the client of the replicator")
+	}
+
+	r.clients = NewClientFactory(&rest.Config{Host: "https://localhost"}, "replicator")
+	if user := r.writeAs(pushed); user != "system:serviceaccount:team-a:replicator" {
+		t.Errorf("expected the default service account, got %s", user)
+	}
+	if user := r.writeAs(pulled); user != "system:serviceaccount:team-b:tenant-writer" {
+		t.Errorf("expected the service account of the source, got %s", user)
+	}
+	if r.writer(pulled) != r.writer(pulled) {
+		t.Errorf("expected the impersonating client to be created once")
+	}
+
+	r.objectStore.Update(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source",
+		Annotations: map[string]string{r.ReplicateAsAnnotation: ""}}})
+	if user := r.writeAs(pushed); user != "system:serviceaccount:team-a:replicator" {
+		t.Errorf("expected an empty annotation to keep the default service account, got %s", user)
+	}
+
+	r.clients = NewClientFactory(&rest.Config{Host: "https://localhost"}, "")
+	if user := r.writeAs(pushed); user != "" {
+		t.Errorf("expected no impersonation without default service account, got %s", user)
+	}
+}
@@ -0,0 +1,237 @@
+package replicate
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the index of the objects by the sources of their replicate-from annotation
+const replicateFromIndex = "replicate-from"
+
+// the index of the targets by the source of their replicated-by annotation
+const replicatedByIndex = "replicated-by"
+
+// the index of the sources annotated to be replicated to other objects, all under the name of the index
+const replicateToIndex = "replicate-to"
+
+// Returns the indexes of the store of the objects
+func (r *objectReplicator) indexers() cache.Indexers {
+	return cache.Indexers{
+		replicateFromIndex:   r.indexSources,
+		replicateBundleIndex: r.indexBundleNamespaces,
+		replicatedByIndex:    r.indexReplicatedBy,
+		replicateToIndex:     r.indexReplicateTo,
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	}
+}
+
+// Indexes the object by the sources of its replicate-from annotation, even if they do not exist (yet)
+func (r *objectReplicator) indexSources(object interface{}) ([]string, error) {
+	val, ok := resolveAnnotation(r.getMeta(object), r.ReplicateFromAnnotation)
+	if !ok {
+		return nil, nil
+	}
+	return strings.Split(val, ","), nil
+}
+
+// Returns the sorted keys of the objects replicated from the source, which are managed by this instance
+func (r *objectReplicator) dependentsOf(source string) []string {
+	objects, err := r.objectStore.ByIndex(replicateFromIndex, source)
+	if err != nil {
+		r.errorf(source, "", "could not get dependents of %s %s: %s", r.Name, source, err)
+		return nil
+	}
+
+	dependents := make([]string, 0, len(objects))
+	for _, object := range objects {
+		meta := r.getMeta(object)
+		// the objects of the other instances are not replicated by this one
+		if r.isManaged(meta) {
+			dependents = append(dependents, objectKey(meta))
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// Returns a {source => targets} map of the objects replicated from the sources
+func (r *objectReplicator) dependents() map[string][]string {
+	dependents := map[string][]string{}
+	for _, source := range r.objectStore.ListIndexFuncValues(replicateFromIndex) {
+		if targets := r.dependentsOf(source); len(targets) > 0 {
+			dependents[source] = targets
+		}
+	}
+	return dependents
+}
+
+// Indexes the target by the source of its replicated-by annotation
+func (r *objectReplicator) indexReplicatedBy(object interface{}) ([]string, error) {
+	if source, ok := r.getMeta(object).Annotations[r.ReplicatedByAnnotation]; ok {
+		return []string{source}, nil
+	}
+	return nil, nil
+}
+
+// Indexes the object if its annotations replicate it to other objects
+// The index only depends on the annotations: the targets are resolved as the sources are looked up,
+// since they also depend on the namespaces and the rules
+func (r *objectReplicator) indexReplicateTo(object interface{}) ([]string, error) {
+	meta := r.getMeta(object)
+	if _, ok := meta.Annotations[r.ReplicatedByAnnotation]; ok {
+		return nil, nil
+	}
+	for _, annotation := range []string{r.ReplicateToAnnotation, r.ReplicateToNamespacesAnnotation,
+		r.ReplicateToNamespacesSelectorAnnotation, r.ReplicatePullSecretAnnotation} {
+		if _, ok := meta.Annotations[annotation]; ok {
+			return []string{replicateToIndex}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Returns the sorted keys of the targets replicated by the source
+// The targets in terminating namespaces are left out, as they are deleted with their namespace
+func (r *objectReplicator) targetsOf(source string) []string {
+	objects, err := r.objectStore.ByIndex(replicatedByIndex, source)
+	if err != nil {
+		r.errorf(source, "", "could not get targets of %s %s: %s", r.Name, source, err)
+		return nil
+	}
+
+	targets := make([]string, 0, len(objects))
+	for _, object := range objects {
+		meta := r.getMeta(object)
+		if namespace, exists, err := r.namespaceStore.GetByKey(meta.Namespace); err == nil && exists &&
+			namespace.(*v1.Namespace).Status.Phase == v1.NamespaceTerminating {
+			continue
+		}
+		targets = append(targets, objectKey(meta))
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Returns a {source => targets} map of the targets of the sources managed by this instance: the replicated ones,
+// and the ones of their annotations in the active namespaces, which are being replicated
+func (r *objectReplicator) targets() map[string][]string {
+	all := map[string]map[string]bool{}
+	add := func(source string, targets []string) {
+		if _, ok := all[source]; !ok {
+			all[source] = map[string]bool{}
+		}
+		for _, target := range targets {
+			all[source][target] = true
+		}
+	}
+
+	for _, source := range r.objectStore.ListIndexFuncValues(replicatedByIndex) {
+		// the targets of the sources of the other instances are not replicated by this one
+		if object, exists, err := r.objectStore.GetByKey(source); err == nil && exists && !r.isManaged(r.getMeta(object)) {
+			continue
+		}
+		if replicas := r.targetsOf(source); len(replicas) > 0 {
+			add(source, replicas)
+		}
+	}
+	for source, watched := range r.watchedSources() {
+		if replicas := r.activeTargets(source, watched); len(replicas) > 0 {
+			add(source, replicas)
+		}
+	}
+
+	targets := make(map[string][]string, len(all))
+	for source, replicas := range all {
+		for target := range replicas {
+			targets[source] = append(targets[source], target)
+		}
+		sort.Strings(targets[source])
+	}
+	return targets
+}
+
+// Returns the targets of the watched source in the active namespaces, none if they are more than allowed
+func (r *objectReplicator) activeTargets(source string, watched watchedTargets) []string {
+	active := map[string]bool{}
+	for _, target := range watched.targets {
+		if r.namespaceActive(strings.SplitN(target, "/", 2)[0]) {
+			active[target] = true
+		}
+	}
+	if len(watched.patterns) > 0 {
+		namespaces := r.namespaceStore.ListKeys()
+		for _, p := range watched.patterns {
+			for _, target := range p.Targets(namespaces) {
+				if r.namespaceActive(strings.SplitN(target, "/", 2)[0]) {
+					active[target] = true
+				}
+			}
+		}
+	}
+	// cannot target itself
+	delete(active, source)
+	if max, err := r.maxTargets(watched.meta); err == nil && max > 0 && len(active) > max {
+		return nil
+	}
+
+	targets := make([]string, 0, len(active))
+	for target := range active {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// the targets and the target patterns of a source
+type watchedTargets struct {
+	meta     *metav1.ObjectMeta
+	targets  []string
+	patterns []targetPattern
+}
+
+// Returns a {source => watched} map of the targets of the sources managed by this instance, from their annotations,
+// the rules selecting them, or the cloning annotations of their namespace
+// The sources with illformed annotations are left out, their error is reported as they are processed
+func (r *objectReplicator) watchedSources() map[string]watchedTargets {
+	candidates, err := r.objectStore.ByIndex(replicateToIndex, replicateToIndex)
+	if err != nil {
+		r.errorf("", "", "could not get the %s sources: %s", r.Name, err)
+		return nil
+	}
+	// the sources without annotations are selected by the namespace they are in
+	for _, object := range r.namespaceStore.List() {
+		namespace := object.(*v1.Namespace)
+		_, cloned := namespace.Annotations[r.ReplicateAllToAnnotation]
+		if !(cloned && cloneableKinds[r.kind()]) && !r.ruleSelectsNamespace(namespace.Name) {
+			continue
+		}
+		objects, err := r.objectStore.ByIndex(cache.NamespaceIndex, namespace.Name)
+		if err != nil {
+			r.errorf("", "", "could not get the %s objects of namespace %s: %s", r.Name, namespace.Name, err)
+			continue
+		}
+		candidates = append(candidates, objects...)
+	}
+
+	watched := map[string]watchedTargets{}
+	for _, object := range candidates {
+		meta := r.getMeta(object)
+		source := objectKey(meta)
+		if _, ok := watched[source]; ok {
+			continue
+		} else if _, ok := meta.Annotations[r.ReplicatedByAnnotation]; ok {
+			continue
+		} else if !r.isManaged(meta) {
+			continue
+		}
+		targets, patterns, err := r.getReplicationTargets(meta)
+		if err == nil && len(targets)+len(patterns) > 0 {
+			watched[source] = watchedTargets{meta, targets, patterns}
+		}
+	}
+	return watched
+}
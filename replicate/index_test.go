@@ -0,0 +1,135 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDependents(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps:   replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+
+	target := func(namespace string, from string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "target", Annotations: map[string]string{
+			r.ReplicateFromAnnotation: from,
+		}}}
+	}
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source"}})
+	r.objectStore.Add(target("team-b", "default/source"))
+	r.objectStore.Add(target("team-a", "default/source, default/other"))
+	// the relative paths are resolved in the namespace of the target
+	r.objectStore.Add(target("default", "source"))
+
+	if expected := []string{"default/target", "team-a/target", "team-b/target"}; !reflect.DeepEqual(r.dependentsOf("default/source"), expected) {
+		t.Errorf("expected dependents %v, got %v", expected, r.dependentsOf("default/source"))
+	}
+	// the missing sources are indexed too
+	if expected := map[string][]string{
+		"default/source": {"default/target", "team-a/target", "team-b/target"},
+		"default/other":  {"team-a/target"},
+	}; !reflect.DeepEqual(r.dependents(), expected) {
+		t.Errorf("expected dependents %v, got %v", expected, r.dependents())
+	}
+
+	// the index follows the changes of the annotations
+	r.objectStore.Update(target("team-b", "default/other"))
+	r.objectStore.Delete(target("team-a", ""))
+	if expected := []string{"team-b/target"}; !reflect.DeepEqual(r.dependentsOf("default/other"), expected) {
+		t.Errorf("expected dependents %v, got %v", expected, r.dependentsOf("default/other"))
+	}
+	if expected := []string{"default/target"}; !reflect.DeepEqual(r.dependentsOf("default/source"), expected) {
+		t.Errorf("expected dependents %v, got %v", expected, r.dependentsOf("default/source"))
+	}
+}
+
+func TestTargetsOf(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			namespaceStore:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}, Status: v1.NamespaceStatus{Phase: v1.NamespaceTerminating}})
+
+	target := func(namespace string, by string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "source", Annotations: map[string]string{
+			r.ReplicatedByAnnotation: by,
+		}}}
+	}
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source"}})
+	r.objectStore.Add(target("team-b", "default/source"))
+	r.objectStore.Add(target("team-a", "default/source"))
+	// the targets in terminating namespaces are deleted with them
+	r.objectStore.Add(target("team-c", "default/source"))
+
+	if expected := []string{"team-a/source", "team-b/source"}; !reflect.DeepEqual(r.targetsOf("default/source"), expected) {
+		t.Errorf("expected targets %v, got %v", expected, r.targetsOf("default/source"))
+	}
+
+	// the index follows the changes of the annotations
+	r.objectStore.Update(target("team-b", "default/other"))
+	if expected := []string{"team-a/source"}; !reflect.DeepEqual(r.targetsOf("default/source"), expected) {
+		t.Errorf("expected targets %v, got %v", expected, r.targetsOf("default/source"))
+	}
+	if expected := map[string][]string{
+		"default/source": {"team-a/source"},
+		"default/other":  {"team-b/source"},
+	}; !reflect.DeepEqual(r.targets(), expected) {
+		t.Errorf("expected targets %v, got %v", expected, r.targets())
+	}
+}
+
+func TestWatchedSources(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			namespaceStore:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cloned", Annotations: map[string]string{
+		r.ReplicateAllToAnnotation: "team-.*",
+	}}})
+
+	object := func(namespace string, name string, annotations map[string]string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations}}
+	}
+	r.objectStore.Add(object("default", "source", map[string]string{r.ReplicateToAnnotation: "team-a/source"}))
+	r.objectStore.Add(object("default", "plain", nil))
+	r.objectStore.Add(object("cloned", "object", nil))
+	// the copies are not sources, nor the sources of the other instances
+	r.objectStore.Add(object("team-a", "source", map[string]string{r.ReplicatedByAnnotation: "default/source",
+		r.ReplicateToAnnotation: "team-a/source"}))
+	r.objectStore.Add(object("default", "other", map[string]string{r.ReplicateToAnnotation: "team-b/other",
+		r.ReplicatorInstanceAnnotation: "other"}))
+
+	watched := r.watchedSources()
+	if len(watched) != 2 {
+		t.Errorf("expected the annotated and the cloned sources, got %v", watched)
+	}
+	if expected := []string{"team-a/source"}; !reflect.DeepEqual(watched["default/source"].targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, watched["default/source"].targets)
+	}
+	if patterns := watched["cloned/object"].patterns; len(patterns) != 1 || patterns[0].MatchNamespace("team-b") != "team-b/object" {
+		t.Errorf("expected the source to be cloned to the matching namespaces, got %v", patterns)
+	}
+
+	// the index follows the changes of the annotations
+	r.objectStore.Update(object("default", "source", nil))
+	if _, ok := r.watchedSources()["default/source"]; ok {
+		t.Errorf("expected the source without annotations not to be watched")
+	}
+}
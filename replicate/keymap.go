@@ -0,0 +1,46 @@
+package replicate
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Returns the {source key => target key} map of the keys renamed during the replication
+// The annotation of the target has priority over the one of the source, and over the rule selecting the source
+func (r *replicatorProps) getKeyMap(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (map[string]string, error) {
+	meta := object
+	val, ok := object.Annotations[r.ReplicateKeyMapAnnotation]
+	if !ok {
+		meta = sourceObject
+		if val, ok = sourceObject.Annotations[r.ReplicateKeyMapAnnotation]; ok {
+		} else if rule := r.matchRule(sourceObject); rule != nil && len(rule.KeyMap) > 0 {
+			return rule.KeyMap, nil
+		} else {
+			return nil, nil
+		}
+	}
+
+	keyMap := map[string]string{}
+	for _, pair := range strings.Split(val, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%s/%s has illformed annotation %s (%s): expected src=dst, got %s",
+				meta.Namespace, meta.Name, r.ReplicateKeyMapAnnotation, val, pair)
+		}
+		keyMap[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return keyMap, nil
+}
+
+// Returns the key of the target for the key of the source
+func mapKey(keyMap map[string]string, key string) string {
+	if mapped, ok := keyMap[key]; ok {
+		return mapped
+	}
+	return key
+}
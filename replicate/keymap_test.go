@@ -0,0 +1,45 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetKeyMap(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicateKeyMapAnnotation: "tls.crt=ca-bundle.pem",
+	}}
+	target := &metav1.ObjectMeta{Namespace: "other", Name: "target", Annotations: map[string]string{}}
+
+	keyMap, err := r.getKeyMap(target, source)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := map[string]string{"tls.crt": "ca-bundle.pem"}; !reflect.DeepEqual(keyMap, expected) {
+		t.Errorf("expected %v, got %v", expected, keyMap)
+	}
+	if key := mapKey(keyMap, "tls.crt"); key != "ca-bundle.pem" {
+		t.Errorf("expected tls.crt to be renamed, got %s", key)
+	}
+	if key := mapKey(keyMap, "tls.key"); key != "tls.key" {
+		t.Errorf("expected tls.key to be kept, got %s", key)
+	}
+
+	// the annotation of the target has priority
+	target.Annotations[r.ReplicateKeyMapAnnotation] = "tls.crt=cert.pem,tls.key=key.pem"
+	keyMap, err = r.getKeyMap(target, source)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := map[string]string{"tls.crt": "cert.pem", "tls.key": "key.pem"}; !reflect.DeepEqual(keyMap, expected) {
+		t.Errorf("expected %v, got %v", expected, keyMap)
+	}
+
+	target.Annotations[r.ReplicateKeyMapAnnotation] = "tls.crt"
+	if _, err := r.getKeyMap(target, source); err == nil {
+		t.Errorf("expected an error for an illformed annotation")
+	}
+}
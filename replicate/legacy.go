@@ -0,0 +1,163 @@
+package replicate
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the previous prefixes of the annotations, still read while the objects are migrated to the current prefix
+var legacyPrefixes []string
+
+// ReadLegacyPrefixes reads the annotations under the legacy prefixes too, as if they were under the prefix of each
+// replicator, so that the prefix can be changed without breaking the existing sources and targets
+// Only the current prefix is written: the legacy annotations are renamed when the objects are written
+// Must be called before the replicators are created
+func ReadLegacyPrefixes(prefixes []string) {
+	legacyPrefixes = prefixes
+}
+
+// Returns the {legacy name => current name} map of the annotations and the finalizer of the replicator
+func (r *replicatorProps) legacyNames() map[string]string {
+	current := reflect.ValueOf(r.annotationNames)
+	names := map[string]string{}
+	for _, prefix := range legacyPrefixes {
+		legacy := reflect.ValueOf(annotationsWithPrefix(prefix))
+		for index := 0; index < legacy.NumField(); index++ {
+			if name := legacy.Field(index).String(); name != current.Field(index).String() {
+				names[name] = current.Field(index).String()
+			}
+		}
+	}
+	return names
+}
+
+// Renames the legacy annotations and finalizer of the object to the current prefix
+// An annotation already set with the current prefix is kept, the legacy one being dropped
+func renameLegacy(object runtime.Object, names map[string]string) {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return
+	}
+
+	var renamed map[string]string
+	annotations := accessor.GetAnnotations()
+	for name, value := range annotations {
+		current, ok := names[name]
+		if !ok {
+			continue
+		} else if renamed == nil {
+			renamed = make(map[string]string, len(annotations))
+			for n, v := range annotations {
+				renamed[n] = v
+			}
+		}
+		delete(renamed, name)
+		if _, exists := annotations[current]; !exists {
+			renamed[current] = value
+		}
+	}
+	if renamed != nil {
+		accessor.SetAnnotations(renamed)
+	}
+
+	finalizers := accessor.GetFinalizers()
+	for index, finalizer := range finalizers {
+		if current, ok := names[finalizer]; ok {
+			finalizers = append([]string{}, finalizers...)
+			finalizers[index] = current
+			accessor.SetFinalizers(finalizers)
+			break
+		}
+	}
+}
+
+// RenameLegacy returns the object of the kind, as sent to the admission webhook, with its legacy annotations and
+// finalizer renamed to the current prefix, so that it is stored with the current prefix
+// Returns false if the object is of another kind, or has nothing to rename
+func (r *objectReplicator) RenameLegacy(kind string, raw []byte) ([]byte, bool, error) {
+	names := r.legacyNames()
+	if len(names) == 0 || strings.ToLower(kind) != r.kind() {
+		return nil, false, nil
+	}
+
+	_, empty := r.listWatch(r.client)
+	object := reflect.New(reflect.TypeOf(empty).Elem()).Interface()
+	if err := json.Unmarshal(raw, object); err != nil {
+		return nil, false, err
+	}
+	legacy := false
+	for name := range r.getMeta(object).Annotations {
+		_, ok := names[name]
+		legacy = legacy || ok
+	}
+	for _, finalizer := range r.getMeta(object).Finalizers {
+		_, ok := names[finalizer]
+		legacy = legacy || ok
+	}
+	if !legacy {
+		return nil, false, nil
+	}
+	renameLegacy(object.(runtime.Object), names)
+
+	// only the metadata is replaced, the other fields are kept as sent
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false, err
+	}
+	metadata, err := json.Marshal(r.getMeta(object))
+	if err != nil {
+		return nil, false, err
+	}
+	fields["metadata"] = metadata
+	renamed, err := json.Marshal(fields)
+	return renamed, err == nil, err
+}
+
+// Returns the lister watcher renaming the legacy annotations of the objects it lists and watches, itself when no
+// legacy prefix is read
+func (r *replicatorProps) readLegacy(listWatch cache.ListerWatcher) cache.ListerWatcher {
+	names := r.legacyNames()
+	if listWatch == nil || len(names) == 0 {
+		return listWatch
+	}
+	return &legacyListWatch{listWatch, names}
+}
+
+// a lister watcher renaming the legacy annotations
+type legacyListWatch struct {
+	cache.ListerWatcher
+	// the {legacy name => current name} map of the annotations
+	names map[string]string
+}
+
+func (lw *legacyListWatch) List(lo metav1.ListOptions) (runtime.Object, error) {
+	list, err := lw.ListerWatcher.List(lo)
+	if err != nil {
+		return list, err
+	}
+	err = meta.EachListItem(list, func(object runtime.Object) error {
+		renameLegacy(object, lw.names)
+		return nil
+	})
+	return list, err
+}
+
+func (lw *legacyListWatch) Watch(lo metav1.ListOptions) (watch.Interface, error) {
+	w, err := lw.ListerWatcher.Watch(lo)
+	if err != nil {
+		return w, err
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		if event.Type != watch.Error {
+			renameLegacy(event.Object, lw.names)
+		}
+		return event, true
+	}), nil
+}
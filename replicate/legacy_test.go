@@ -0,0 +1,118 @@
+package replicate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestReadLegacyPrefixes(t *testing.T) {
+	ReadLegacyPrefixes([]string{"replicator.v1.mittwald.de/"})
+	defer ReadLegacyPrefixes(nil)
+
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("v1.kubernetes-replicator.olli.com/")}
+	fake := watch.NewFake()
+	lw := r.readLegacy(&cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			return &v1.SecretList{Items: []v1.Secret{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "old", Annotations: map[string]string{
+					"replicator.v1.mittwald.de/replicate-to": "team-a/old",
+					"other.io/annotation":                    "kept",
+				}, Finalizers: []string{"replicator.v1.mittwald.de/replicated-targets"}}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "both", Annotations: map[string]string{
+					"replicator.v1.mittwald.de/replicate-to":         "team-a/legacy",
+					"v1.kubernetes-replicator.olli.com/replicate-to": "team-a/current",
+				}}},
+			}}, nil
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return fake, nil
+		},
+	})
+
+	object, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := object.(*v1.SecretList)
+	old := list.Items[0].ObjectMeta
+	if val := old.Annotations[r.ReplicateToAnnotation]; val != "team-a/old" {
+		t.Errorf("expected the legacy annotation to be read, got %s", val)
+	} else if _, ok := old.Annotations["replicator.v1.mittwald.de/replicate-to"]; ok {
+		t.Errorf("expected the legacy annotation to be renamed")
+	} else if old.Annotations["other.io/annotation"] != "kept" {
+		t.Errorf("expected the other annotations to be kept, got %v", old.Annotations)
+	} else if len(old.Finalizers) != 1 || old.Finalizers[0] != r.ReplicatedTargetsFinalizer {
+		t.Errorf("expected the legacy finalizer to be renamed, got %v", old.Finalizers)
+	}
+	if both := list.Items[1].ObjectMeta; both.Annotations[r.ReplicateToAnnotation] != "team-a/current" || len(both.Annotations) != 1 {
+		t.Errorf("expected the current annotation to have priority, got %v", both.Annotations)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go fake.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "new",
+		Annotations: map[string]string{"replicator.v1.mittwald.de/replicate-from": "default/old"}}})
+	event := <-w.ResultChan()
+	if val := event.Object.(*v1.Secret).Annotations[r.ReplicateFromAnnotation]; val != "default/old" {
+		t.Errorf("expected the legacy annotation of the event to be read, got %s", val)
+	}
+	w.Stop()
+
+	ReadLegacyPrefixes(nil)
+	if r.readLegacy(lw) != lw {
+		t.Errorf("expected no renaming without legacy prefix")
+	}
+}
+
+func TestRenameLegacyAtAdmission(t *testing.T) {
+	ReadLegacyPrefixes([]string{"replicator.v1.mittwald.de/"})
+	defer ReadLegacyPrefixes(nil)
+
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{Name: "secret",
+			annotationNames: annotationsWithPrefix("v1.kubernetes-replicator.olli.com/")},
+		replicatorActions: SecretActions,
+	}
+	raw := []byte(`{"kind":"Secret","metadata":{"namespace":"default","name":"source",` +
+		`"annotations":{"replicator.v1.mittwald.de/replicate-to":"team-a/source","other.io/annotation":"kept"},` +
+		`"finalizers":["replicator.v1.mittwald.de/replicated-targets"]},"data":{"key":"dmFsdWU="}}`)
+
+	renamed, ok, err := r.RenameLegacy("Secret", raw)
+	if err != nil || !ok {
+		t.Fatalf("expected the legacy annotations to be renamed, got %v", err)
+	}
+	secret := &v1.Secret{}
+	if err := json.Unmarshal(renamed, secret); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"v1.kubernetes-replicator.olli.com/replicate-to": "team-a/source",
+		"other.io/annotation":                            "kept",
+	}
+	if !reflect.DeepEqual(secret.Annotations, expected) {
+		t.Errorf("expected %v, got %v", expected, secret.Annotations)
+	}
+	if len(secret.Finalizers) != 1 || secret.Finalizers[0] != "v1.kubernetes-replicator.olli.com/replicated-targets" {
+		t.Errorf("expected the legacy finalizer to be renamed, got %v", secret.Finalizers)
+	}
+	if string(secret.Data["key"]) != "value" {
+		t.Errorf("expected the data to be kept, got %v", secret.Data)
+	}
+
+	// the objects of other kinds, or without legacy annotations, are left as is
+	if _, ok, _ := r.RenameLegacy("ConfigMap", raw); ok {
+		t.Errorf("expected the object of another kind not to be renamed")
+	}
+	if _, ok, _ := r.RenameLegacy("Secret", renamed); ok {
+		t.Errorf("expected the object without legacy annotations not to be renamed")
+	}
+}
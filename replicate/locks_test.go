@@ -0,0 +1,75 @@
+package replicate
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Runs the handlers and the accessors of the state concurrently, to be checked with the race detector
+func TestConcurrentState(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			concurrency:     4,
+			namespaceStore:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+			retries:         map[string]*retryState{},
+			collisions:      map[collisionKey]string{},
+			startupReport:   &StartupReport{},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.queue, r.rateLimiter = newQueue("secret")
+	defer r.queue.ShutDown()
+
+	wg := sync.WaitGroup{}
+	run := func(times int, f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < times; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	// the handlers change the state
+	run(50, func(i int) {
+		r.lock.Lock()
+		targets := []string{}
+		for j := 0; j < 8; j++ {
+			targets = append(targets, fmt.Sprintf("ns-%d/target", j))
+		}
+		r.forEachTarget(targets, func(target string) {
+			r.collisions[collisionKey{"default/source", target}] = "default/other"
+			r.track(target, "default/source", "created", nil)
+			r.writeTarget(target, func() error {
+				r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: target[:4], Name: "target",
+					Annotations: map[string]string{r.ReplicateFromAnnotation: "default/source"}}})
+				return nil
+			})
+		})
+		r.lock.Unlock()
+	})
+	run(50, func(i int) {
+		r.NamespaceDeleted(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ns-%d", i%8)}})
+	})
+	// the accessors read it
+	run(50, func(i int) {
+		r.Replications()
+		r.Collisions()
+		r.sourceStatuses()
+		r.StartupReport()
+	})
+	wg.Wait()
+
+	if replications := r.Replications(); len(replications) == 0 {
+		t.Errorf("expected some replications")
+	}
+}
@@ -0,0 +1,108 @@
+package replicate
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the verbosities of the log levels
+var logLevels = map[string]int{
+	"error": -1,
+	"info":  0,
+	"debug": 1,
+}
+
+// the logger of the objects without their own logger
+var logger = newLogger(0, false, os.Stderr)
+
+// NewLogger returns a logger for the level (error, info or debug), writing JSON lines if asked
+func NewLogger(level string, jsonOutput bool) (logr.Logger, error) {
+	verbosity, ok := logLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("unknown log level %s", level)
+	}
+	return newLogger(verbosity, jsonOutput, os.Stderr), nil
+}
+
+// Returns a zap logger writing the lines up to the verbosity, as text or as JSON lines
+// The verbosity n of logr is the level -n of zap: the errors are logged whatever the verbosity
+func newLogger(verbosity int, jsonOutput bool, out io.Writer) logr.Logger {
+	config := zap.NewProductionEncoderConfig()
+	config.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoder := zapcore.NewConsoleEncoder(config)
+	if jsonOutput {
+		encoder = zapcore.NewJSONEncoder(config)
+	}
+	core := zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(out)), zapcore.Level(-verbosity))
+	return zapr.NewLogger(zap.New(core))
+}
+
+// SetLogger sets the logger of the objects without their own logger
+func SetLogger(l logr.Logger) {
+	logger = l
+}
+
+// Logs a message, unless it was already logged within the deduplication window
+func logWith(l logr.Logger, isError bool, msg string, keysAndValues ...interface{}) {
+	ok, repeated := logLines.emit(fmt.Sprint(msg, keysAndValues))
+	if !ok {
+		return
+	} else if repeated > 0 {
+		keysAndValues = append(keysAndValues, "repeated", repeated)
+	}
+
+	if isError {
+		l.Error(nil, msg, keysAndValues...)
+	} else {
+		l.Info(msg, keysAndValues...)
+	}
+}
+
+// Returns the logger of the replicator, or the default logger
+func (r *replicatorProps) log() logr.Logger {
+	if r.logger == nil {
+		return logger
+	}
+	return r.logger
+}
+
+// Returns the source and target fields of a log line, omitted when empty
+func logKeys(source string, target string) []interface{} {
+	keysAndValues := []interface{}{}
+	if source != "" {
+		keysAndValues = append(keysAndValues, "source", source)
+	}
+	if target != "" {
+		keysAndValues = append(keysAndValues, "target", target)
+	}
+	return keysAndValues
+}
+
+// Logs a message about the replication of the source to the target
+func (r *replicatorProps) infof(source string, target string, format string, args ...interface{}) {
+	logWith(r.log(), false, fmt.Sprintf(format, args...), logKeys(source, target)...)
+}
+
+// Logs an error about the replication of the source to the target
+func (r *replicatorProps) errorf(source string, target string, format string, args ...interface{}) {
+	logWith(r.log(), true, fmt.Sprintf(format, args...), logKeys(source, target)...)
+}
+
+// Logs a detailed message about the replication of the source to the target, only in debug level
+func (r *replicatorProps) debugf(source string, target string, format string, args ...interface{}) {
+	if l := r.log().V(1); l.Enabled() {
+		logWith(l, false, fmt.Sprintf(format, args...), logKeys(source, target)...)
+	}
+}
+
+// Returns the "namespace/name" key of the object, as a log field
+func objectKey(object metav1.Object) string {
+	return fmt.Sprintf("%s/%s", object.GetNamespace(), object.GetName())
+}
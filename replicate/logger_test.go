@@ -0,0 +1,65 @@
+package replicate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := newLogger(0, true, out).WithValues("kind", "secret")
+
+	l.Info("installing secret", "source", "default/source", "target", "other/target")
+	l.V(1).Info("not logged at the info level")
+	l.Error(errors.New("forbidden"), "could not install secret")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %s", len(lines), out.String())
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &fields); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for key, expected := range map[string]string{
+		"level":  "info",
+		"msg":    "installing secret",
+		"kind":   "secret",
+		"source": "default/source",
+		"target": "other/target",
+	} {
+		if fields[key] != expected {
+			t.Errorf("expected %s to be %s, got %v", key, expected, fields[key])
+		}
+	}
+
+	fields = map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[1]), &fields); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fields["level"] != "error" || fields["error"] != "forbidden" {
+		t.Errorf("expected an error line, got %s", lines[1])
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	if _, err := NewLogger("verbose", false); err == nil {
+		t.Errorf("expected an error for an unknown level")
+	}
+
+	l, err := NewLogger("error", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if l.Enabled() {
+		t.Errorf("expected the info lines to be disabled at the error level")
+	}
+
+	if l, _ = NewLogger("debug", false); !l.V(1).Enabled() {
+		t.Errorf("expected the debug lines to be enabled at the debug level")
+	}
+}
@@ -0,0 +1,48 @@
+package replicate
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Returns the maximum number of targets of the source, 0 for no limit
+// The annotation of the source overrides the limit of the replicator
+func (r *replicatorProps) maxTargets(sourceMeta *metav1.ObjectMeta) (int, error) {
+	val, ok := sourceMeta.Annotations[r.ReplicateMaxTargetsAnnotation]
+	if !ok {
+		return r.maxTargetsPerSource, nil
+	}
+	max, err := strconv.Atoi(val)
+	if err != nil || max < 0 {
+		return r.maxTargetsPerSource, fmt.Errorf("%s/%s has illformed annotation %s (%s): expected a positive number",
+			sourceMeta.Namespace, sourceMeta.Name, r.ReplicateMaxTargetsAnnotation, val)
+	}
+	return max, nil
+}
+
+// Returns false if the source would have more targets than allowed, so that a pattern matching
+// too many namespaces is rejected instead of creating all its targets
+// The rejection is reported with an event on the source and a metric
+func (r *objectReplicator) checkTargetsCount(object interface{}, count int) bool {
+	meta := r.getMeta(object)
+	key := objectKey(meta)
+	max, err := r.maxTargets(meta)
+	if err != nil {
+		r.errorf(key, "", "could not parse %s %s: %s", r.Name, key, err)
+		r.reportError(err)
+	}
+	if max == 0 || count <= max {
+		return true
+	}
+
+	err = fmt.Errorf("%s %s has %d targets, more than the maximum of %d: no target is replicated, set annotation %s to raise it",
+		r.Name, key, count, max, r.ReplicateMaxTargetsAnnotation)
+	r.errorf(key, "", "%s", err)
+	r.reportError(err)
+	r.recordEvent(object, v1.EventTypeWarning, "TooManyTargets", "%s", err)
+	r.metrics.add("replicator_rejected_fanouts_total", 1, "kind", r.Name)
+	return false
+}
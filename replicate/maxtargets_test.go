@@ -0,0 +1,44 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaxTargets(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:                "config map",
+			annotationNames:     annotationsWithPrefix(""),
+			maxTargetsPerSource: 2,
+		},
+		replicatorActions: ConfigMapActions,
+	}
+	// the client is not set, the events cannot be written
+	DryRun(true)
+	defer DryRun(false)
+
+	source := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{}}}
+	if !r.checkTargetsCount(source, 2) {
+		t.Errorf("expected 2 targets to be allowed")
+	}
+	if r.checkTargetsCount(source, 3) {
+		t.Errorf("expected 3 targets to be rejected")
+	}
+
+	// the annotation overrides the limit
+	source.Annotations[r.ReplicateMaxTargetsAnnotation] = "5"
+	if !r.checkTargetsCount(source, 3) {
+		t.Errorf("expected 3 targets to be allowed by the annotation")
+	}
+	source.Annotations[r.ReplicateMaxTargetsAnnotation] = "0"
+	if !r.checkTargetsCount(source, 100) {
+		t.Errorf("expected the limit to be disabled by the annotation")
+	}
+	source.Annotations[r.ReplicateMaxTargetsAnnotation] = "many"
+	if max, err := r.maxTargets(&source.ObjectMeta); err == nil || max != 2 {
+		t.Errorf("expected an error and the limit of the replicator, got %d, %v", max, err)
+	}
+}
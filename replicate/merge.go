@@ -0,0 +1,92 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Returns true if the data of the source is merged into the existing data of the target, instead of replacing it
+// The annotation of the target has priority over the one of the source, and over the rule selecting the source
+func (r *replicatorProps) isMerged(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, error) {
+	meta := object
+	val, ok := object.Annotations[r.ReplicationStrategyAnnotation]
+	if !ok {
+		meta = sourceObject
+		if val, ok = sourceObject.Annotations[r.ReplicationStrategyAnnotation]; ok {
+		} else if rule := r.matchRule(sourceObject); rule != nil {
+			return rule.Strategy == "merge", nil
+		} else {
+			return false, nil
+		}
+	}
+
+	switch val {
+	case "replace":
+		return false, nil
+	case "merge":
+		return true, nil
+	default:
+		return false, fmt.Errorf("%s/%s has illformed annotation %s (%s): expected replace or merge",
+			meta.Namespace, meta.Name, r.ReplicationStrategyAnnotation, val)
+	}
+}
+
+// Returns the keys of the target which were written by the replication
+func (r *replicatorProps) replicatedKeys(object *metav1.ObjectMeta) map[string]bool {
+	keys := map[string]bool{}
+	for _, key := range strings.Split(object.Annotations[r.ReplicatedKeysAnnotation], ",") {
+		if key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// Records the keys written by the replication on the target, so that they can be removed later
+func (r *replicatorProps) setReplicatedKeys(object *metav1.ObjectMeta, keys map[string]bool) {
+	list := make([]string, 0, len(keys))
+	for key := range keys {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+
+	if object.Annotations == nil {
+		object.Annotations = map[string]string{}
+	}
+	object.Annotations[r.ReplicatedKeysAnnotation] = strings.Join(list, ",")
+}
+
+// Merges the replicated data into the existing data of the target, replacing the keys previously replicated
+// The replicated keys are added to keys
+func mergeStrings(existing map[string]string, replicated map[string]string, previous map[string]bool, keys map[string]bool) map[string]string {
+	data := map[string]string{}
+	for key, value := range existing {
+		if !previous[key] {
+			data[key] = value
+		}
+	}
+	for key, value := range replicated {
+		data[key] = value
+		keys[key] = true
+	}
+	return data
+}
+
+// Merges the replicated binary data into the existing data of the target, replacing the keys previously replicated
+// The replicated keys are added to keys
+func mergeBytes(existing map[string][]byte, replicated map[string][]byte, previous map[string]bool, keys map[string]bool) map[string][]byte {
+	data := map[string][]byte{}
+	for key, value := range existing {
+		if !previous[key] {
+			data[key] = value
+		}
+	}
+	for key, value := range replicated {
+		data[key] = value
+		keys[key] = true
+	}
+	return data
+}
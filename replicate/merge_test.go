@@ -0,0 +1,52 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsMerged(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicationStrategyAnnotation: "merge",
+	}}
+	target := &metav1.ObjectMeta{Namespace: "other", Name: "target", Annotations: map[string]string{}}
+
+	if ok, err := r.isMerged(target, source); !ok || err != nil {
+		t.Errorf("expected the strategy of the source to be merge, got %v, %v", ok, err)
+	}
+	// the annotation of the target has priority
+	target.Annotations[r.ReplicationStrategyAnnotation] = "replace"
+	if ok, err := r.isMerged(target, source); ok || err != nil {
+		t.Errorf("expected the strategy of the target to be replace, got %v, %v", ok, err)
+	}
+	target.Annotations[r.ReplicationStrategyAnnotation] = "patch"
+	if _, err := r.isMerged(target, source); err == nil {
+		t.Errorf("expected an error for an unknown strategy")
+	}
+}
+
+func TestMergeStrings(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	target := &metav1.ObjectMeta{Annotations: map[string]string{r.ReplicatedKeysAnnotation: "old,shared"}}
+	existing := map[string]string{"local": "1", "old": "2", "shared": "3"}
+
+	keys := map[string]bool{}
+	data := mergeStrings(existing, map[string]string{"shared": "4", "new": "5"}, r.replicatedKeys(target), keys)
+	if expected := map[string]string{"local": "1", "shared": "4", "new": "5"}; !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+
+	r.setReplicatedKeys(target, keys)
+	if val := target.Annotations[r.ReplicatedKeysAnnotation]; val != "new,shared" {
+		t.Errorf("expected the replicated keys new,shared, got %s", val)
+	}
+
+	// clearing removes only the replicated keys
+	data = mergeStrings(data, nil, r.replicatedKeys(target), map[string]bool{})
+	if expected := map[string]string{"local": "1"}; !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+}
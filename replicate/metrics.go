@@ -0,0 +1,151 @@
+package replicate
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// the type and help of all the exported metrics
+var metricsDescriptions = map[string][2]string{
+	"replicator_target_collisions":                    {"gauge", "Number of targets which cannot be replicated as they belong to another object"},
+	"replicator_source_staleness_seconds":             {"gauge", "Seconds since all the targets of the source were last synchronized"},
+	"replicator_stale_targets":                        {"gauge", "Number of targets which are not synchronized with their source"},
+	"replicator_operations_total":                     {"counter", "Number of operations performed on targets: created, updated, cleared or deleted"},
+	"replicator_failures_total":                       {"counter", "Number of operations on targets which failed"},
+	"replicator_targets":                              {"gauge", "Number of targets tracked by the replicator"},
+	"replicator_sources":                              {"gauge", "Number of sources with targets tracked by the replicator"},
+	"replicator_drift_total":                          {"counter", "Number of targets found drifted from their source by the reconciliations: missing or modified"},
+	"replicator_drift_corrected_total":                {"counter", "Number of drifted targets repaired by the reconciliations"},
+	"replicator_certificate_expiry_timestamp_seconds": {"gauge", "Expiry time of the certificates of the TLS sources, as a Unix timestamp"},
+	"replicator_rejected_fanouts_total":               {"counter", "Number of times the targets of a source were rejected as more than the maximum number of targets"},
+	"replicator_noop_updates_total":                   {"counter", "Number of updates of objects which were not replicated as they changed nothing to replicate"},
+}
+
+// Metrics is a registry of metrics, served in the prometheus text format
+// All the methods can be called on a nil registry, doing nothing
+type Metrics struct {
+	lock sync.Mutex
+	// a {metric => {labels => value}} map
+	values map[string]map[string]float64
+	// functions updating the computed metrics, called before serving them
+	collectors []func()
+}
+
+// NewMetrics creates a new metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{
+		values: map[string]map[string]float64{},
+	}
+}
+
+// formats the labels, given as name and value pairs
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(labels)/2)
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, labels[i], escaper.Replace(labels[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// Sets the value of a metric
+func (m *Metrics) set(name string, value float64, labels ...string) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.values[name]; !ok {
+		m.values[name] = map[string]float64{}
+	}
+	m.values[name][formatLabels(labels)] = value
+}
+
+// Adds to the value of a metric
+func (m *Metrics) add(name string, value float64, labels ...string) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.values[name]; !ok {
+		m.values[name] = map[string]float64{}
+	}
+	m.values[name][formatLabels(labels)] += value
+}
+
+// Deletes the value of a metric
+func (m *Metrics) delete(name string, labels ...string) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.values[name], formatLabels(labels))
+}
+
+// Registers a function updating computed metrics, called before serving them
+func (m *Metrics) addCollector(collector func()) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.collectors = append(m.collectors, collector)
+}
+
+func (m *Metrics) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if m == nil {
+		return
+	}
+
+	// the collectors set metrics, they must be called without the lock
+	m.lock.Lock()
+	collectors := m.collectors
+	m.lock.Unlock()
+	for _, collector := range collectors {
+		collector()
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := make([]string, 0, len(m.values))
+	for name := range m.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if description, ok := metricsDescriptions[name]; ok {
+			fmt.Fprintf(res, "# HELP %s %s\n", name, description[1])
+			fmt.Fprintf(res, "# TYPE %s %s\n", name, description[0])
+		}
+
+		labels := make([]string, 0, len(m.values[name]))
+		for l := range m.values[name] {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+
+		for _, l := range labels {
+			fmt.Fprintf(res, "%s%s %g\n", name, l, m.values[name][l])
+		}
+	}
+}
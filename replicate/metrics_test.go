@@ -0,0 +1,37 @@
+package replicate
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsServeHTTP(t *testing.T) {
+	m := NewMetrics()
+	m.add("replicator_operations_total", 1, "kind", "secret", "operation", "created")
+	m.add("replicator_operations_total", 2, "kind", "secret", "operation", "created")
+	m.addCollector(func() {
+		m.set("replicator_targets", 3, "kind", "secret")
+	})
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	body := res.Body.String()
+
+	for _, line := range []string{
+		"# TYPE replicator_operations_total counter",
+		`replicator_operations_total{kind="secret",operation="created"} 3`,
+		"# TYPE replicator_targets gauge",
+		`replicator_targets{kind="secret"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsNil(t *testing.T) {
+	var m *Metrics
+	m.add("replicator_operations_total", 1, "kind", "secret", "operation", "created")
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+}
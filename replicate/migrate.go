@@ -0,0 +1,96 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Migration describes an object whose legacy annotations are renamed to the current prefix
+type Migration struct {
+	Kind   string `json:"kind"`
+	Object string `json:"object"`
+	// the legacy annotations and finalizer of the object
+	Renamed []string `json:"renamed"`
+	// the error of the write, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// MigrateAnnotations renames the annotations and the finalizer of the objects under the legacy prefixes to the current
+// prefix, as ReadLegacyPrefixes reads them, and returns the migrated objects
+// The objects are listed from the API, as they are in the store with their annotations already renamed
+func (r *objectReplicator) MigrateAnnotations() ([]Migration, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := r.legacyNames()
+	if len(names) == 0 {
+		return []Migration{}, nil
+	}
+	list, err := r.rawLister().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+	for _, item := range items {
+		object := r.getMeta(item)
+		renamed := []string{}
+		for annotation := range object.Annotations {
+			if _, ok := names[annotation]; ok {
+				renamed = append(renamed, annotation)
+			}
+		}
+		for _, finalizer := range object.Finalizers {
+			if _, ok := names[finalizer]; ok {
+				renamed = append(renamed, finalizer)
+			}
+		}
+		if len(renamed) == 0 {
+			continue
+		}
+
+		migrated := item.DeepCopyObject()
+		renameLegacy(migrated, names)
+		migratedMeta := r.getMeta(migrated)
+		if !r.isManaged(migratedMeta) {
+			continue
+		}
+		sort.Strings(renamed)
+		migration := Migration{Kind: r.Name, Object: fmt.Sprintf("%s/%s", object.Namespace, object.Name), Renamed: renamed}
+		r.infof("", migration.Object, "renaming legacy annotations of %s %s", r.Name, migration.Object)
+		// install it, but keeps the original data, not transformed as the data of a source
+		if err := r.install(&r.replicatorProps, migratedMeta.DeepCopy(), migrated, migrated.DeepCopyObject()); err != nil {
+			migration.Error = err.Error()
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Object < migrations[j].Object
+	})
+	return migrations, nil
+}
+
+// Returns the lister of the objects as they are in the API, without renaming their legacy annotations
+func (r *objectReplicator) rawLister() cache.ListerWatcher {
+	if dynamic, ok := baseActions(r.replicatorActions).(*dynamicActions); ok {
+		return scopedListWatch(func(namespace string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+					return dynamic.resource.Namespace(namespace).List(lo)
+				},
+			}
+		})
+	}
+	listWatch, _ := r.listWatch(r.client)
+	return listWatch
+}
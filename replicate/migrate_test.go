@@ -0,0 +1,76 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// actions listing the given objects instead of the ones of the API
+type listedActions struct {
+	replicatorActions
+	list runtime.Object
+}
+
+func (a *listedActions) listWatch(client kubernetes.Interface) (cache.ListerWatcher, runtime.Object) {
+	return &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			return a.list.DeepCopyObject(), nil
+		},
+	}, &v1.ConfigMap{}
+}
+
+func TestMigrateAnnotations(t *testing.T) {
+	ReadLegacyPrefixes([]string{"replicator.v1.mittwald.de/"})
+	defer ReadLegacyPrefixes(nil)
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+
+	list := &v1.ConfigMapList{Items: []v1.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "legacy", ResourceVersion: "1",
+			Annotations: map[string]string{"replicator.v1.mittwald.de/replicate-to": "team-a/legacy"},
+			Finalizers:  []string{"replicator.v1.mittwald.de/replicated-targets"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "current", ResourceVersion: "1",
+			Annotations: map[string]string{"v1.kubernetes-replicator.olli.com/replicate-to": "team-a/current"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other-instance", ResourceVersion: "1",
+			Annotations: map[string]string{"replicator.v1.mittwald.de/replicator-instance": "other"}}},
+	}}
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix("v1.kubernetes-replicator.olli.com/"),
+			objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		},
+		// the client is not set, any write would panic
+		replicatorActions: &listedActions{&dryRunActions{ConfigMapActions}, list},
+	}
+
+	migrations, err := r.MigrateAnnotations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Migration{{Kind: "config map", Object: "default/legacy",
+		Renamed: []string{"replicator.v1.mittwald.de/replicate-to", "replicator.v1.mittwald.de/replicated-targets"}}}
+	if !reflect.DeepEqual(migrations, expected) {
+		t.Errorf("expected migrations %v, got %v", expected, migrations)
+	}
+	if actions := DryRunActions(); len(actions) != 1 || actions[0].Action != "update" || actions[0].Target != "default/legacy" {
+		t.Errorf("expected the update of the migrated object, got %v", actions)
+	}
+	if _, ok := list.Items[0].Annotations["replicator.v1.mittwald.de/replicate-to"]; !ok {
+		t.Errorf("expected the listed object to be left unchanged")
+	}
+
+	ReadLegacyPrefixes(nil)
+	if migrations, err := r.MigrateAnnotations(); err != nil || len(migrations) != 0 {
+		t.Errorf("expected no migration without legacy prefix, got %v %v", migrations, err)
+	}
+}
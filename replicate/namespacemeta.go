@@ -0,0 +1,103 @@
+package replicate
+
+import (
+	"encoding/json"
+	"io"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the media types of the metadata of the namespaces, the API servers which cannot return it returning the whole namespaces
+const (
+	namespaceMetadataListAccept  = "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1beta1,application/json"
+	namespaceMetadataWatchAccept = "application/json;as=PartialObjectMetadata;g=meta.k8s.io;v=v1beta1,application/json"
+)
+
+// Lists and watches only the metadata of the namespaces, returned as namespaces without spec
+// Their phase is deduced from their deletion timestamp, so that they can be handled as the whole namespaces
+func namespaceMetadataListWatch(client kubernetes.Interface, restrict func(lo metav1.ListOptions) metav1.ListOptions) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			lo = restrict(lo)
+			body, err := client.CoreV1().RESTClient().Get().
+				Resource("namespaces").
+				VersionedParams(&lo, scheme.ParameterCodec).
+				SetHeader("Accept", namespaceMetadataListAccept).
+				DoRaw()
+			if err != nil {
+				return nil, err
+			}
+			// the items of a list of metadata have the metadata of the namespaces
+			list := &v1.NamespaceList{}
+			if err := json.Unmarshal(body, list); err != nil {
+				return nil, err
+			}
+			for index := range list.Items {
+				namespaceFromMetadata(&list.Items[index])
+			}
+			return list, nil
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			lo = restrict(lo)
+			lo.Watch = true
+			stream, err := client.CoreV1().RESTClient().Get().
+				Resource("namespaces").
+				VersionedParams(&lo, scheme.ParameterCodec).
+				SetHeader("Accept", namespaceMetadataWatchAccept).
+				Stream()
+			if err != nil {
+				return nil, err
+			}
+			return watch.NewStreamWatcher(&namespaceMetadataDecoder{stream, json.NewDecoder(stream)}), nil
+		},
+	}
+}
+
+// Completes a namespace read from its metadata
+func namespaceFromMetadata(namespace *v1.Namespace) {
+	namespace.TypeMeta = metav1.TypeMeta{}
+	if namespace.Status.Phase != "" {
+		return
+	} else if namespace.DeletionTimestamp != nil {
+		namespace.Status.Phase = v1.NamespaceTerminating
+	} else {
+		namespace.Status.Phase = v1.NamespaceActive
+	}
+}
+
+// decodes the events of a watch of the metadata of the namespaces
+type namespaceMetadataDecoder struct {
+	stream  io.ReadCloser
+	decoder *json.Decoder
+}
+
+func (d *namespaceMetadataDecoder) Decode() (watch.EventType, runtime.Object, error) {
+	event := struct {
+		Type   watch.EventType `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}{}
+	if err := d.decoder.Decode(&event); err != nil {
+		return "", nil, err
+	}
+	if event.Type == watch.Error {
+		status := &metav1.Status{}
+		err := json.Unmarshal(event.Object, status)
+		return event.Type, status, err
+	}
+	namespace := &v1.Namespace{}
+	if err := json.Unmarshal(event.Object, namespace); err != nil {
+		return "", nil, err
+	}
+	namespaceFromMetadata(namespace)
+	return event.Type, namespace, nil
+}
+
+func (d *namespaceMetadataDecoder) Close() {
+	d.stream.Close()
+}
@@ -0,0 +1,59 @@
+package replicate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestNamespaceMetadataListWatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.Contains(req.Header.Get("Accept"), "as=PartialObjectMetadata") {
+			t.Errorf("expected only the metadata to be requested, got %s", req.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if req.URL.Query().Get("watch") == "true" {
+			w.Write([]byte(`{"type":"ADDED","object":{"kind":"PartialObjectMetadata","apiVersion":"meta.k8s.io/v1beta1",` +
+				`"metadata":{"name":"team-b","resourceVersion":"12","labels":{"team":"b"}}}}` + "\n"))
+			return
+		}
+		w.Write([]byte(`{"kind":"PartialObjectMetadataList","apiVersion":"meta.k8s.io/v1beta1","metadata":{"resourceVersion":"10"},` +
+			`"items":[{"kind":"PartialObjectMetadata","apiVersion":"meta.k8s.io/v1beta1","metadata":{"name":"team-a","resourceVersion":"9"}},` +
+			`{"kind":"PartialObjectMetadata","apiVersion":"meta.k8s.io/v1beta1","metadata":{"name":"old","deletionTimestamp":"2020-01-01T00:00:00Z"}}]}`))
+	}))
+	defer server.Close()
+	client := kubernetes.NewForConfigOrDie(&rest.Config{Host: server.URL})
+	lw := namespaceMetadataListWatch(client, func(lo metav1.ListOptions) metav1.ListOptions { return lo })
+
+	object, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := object.(*v1.NamespaceList)
+	if list.ResourceVersion != "10" || len(list.Items) != 2 {
+		t.Fatalf("expected the namespaces of version 10, got %v", list)
+	}
+	if list.Items[0].Name != "team-a" || list.Items[0].Status.Phase != v1.NamespaceActive {
+		t.Errorf("expected an active namespace, got %v", list.Items[0])
+	}
+	if list.Items[1].Status.Phase != v1.NamespaceTerminating {
+		t.Errorf("expected a namespace being deleted to be terminating, got %v", list.Items[1])
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{ResourceVersion: "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	event := <-w.ResultChan()
+	if namespace, ok := event.Object.(*v1.Namespace); event.Type != watch.Added || !ok || namespace.Labels["team"] != "b" {
+		t.Errorf("expected the namespace to be added with its labels, got %v", event)
+	}
+}
@@ -0,0 +1,64 @@
+package replicate
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNamespaceDeleted(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{
+		Name: "secret",
+		retries: map[string]*retryState{
+			"team-a/a": {Attempts: 1},
+			"team-b/a": {Attempts: 1},
+		},
+		collisions: map[collisionKey]string{
+			{"default/b", "team-a/b"}: "",
+		},
+	}}
+	r.queue, r.rateLimiter = newQueue("secret")
+	defer r.queue.ShutDown()
+
+	r.NamespaceDeleted(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+
+	if _, ok := r.retries["team-a/a"]; ok || len(r.retries) != 1 {
+		t.Errorf("expected the retries in the namespace to be forgotten, got %v", r.retries)
+	}
+	if len(r.collisions) != 0 {
+		t.Errorf("expected the collisions in the namespace to be resolved, got %v", r.collisions)
+	}
+}
+
+func TestNamespaceAddedDebounce(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{
+		Name:              "secret",
+		annotationNames:   annotationsWithPrefix(""),
+		namespaceDebounce: 20 * time.Millisecond,
+		namespaceStore:    cache.NewStore(cache.MetaNamespaceKeyFunc),
+	}, replicatorActions: SecretActions}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a",
+		Annotations: map[string]string{r.ReplicateToAnnotation: "team-a/a,team-b/a"}}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b",
+		Annotations: map[string]string{r.ReplicateToAnnotation: "team-c/b"}}})
+	r.queue, r.rateLimiter = newQueue("secret")
+	defer r.queue.ShutDown()
+
+	r.NamespaceAdded(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	r.NamespaceAdded(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}})
+	if r.queue.Len() != 0 {
+		t.Errorf("expected the sources to wait for the end of the window")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if r.queue.Len() != 1 {
+		t.Fatalf("expected the source to be queued once, got %d items", r.queue.Len())
+	}
+	if item, _ := r.queue.Get(); item != "default/a" {
+		t.Errorf("expected default/a to be queued, got %v", item)
+	}
+}
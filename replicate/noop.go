@@ -0,0 +1,37 @@
+package replicate
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Returns true if the update of the object changes neither its data, nor its annotations, nor its propagated labels,
+// so that its targets have nothing to replicate, ex: a change of another label or of the owners of a source
+// The resynchronizations of the informer are not no-ops, as they repair the targets
+func (r *objectReplicator) isNoopUpdate(old interface{}, new interface{}) bool {
+	oldMeta, newMeta := r.getMeta(old), r.getMeta(new)
+	if oldMeta.ResourceVersion == newMeta.ResourceVersion {
+		return false
+	} else if oldMeta.DeletionTimestamp != nil || newMeta.DeletionTimestamp != nil {
+		return false
+	} else if !reflect.DeepEqual(oldMeta.Annotations, newMeta.Annotations) {
+		return false
+	}
+
+	oldLabels, newLabels := &metav1.ObjectMeta{}, &metav1.ObjectMeta{}
+	r.propagateLabels(oldLabels, oldMeta)
+	r.propagateLabels(newLabels, newMeta)
+	if !reflect.DeepEqual(oldLabels.Labels, newLabels.Labels) {
+		return false
+	}
+
+	// the data is compared by hash
+	oldHash := r.replicatedDataHash(old)
+	return oldHash != "" && oldHash == r.replicatedDataHash(new)
+}
+
+// Counts an update which was not replicated, as it changed nothing to replicate
+func (r *replicatorProps) countNoop(stage string) {
+	r.metrics.add("replicator_noop_updates_total", 1, "kind", r.Name, "stage", stage)
+}
@@ -0,0 +1,59 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNoopUpdates(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			updated:         map[string]interface{}{},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.queue, r.rateLimiter = newQueue("test")
+	defer r.queue.ShutDown()
+
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "10",
+			Labels: map[string]string{"team": "a"}, Annotations: map[string]string{r.ReplicationAllowed: "true"}},
+		Data: map[string][]byte{"key": []byte("value")},
+	}
+
+	// a change of a label which is not propagated is not replicated
+	labeled := source.DeepCopy()
+	labeled.ResourceVersion = "11"
+	labeled.Labels["team"] = "b"
+	r.enqueueUpdated(source, labeled)
+	if r.queue.Len() != 0 {
+		t.Errorf("expected the change of the label not to be queued")
+	}
+	if !r.isSourceVersion(&labeled.ObjectMeta, "10") {
+		t.Errorf("expected the targets of version 10 to be up to date")
+	}
+
+	// the resyncs are still processed
+	r.enqueueUpdated(labeled, labeled)
+	if r.queue.Len() != 1 {
+		t.Errorf("expected the resync to be queued")
+	}
+
+	// the changes of the annotations and of the data are replicated
+	annotated := labeled.DeepCopy()
+	annotated.ResourceVersion = "12"
+	annotated.Annotations[r.ReplicateOnceAnnotation] = "true"
+	if r.isNoopUpdate(labeled, annotated) {
+		t.Errorf("expected the change of the annotations to be replicated")
+	}
+	changed := labeled.DeepCopy()
+	changed.ResourceVersion = "12"
+	changed.Data["key"] = []byte("changed")
+	if r.isNoopUpdate(labeled, changed) {
+		t.Errorf("expected the change of the data to be replicated")
+	}
+}
@@ -0,0 +1,139 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OrphanAction is what to do with the orphans once found
+type OrphanAction string
+
+const (
+	// OrphanReport only reports the orphans
+	OrphanReport OrphanAction = ""
+	// OrphanDelete deletes the orphans
+	OrphanDelete OrphanAction = "delete"
+	// OrphanClear removes the replicated data of the orphans
+	OrphanClear OrphanAction = "clear"
+)
+
+// Orphan describes an object with replication annotations,
+// whose source does not exist or does not target it anymore
+type Orphan struct {
+	Kind   string `json:"kind"`
+	Object string `json:"object"`
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+	// the error of the action, if it failed
+	Error string `json:"error,omitempty"`
+	// true if the orphan was deleted or cleared after the initial synchronization
+	Collected bool `json:"collected,omitempty"`
+}
+
+// Orphans lists the orphans managed by this replicator, and applies the action to them
+// If the replicator is not started, the objects are listed first
+func (r *objectReplicator) Orphans(action OrphanAction) ([]Orphan, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.orphans(action)
+}
+
+func (r *objectReplicator) orphans(action OrphanAction) ([]Orphan, error) {
+	if err := r.listUnstarted(); err != nil {
+		return nil, err
+	}
+
+	objects := r.objectStore.List()
+	// an {alias => source} map, as the state of the replicator may not be loaded
+	aliases := map[string]string{}
+	for _, object := range objects {
+		meta := r.getMeta(object)
+		if names, err := r.getAliases(meta); err == nil {
+			for _, alias := range names {
+				aliases[alias] = fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+			}
+		}
+	}
+
+	orphans := []Orphan{}
+	for _, object := range objects {
+		meta := r.getMeta(object)
+		if !r.isManaged(meta) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+		source, replicatedBy := meta.Annotations[r.ReplicatedByAnnotation]
+		if !replicatedBy {
+			if from, ok := resolveAnnotation(meta, r.ReplicateFromAnnotation); ok {
+				source = from
+			} else {
+				continue
+			}
+		}
+
+		// the sources in other clusters and the merged sources are not checked
+		if strings.ContainsAny(source, ":,") {
+			continue
+		}
+
+		reason := ""
+		if sourceObject, exists, err := r.objectStore.GetByKey(source); err != nil {
+			return nil, err
+		} else if !exists {
+			// the source was renamed, its targets are migrated
+			if _, ok := aliases[source]; ok {
+				continue
+			}
+			reason = fmt.Sprintf("source %s does not exist", source)
+		} else if !replicatedBy {
+			// the objects pulling their data must still be allowed by their source
+			if ok, err := r.isReplicationAllowed(meta, r.getMeta(sourceObject)); !ok && !isDenied(err) {
+				r.errorf(source, key, "%s %s is not checked: %s", r.Name, key, err)
+				continue
+			} else if !ok {
+				reason = fmt.Sprintf("source %s does not allow the replication anymore: %s", source, err)
+			}
+		} else if ok, err := r.isReplicatedTo(r.getMeta(sourceObject), meta); err != nil {
+			// the targets are kept until the annotations of their source are fixed
+			r.errorf(source, key, "%s %s is not checked: source %s has illformed annotations: %s", r.Name, key, source, err)
+			continue
+		} else if !ok {
+			reason = fmt.Sprintf("source %s does not replicate to %s anymore", source, key)
+		}
+
+		if reason == "" {
+			continue
+		}
+
+		orphan := Orphan{Kind: r.Name, Object: key, Source: source, Reason: reason}
+		if err := r.resolveOrphan(object, action); err != nil {
+			orphan.Error = err.Error()
+		}
+		orphans = append(orphans, orphan)
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Object < orphans[j].Object
+	})
+	return orphans, nil
+}
+
+// Applies the action to the orphan
+// The objects pulling their data with replicate-from are only reported, as they are created by the users
+func (r *objectReplicator) resolveOrphan(object interface{}, action OrphanAction) error {
+	if _, ok := r.getMeta(object).Annotations[r.ReplicatedByAnnotation]; !ok {
+		return nil
+	}
+	switch action {
+	case OrphanDelete:
+		return r.delete(&r.replicatorProps, object)
+	case OrphanClear:
+		if _, ok := r.getMeta(object).Annotations[r.ReplicatedFromVersionAnnotation]; ok {
+			return r.clear(&r.replicatorProps, object)
+		}
+	}
+	return nil
+}
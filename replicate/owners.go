@@ -0,0 +1,135 @@
+package replicate
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Returns a reference to the owner object, for the objects it owns
+func (r *replicatorProps) ownerReference(owner *metav1.ObjectMeta, ownerObject interface{}) metav1.OwnerReference {
+	// the typed objects have no kind, unlike the objects of the dynamic replicators
+	ownerKind := ownerKinds[r.kind()]
+	if object, ok := ownerObject.(runtime.Object); ok && object.GetObjectKind().GroupVersionKind().Kind != "" {
+		ownerKind.APIVersion, ownerKind.Kind = object.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+	}
+
+	return metav1.OwnerReference{
+		APIVersion: ownerKind.APIVersion,
+		Kind:       ownerKind.Kind,
+		Name:       owner.Name,
+		UID:        owner.UID,
+	}
+}
+
+// Sets the source as owner of the target in the same namespace, when owner references are enabled
+// The targets in other namespaces cannot be owned, their source has a finalizer instead
+func (r *replicatorProps) setOwner(object *metav1.ObjectMeta, sourceMeta *metav1.ObjectMeta, sourceObject interface{}) {
+	if !r.ownerReferences || sourceMeta.UID == "" || object.Namespace != sourceMeta.Namespace {
+		return
+	}
+	// the garbage collector would delete the targets which the source keeps
+	if policy, _ := r.deletionPolicy(sourceMeta); policy != DeletionPolicyDelete {
+		return
+	}
+	object.OwnerReferences = []metav1.OwnerReference{r.ownerReference(sourceMeta, sourceObject)}
+}
+
+// Returns true if the object is owned by the source
+func isOwnedBy(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) bool {
+	for _, reference := range object.OwnerReferences {
+		if reference.UID == sourceObject.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the object has the finalizer
+func hasFinalizer(object *metav1.ObjectMeta, finalizer string) bool {
+	for _, f := range object.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds the finalizer to the source while it has targets in other namespaces, so that they are deleted
+// before it, and removes it otherwise
+func (r *objectReplicator) updateFinalizer(key string) {
+	object, exists, err := r.objectStore.GetByKey(key)
+	if err != nil || !exists {
+		return
+	}
+	meta := r.getMeta(object)
+	if meta.DeletionTimestamp != nil {
+		return
+	}
+
+	needed := false
+	if policy, _ := r.deletionPolicy(meta); r.ownerReferences && policy == DeletionPolicyDelete {
+		for _, target := range r.targetsOf(key) {
+			if !strings.HasPrefix(target, meta.Namespace+"/") {
+				needed = true
+				break
+			}
+		}
+	}
+	if hasFinalizer(meta, r.ReplicatedTargetsFinalizer) == needed {
+		return
+	}
+
+	if err := r.setFinalizer(object, needed); err != nil && !isPaused(err) {
+		r.errorf(key, "", "could not update finalizer of %s %s: %s", r.Name, key, err)
+	}
+}
+
+// Adds or removes the finalizer of the source, keeping its data
+func (r *objectReplicator) setFinalizer(object interface{}, present bool) error {
+	copyMeta := r.getMeta(object).DeepCopy()
+	finalizers := []string{}
+	for _, f := range copyMeta.Finalizers {
+		if f != r.ReplicatedTargetsFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if present {
+		finalizers = append(finalizers, r.ReplicatedTargetsFinalizer)
+	}
+	copyMeta.Finalizers = finalizers
+
+	// a copy of the data, so that it is not transformed as the data of a source
+	data := object.(runtime.Object).DeepCopyObject()
+	return r.install(&r.replicatorProps, copyMeta, object, data)
+}
+
+// Deletes the targets of the source being deleted, then removes its finalizer so that it can be deleted
+func (r *objectReplicator) finalizeSource(object interface{}) {
+	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+
+	// the source is finalized once the replication is resumed, its finalizer is not removed while its targets remain
+	if ok, err := r.isRunning(meta); !ok {
+		r.debugf(key, "", "finalization of %s %s is skipped: %s", r.Name, key, err)
+		return
+	}
+	r.infof(key, "", "source %s %s is being deleted: deleting its targets", r.Name, key)
+	policy := r.sourceDeletionPolicy(meta)
+	for _, target := range r.targetsOf(key) {
+		// the target was already deleted
+		if _, exists, err := r.objectStore.GetByKey(target); err == nil && !exists {
+			continue
+		}
+		// the deletion is retried when the source is processed again
+		if ok, err := r.removeTarget(target, object, policy); ok && err != nil {
+			return
+		}
+	}
+
+	if err := r.setFinalizer(object, false); err != nil {
+		r.errorf(key, "", "could not remove finalizer of %s %s: %s", r.Name, key, err)
+	}
+}
@@ -0,0 +1,36 @@
+package replicate
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSetOwner(t *testing.T) {
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix(""), ownerReferences: true}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", UID: types.UID("1234")}
+
+	target := &metav1.ObjectMeta{Namespace: "default", Name: "target"}
+	r.setOwner(target, source, nil)
+	if len(target.OwnerReferences) != 1 || target.OwnerReferences[0].Kind != "Secret" || target.OwnerReferences[0].Name != "source" {
+		t.Errorf("expected the target to be owned by secret source, got %v", target.OwnerReferences)
+	}
+	if !isOwnedBy(target, source) {
+		t.Errorf("expected the target to be owned by the source")
+	}
+
+	// the objects cannot be owned across namespaces
+	other := &metav1.ObjectMeta{Namespace: "other", Name: "target"}
+	r.setOwner(other, source, nil)
+	if len(other.OwnerReferences) != 0 || isOwnedBy(other, source) {
+		t.Errorf("expected the target in another namespace not to be owned, got %v", other.OwnerReferences)
+	}
+
+	r.ownerReferences = false
+	disabled := &metav1.ObjectMeta{Namespace: "default", Name: "target"}
+	r.setOwner(disabled, source, nil)
+	if len(disabled.OwnerReferences) != 0 {
+		t.Errorf("expected no owner reference when disabled, got %v", disabled.OwnerReferences)
+	}
+}
@@ -0,0 +1,82 @@
+package replicate
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Wraps the lister watcher of the replicator, so that the objects are listed by pages of the configured size
+func (r *replicatorProps) pageLists(listWatch cache.ListerWatcher) cache.ListerWatcher {
+	if listWatch == nil || r.listPageSize <= 0 {
+		return listWatch
+	}
+	return &pagedListWatch{listWatch, r.listPageSize}
+}
+
+// a lister watcher listing the objects by pages, returned as a single list
+type pagedListWatch struct {
+	cache.ListerWatcher
+	pageSize int64
+}
+
+func (lw *pagedListWatch) List(lo metav1.ListOptions) (runtime.Object, error) {
+	lo.Limit = lw.pageSize
+	// the API server ignores the limit of the lists served from its cache, the pages are read from etcd
+	if lo.ResourceVersion == "0" {
+		lo.ResourceVersion = ""
+	}
+	return listPages(lw.ListerWatcher.List, lo)
+}
+
+func (lw *pagedListWatch) Watch(lo metav1.ListOptions) (watch.Interface, error) {
+	lo.Limit = 0
+	return lw.ListerWatcher.Watch(lo)
+}
+
+// Lists all the objects, page by page when the options have a limit, returned as a list of the type of the first page
+// Falls back to a full list when the list expires between two pages
+func listPages(list func(metav1.ListOptions) (runtime.Object, error), lo metav1.ListOptions) (runtime.Object, error) {
+	first, err := list(lo)
+	if err != nil {
+		return first, err
+	}
+	accessor, err := meta.ListAccessor(first)
+	if err != nil || accessor.GetContinue() == "" {
+		return first, err
+	}
+	items, err := meta.ExtractList(first)
+	if err != nil {
+		return nil, err
+	}
+
+	for continued := accessor.GetContinue(); continued != ""; {
+		lo.Continue = continued
+		page, err := list(lo)
+		if errors.IsResourceExpired(err) {
+			lo.Limit, lo.Continue = 0, ""
+			return list(lo)
+		} else if err != nil {
+			return nil, err
+		}
+		objects, err := meta.ExtractList(page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, objects...)
+		pageAccessor, err := meta.ListAccessor(page)
+		if err != nil {
+			return nil, err
+		}
+		continued = pageAccessor.GetContinue()
+	}
+
+	if err := meta.SetList(first, items); err != nil {
+		return nil, err
+	}
+	accessor.SetContinue("")
+	return first, nil
+}
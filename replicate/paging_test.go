@@ -0,0 +1,56 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPagedListWatch(t *testing.T) {
+	pages := map[string]*v1.ConfigMapList{
+		"": {ListMeta: metav1.ListMeta{ResourceVersion: "10", Continue: "page-2"},
+			Items: []v1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "a"}}, {ObjectMeta: metav1.ObjectMeta{Name: "b"}}}},
+		"page-2": {ListMeta: metav1.ListMeta{ResourceVersion: "10"},
+			Items: []v1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "c"}}}},
+	}
+	expired := false
+	requests := []metav1.ListOptions{}
+	lw := &cache.ListWatch{DisableChunking: true, ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+		requests = append(requests, lo)
+		if expired && lo.Continue != "" {
+			return nil, errors.NewResourceExpired("continue expired")
+		} else if lo.Limit == 0 {
+			return &v1.ConfigMapList{Items: []v1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "whole"}}}}, nil
+		}
+		return pages[lo.Continue].DeepCopy(), nil
+	}}
+
+	r := &replicatorProps{listPageSize: 2}
+	object, err := r.pageLists(lw).List(metav1.ListOptions{ResourceVersion: "0", Limit: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := object.(*v1.ConfigMapList)
+	if len(list.Items) != 3 || list.Items[2].Name != "c" || list.Continue != "" || list.ResourceVersion != "10" {
+		t.Errorf("expected the pages to be merged, got %v", list)
+	}
+	if len(requests) != 2 || requests[0].Limit != 2 || requests[0].ResourceVersion != "" {
+		t.Errorf("expected 2 pages to be read from etcd, got %v", requests)
+	}
+
+	// an expired list is read again at once
+	expired = true
+	object, err = r.pageLists(lw).List(metav1.ListOptions{})
+	if err != nil || len(object.(*v1.ConfigMapList).Items) != 1 {
+		t.Errorf("expected the whole list, got %v %v", object, err)
+	}
+
+	r.listPageSize = 0
+	if r.pageLists(lw) != cache.ListerWatcher(lw) {
+		t.Errorf("expected the objects to be listed at once by default")
+	}
+}
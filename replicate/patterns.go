@@ -0,0 +1,79 @@
+package replicate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the options of the namespace patterns
+type patternOptions struct {
+	caseInsensitive bool
+	unanchored      bool
+}
+
+// the options of the namespace patterns of the objects without the pattern-options annotation
+var defaultPatternOptions = patternOptions{}
+
+// PatternOptions sets the default options of the namespace patterns, comma separated:
+//   - "case-insensitive" or "case-sensitive" (default)
+//   - "unanchored", for patterns matching a part of the namespace, or "anchored" (default)
+func PatternOptions(options string) error {
+	parsed, err := parsePatternOptions(options, patternOptions{})
+	if err != nil {
+		return err
+	}
+
+	defaultPatternOptions = parsed
+	return nil
+}
+
+// Parses comma separated options, overriding the given ones
+func parsePatternOptions(options string, parsed patternOptions) (patternOptions, error) {
+	for _, option := range strings.Split(options, ",") {
+		switch strings.TrimSpace(option) {
+		case "":
+		case "case-insensitive":
+			parsed.caseInsensitive = true
+		case "case-sensitive":
+			parsed.caseInsensitive = false
+		case "unanchored":
+			parsed.unanchored = true
+		case "anchored":
+			parsed.unanchored = false
+		default:
+			return parsed, fmt.Errorf("unknown pattern option %s", option)
+		}
+	}
+
+	return parsed, nil
+}
+
+// Compiles a namespace pattern with the options
+func (options patternOptions) compile(pattern string) (*regexp.Regexp, error) {
+	expr := `(?:` + pattern + `)`
+	if !options.unanchored {
+		expr = `^` + expr + `$`
+	}
+	if options.caseInsensitive {
+		expr = `(?i)` + expr
+	}
+
+	return regexp.Compile(expr)
+}
+
+// Compiles a namespace pattern of the annotations of the object,
+// with the options of its pattern-options annotation, or the default ones
+func (r *replicatorProps) compilePattern(object *metav1.ObjectMeta, pattern string) (*regexp.Regexp, error) {
+	options := defaultPatternOptions
+	if val, ok := object.Annotations[r.ReplicatePatternOptionsAnnotation]; ok {
+		var err error
+		if options, err = parsePatternOptions(val, options); err != nil {
+			return nil, fmt.Errorf("illformed annotation %s (%s): %s", r.ReplicatePatternOptionsAnnotation, val, err)
+		}
+	}
+
+	return options.compile(pattern)
+}
@@ -0,0 +1,95 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPatternOptionsCompile(t *testing.T) {
+	cases := []struct {
+		options   string
+		namespace string
+		match     bool
+	}{
+		{"", "team-a", true},
+		{"", "prod-team-a", false},
+		{"", "Team-A", false},
+		{"unanchored", "prod-team-a", true},
+		{"case-insensitive", "Team-A", true},
+		{"case-insensitive,unanchored", "prod-Team-A-1", true},
+		{"unanchored,anchored", "prod-team-a", false},
+	}
+
+	for _, c := range cases {
+		options, err := parsePatternOptions(c.options, patternOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error for options %q: %s", c.options, err)
+		}
+		pattern, err := options.compile("team-[a-z]")
+		if err != nil {
+			t.Fatalf("unexpected error for options %q: %s", c.options, err)
+		}
+		if match := pattern.MatchString(c.namespace); match != c.match {
+			t.Errorf("options %q on %s: expected %v, got %v", c.options, c.namespace, c.match, match)
+		}
+	}
+}
+
+func TestPatternOptionsInvalid(t *testing.T) {
+	if _, err := parsePatternOptions("case-insensitive,multiline", patternOptions{}); err == nil {
+		t.Errorf("expected error for unknown option")
+	}
+}
+
+func TestPatternOptionsAnnotationOverridesDefault(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	object := &metav1.ObjectMeta{Annotations: map[string]string{
+		r.ReplicatePatternOptionsAnnotation: "unanchored",
+	}}
+
+	pattern, err := r.compilePattern(object, "team")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !pattern.MatchString("prod-team-a") {
+		t.Errorf("expected unanchored pattern to match")
+	}
+
+	object.Annotations[r.ReplicatePatternOptionsAnnotation] = "unknown"
+	if _, err := r.compilePattern(object, "team"); err == nil {
+		t.Errorf("expected error for illformed annotation")
+	}
+}
+
+func TestTargetPatternSelector(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	r.namespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments-dev",
+		Labels: map[string]string{"team": "payments", "env": "dev"}}})
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments-prod",
+		Labels: map[string]string{"team": "payments", "env": "prod"}}})
+
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicateToNamespacesSelectorAnnotation: "team=payments,env in (dev,staging)",
+	}}
+	targets, patterns, err := r.getReplicationTargets(source)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(targets) != 0 || len(patterns) != 1 {
+		t.Fatalf("expected a single pattern, got %v and %v", targets, patterns)
+	}
+
+	if got := patterns[0].Targets(r.namespaceStore.ListKeys()); len(got) != 1 || got[0] != "payments-dev/source" {
+		t.Errorf("expected target payments-dev/source, got %v", got)
+	}
+
+	r.simulatedNamespace = &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments-staging",
+		Labels: map[string]string{"team": "payments", "env": "staging"}}}
+	if target := patterns[0].MatchNamespace("payments-staging"); target != "payments-staging/source" {
+		t.Errorf("expected the simulated namespace to match, got %q", target)
+	}
+}
@@ -0,0 +1,170 @@
+package replicate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 1 while the replication is paused for all the sources
+var paused int32
+
+// Pause pauses or resumes the replication of all the sources
+// While paused, the caches are kept up-to-date but nothing is written;
+// once resumed, all the objects are processed again to catch up with the changes
+func Pause(enabled bool) {
+	value := int32(0)
+	if enabled {
+		value = 1
+	}
+	if atomic.SwapInt32(&paused, value) == value {
+		return
+	}
+
+	if enabled {
+		logf("replication is paused")
+		return
+	}
+	logf("replication is resumed")
+	dependencies.Lock()
+	defer dependencies.Unlock()
+	for _, r := range dependencies.replicators {
+		r.resync()
+	}
+}
+
+// Paused returns true while the replication is paused for all the sources
+func Paused() bool {
+	return atomic.LoadInt32(&paused) == 1
+}
+
+// TogglePause pauses the replication if running, resumes it otherwise
+// Returns true if it is now paused
+func TogglePause() bool {
+	enabled := !Paused()
+	Pause(enabled)
+	return enabled
+}
+
+// Returns true if the source is paused by its annotation
+func (r *replicatorProps) isSourcePaused(sourceMeta *metav1.ObjectMeta) (bool, error) {
+	val, ok := sourceMeta.Annotations[r.ReplicatePausedAnnotation]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("%s/%s has illformed annotation %s (%s): expected a boolean",
+			sourceMeta.Namespace, sourceMeta.Name, r.ReplicatePausedAnnotation, val)
+	}
+	return b, nil
+}
+
+// Returns false if nothing can be written for the source, as the replication is paused globally or for the source
+// The source is nil for the writes which do not depend on a source
+// The paused writes are not retried, they are made once the source is processed again after the resume
+// The writes of the actions are paused by pausedActions, only the other writes must check it
+func (r *replicatorProps) isRunning(sourceMeta *metav1.ObjectMeta) (bool, error) {
+	if Paused() {
+		return false, fmt.Errorf("replication is paused")
+	} else if sourceMeta == nil {
+		return true, nil
+	}
+
+	paused, err := r.isSourcePaused(sourceMeta)
+	// an illformed annotation does not pause the source
+	if err != nil {
+		r.errorf(objectKey(sourceMeta), "", "could not parse %s %s: %s", r.Name, objectKey(sourceMeta), err)
+		r.reportError(err)
+	} else if paused {
+		return false, fmt.Errorf("replication of %s is paused", objectKey(sourceMeta))
+	}
+	return true, nil
+}
+
+// the error of a write skipped while the replication is paused, which is not retried
+type pausedError struct {
+	message string
+}
+
+func (e *pausedError) Error() string {
+	return e.message
+}
+
+// Returns true if the error is a write skipped while the replication is paused
+func isPaused(err error) bool {
+	_, ok := err.(*pausedError)
+	return ok
+}
+
+// the actions of a replicator which write nothing while the replication is paused, globally or for the source
+type pausedActions struct {
+	replicatorActions
+}
+
+func (a *pausedActions) wrapped() replicatorActions {
+	return a.replicatorActions
+}
+
+// Returns a paused error if nothing can be written for the source
+func (a *pausedActions) paused(r *replicatorProps, sourceMeta *metav1.ObjectMeta) error {
+	if ok, err := r.isRunning(sourceMeta); !ok {
+		return &pausedError{err.Error()}
+	}
+	return nil
+}
+
+// Returns the metadata of the source of the target, nil if it is unknown
+func (a *pausedActions) sourceOf(r *replicatorProps, meta *metav1.ObjectMeta) *metav1.ObjectMeta {
+	source, ok := meta.Annotations[r.ReplicatedByAnnotation]
+	if !ok {
+		// the first source of the target decides, as for the impersonation
+		val, _ := resolveAnnotation(meta, r.ReplicateFromAnnotation)
+		source = strings.Split(val, ",")[0]
+	}
+	if source == "" || r.objectStore == nil {
+		return nil
+	}
+	if sourceObject, exists, err := r.cachedByKey(source); err == nil && exists {
+		return a.getMeta(sourceObject)
+	}
+	return nil
+}
+
+func (a *pausedActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	if err := a.paused(r, a.getMeta(sourceObject)); err != nil {
+		return err
+	}
+	return a.replicatorActions.update(r, object, sourceObject)
+}
+
+func (a *pausedActions) clear(r *replicatorProps, object interface{}) error {
+	if err := a.paused(r, a.sourceOf(r, a.getMeta(object))); err != nil {
+		return err
+	}
+	return a.replicatorActions.clear(r, object)
+}
+
+func (a *pausedActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	if err := a.paused(r, a.getMeta(sourceObject)); err != nil {
+		return err
+	}
+	return a.replicatorActions.install(r, meta, sourceObject, dataObject)
+}
+
+func (a *pausedActions) delete(r *replicatorProps, object interface{}) error {
+	if err := a.paused(r, a.sourceOf(r, a.getMeta(object))); err != nil {
+		return err
+	}
+	return a.replicatorActions.delete(r, object)
+}
+
+func (a *pausedActions) patch(r *replicatorProps, target *metav1.ObjectMeta, p *patchWrite) error {
+	if err := a.paused(r, a.sourceOf(r, target)); err != nil {
+		return err
+	}
+	return patchThrough(a.replicatorActions, r, target, p)
+}
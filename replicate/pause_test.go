@@ -0,0 +1,115 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSourcePaused(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{}}
+
+	if ok, err := r.isRunning(source); !ok || err != nil {
+		t.Errorf("expected the source to be running, got %v", err)
+	}
+	source.Annotations[r.ReplicatePausedAnnotation] = "true"
+	if ok, err := r.isRunning(source); ok || err == nil {
+		t.Errorf("expected the source to be paused")
+	}
+	source.Annotations[r.ReplicatePausedAnnotation] = "false"
+	if ok, err := r.isRunning(source); !ok || err != nil {
+		t.Errorf("expected the source to be running, got %v", err)
+	}
+	source.Annotations[r.ReplicatePausedAnnotation] = "maybe"
+	if _, err := r.isSourcePaused(source); err == nil {
+		t.Errorf("expected an error for an illformed annotation")
+	}
+	// an illformed annotation does not pause the source
+	if ok, _ := r.isRunning(source); !ok {
+		t.Errorf("expected the source with an illformed annotation to be running")
+	}
+}
+
+func TestPause(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+			allowAll:        true,
+			objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		},
+		replicatorActions: &pausedActions{ConfigMapActions},
+	}
+	r.queue, r.rateLimiter = newQueue("config map")
+	defer r.queue.ShutDown()
+
+	source := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "2"}}
+	target := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "target",
+		Annotations: map[string]string{r.ReplicateFromAnnotation: "default/source"}}}
+	r.objectStore.Add(source)
+	r.objectStore.Add(target)
+
+	registerReplicator(r)
+	defer func() {
+		dependencies.Lock()
+		delete(dependencies.replicators, r.kind())
+		dependencies.Unlock()
+	}()
+
+	Pause(true)
+	defer Pause(false)
+	if !Paused() {
+		t.Fatalf("expected the replication to be paused")
+	}
+	if ok, err := r.isRunning(nil); ok || err == nil {
+		t.Errorf("expected nothing to be written while paused")
+	}
+	// the client is not set, any write would panic
+	if err := r.replicateObject(target, source); err != nil {
+		t.Errorf("expected the replication to be skipped, got %s", err)
+	}
+	if err := r.installObject("team-b/target", nil, source); err != nil {
+		t.Errorf("expected the installation to be skipped, got %s", err)
+	}
+
+	// all the objects are processed again once resumed
+	if TogglePause() {
+		t.Errorf("expected the replication to be resumed")
+	}
+	if r.queue.Len() != 2 {
+		t.Errorf("expected all the objects to be queued again, got %d", r.queue.Len())
+	}
+}
+
+func TestPausedActions(t *testing.T) {
+	r := &replicatorProps{
+		Name:            "config map",
+		annotationNames: annotationsWithPrefix(""),
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	actions := &pausedActions{ConfigMapActions}
+	source := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source",
+		Annotations: map[string]string{r.ReplicatePausedAnnotation: "true"}}}
+	target := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "target",
+		Annotations: map[string]string{r.ReplicatedByAnnotation: "default/source"}}}
+	r.objectStore.Add(source)
+
+	// the client is not set, any write would panic
+	if err := actions.install(r, &target.ObjectMeta, source, source); !isPaused(err) {
+		t.Errorf("expected the installation to be paused with its source, got %v", err)
+	}
+	if err := actions.delete(r, target); !isPaused(err) {
+		t.Errorf("expected the deletion to be paused with the source of the target, got %v", err)
+	}
+
+	// the writes without source are paused globally only
+	other := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "other"}}
+	Pause(true)
+	defer Pause(false)
+	if err := actions.clear(r, other); !isPaused(err) {
+		t.Errorf("expected the clearing to be paused globally, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package replicate
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// patterns of the namespaces where targets can be installed, all the namespaces when empty
+var allowedNamespaces []*regexp.Regexp
+
+// patterns of the namespaces where targets can never be installed
+var deniedNamespaces []*regexp.Regexp
+
+// RestrictNamespaces sets the namespaces where targets can be installed, whatever the annotations,
+// as lists of namespace names or patterns
+// The denied namespaces have priority over the allowed ones
+func RestrictNamespaces(allow []string, deny []string) error {
+	allowed, err := compilePatterns(allow)
+	if err != nil {
+		return err
+	}
+
+	denied, err := compilePatterns(deny)
+	if err != nil {
+		return err
+	}
+
+	allowedNamespaces = allowed
+	deniedNamespaces = denied
+	return nil
+}
+
+// Returns an error if the policy forbids targets in the namespace
+func checkTargetNamespace(namespace string) error {
+	if matchPatterns(deniedNamespaces, namespace) {
+		return fmt.Errorf("namespace %s is denied", namespace)
+	} else if len(allowedNamespaces) > 0 && !matchPatterns(allowedNamespaces, namespace) {
+		return fmt.Errorf("namespace %s is not allowed", namespace)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package replicate
+
+import (
+	"testing"
+)
+
+func TestCheckTargetNamespace(t *testing.T) {
+	defer RestrictNamespaces(nil, nil)
+
+	if err := RestrictNamespaces([]string{"team-.*", "default"}, []string{"team-legacy", ""}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for namespace, allowed := range map[string]bool{
+		"default":     true,
+		"team-a":      true,
+		"team-legacy": false,
+		"kube-system": false,
+		"my-team-a":   false,
+	} {
+		if err := checkTargetNamespace(namespace); (err == nil) != allowed {
+			t.Errorf("expected namespace %s to be allowed: %t, got %v", namespace, allowed, err)
+		}
+	}
+
+	if err := RestrictNamespaces(nil, []string{"kube-.*"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := checkTargetNamespace("default"); err != nil {
+		t.Errorf("expected all the namespaces to be allowed, got %s", err)
+	}
+	if err := checkTargetNamespace("kube-system"); err == nil {
+		t.Errorf("expected namespace kube-system to be denied")
+	}
+
+	if err := RestrictNamespaces([]string{"team-("}, nil); err == nil {
+		t.Errorf("expected an error for an illformed pattern")
+	}
+}
@@ -0,0 +1,317 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// a cluster-scoped kind, projected into namespaced copies of another kind
+type projectedKind struct {
+	// the name of the cluster-scoped kind, for the logs
+	name string
+	// the kind of the namespaced copies, as used in annotations
+	target     string
+	store      cache.Store
+	controller cache.Controller
+	// returns the namespaced copy of the cluster-scoped object in the namespace, without metadata
+	project func(object interface{}, namespace string) interface{}
+}
+
+// ProjectionController projects the annotated cluster roles and cluster role bindings into roles and role bindings
+// of the selected namespaces, for the tenants which cannot reference cluster roles
+// It relies on the replicators of roles and role bindings, which hold the projected copies
+type ProjectionController struct {
+	lock  sync.Mutex
+	kinds []*projectedKind
+}
+
+// the running projection controller, notified when the copies or the namespaces change
+var projections *ProjectionController
+
+// NewProjectionController creates the controller projecting the cluster roles and cluster role bindings
+func NewProjectionController(client kubernetes.Interface, resyncPeriod time.Duration) *ProjectionController {
+	p := &ProjectionController{}
+	clusterRoles := &projectedKind{name: "cluster role", target: "role", project: projectClusterRole}
+	p.kinds = append(p.kinds, clusterRoles)
+	p.kinds = append(p.kinds, &projectedKind{name: "cluster role binding", target: "rolebinding",
+		project: func(object interface{}, namespace string) interface{} {
+			return projectClusterRoleBinding(object, namespace, clusterRoles)
+		}})
+
+	clusterRoles.store, clusterRoles.controller = p.informer(clusterRoles, &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().ClusterRoles().List(lo)
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return client.RbacV1().ClusterRoles().Watch(lo)
+		},
+	}, &rbacv1.ClusterRole{}, resyncPeriod)
+
+	bindings := p.kinds[1]
+	bindings.store, bindings.controller = p.informer(bindings, &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().ClusterRoleBindings().List(lo)
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return client.RbacV1().ClusterRoleBindings().Watch(lo)
+		},
+	}, &rbacv1.ClusterRoleBinding{}, resyncPeriod)
+
+	return p
+}
+
+// Returns the informer of a projected kind
+func (p *ProjectionController) informer(kind *projectedKind, lw cache.ListerWatcher, object runtime.Object, resyncPeriod time.Duration) (cache.Store, cache.Controller) {
+	return cache.NewInformer(lw, object, resyncPeriod, cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(object interface{}) { p.projectionChanged(kind, object) },
+		UpdateFunc: func(old interface{}, new interface{}) { p.projectionChanged(kind, new) },
+		DeleteFunc: func(object interface{}) { p.projectionDeleted(kind, object) },
+	})
+}
+
+// Start runs the controller, and registers it to be notified of the changes of the copies and of the namespaces
+func (p *ProjectionController) Start() {
+	projections = p
+	logf("running projection controller")
+	for _, kind := range p.kinds {
+		go kind.controller.Run(wait.NeverStop)
+	}
+}
+
+// Synced returns true once all the cluster roles and cluster role bindings are listed
+func (p *ProjectionController) Synced() bool {
+	for _, kind := range p.kinds {
+		if !kind.controller.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Notifies the projection controller that a namespaced object changed, or a namespace if meta is nil
+// The cluster-scoped objects projected into it are processed again
+func notifyProjections(r *objectReplicator, meta *metav1.ObjectMeta) {
+	p := projections
+	if p == nil {
+		return
+	}
+
+	owner := ""
+	if meta != nil {
+		if owner = meta.Annotations[r.ProjectedFromAnnotation]; owner == "" {
+			return
+		}
+	}
+	// the projections are processed asynchronously, as they need the lock of this replicator
+	go func() {
+		for _, kind := range p.kinds {
+			if kind.target != r.kind() {
+				continue
+			}
+			for _, object := range kind.store.List() {
+				if owner == "" || owner == object.(metav1.Object).GetName() {
+					p.projectionChanged(kind, object)
+				}
+			}
+		}
+	}()
+}
+
+// Returns the namespaces the cluster-scoped object is projected into, sorted
+func projectedNamespaces(r *objectReplicator, meta metav1.Object) ([]string, error) {
+	annotations := meta.GetAnnotations()
+	patterns, okNs := annotations[r.ReplicateToNamespacesAnnotation]
+	val, okSelector := annotations[r.ReplicateToNamespacesSelectorAnnotation]
+	if !okNs && !okSelector {
+		return nil, nil
+	}
+	var selector labels.Selector
+	if okSelector {
+		var err error
+		if selector, err = labels.Parse(val); err != nil {
+			return nil, fmt.Errorf("invalid selector on annotation %s (%s): %s", r.ReplicateToNamespacesSelectorAnnotation, val, err)
+		}
+	}
+
+	namespaces := []string{}
+	for _, object := range r.namespaceStore.List() {
+		namespace := object.(*v1.Namespace)
+		matched, err := matchNamespace(strings.Split(patterns, ","), namespace.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !matched && selector != nil {
+			matched = selector.Matches(labels.Set(namespace.Labels))
+		}
+		// the policy forbids copies in this namespace
+		if matched && namespace.Status.Phase != v1.NamespaceTerminating && checkTargetNamespace(namespace.Name) == nil {
+			namespaces = append(namespaces, namespace.Name)
+		}
+	}
+
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// Projects the cluster-scoped object into the selected namespaces, and deletes the copies it does not select anymore
+func (p *ProjectionController) projectionChanged(kind *projectedKind, object interface{}) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	r, ok := replicatorOfKind(kind.target)
+	if !ok {
+		return
+	}
+	meta := object.(metav1.Object)
+	name := meta.GetName()
+	if !r.isManaged(&metav1.ObjectMeta{Name: name, Annotations: meta.GetAnnotations()}) {
+		return
+	}
+
+	namespaces, err := projectedNamespaces(r, meta)
+	if err != nil {
+		logf("could not project %s %s: %s", kind.name, name, err)
+		return
+	}
+
+	desired := map[string]bool{}
+	for _, ns := range namespaces {
+		desired[fmt.Sprintf("%s/%s", ns, name)] = true
+		if err := p.installCopy(r, kind, object, ns); err != nil {
+			logf("could not project %s %s into %s: %s", kind.name, name, ns, err)
+		}
+	}
+
+	for _, target := range r.objectStore.List() {
+		copyMeta := r.getMeta(target)
+		if copyMeta.Annotations[r.ProjectedFromAnnotation] == name && !desired[objectKey(copyMeta)] {
+			logf("%s %s is not projected into namespace %s anymore: deleting %s", kind.name, name, copyMeta.Namespace, r.Name)
+			r.delete(&r.replicatorProps, target)
+		}
+	}
+}
+
+// Creates or updates the copy of the cluster-scoped object in the namespace
+// An existing object which is not a copy of the cluster-scoped object is left untouched
+func (p *ProjectionController) installCopy(r *objectReplicator, kind *projectedKind, object interface{}, namespace string) error {
+	meta := object.(metav1.Object)
+	target := fmt.Sprintf("%s/%s", namespace, meta.GetName())
+
+	copyMeta := metav1.ObjectMeta{
+		Namespace: namespace,
+		Name:      meta.GetName(),
+		Annotations: map[string]string{
+			r.ReplicatedAtAnnotation:          time.Now().Format(time.RFC3339),
+			r.ProjectedFromAnnotation:         meta.GetName(),
+			r.ReplicatedFromVersionAnnotation: meta.GetResourceVersion(),
+		},
+	}
+	if existing, exists, err := r.objectStore.GetByKey(target); err != nil {
+		return err
+	} else if exists {
+		existingMeta := r.getMeta(existing)
+		if existingMeta.Annotations[r.ProjectedFromAnnotation] != meta.GetName() {
+			return fmt.Errorf("%s %s already exists and is not projected from %s %s", r.Name, target, kind.name, meta.GetName())
+			// already up-to-date
+		} else if existingMeta.Annotations[r.ReplicatedFromVersionAnnotation] == meta.GetResourceVersion() {
+			return nil
+		}
+		// Needs ResourceVersion for update
+		copyMeta.ResourceVersion = existingMeta.ResourceVersion
+	}
+
+	sourceMeta := &metav1.ObjectMeta{Name: meta.GetName(), Annotations: meta.GetAnnotations(), Labels: meta.GetLabels()}
+	r.propagateAnnotations(&copyMeta, sourceMeta)
+	r.propagateLabels(&copyMeta, sourceMeta)
+	r.stampInstance(&copyMeta)
+
+	logf("projecting %s %s into %s %s", kind.name, meta.GetName(), r.Name, target)
+	projected := kind.project(object, namespace)
+	return r.install(&r.replicatorProps, &copyMeta, projected, projected)
+}
+
+// Deletes all the copies of the deleted cluster-scoped object
+func (p *ProjectionController) projectionDeleted(kind *projectedKind, object interface{}) {
+	if tombstone, ok := object.(cache.DeletedFinalStateUnknown); ok {
+		object = tombstone.Obj
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	r, ok := replicatorOfKind(kind.target)
+	if !ok {
+		return
+	}
+	name := object.(metav1.Object).GetName()
+	for _, target := range r.objectStore.List() {
+		copyMeta := r.getMeta(target)
+		if copyMeta.Annotations[r.ProjectedFromAnnotation] == name {
+			logf("%s %s deleted: deleting %s %s/%s", kind.name, name, r.Name, copyMeta.Namespace, copyMeta.Name)
+			r.delete(&r.replicatorProps, target)
+		}
+	}
+}
+
+// Returns the role with the rules of the cluster role
+// The aggregation rule is not projected, only the rules it aggregated
+func projectClusterRole(object interface{}, namespace string) interface{} {
+	clusterRole := object.(*rbacv1.ClusterRole)
+	role := &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Role",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: clusterRole.Name},
+	}
+	for _, rule := range clusterRole.Rules {
+		// the non-resource URLs only exist at the cluster scope
+		if len(rule.NonResourceURLs) == 0 {
+			role.Rules = append(role.Rules, *rule.DeepCopy())
+		}
+	}
+	return role
+}
+
+// Returns the role binding with the subjects of the cluster role binding
+// It references the projected role when its cluster role is projected into the same namespace, or the cluster role
+func projectClusterRoleBinding(object interface{}, namespace string, clusterRoles *projectedKind) interface{} {
+	clusterRoleBinding := object.(*rbacv1.ClusterRoleBinding)
+	roleBinding := &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "RoleBinding",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: clusterRoleBinding.Name},
+		RoleRef:    clusterRoleBinding.RoleRef,
+	}
+	for _, subject := range clusterRoleBinding.Subjects {
+		roleBinding.Subjects = append(roleBinding.Subjects, subject)
+	}
+
+	if r, ok := replicatorOfKind(clusterRoles.target); ok && roleBinding.RoleRef.Kind == "ClusterRole" {
+		if clusterRole, exists, err := clusterRoles.store.GetByKey(roleBinding.RoleRef.Name); err == nil && exists {
+			if namespaces, err := projectedNamespaces(r, clusterRole.(metav1.Object)); err == nil {
+				for _, ns := range namespaces {
+					if ns == namespace {
+						roleBinding.RoleRef.Kind = "Role"
+					}
+				}
+			}
+		}
+	}
+	return roleBinding
+}
@@ -0,0 +1,69 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestProjectedNamespaces(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "role", annotationNames: annotationsWithPrefix("")}}
+	r.namespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}})
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments",
+		Labels: map[string]string{"tenant": "true"}}})
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"},
+		Status: v1.NamespaceStatus{Phase: v1.NamespaceTerminating}})
+
+	clusterRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "viewer"}}
+	if namespaces, err := projectedNamespaces(r, clusterRole); err != nil || len(namespaces) != 0 {
+		t.Errorf("expected no projection without annotation, got %v (%v)", namespaces, err)
+	}
+
+	clusterRole.Annotations = map[string]string{
+		r.ReplicateToNamespacesAnnotation:         "team-[a-z]",
+		r.ReplicateToNamespacesSelectorAnnotation: "tenant=true",
+	}
+	namespaces, err := projectedNamespaces(r, clusterRole)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(namespaces) != 3 || namespaces[0] != "payments" || namespaces[1] != "team-a" || namespaces[2] != "team-b" {
+		t.Errorf("expected payments, team-a and team-b, got %v", namespaces)
+	}
+}
+
+func TestProjectClusterRole(t *testing.T) {
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewer"},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+			{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+		},
+	}
+	role := projectClusterRole(clusterRole, "team-a").(*rbacv1.Role)
+	if role.Namespace != "team-a" || role.Name != "viewer" || role.Kind != "Role" {
+		t.Errorf("expected role team-a/viewer, got %s %s/%s", role.Kind, role.Namespace, role.Name)
+	}
+	if len(role.Rules) != 1 || role.Rules[0].Resources[0] != "pods" {
+		t.Errorf("expected only the resource rules, got %v", role.Rules)
+	}
+
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "viewer"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Namespace: "ci", Name: "builder"}},
+	}
+	clusterRoles := &projectedKind{target: "unknown", store: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	roleBinding := projectClusterRoleBinding(clusterRoleBinding, "team-a", clusterRoles).(*rbacv1.RoleBinding)
+	if roleBinding.Namespace != "team-a" || roleBinding.RoleRef.Kind != "ClusterRole" {
+		t.Errorf("expected a role binding to the cluster role, got %v", roleBinding.RoleRef)
+	}
+	if len(roleBinding.Subjects) != 1 || roleBinding.Subjects[0].Namespace != "ci" {
+		t.Errorf("expected the subjects of the cluster role binding, got %v", roleBinding.Subjects)
+	}
+}
@@ -0,0 +1,75 @@
+package replicate
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// the replication of the pull secrets to all the namespaces
+var pullSecrets struct {
+	// when true, the pull secrets with the "replicate-pull-secret" annotation are replicated to all the namespaces
+	enabled bool
+	// when set, restricts the namespaces the pull secrets are replicated to
+	selector string
+	// when true, the pull secrets are added to the image pull secrets of the default service account of their targets
+	patchServiceAccounts bool
+}
+
+// ReplicatePullSecrets replicates the annotated pull secrets to all the namespaces matching the selector
+// (all of them when the selector is empty), and optionally attaches them to their default service account
+func ReplicatePullSecrets(selector string, patchServiceAccounts bool) error {
+	if _, err := labels.Parse(selector); err != nil {
+		return fmt.Errorf("invalid pull secrets namespace selector (%s): %s", selector, err)
+	}
+	pullSecrets.enabled = true
+	pullSecrets.selector = selector
+	pullSecrets.patchServiceAccounts = patchServiceAccounts
+	return nil
+}
+
+// Returns true if the secret holds docker registry credentials
+func isDockerConfig(secret *v1.Secret) bool {
+	return secret.Type == v1.SecretTypeDockerConfigJson || secret.Type == v1.SecretTypeDockercfg
+}
+
+// Returns true if the source is a pull secret to replicate to all the namespaces
+func (r *replicatorProps) isPullSecret(secret *v1.Secret) bool {
+	if !pullSecrets.enabled || !isDockerConfig(secret) {
+		return false
+	}
+	// the copies are never replicated again
+	if _, ok := secret.Annotations[r.ReplicatedByAnnotation]; ok {
+		return false
+	}
+	enabled, _ := strconv.ParseBool(secret.Annotations[r.ReplicatePullSecretAnnotation])
+	return enabled
+}
+
+// Returns the namespace pattern or selector of the targets of the object, when it is a pull secret
+func (r *replicatorProps) pullSecretTargets(object *metav1.ObjectMeta) (string, string, bool) {
+	if !pullSecrets.enabled || r.kind() != "secret" {
+		return "", "", false
+	}
+	if _, ok := object.Annotations[r.ReplicatePullSecretAnnotation]; !ok {
+		return "", "", false
+	}
+	// the type of the secret is not part of its metadata
+	obj, exists, err := r.objectStore.GetByKey(fmt.Sprintf("%s/%s", object.Namespace, object.Name))
+	if err != nil || !exists || !r.isPullSecret(obj.(*v1.Secret)) {
+		return "", "", false
+	}
+
+	if pullSecrets.selector != "" {
+		return "", pullSecrets.selector, true
+	}
+	return ".*", "", true
+}
+
+// Adds the pull secret to the image pull secrets of the default service account of its namespace
+func (r *replicatorProps) attachPullSecret(secret *v1.Secret) error {
+	return r.attachToServiceAccount(secret, "default")
+}
@@ -0,0 +1,61 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPullSecretTargets(t *testing.T) {
+	saved := pullSecrets
+	defer func() { pullSecrets = saved }()
+
+	r := &replicatorProps{
+		Name:            "secret",
+		annotationNames: annotationsWithPrefix(""),
+		objectStore:     cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		namespaceStore:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "registry", Name: "pull", Annotations: map[string]string{
+			r.ReplicatePullSecretAnnotation: "true",
+		}},
+		Type: v1.SecretTypeDockerConfigJson,
+	}
+	r.objectStore.Add(secret)
+
+	// disabled by default
+	if _, _, ok := r.pullSecretTargets(&secret.ObjectMeta); ok {
+		t.Errorf("expected the pull secrets not to be replicated without the flag")
+	}
+
+	if err := ReplicatePullSecrets("", false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if pattern, selector, ok := r.pullSecretTargets(&secret.ObjectMeta); !ok || pattern != ".*" || selector != "" {
+		t.Errorf("expected the pull secret to be replicated to all the namespaces, got %s, %s, %v", pattern, selector, ok)
+	}
+	targets, targetPatterns, err := r.getReplicationTargets(&secret.ObjectMeta)
+	if err != nil || len(targets) != 0 || len(targetPatterns) != 1 {
+		t.Errorf("expected a single target pattern, got %v, %v, %v", targets, targetPatterns, err)
+	}
+
+	if err := ReplicatePullSecrets("registry=true", false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if pattern, selector, ok := r.pullSecretTargets(&secret.ObjectMeta); !ok || pattern != "" || selector != "registry=true" {
+		t.Errorf("expected the pull secret to be replicated to the selected namespaces, got %s, %s, %v", pattern, selector, ok)
+	}
+
+	// only the docker registry secrets are replicated
+	secret.Type = v1.SecretTypeOpaque
+	if _, _, ok := r.pullSecretTargets(&secret.ObjectMeta); ok {
+		t.Errorf("expected an opaque secret not to be replicated")
+	}
+
+	if err := ReplicatePullSecrets("registry in (true", false); err == nil {
+		t.Errorf("expected an error for an illformed selector")
+	}
+}
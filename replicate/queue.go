@@ -0,0 +1,91 @@
+package replicate
+
+import (
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Creates the queue of the replicator, retrying the failing objects with an exponential backoff
+func newQueue(name string) (workqueue.RateLimitingInterface, workqueue.RateLimiter) {
+	rateLimiter := workqueue.NewItemExponentialFailureRateLimiter(retryInitialDelay, retryMaxDelay)
+	return workqueue.NewNamedRateLimitingQueue(rateLimiter, name), rateLimiter
+}
+
+// Queues an object added by the informer
+func (r *objectReplicator) enqueueAdded(object interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(object)
+	if err != nil {
+		logf("could not get key of %s: %s", r.Name, err)
+		return
+	}
+	r.queue.Add(key)
+}
+
+// Queues an object deleted by the informer
+// The object is kept until processed, as it is not in the store anymore
+func (r *objectReplicator) enqueueDeleted(object interface{}) {
+	if tombstone, ok := object.(cache.DeletedFinalStateUnknown); ok {
+		object = tombstone.Obj
+	}
+	key, err := cache.MetaNamespaceKeyFunc(object)
+	if err != nil {
+		logf("could not get key of %s: %s", r.Name, err)
+		return
+	}
+
+	r.deletedLock.Lock()
+	r.deleted[key] = object
+	r.deletedLock.Unlock()
+	r.queue.Add(key)
+}
+
+// Runs the workers processing the queue
+func (r *objectReplicator) runWorkers() {
+	workers := r.workers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go wait.Until(func() {
+			for r.processNext() {
+			}
+		}, 0, wait.NeverStop)
+	}
+}
+
+// Processes the next object of the queue, returns false once the queue is shut down
+func (r *objectReplicator) processNext() bool {
+	item, quit := r.queue.Get()
+	if quit {
+		return false
+	}
+	defer r.queue.Done(item)
+
+	key := item.(string)
+	defer r.processed(key)
+	r.deletedLock.Lock()
+	deletedObject, deleted := r.deleted[key]
+	delete(r.deleted, key)
+	oldObject, updated := r.updated[key]
+	delete(r.updated, key)
+	r.deletedLock.Unlock()
+
+	// the data dropped from the cache is read only if needed
+	if object, exists, err := r.cachedByKey(key); err != nil {
+		logf("could not get %s %s: %s", r.Name, key, err)
+	} else if exists {
+		// the object was deleted, then created again
+		if deleted {
+			r.ObjectDeleted(deletedObject)
+			r.ObjectAdded(object)
+		} else if updated {
+			r.ObjectUpdated(oldObject, object)
+		} else {
+			r.ObjectAdded(object)
+		}
+	} else if deleted {
+		r.ObjectDeleted(deletedObject)
+	}
+	return true
+}
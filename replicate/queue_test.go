@@ -0,0 +1,73 @@
+package replicate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestQueueDeduplicatesKeys(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "secret", deleted: map[string]interface{}{}}}
+	r.queue, r.rateLimiter = newQueue("secret")
+	defer r.queue.ShutDown()
+
+	// the events of the same object are processed once
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source"}}
+	r.enqueueAdded(secret)
+	r.enqueueAdded(secret)
+	r.enqueueDeleted(secret)
+	r.enqueueAdded(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}})
+	if r.queue.Len() != 2 {
+		t.Fatalf("expected each key to be queued once, got %d keys", r.queue.Len())
+	}
+	if _, ok := r.deleted["default/source"]; !ok {
+		t.Errorf("expected the deleted object to be kept until processed")
+	}
+
+	// an object changed while being processed is processed again once, after it is done
+	item, _ := r.queue.Get()
+	r.enqueueAdded(secret)
+	r.enqueueAdded(secret)
+	if item != "default/source" || r.queue.Len() != 1 {
+		t.Fatalf("expected the key being processed not to be queued meanwhile, got %v and %d keys", item, r.queue.Len())
+	}
+	r.queue.Done(item)
+	if r.queue.Len() != 2 {
+		t.Errorf("expected the key to be queued again once done, got %d keys", r.queue.Len())
+	}
+}
+
+func TestQueueRequeuesAfterError(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{
+		Name:        "secret",
+		objectStore: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		retries:     map[string]*retryState{},
+	}}
+	// short delays, so that the retry is seen
+	r.rateLimiter = workqueue.NewItemExponentialFailureRateLimiter(10*time.Millisecond, time.Second)
+	r.queue = workqueue.NewRateLimitingQueue(r.rateLimiter)
+	defer r.queue.ShutDown()
+
+	r.track("team-a/target", "default/source", "created", fmt.Errorf("forbidden"))
+	if r.queue.Len() != 0 {
+		t.Errorf("expected the source not to be retried before its backoff delay")
+	}
+	items := make(chan interface{})
+	go func() {
+		item, _ := r.queue.Get()
+		items <- item
+	}()
+	select {
+	case item := <-items:
+		if item != "default/source" {
+			t.Errorf("expected the source to be processed again, got %v", item)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the source to be queued again after the failure")
+	}
+}
@@ -0,0 +1,99 @@
+package replicate
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// the token buckets limiting the writes to the API, overall and per target namespace
+var writeLimits = struct {
+	sync.Mutex
+	// nil when the writes are not limited overall
+	overall flowcontrol.RateLimiter
+	// the QPS and burst of the buckets of the namespaces, not limited when the QPS is 0
+	namespaceQPS   float32
+	namespaceBurst int
+	// the {namespace => bucket} map, created as the namespaces are written
+	namespaces map[string]flowcontrol.RateLimiter
+}{
+	namespaces: map[string]flowcontrol.RateLimiter{},
+}
+
+// LimitWrites limits the writes to the API of all the replicators with token buckets,
+// overall and per target namespace, so that a source replicated everywhere cannot overwhelm the API server
+// A QPS of 0 disables the corresponding limit
+func LimitWrites(qps float32, burst int, namespaceQPS float32, namespaceBurst int) {
+	writeLimits.Lock()
+	defer writeLimits.Unlock()
+
+	writeLimits.overall = nil
+	if qps > 0 {
+		writeLimits.overall = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+	writeLimits.namespaceQPS = namespaceQPS
+	writeLimits.namespaceBurst = namespaceBurst
+	writeLimits.namespaces = map[string]flowcontrol.RateLimiter{}
+}
+
+// Returns true if the writes to the API are limited
+func writesLimited() bool {
+	writeLimits.Lock()
+	defer writeLimits.Unlock()
+	return writeLimits.overall != nil || writeLimits.namespaceQPS > 0
+}
+
+// Waits until a write to the namespace is allowed by the limits
+func waitForWrite(namespace string) {
+	writeLimits.Lock()
+	overall := writeLimits.overall
+	limiter, ok := writeLimits.namespaces[namespace]
+	if !ok && writeLimits.namespaceQPS > 0 {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(writeLimits.namespaceQPS, writeLimits.namespaceBurst)
+		writeLimits.namespaces[namespace] = limiter
+	}
+	writeLimits.Unlock()
+
+	// the namespace is waited for first, so that a busy namespace does not hold the tokens of the others
+	if limiter != nil {
+		limiter.Accept()
+	}
+	if overall != nil {
+		overall.Accept()
+	}
+}
+
+// the actions of a replicator whose writes wait for the limits of the API writes
+type rateLimitedActions struct {
+	replicatorActions
+}
+
+func (a *rateLimitedActions) wrapped() replicatorActions {
+	return a.replicatorActions
+}
+
+func (a *rateLimitedActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	waitForWrite(a.getMeta(object).Namespace)
+	return a.replicatorActions.update(r, object, sourceObject)
+}
+
+func (a *rateLimitedActions) clear(r *replicatorProps, object interface{}) error {
+	waitForWrite(a.getMeta(object).Namespace)
+	return a.replicatorActions.clear(r, object)
+}
+
+func (a *rateLimitedActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	waitForWrite(meta.Namespace)
+	return a.replicatorActions.install(r, meta, sourceObject, dataObject)
+}
+
+func (a *rateLimitedActions) delete(r *replicatorProps, object interface{}) error {
+	waitForWrite(a.getMeta(object).Namespace)
+	return a.replicatorActions.delete(r, object)
+}
+
+func (a *rateLimitedActions) patch(r *replicatorProps, target *metav1.ObjectMeta, p *patchWrite) error {
+	waitForWrite(target.Namespace)
+	return patchThrough(a.replicatorActions, r, target, p)
+}
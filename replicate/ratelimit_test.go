@@ -0,0 +1,30 @@
+package replicate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitWrites(t *testing.T) {
+	LimitWrites(0, 0, 10, 1)
+	defer LimitWrites(0, 0, 0, 0)
+	if !writesLimited() {
+		t.Fatalf("expected the writes to be limited")
+	}
+
+	start := time.Now()
+	waitForWrite("team-a")
+	waitForWrite("team-b")
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected the first writes of each namespace to be allowed right away, waited %s", elapsed)
+	}
+	waitForWrite("team-a")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected the second write of the namespace to wait, waited %s", elapsed)
+	}
+
+	LimitWrites(0, 0, 0, 0)
+	if writesLimited() {
+		t.Errorf("expected the writes not to be limited anymore")
+	}
+}
@@ -0,0 +1,164 @@
+package replicate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// the kinds whose targets have the same data as their source, so that their changes can be detected
+var verbatimKinds = map[string]bool{
+	"secret":         true,
+	"configmap":      true,
+	"serviceaccount": true,
+	"role":           true,
+}
+
+// Lists the objects from the API once the objects listed at startup are processed, then periodically, to repair
+// the targets which drifted from their source
+// Nothing is listed when the reconciliations are disabled
+func (r *objectReplicator) runReconcile() {
+	if r.reconcilePeriod <= 0 {
+		return
+	}
+	// the dynamic objects cannot be listed with the typed client
+	if listWatch, _ := r.listWatch(r.client); listWatch == nil {
+		return
+	}
+	// the first reconciliation is made at once
+	go wait.NonSlidingUntil(r.reconcile, r.reconcilePeriod, wait.NeverStop)
+}
+
+// Repairs the targets which were deleted or modified out-of-band, without the informer noticing it
+func (r *objectReplicator) reconcile() {
+	listedAt := time.Now()
+	listWatch, _ := r.listWatch(r.client)
+	list, err := r.readLegacy(r.pageLists(listWatch)).List(metav1.ListOptions{})
+	if err != nil {
+		r.errorf("", "", "could not list %s: %s", r.Name, err)
+		return
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		r.errorf("", "", "could not list %s: %s", r.Name, err)
+		return
+	}
+
+	objects := map[string]runtime.Object{}
+	for _, item := range items {
+		m := r.getMeta(item)
+		objects[fmt.Sprintf("%s/%s", m.Namespace, m.Name)] = item
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.infof("", "", "reconciling %d %s objects", len(objects), r.Name)
+	for source, targets := range r.targets() {
+		for _, target := range targets {
+			r.reconcileTarget(source, target, objects, listedAt, true)
+		}
+	}
+	for source, targets := range r.dependents() {
+		// the sources of other clusters are not listed
+		if strings.Contains(source, ":") {
+			continue
+		}
+		for _, target := range targets {
+			r.reconcileTarget(source, target, objects, listedAt, false)
+		}
+	}
+}
+
+// Repairs the target if it drifted from its source
+func (r *objectReplicator) reconcileTarget(source string, target string, objects map[string]runtime.Object, listedAt time.Time, replicatedTo bool) {
+	sourceObject, exists, err := r.objectStore.GetByKey(source)
+	if err != nil || !exists {
+		return
+	}
+	sourceMeta := r.getMeta(sourceObject)
+	cached, cachedExists, err := r.objectStore.GetByKey(target)
+	if err != nil {
+		return
+	}
+
+	object, ok := objects[target]
+	if !ok {
+		// the target was deleted without the informer noticing it, unless it was created after the list
+		if !replicatedTo || !cachedExists || !r.getMeta(cached).CreationTimestamp.Time.Before(listedAt.Add(-2*time.Second)) {
+			return
+		}
+		r.drift(source, target, "missing")
+		r.objectStore.Delete(cached)
+		r.driftCorrected(source, target, "missing", r.installObject(target, nil, sourceObject))
+		return
+	}
+
+	targetMeta := r.getMeta(object)
+	// only the targets which claim to be up to date are compared
+	if !r.isSourceVersion(sourceMeta, targetMeta.Annotations[r.ReplicatedFromVersionAnnotation]) {
+		return
+	} else if !r.isVerbatimCopy(targetMeta, sourceMeta) {
+		return
+	}
+
+	targetHash, err := dataHash(object)
+	if err != nil {
+		return
+	}
+	sourceHash, err := dataHash(sourceObject)
+	if err != nil || targetHash == sourceHash {
+		return
+	}
+
+	r.drift(source, target, "modified")
+	r.driftCorrected(source, target, "modified", r.revertDrift(target, object, sourceObject, replicatedTo))
+}
+
+// Replicates the data of the source again on a target which drifted from it, as if the target was outdated
+func (r *objectReplicator) revertDrift(target string, object interface{}, sourceObject interface{}, replicatedTo bool) error {
+	stale := object.(runtime.Object).DeepCopyObject()
+	delete(r.getMeta(stale).Annotations, r.ReplicatedFromVersionAnnotation)
+	if replicatedTo {
+		return r.installObject(target, stale, sourceObject)
+	}
+	return r.replicateObject(stale, sourceObject)
+}
+
+// Returns true if the data of the target must be the same as the data of the source
+func (r *objectReplicator) isVerbatimCopy(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) bool {
+	if !verbatimKinds[r.kind()] {
+		return false
+	}
+	for _, annotation := range []string{r.ReplicateKeyMapAnnotation, r.ReplicateTemplateAnnotation, r.ReplicateHashSuffixAnnotation, r.ReplicationStrategyAnnotation} {
+		if _, ok := sourceObject.Annotations[annotation]; ok {
+			return false
+		}
+	}
+	for _, annotation := range []string{r.ReplicateKeyMapAnnotation, r.ReplicationStrategyAnnotation, r.ReplicatedKeysAnnotation} {
+		if _, ok := object.Annotations[annotation]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Counts a target which drifted from its source
+func (r *objectReplicator) drift(source string, target string, drift string) {
+	r.infof(source, target, "%s %s drifted from %s: %s", r.Name, target, source, drift)
+	r.metrics.add("replicator_drift_total", 1, "kind", r.Name, "drift", drift)
+}
+
+// Counts a target which was repaired
+func (r *objectReplicator) driftCorrected(source string, target string, drift string, err error) {
+	if err != nil {
+		r.errorf(source, target, "could not repair %s %s: %s", r.Name, target, err)
+		return
+	}
+	r.metrics.add("replicator_drift_corrected_total", 1, "kind", r.Name, "drift", drift)
+}
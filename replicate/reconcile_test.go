@@ -0,0 +1,34 @@
+package replicate
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsVerbatimCopy(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{}}
+	target := &metav1.ObjectMeta{Namespace: "other", Name: "target", Annotations: map[string]string{}}
+
+	if !r.isVerbatimCopy(target, source) {
+		t.Errorf("expected the target to be a verbatim copy")
+	}
+
+	target.Annotations[r.ReplicateKeyMapAnnotation] = "tls.crt=ca.crt"
+	if r.isVerbatimCopy(target, source) {
+		t.Errorf("expected a target with renamed keys not to be a verbatim copy")
+	}
+
+	delete(target.Annotations, r.ReplicateKeyMapAnnotation)
+	source.Annotations[r.ReplicateTemplateAnnotation] = "true"
+	if r.isVerbatimCopy(target, source) {
+		t.Errorf("expected the target of a template not to be a verbatim copy")
+	}
+
+	delete(source.Annotations, r.ReplicateTemplateAnnotation)
+	r.Name = "role binding"
+	if r.isVerbatimCopy(target, source) {
+		t.Errorf("expected a role binding not to be a verbatim copy")
+	}
+}
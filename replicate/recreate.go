@@ -0,0 +1,54 @@
+package replicate
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the delays of the recreations of a target which keeps being deleted, doubled on each deletion
+const (
+	recreateMinDelay = time.Second
+	recreateMaxDelay = 5 * time.Minute
+)
+
+// the last recreation of a target deleted out-of-band
+type recreation struct {
+	// when the target is recreated
+	at    time.Time
+	delay time.Duration
+}
+
+// Returns how long to wait before recreating the deleted target of the source, zero to recreate it immediately
+// A target deleted again soon after being recreated, as by another controller, waits longer each time, so that both
+// do not fight in a loop
+// The targets which were not replicated by the source, as the objects blocking it, are always replicated immediately
+func (r *objectReplicator) recreateDelay(target *metav1.ObjectMeta, source string) time.Duration {
+	if target.Annotations[r.ReplicatedByAnnotation] != source {
+		return 0
+	}
+
+	now := time.Now()
+	if r.recreations == nil {
+		r.recreations = map[string]recreation{}
+	}
+	// forgets the targets left alone long enough
+	for key, last := range r.recreations {
+		if now.Sub(last.at) > 2*recreateMaxDelay {
+			delete(r.recreations, key)
+		}
+	}
+
+	key := objectKey(target)
+	delay := time.Duration(0)
+	if last, ok := r.recreations[key]; ok {
+		delay = 2 * last.delay
+		if delay < recreateMinDelay {
+			delay = recreateMinDelay
+		} else if delay > recreateMaxDelay {
+			delay = recreateMaxDelay
+		}
+	}
+	r.recreations[key] = recreation{at: now.Add(delay), delay: delay}
+	return delay
+}
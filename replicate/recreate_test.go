@@ -0,0 +1,38 @@
+package replicate
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecreateDelay(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}}
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "tls",
+		Annotations: map[string]string{r.ReplicatedByAnnotation: "default/tls"}}
+
+	for _, expected := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second} {
+		if delay := r.recreateDelay(target, "default/tls"); delay != expected {
+			t.Errorf("expected a delay of %s, got %s", expected, delay)
+		}
+	}
+	r.recreations["team-a/tls"] = recreation{at: time.Now(), delay: recreateMaxDelay}
+	if delay := r.recreateDelay(target, "default/tls"); delay != recreateMaxDelay {
+		t.Errorf("expected the delay to be capped, got %s", delay)
+	}
+
+	// a target left alone long enough is recreated immediately
+	r.recreations["team-a/tls"] = recreation{at: time.Now().Add(-time.Hour), delay: time.Minute}
+	if delay := r.recreateDelay(target, "default/tls"); delay != 0 {
+		t.Errorf("expected no delay, got %s", delay)
+	}
+
+	// an object blocking the source is not a recreation
+	blocking := &metav1.ObjectMeta{Namespace: "team-b", Name: "tls"}
+	for i := 0; i < 2; i++ {
+		if delay := r.recreateDelay(blocking, "default/tls"); delay != 0 {
+			t.Errorf("expected no delay for an object not replicated by the source, got %s", delay)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package replicate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the resource of the ReplicationRule custom resource
+var ruleResource = schema.GroupVersionResource{
+	Group:    "replicator.olli.com",
+	Version:  "v1alpha1",
+	Resource: "replicationrules",
+}
+
+// ReplicationRule replicates the sources of its namespace it selects, as the rules of the rules file
+type ReplicationRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              RuleSpec   `json:"spec"`
+	Status            RuleStatus `json:"status,omitempty"`
+}
+
+// RuleSpec describes the sources of a rule, in the namespace of the rule, and how they are replicated
+type RuleSpec struct {
+	// the kinds of the sources, as used in annotations, all the kinds when empty
+	Kinds []string `json:"kinds,omitempty"`
+	// the name of the source, or empty for the sources matching the selector
+	Name string `json:"name,omitempty"`
+	// the labels of the sources
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// the names or patterns of the target namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// the labels of the target namespaces
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// the {source key => target key} map of the renamed keys
+	KeyMap map[string]string `json:"keyMap,omitempty"`
+	// "replace" or "merge"
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// RuleStatus reports the replication of the sources selected by a rule
+type RuleStatus struct {
+	// the numbers of targets by condition
+	Synced  int `json:"synced"`
+	Pending int `json:"pending"`
+	Failed  int `json:"failed"`
+	Blocked int `json:"blocked"`
+	// why the rule is ignored, if it is invalid
+	Message string `json:"message,omitempty"`
+	// the targets of the selected sources, the failed and blocked ones first
+	Targets            []TargetCondition `json:"targets,omitempty"`
+	ObservedGeneration int64             `json:"observedGeneration,omitempty"`
+}
+
+// the conditions of the targets of the rules
+const (
+	// the target has the latest version of its source
+	ConditionSynced = "Synced"
+	// the target is not replicated yet
+	ConditionPending = "Pending"
+	// the write of the target failed, and is retried
+	ConditionFailed = "Failed"
+	// the target cannot be replicated until something else changes
+	ConditionBlocked = "Blocked"
+)
+
+// TargetCondition is the condition of a target of a source selected by a rule
+type TargetCondition struct {
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Condition string `json:"condition"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// RuleController reads the ReplicationRule custom resources, the replicators replicating the sources they select
+type RuleController struct {
+	client     dynamic.Interface
+	lock       sync.Mutex
+	store      cache.Store
+	controller cache.Controller
+	// the period of the writes of the statuses of the rules, disabled when zero
+	statusPeriod time.Duration
+}
+
+// NewRuleController creates the controller of the rules, writing their status every status period
+func NewRuleController(client dynamic.Interface, resyncPeriod time.Duration, statusPeriod time.Duration) *RuleController {
+	c := &RuleController{client: client, statusPeriod: statusPeriod}
+
+	c.store, c.controller = cache.NewInformer(
+		scopedListWatch(func(namespace string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+					return client.Resource(ruleResource).Namespace(namespace).List(lo)
+				},
+				WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+					return client.Resource(ruleResource).Namespace(namespace).Watch(lo)
+				},
+			}
+		}),
+		&unstructured.Unstructured{},
+		resyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(object interface{}) { c.rulesChanged() },
+			UpdateFunc: func(old interface{}, new interface{}) { c.rulesChanged() },
+			DeleteFunc: func(object interface{}) { c.rulesChanged() },
+		},
+	)
+
+	return c
+}
+
+// Start runs the controller
+func (c *RuleController) Start() {
+	logf("running rule controller")
+	go c.controller.Run(wait.NeverStop)
+	if c.statusPeriod > 0 {
+		go wait.Until(c.writeStatuses, c.statusPeriod, wait.NeverStop)
+	}
+}
+
+// Synced returns true once all the rules are listed
+func (c *RuleController) Synced() bool {
+	return c.controller.HasSynced()
+}
+
+// Converts the custom resource to a rule
+func parseRule(object interface{}) (*ReplicationRule, error) {
+	u, ok := object.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected rule type %T", object)
+	}
+
+	rule := &ReplicationRule{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Returns the rule as a rule of the rules file, restricted to the sources of its namespace
+func (rule *ReplicationRule) fileRule() (FileRule, error) {
+	file := FileRule{
+		Kinds:            rule.Spec.Kinds,
+		Namespace:        regexp.QuoteMeta(rule.Namespace),
+		TargetNamespaces: strings.Join(rule.Spec.Namespaces, ","),
+		KeyMap:           rule.Spec.KeyMap,
+		Strategy:         rule.Spec.Strategy,
+	}
+	if rule.Spec.Name == "" && rule.Spec.Selector == nil {
+		return file, fmt.Errorf("missing name or selector")
+	} else if rule.Spec.Name != "" {
+		file.Name = regexp.QuoteMeta(rule.Spec.Name)
+	}
+	if rule.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(rule.Spec.Selector)
+		if err != nil {
+			return file, fmt.Errorf("illformed selector: %s", err)
+		}
+		file.Selector = selector.String()
+	}
+	if rule.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(rule.Spec.NamespaceSelector)
+		if err != nil {
+			return file, fmt.Errorf("illformed namespace selector: %s", err)
+		}
+		file.TargetSelector = selector.String()
+	}
+	return file, nil
+}
+
+// Compiles all the rules, and processes all the objects again if they changed
+// The invalid rules are ignored
+func (c *RuleController) rulesChanged() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	objects := c.store.List()
+	rules := []compiledRule{}
+	for _, object := range objects {
+		rule, err := parseRule(object)
+		if err != nil {
+			logf("could not parse rule: %s", err)
+			continue
+		}
+		origin := fmt.Sprintf("rule %s/%s", rule.Namespace, rule.Name)
+		file, err := rule.fileRule()
+		if err == nil {
+			var compiled compiledRule
+			if compiled, err = compileRule(file, origin); err == nil {
+				rules = append(rules, compiled)
+				continue
+			}
+		}
+		logf("%s is ignored: %s", origin, err)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].origin < rules[j].origin
+	})
+
+	replicationRules.Lock()
+	changed := !equalRules(rules, replicationRules.resources)
+	replicationRules.resources = rules
+	replicationRules.Unlock()
+	if changed {
+		logf("replication rules changed: processing all the objects again")
+		resyncReplicators()
+	}
+}
+
+// Returns true if the rules are the same
+func equalRules(rules []compiledRule, others []compiledRule) bool {
+	if len(rules) != len(others) {
+		return false
+	}
+	for index := range rules {
+		if rules[index].origin != others[index].origin || !reflect.DeepEqual(rules[index].FileRule, others[index].FileRule) {
+			return false
+		}
+	}
+	return true
+}
+
+// Writes the status of all the rules, once the rules and the objects are listed
+func (c *RuleController) writeStatuses() {
+	replicators := registeredReplicators()
+	if !c.Synced() {
+		return
+	}
+	for _, r := range replicators {
+		if !r.Synced() {
+			return
+		}
+	}
+
+	for _, object := range c.store.List() {
+		rule, err := parseRule(object)
+		if err != nil {
+			continue
+		}
+		status := ruleStatus(rule, replicators)
+		if reflect.DeepEqual(rule.Status, status) {
+			continue
+		}
+
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+		if err != nil {
+			logf("could not convert status of rule %s/%s: %s", rule.Namespace, rule.Name, err)
+			continue
+		}
+		u := object.(*unstructured.Unstructured).DeepCopy()
+		u.Object["status"] = content
+		if _, err := c.client.Resource(ruleResource).Namespace(rule.Namespace).UpdateStatus(u, metav1.UpdateOptions{}); err != nil {
+			logf("could not update status of rule %s/%s: %s", rule.Namespace, rule.Name, err)
+		}
+	}
+}
+
+// Returns the status of the rule, from the conditions of the targets of the sources it selects
+func ruleStatus(rule *ReplicationRule, replicators []*objectReplicator) RuleStatus {
+	status := RuleStatus{ObservedGeneration: rule.Generation}
+	origin := fmt.Sprintf("rule %s/%s", rule.Namespace, rule.Name)
+	if file, err := rule.fileRule(); err != nil {
+		status.Message = err.Error()
+		return status
+	} else if _, err := compileRule(file, origin); err != nil {
+		status.Message = err.Error()
+		return status
+	}
+
+	for _, r := range replicators {
+		status.Targets = append(status.Targets, r.ruleConditions(rule.Namespace, origin)...)
+	}
+	// the failed and blocked targets first
+	order := map[string]int{ConditionFailed: 0, ConditionBlocked: 1, ConditionPending: 2, ConditionSynced: 3}
+	sort.SliceStable(status.Targets, func(i, j int) bool {
+		return order[status.Targets[i].Condition] < order[status.Targets[j].Condition]
+	})
+	for _, target := range status.Targets {
+		switch target.Condition {
+		case ConditionSynced:
+			status.Synced++
+		case ConditionPending:
+			status.Pending++
+		case ConditionFailed:
+			status.Failed++
+		case ConditionBlocked:
+			status.Blocked++
+		}
+	}
+	return status
+}
+
+// Returns the conditions of the targets of the sources of the namespace selected by the rule, sorted
+func (r *objectReplicator) ruleConditions(namespace string, origin string) []TargetCondition {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	conditions := []TargetCondition{}
+	targets := r.targets()
+	for _, object := range r.objectStore.List() {
+		meta := r.getMeta(object)
+		if meta.Namespace != namespace {
+			continue
+		} else if rule := r.matchRule(meta); rule == nil || rule.origin != origin {
+			continue
+		}
+
+		source := objectKey(meta)
+		for _, target := range targets[source] {
+			condition, reason := r.targetCondition(source, target)
+			conditions = append(conditions, TargetCondition{r.kind(), source, target, condition, reason})
+		}
+	}
+	sort.SliceStable(conditions, func(i, j int) bool {
+		return conditions[i].Source < conditions[j].Source
+	})
+	return conditions
+}
+
+// Returns the condition of the target of the source, and its reason
+func (r *objectReplicator) targetCondition(source string, target string) (string, string) {
+	if state, ok := r.retries[target]; ok {
+		return ConditionFailed, state.LastError
+	} else if owner, ok := r.collisions[collisionKey{source, target}]; ok {
+		return ConditionBlocked, fmt.Sprintf("%s %s belongs to %s", r.Name, target, owner)
+	} else if err := checkTargetNamespace(strings.SplitN(target, "/", 2)[0]); err != nil {
+		return ConditionBlocked, err.Error()
+	}
+
+	object, exists, err := r.objectStore.GetByKey(target)
+	if err != nil {
+		return ConditionPending, err.Error()
+	} else if exists && r.isUpToDate(r.getMeta(object)) {
+		return ConditionSynced, ""
+	}
+	return ConditionPending, ""
+}
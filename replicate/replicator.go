@@ -2,24 +2,71 @@ package replicate
 
 import (
 	"fmt"
-	"log"
-	"sort"
 	"strings"
 	"time"
 
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 type replicatorActions interface {
 	getMeta(object interface{}) *metav1.ObjectMeta
+	listWatch(client kubernetes.Interface) (cache.ListerWatcher, runtime.Object)
 	update(r *replicatorProps, object interface{}, sourceObject interface{}) error
 	clear(r *replicatorProps, object interface{}) error
 	install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error
 	delete(r *replicatorProps, meta interface{}) error
 }
 
+// implemented by the actions wrapping the actions of a replicator, such as in dry-run mode
+type wrappingActions interface {
+	wrapped() replicatorActions
+}
+
+// a write of an object of another kind made for a target, such as a service account the copy of a secret is attached to
+// The reason tells why the object is written, for the audit log
+type patchWrite struct {
+	kind   string
+	key    string
+	reason string
+	write  func() error
+}
+
+// implemented by the actions wrapping the writes of the objects of other kinds made for the targets
+type patchingActions interface {
+	patch(r *replicatorProps, target *metav1.ObjectMeta, p *patchWrite) error
+}
+
+// Writes the object of another kind for the target through the actions, if they wrap such writes
+func patchThrough(actions replicatorActions, r *replicatorProps, target *metav1.ObjectMeta, p *patchWrite) error {
+	if patching, ok := actions.(patchingActions); ok {
+		return patching.patch(r, target, p)
+	}
+	return p.write()
+}
+
+// Writes the object of another kind for the target through the actions of the replicator and their wrappers,
+// so that the write is limited, audited, dry run and paused as the writes of the targets
+func (r *replicatorProps) patch(target *metav1.ObjectMeta, p *patchWrite) error {
+	return patchThrough(r.actions, r, target, p)
+}
+
+// Returns the actions of the kind of the replicator, without the actions wrapping them
+func baseActions(actions replicatorActions) replicatorActions {
+	for {
+		wrapping, ok := actions.(wrappingActions)
+		if !ok {
+			return actions
+		}
+		actions = wrapping.wrapped()
+	}
+}
+
 type objectReplicator struct {
 	replicatorProps
 	replicatorActions
@@ -29,69 +76,246 @@ func (r *objectReplicator) Synced() bool {
 	return r.namespaceController.HasSynced() && r.objectController.HasSynced()
 }
 
+// Lists the namespaces and the objects with the lister watchers of the informers, when the replicator is not started,
+// so that the commands see the same objects as the running replicator
+func (r *objectReplicator) listUnstarted() error {
+	if r.objectController.HasSynced() {
+		return nil
+	}
+	if _, err := r.namespaceLister.List(metav1.ListOptions{}); err != nil {
+		return err
+	}
+	_, err := r.objectLister.List(metav1.ListOptions{})
+	return err
+}
+
 func (r *objectReplicator) Start() {
-	log.Printf("running %s object controller", r.Name)
+	// never write the metadata reduced in the cache
+	if r.cacheTransform != CacheFull {
+		r.replicatorActions = &cacheTransformActions{r.replicatorActions}
+	}
+	// wait for the limits of the API writes
+	if writesLimited() {
+		r.replicatorActions = &rateLimitedActions{r.replicatorActions}
+	}
+	// record the writes in the audit log
+	if auditEnabled() {
+		r.replicatorActions = &auditActions{r.replicatorActions}
+	}
+	// record the writes instead of making them
+	if dryRun {
+		r.replicatorActions = &dryRunActions{r.replicatorActions}
+	}
+	// write nothing while paused, not even the records of the dry run
+	r.replicatorActions = &pausedActions{r.replicatorActions}
+	r.actions = r.replicatorActions
+
+	r.infof("", "", "running %s object controller", r.Name)
 	go r.namespaceController.Run(wait.NeverStop)
 	go r.objectController.Run(wait.NeverStop)
+	r.startClusters()
+	r.runExternalSources()
+	go r.runAfterSync(wait.NeverStop)
+	r.runSourceStatus()
+}
+
+// Processes all the objects again, when their assignment to the instances changed
+func (r *objectReplicator) resync() {
+	for _, object := range r.objectStore.List() {
+		r.enqueueAdded(object)
+	}
 }
 
 func (r *objectReplicator) NamespaceAdded(object interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
 	namespace := object.(*v1.Namespace)
-	log.Printf("new namespace %s", namespace.Name)
+	r.infof("", "", "new namespace %s", namespace.Name)
+	notifyProjections(r, nil)
+	notifyConversionsOfNamespace(r)
 	// find all the objects which want to replicate to that namespace
-	todo := map[string]bool{}
+	todo := r.sourcesWatching(namespace.Name)
+	// the sources are processed by the workers once for all the namespaces created during the window
+	if r.namespaceDebounce > 0 {
+		for source := range todo {
+			r.debugf(source, "", "%s %s is watching namespace %s: processing it in %s", r.Name, source, namespace.Name, r.namespaceDebounce)
+			r.queue.AddAfter(source, r.namespaceDebounce)
+		}
+		return
+	}
+	// get all sources and let them replicate
+	for source := range todo {
+		if sourceObject, exists, err := r.objectStore.GetByKey(source); err != nil {
+			r.errorf(source, "", "could not get %s %s: %s", r.Name, source, err)
+			// it should not happen, as the sources are found in the store
+		} else if !exists {
+			r.infof(source, "", "%s %s not found", r.Name, source)
+			// let the source replicate
+		} else {
+			r.infof(source, "", "%s %s is watching namespace %s", r.Name, source, namespace.Name)
+			r.replicateToNamespace(sourceObject, namespace.Name)
+		}
+	}
+}
 
-	for source, watched := range r.watchedTargets {
-		for _, ns := range watched {
-			if namespace.Name == strings.SplitN(ns, "/", 2)[0] {
-				todo[source] = true
+// Processes again the sources selecting namespaces by labels, when the labels of a namespace change
+// A terminating namespace is forgotten, as no target can be installed in it anymore
+func (r *objectReplicator) NamespaceUpdated(old interface{}, new interface{}) {
+	oldNamespace := old.(*v1.Namespace)
+	namespace := new.(*v1.Namespace)
+	if namespace.Status.Phase == v1.NamespaceTerminating {
+		if oldNamespace.Status.Phase != v1.NamespaceTerminating {
+			r.NamespaceDeleted(namespace)
+		}
+		return
+	}
+	cloneChanged := r.cloneChanged(oldNamespace, namespace)
+	labelsChanged := !labels.Equals(oldNamespace.Labels, namespace.Labels)
+	if !cloneChanged && !labelsChanged {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if cloneChanged {
+		r.cloneNamespace(namespace.Name)
+	}
+	if !labelsChanged {
+		return
+	}
+	notifyProjections(r, nil)
+	for source, watched := range r.watchedSources() {
+		for _, p := range watched.patterns {
+			if p.selector != nil {
+				r.infof(source, "", "labels of namespace %s changed: %s %s is processed again", namespace.Name, r.Name, source)
+				r.queue.Add(source)
 				break
 			}
 		}
 	}
+}
+
+// Forgets the targets in the deleted namespace, as they are deleted with it
+func (r *objectReplicator) NamespaceDeleted(object interface{}) {
+	if tombstone, ok := object.(cache.DeletedFinalStateUnknown); ok {
+		object = tombstone.Obj
+	}
+	namespace := object.(*v1.Namespace)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.infof("", "", "namespace %s deleted", namespace.Name)
+	prefix := namespace.Name + "/"
+	for target := range r.retries {
+		if strings.HasPrefix(target, prefix) {
+			r.forgetRetry(target)
+		}
+	}
+
+	for key := range r.collisions {
+		if strings.HasPrefix(key.target, prefix) {
+			r.resolveCollisions(key.source, key.target)
+		}
+	}
+}
+
+// Returns true if the namespace exists and is not terminating, so that targets can be installed in it
+func (r *replicatorProps) namespaceActive(namespace string) bool {
+	object, exists, err := r.namespaceStore.GetByKey(namespace)
+	return err == nil && exists && object.(*v1.Namespace).Status.Phase != v1.NamespaceTerminating
+}
+
+// Returns the labels of the namespace, empty if it does not exist
+func (r *replicatorProps) namespaceLabels(namespace string) labels.Set {
+	if r.simulatedNamespace != nil && r.simulatedNamespace.Name == namespace {
+		return labels.Set(r.simulatedNamespace.Labels)
+	}
+
+	if object, exists, err := r.namespaceStore.GetByKey(namespace); err == nil && exists {
+		return labels.Set(object.(*v1.Namespace).Labels)
+	}
+	return labels.Set{}
+}
 
-	for source, patterns := range r.watchedPatterns {
+// Returns the sources which want to replicate to the namespace
+func (r *objectReplicator) sourcesWatching(namespace string) map[string]bool {
+	todo := map[string]bool{}
+
+	for source, watched := range r.watchedSources() {
+		for _, ns := range watched.targets {
+			if namespace == strings.SplitN(ns, "/", 2)[0] {
+				todo[source] = true
+				break
+			}
+		}
 		if todo[source] {
 			continue
 		}
 
-		for _, p := range patterns {
-			if p.MatchNamespace(namespace.Name) != "" {
+		for _, p := range watched.patterns {
+			if p.MatchNamespace(namespace) != "" {
 				todo[source] = true
 				break
 			}
 		}
 	}
-	// get all sources and let them replicate
-	for source := range todo {
-		if sourceObject, exists, err := r.objectStore.GetByKey(source); err != nil {
-			log.Printf("could not get %s %s: %s", r.Name, source, err)
-		// it should not happen, but maybe `ObjectDeleted` hasn't been called yet
-		// just clean watched targets to avoid this to happen again
-		} else if !exists {
-			log.Printf("%s %s not found", r.Name, source)
-			delete(r.watchedTargets, source)
-			delete(r.watchedPatterns, source)
-		// let the source replicate
-		} else {
-			log.Printf("%s %s is watching namespace %s", r.Name, source, namespace.Name)
-			r.replicateToNamespace(sourceObject, namespace.Name)
-		}
-	}
+
+	return todo
 }
 
 func (r *objectReplicator) replicateToNamespace(object interface{}, namespace string) {
 	meta := r.getMeta(object)
 	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
 	// those annotations have priority
-	if _, ok := meta.Annotations[ReplicatedByAnnotation]; ok {
+	if _, ok := meta.Annotations[r.ReplicatedByAnnotation]; ok {
 		return
 	}
+	// get all targets in the namespace
+	existingTargets, err := r.targetsInNamespace(meta, namespace)
+	if err != nil {
+		r.errorf(key, "", "could not parse %s %s: %s", r.Name, key, err)
+		return
+	}
+	if len(existingTargets) == 0 {
+		return
+	}
+	// get the current targets, to count them
+	currentTargets := r.targetsOf(key)
+	// install all the new targets
+	newTargets := make([]string, 0, len(existingTargets))
+	for target := range existingTargets {
+		newTargets = append(newTargets, target)
+	}
+	// too many targets, the new ones are not replicated
+	allTargets := map[string]bool{}
+	for _, target := range currentTargets {
+		allTargets[target] = true
+	}
+	for target := range existingTargets {
+		allTargets[target] = true
+	}
+	if !r.checkTargetsCount(object, len(allTargets)) {
+		return
+	}
+	r.forEachTarget(newTargets, func(target string) {
+		r.infof(key, target, "%s %s is replicated to %s", r.Name, key, target)
+		r.installObject(target, nil, object)
+	})
+	r.updateFinalizer(key)
+	// no need to update watched namespaces nor pattern namespaces
+	// because if we are here, it means they already match this namespace
+}
+
+// Returns the targets of the source in the namespace
+func (r *objectReplicator) targetsInNamespace(meta *metav1.ObjectMeta, namespace string) (map[string]bool, error) {
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
 	// get all targets
 	targets, targetPatterns, err := r.getReplicationTargets(meta)
 	if err != nil {
-		log.Printf("could not parse %s %s: %s", r.Name, key, err)
-		return
+		return nil, err
 	}
 	// find the ones matching with the namespace
 	existingTargets := map[string]bool{}
@@ -109,49 +333,69 @@ func (r *objectReplicator) replicateToNamespace(object interface{}, namespace st
 	}
 	// cannot target itself
 	delete(existingTargets, key)
-	if len(existingTargets) == 0 {
-		return
-	}
-	// get the current targets in order to update the slice
-	currentTargets, ok := r.targetsTo[key]
-	if !ok {
-		currentTargets = []string{}
-	}
-	// install all the new targets
-	for target := range existingTargets {
-		log.Printf("%s %s is replicated to %s", r.Name, key, target)
-		currentTargets = append(currentTargets, target)
-		r.installObject(target, nil, object)
-	}
-	// update the current targets
-	r.targetsTo[key] = currentTargets
-	// no need to update watched namespaces nor pattern namespaces
-	// because if we are here, it means they already match this namespace
+	return existingTargets, nil
 }
 
 func (r *objectReplicator) ObjectAdded(object interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
 	meta := r.getMeta(object)
 	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+	// some targets may be waiting for this object to be installed
+	r.installDependents(key)
+	// the bundles using this object are processed again
+	notifyBundles(r, meta)
+	// and the cluster-scoped object projected into it
+	notifyProjections(r, meta)
+	// and the targets converted from it to the other kind
+	notifyConversions(r, object, false)
+	// and the bundles which may include it
+	r.updateBundles(meta)
+	// this object is failing, it will be processed again on its next retry
+	if r.isBackingOff(meta) {
+		r.debugf(key, "", "%s %s is waiting for its next retry", r.Name, key)
+		return
+	}
+	// this target was modified by hand, and reverted to the data of its enforced source
+	if r.enforceTarget(object) {
+		return
+	}
+	// this object belongs to another instance, which takes care of its targets
+	if !r.isManaged(meta) {
+		r.debugf(key, "", "%s %s is managed by another instance", r.Name, key)
+		r.deleteAliases(key)
+		// it can still be the source of targets of this instance
+		if replicas := r.dependentsOf(key); len(replicas) > 0 {
+			r.updateDependents(object, replicas)
+		}
+		return
+	}
+	// the source is being deleted, its targets must be deleted first
+	if meta.DeletionTimestamp != nil && hasFinalizer(meta, r.ReplicatedTargetsFinalizer) {
+		r.finalizeSource(object)
+		return
+	}
+	// the targets in other namespaces need the finalizer of the source
+	defer r.updateFinalizer(key)
+	// push it to the other clusters
+	r.pushToClusters(object)
+	// and to the external destinations
+	r.pushToExternal(object, false)
 	// get replication targets
 	targets, targetPatterns, err := r.getReplicationTargets(meta)
 	if err != nil {
-		log.Printf("could not parse %s %s: %s", r.Name, key, err)
+		r.errorf(key, "", "could not parse %s %s: %s", r.Name, key, err)
+		r.reportError(err)
 		return
 	}
 	// if it was already replicated to some targets
 	// check that the annotations still permit it
-	if oldTargets, ok := r.targetsTo[key]; ok {
-		log.Printf("source %s %s changed", r.Name, key)
+	if oldTargets := r.targetsOf(key); len(oldTargets) > 0 {
+		r.infof(key, "", "source %s %s changed", r.Name, key)
 
-		sort.Strings(oldTargets)
-		previous := ""
-Targets:
+	Targets:
 		for _, target := range oldTargets {
-			if target == previous {
-				continue Targets
-			}
-			previous = target
-
 			for _, t := range targets {
 				if t == target {
 					continue Targets
@@ -163,52 +407,59 @@ Targets:
 				}
 			}
 			// apparently this target is not valid anymore
-			log.Printf("annotation of source %s %s changed: deleting target %s",
+			r.infof(key, target, "annotation of source %s %s changed: deleting target %s",
 				r.Name, key, target)
+			r.resolveCollisions(key, target)
 			r.deleteObject(target, object)
 		}
 	}
-	// clean all thos fields, they will be refilled further anyway
-	delete(r.targetsTo, key)
-	delete(r.watchedTargets, key)
-	delete(r.watchedPatterns, key)
+	// register the former paths of this object
+	r.updateAliases(object)
 	// check for object having dependencies, and update them
-	if replicas, ok := r.targetsFrom[key]; ok {
-		log.Printf("%s %s has %d dependents", r.Name, key, len(replicas))
+	if replicas := r.dependentsOf(key); len(replicas) > 0 {
+		r.debugf(key, "", "%s %s has %d dependents", r.Name, key, len(replicas))
 		r.updateDependents(object, replicas)
 	}
 	// this object was replicated by another, update it
-	if val, ok := meta.Annotations[ReplicatedByAnnotation]; ok {
-		log.Printf("%s %s is replicated by %s", r.Name, key, val)
+	if val, ok := meta.Annotations[r.ReplicatedByAnnotation]; ok {
+		r.infof(val, key, "%s %s is replicated by %s", r.Name, key, val)
 		sourceObject, exists, err := r.objectStore.GetByKey(val)
+		// the source may have been renamed, and declare its former path as alias
+		if err == nil && !exists {
+			sourceObject, exists = r.aliasSource(val)
+		}
 
 		if err != nil {
-			log.Printf("could not get %s %s: %s", r.Name, val, err)
+			r.errorf(val, key, "could not get %s %s: %s", r.Name, val, err)
 			return
-		// the source has been deleted, so should this object be
+			// the source has been deleted, so should this object be
 		} else if !exists {
-			log.Printf("source %s %s deleted: deleting target %s", r.Name, val, key)
+			r.infof(val, key, "source %s %s deleted: deleting target %s", r.Name, val, key)
+			// the source belongs to another instance, which takes care of this object
+		} else if !r.isManaged(r.getMeta(sourceObject)) {
+			r.infof(val, key, "source %s %s is managed by another instance", r.Name, val)
+			return
 
 		} else if ok, err := r.isReplicatedTo(r.getMeta(sourceObject), meta); err != nil {
-			log.Printf("could not parse %s %s: %s", r.Name, val, err)
+			r.errorf(val, key, "could not parse %s %s: %s", r.Name, val, err)
 			return
-		// the source annotations have changed, this replication is deleted
+			// the source annotations have changed, this replication is deleted
 		} else if !ok {
-			log.Printf("source %s %s is not replicated to %s: deleting target", r.Name, val, key)
+			r.infof(val, key, "source %s %s is not replicated to %s: deleting target", r.Name, val, key)
 			exists = false
 		}
 		// no source, delete it
 		if !exists {
 			r.doDeleteObject(object)
 			return
-		// source is here, install it
+			// source is here, install it
 		} else if err := r.installObject("", object, sourceObject); err != nil {
 			return
-		// get it back after edit
+			// get it back after edit
 		} else if obj, m, err := r.objectFromStore(key); err != nil {
-			log.Printf("could not get %s %s: %s", r.Name, key, err)
+			r.errorf(val, key, "could not get %s %s: %s", r.Name, key, err)
 			return
-		// continue
+			// continue
 		} else {
 			object = obj
 			meta = m
@@ -219,25 +470,25 @@ Targets:
 	// this object is replicated to other locations
 	if targets != nil || targetPatterns != nil {
 		existsNamespaces := map[string]bool{} // a cache to remember the done lookups
-		existingTargets := []string{} // the slice of all the target this object should replicate to
+		existingTargets := []string{}         // the slice of all the target this object should replicate to
 
-		for _, t := range(targets) {
+		for _, t := range targets {
 			ns := strings.SplitN(t, "/", 2)[0]
 			var exists, ok bool
 			var err error
 			// already in cache
 			if exists, ok = existsNamespaces[ns]; ok {
-			// get it
+				// get it
 			} else if _, exists, err = r.namespaceStore.GetByKey(ns); err == nil {
 				existsNamespaces[ns] = exists
 			}
 
 			if err != nil {
-				log.Printf("could not get namespace %s: %s", ns, err)
+				r.errorf(key, "", "could not get namespace %s: %s", ns, err)
 			} else if exists {
 				existingTargets = append(existingTargets, t)
 			} else {
-				log.Printf("replication of %s %s to %s cancelled: no namespace %s",
+				r.infof(key, t, "replication of %s %s to %s cancelled: no namespace %s",
 					r.Name, key, t, ns)
 			}
 		}
@@ -246,7 +497,7 @@ Targets:
 			namespaces := r.namespaceStore.ListKeys()
 			// cache all existing targets
 			seen := map[string]bool{key: true}
-			for _, t := range(existingTargets) {
+			for _, t := range existingTargets {
 				seen[t] = true
 			}
 			// find which new targets match the patterns
@@ -259,70 +510,140 @@ Targets:
 				}
 			}
 		}
-		// save all those info
-		if len(targets) > 0 {
-			r.watchedTargets[key] = targets
-		}
-
-		if len(targetPatterns) > 0 {
-			r.watchedPatterns[key] = targetPatterns
+		// too many targets, none is replicated
+		// the targets already replicated are kept, and deleted with the source
+		if !r.checkTargetsCount(object, len(existingTargets)) {
+			return
 		}
 
 		if len(existingTargets) > 0 {
-			r.targetsTo[key] = existingTargets
 			// create all targets
-			for _, t := range(existingTargets) {
-				log.Printf("%s %s is replicated to %s", r.Name, key, t)
+			r.forEachTarget(existingTargets, func(t string) {
+				r.infof(key, t, "%s %s is replicated to %s", r.Name, key, t)
 				r.installObject(t, nil, object)
-			}
+			})
 		}
 		// in this case, replicate-from annoation only refers to the target
 		// so should stop now
 		return
 	}
+	// this object bundles the data of the sources selected by its annotation
+	if _, ok := meta.Annotations[r.ReplicateBundleFromAnnotation]; ok {
+		r.replicateBundle(object)
+		return
+	}
 	// this object is replicated from another, update it
-	if val, ok := resolveAnnotation(meta, ReplicateFromAnnotation); ok {
-		log.Printf("%s %s is replicated from %s", r.Name, key, val)
-		// update the dependencies of the source, even if it maybe does not exist yet
-		if _, ok := r.targetsFrom[val]; !ok {
-			r.targetsFrom[val] = make([]string, 0, 1)
-		}
-		r.targetsFrom[val] = append(r.targetsFrom[val], key)
+	if val, ok := resolveAnnotation(meta, r.ReplicateFromAnnotation); ok {
+		r.infof(val, key, "%s %s is replicated from %s", r.Name, key, val)
+		// the object is indexed by its sources, even if they maybe do not exist yet
+		sources := strings.Split(val, ",")
 
-		if sourceObject, exists, err := r.objectStore.GetByKey(val); err != nil {
-			log.Printf("could not get %s %s: %s", r.Name, val, err)
+		// the data of several sources is merged
+		if len(sources) > 1 {
+			r.replicateFromSources(object, sources)
+		} else if sourceObject, exists, err := r.getSource(val); err != nil {
+			r.errorf(val, key, "could not get %s %s: %s", r.Name, val, err)
 			return
-		// the source does not exist anymore/yet, clear the data of the target
+			// the source does not exist anymore/yet
 		} else if !exists {
-			log.Printf("source %s %s deleted: clearing target %s", r.Name, val, key)
-			r.doClearObject(object)
-		// update the target
+			// the source has been renamed, migrate the target to the new source
+			if aliasObject, ok := r.aliasSource(val); ok {
+				r.migrateObject(key, val, aliasObject)
+				// otherwise clear the data of the target
+			} else {
+				r.infof(val, key, "source %s %s deleted: clearing target %s", r.Name, val, key)
+				r.doClearObject(object)
+				r.setSourceMissing(object, fmt.Sprintf("source %s does not exist", val))
+			}
+			// update the target
 		} else {
 			r.replicateObject(object, sourceObject)
 		}
 	}
 }
 
-func (r *objectReplicator) replicateObject(object interface{}, sourceObject  interface{}) error {
+func (r *objectReplicator) replicateObject(object interface{}, sourceObject interface{}) error {
 	meta := r.getMeta(object)
 	sourceMeta := r.getMeta(sourceObject)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+	sourceKey := fmt.Sprintf("%s/%s", sourceMeta.Namespace, sourceMeta.Name)
+	// the policy forbids targets in this namespace
+	if err := checkTargetNamespace(meta.Namespace); err != nil {
+		r.infof(sourceKey, key, "replication of %s %s is cancelled: %s", r.Name, key, err)
+		return err
+	}
 	// make sure replication is allowed
-	if ok, err := r.isReplicationAllowed(meta, sourceMeta); !ok {
-		log.Printf("replication of %s %s/%s is cancelled: %s", r.Name, meta.Namespace, meta.Name, err)
+	if ok, err := r.isReplicationAllowed(meta, sourceMeta); !ok && !isDenied(err) {
+		// the data of the target is kept while the annotations of the source are invalid
+		r.errorf(sourceKey, key, "replication of %s %s/%s is cancelled: %s", r.Name, meta.Namespace, meta.Name, err)
+		return err
+	} else if !ok {
+		r.infof(sourceKey, key, "replication of %s %s/%s is cancelled: %s", r.Name, meta.Namespace, meta.Name, err)
+		// the permission may have been revoked, the previously replicated data must not remain
+		r.doClearObject(object)
+		r.setDenial(object, err.Error())
 		return err
 	}
+	// the replication is allowed, the reason of a previous denial is removed
+	object = r.setDenial(object, "")
 	// check if replication is needed
-	if ok, _, err := r.needsDataUpdate(meta, sourceMeta); !ok {
-		log.Printf("replication of %s %s/%s is skipped: %s", r.Name, meta.Namespace, meta.Name, err)
+	hashes := r.dataHashes(object, sourceObject)
+	if ok, _, err := r.needsDataUpdate(meta, sourceMeta, hashes); !ok {
+		r.debugf(sourceKey, key, "replication of %s %s/%s is skipped: %s", r.Name, meta.Namespace, meta.Name, err)
+		r.reportOutcome("skipped")
+		// only the new version of the source is recorded when its data did not change
+		if r.onlyVersionChanged(meta, sourceMeta, hashes) {
+			return r.recordSourceVersion(object, sourceMeta)
+		}
+		return err
+	}
+	// the dependencies of the object must be updated first
+	if ok, err := r.dependenciesReady(meta, "", key); !ok {
+		r.infof(sourceKey, key, "replication of %s %s is delayed: %s", r.Name, key, err)
 		return err
 	}
-	// replicate it
-	return r.update(&r.replicatorProps, object, sourceObject)
+	// the new data must be approved first
+	if ok, err := r.isApproved(meta, sourceMeta); !ok {
+		r.infof(sourceKey, key, "replication of %s %s is delayed: %s", r.Name, key, err)
+		if r.awaitApproval(key, sourceMeta.ResourceVersion) {
+			r.recordEvent(object, v1.EventTypeNormal, "ApprovalRequired", "%s", err)
+		}
+		return err
+	}
+	r.approvalDone(key)
+	// the changes of the source are replicated on its schedule
+	if ok, err := r.isSyncDue(sourceMeta, key); !ok {
+		r.infof(sourceKey, key, "replication of %s %s is delayed: %s", r.Name, key, err)
+		return err
+	}
+	// replicate it, into the latest version of the target after a conflict
+	err := r.writeTarget(key, func() error {
+		return r.retryOnConflict(key, func() error {
+			return r.update(&r.replicatorProps, object, sourceObject)
+		}, func(latest interface{}) error {
+			if latest == nil {
+				return fmt.Errorf("%s %s was deleted", r.Name, key)
+			}
+			object = latest
+			return nil
+		})
+	})
+	return r.track(key, key, "updated", err)
 }
 
 func (r *objectReplicator) installObject(target string, targetObject interface{}, sourceObject interface{}) error {
 	var targetMeta *metav1.ObjectMeta
 	sourceMeta := r.getMeta(sourceObject)
+	sourceKey := fmt.Sprintf("%s/%s", sourceMeta.Namespace, sourceMeta.Name)
+	// the policy forbids targets in this namespace
+	namespace := strings.SplitN(target, "/", 2)[0]
+	if targetObject != nil {
+		namespace = r.getMeta(targetObject).Namespace
+	}
+	if err := checkTargetNamespace(namespace); err != nil {
+		r.infof(sourceKey, target, "replication of %s %s to %s is cancelled: %s", r.Name, sourceKey, target, err)
+		return err
+	}
 	var targetSplit []string // similar to target, but splitted in 2
 	// targetObject was not passed, check if it exists
 	if targetObject == nil {
@@ -330,37 +651,65 @@ func (r *objectReplicator) installObject(target string, targetObject interface{}
 		// invalid target
 		if len(targetSplit) != 2 {
 			err := fmt.Errorf("illformed annotation %s in %s %s/%s: expected namespace/name, got %s",
-				ReplicatedByAnnotation, r.Name, sourceMeta.Namespace, sourceMeta.Name, target)
-			log.Printf("%s", err)
+				r.ReplicatedByAnnotation, r.Name, sourceMeta.Namespace, sourceMeta.Name, target)
+			r.errorf(sourceKey, target, "%s", err)
+			r.reportError(err)
 			return err
 		}
 		// error while getting the target
 		if obj, exists, err := r.objectStore.GetByKey(target); err != nil {
-			log.Printf("could not get %s %s: %s", r.Name, target, err)
+			r.errorf(sourceKey, target, "could not get %s %s: %s", r.Name, target, err)
 			return err
-		// the target exists already
+			// the target exists already
 		} else if exists {
 			// update related objects
 			targetObject = obj
 			targetMeta = r.getMeta(targetObject)
 			// check if target was created by replication from source
-			if ok, err := r.isReplicatedBy(targetMeta, sourceMeta); !ok {
-				log.Printf("replication of %s %s/%s is cancelled: %s",
+			if ok, err := r.isReplicatedBy(targetMeta, sourceMeta); ok {
+				// the existing object can be overwritten, its original data is recorded
+			} else if adopt, err2 := r.canAdopt(targetMeta, sourceMeta); err2 != nil {
+				r.errorf(sourceKey, target, "replication of %s %s/%s is cancelled: %s",
+					r.Name, sourceMeta.Namespace, sourceMeta.Name, err2)
+				r.reportError(err2)
+				return err2
+			} else if !adopt {
+				r.infof(sourceKey, target, "replication of %s %s/%s is cancelled: %s",
 					r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
+				r.collide(target, targetMeta, sourceObject)
 				return err
+			} else if adopted, err := r.adopt(targetObject, targetMeta); err != nil {
+				r.errorf(sourceKey, target, "could not adopt %s %s: %s", r.Name, target, err)
+				return err
+			} else {
+				r.infof(sourceKey, target, "%s %s/%s adopts existing %s %s",
+					r.Name, sourceMeta.Namespace, sourceMeta.Name, r.Name, target)
+				targetMeta = adopted
 			}
+			// the target does not exist yet, its dependencies must be installed first
+		} else if ok, err := r.dependenciesReady(sourceMeta, sourceKey, target); !ok {
+			r.infof(sourceKey, target, "replication of %s %s/%s is delayed: %s",
+				r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
+			return err
 		}
-	// targetObject was passed already
+		// targetObject was passed already
 	} else {
 		targetMeta = r.getMeta(targetObject)
 		targetSplit = []string{targetMeta.Namespace, targetMeta.Name}
 	}
+	targetKey := fmt.Sprintf("%s/%s", targetSplit[0], targetSplit[1])
+	// the target can be replicated from the source
+	r.resolveCollisions(sourceKey, targetKey)
+	outcome := "created"
+	if targetMeta != nil {
+		outcome = "updated"
+	}
 	// the data must come from another object
-	if source, ok := resolveAnnotation(sourceMeta, ReplicateFromAnnotation); ok {
+	if source, ok := resolveAnnotation(sourceMeta, r.ReplicateFromAnnotation); ok {
 		if targetMeta != nil {
 			// Check if needs an annotations update
 			if ok, err := r.needsFromAnnotationsUpdate(targetMeta, sourceMeta); err != nil {
-				log.Printf("replication of %s %s/%s is cancelled: %s",
+				r.infof(sourceKey, targetKey, "replication of %s %s/%s is cancelled: %s",
 					r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
 				return err
 
@@ -375,58 +724,128 @@ func (r *objectReplicator) installObject(target string, targetObject interface{}
 			Annotations: map[string]string{},
 		}
 
-		copyMeta.Annotations[ReplicatedByAnnotation] = fmt.Sprintf("%s/%s",
+		copyMeta.Annotations[r.ReplicatedByAnnotation] = fmt.Sprintf("%s/%s",
 			sourceMeta.Namespace, sourceMeta.Name)
-		copyMeta.Annotations[ReplicateFromAnnotation] = source
-		if val, ok := sourceMeta.Annotations[ReplicateOnceAnnotation]; ok {
-			copyMeta.Annotations[ReplicateOnceAnnotation] = val
+		copyMeta.Annotations[r.ReplicateFromAnnotation] = source
+		if val, ok := sourceMeta.Annotations[r.ReplicateOnceAnnotation]; ok {
+			copyMeta.Annotations[r.ReplicateOnceAnnotation] = val
+		}
+		// keep the annotations describing the data of the target
+		if targetMeta == nil {
+		} else {
+			for _, annotation := range []string{r.ReplicatedOriginalDataAnnotation, r.ReplicatedKeysAnnotation,
+				r.ReplicatedAttachedToServiceAccountAnnotation} {
+				if val, ok := targetMeta.Annotations[annotation]; ok {
+					copyMeta.Annotations[annotation] = val
+				}
+			}
 		}
+		r.mergeTargetMetadata(&copyMeta, targetMeta, sourceMeta)
+		r.stampInstance(&copyMeta)
+		r.setOwner(&copyMeta, sourceMeta, sourceObject)
 		// Needs ResourceVersion for update
 		if targetMeta != nil {
 			copyMeta.ResourceVersion = targetMeta.ResourceVersion
 		}
 
-		log.Printf("installing %s %s/%s: updating replicate-from annotations", r.Name, copyMeta.Namespace, copyMeta.Name)
+		r.infof(sourceKey, targetKey, "installing %s %s/%s: updating replicate-from annotations", r.Name, copyMeta.Namespace, copyMeta.Name)
 		// install it, but keeps the original data
-		return r.install(&r.replicatorProps, &copyMeta, sourceObject, targetObject)
+		return r.track(targetKey, sourceKey, outcome, r.install(&r.replicatorProps, &copyMeta, sourceObject, targetObject))
 	}
 	// the data comes directly from the source
 	if targetMeta != nil {
 		// the target was previously replicated from another source
 		// replication is required
-		if _, ok := targetMeta.Annotations[ReplicateFromAnnotation]; ok {
-		// checks that the target is up to date
-		} else if ok, once, err := r.needsDataUpdate(targetMeta, sourceMeta); !ok {
+		hashes := r.dataHashes(targetObject, sourceObject)
+		// the data is replicated again with the replication-allowed annotations which changed
+		if ok, _ := r.needsAllowedAnnotationsUpdate(targetMeta, sourceMeta); ok {
+			hashes.source = ""
+		}
+		if _, ok := targetMeta.Annotations[r.ReplicateFromAnnotation]; ok {
+			// checks that the target is up to date
+		} else if ok, once, err := r.needsDataUpdate(targetMeta, sourceMeta, hashes); !ok {
 			// check that the target needs replication-allowed annoations update
-			if (!once) {
-			} else if ok, err2 := r.needsAllowedAnnotationsUpdate(targetMeta, sourceMeta); err2 != nil {
-				err = err2
-			} else if ok {
+			if !once {
+			} else if ok, err2 := r.needsAllowedAnnotationsUpdate(targetMeta, sourceMeta); ok {
+				// illformed annotations are propagated anyway, revoking replication downstream
+				if err2 != nil {
+					r.infof(sourceKey, targetKey, "replication of %s %s/%s propagates illformed annotations: %s",
+						r.Name, sourceMeta.Namespace, sourceMeta.Name, err2)
+				}
 				err = nil
 			}
-			if (err != nil) {
-				log.Printf("replication of %s %s/%s is skipped: %s",
+			if err != nil {
+				r.debugf(sourceKey, targetKey, "replication of %s %s/%s is skipped: %s",
 					r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
+				r.reportOutcome("skipped")
+				// only the new version of the source is recorded when its data did not change
+				if r.onlyVersionChanged(targetMeta, sourceMeta, hashes) {
+					return r.recordSourceVersion(targetObject, sourceMeta)
+				}
 				return err
 			}
 			// copy the target but update replication-allowed annoations
 			copyMeta := targetMeta.DeepCopy()
-			if val, ok := sourceMeta.Annotations[ReplicationAllowed]; ok {
-				copyMeta.Annotations[ReplicationAllowed] = val
+			if val, ok := sourceMeta.Annotations[r.ReplicationAllowed]; ok {
+				copyMeta.Annotations[r.ReplicationAllowed] = val
 			} else {
-				delete(copyMeta.Annotations, ReplicationAllowed)
+				delete(copyMeta.Annotations, r.ReplicationAllowed)
 			}
-			if val, ok := sourceMeta.Annotations[ReplicationAllowedNamespaces]; ok {
-				copyMeta.Annotations[ReplicationAllowedNamespaces] = val
+			if val, ok := sourceMeta.Annotations[r.ReplicationAllowedNamespaces]; ok {
+				copyMeta.Annotations[r.ReplicationAllowedNamespaces] = val
 			} else {
-				delete(copyMeta.Annotations, ReplicationAllowedNamespaces)
+				delete(copyMeta.Annotations, r.ReplicationAllowedNamespaces)
 			}
+			if val, ok := sourceMeta.Annotations[r.ReplicationAllowedNamespacesExclude]; ok {
+				copyMeta.Annotations[r.ReplicationAllowedNamespacesExclude] = val
+			} else {
+				delete(copyMeta.Annotations, r.ReplicationAllowedNamespacesExclude)
+			}
+			if val, ok := sourceMeta.Annotations[r.ReplicationAllowedServiceAccounts]; ok {
+				copyMeta.Annotations[r.ReplicationAllowedServiceAccounts] = val
+			} else {
+				delete(copyMeta.Annotations, r.ReplicationAllowedServiceAccounts)
+			}
+			r.stampInstance(copyMeta)
 
-			log.Printf("installing %s %s/%s: updating replication-allowed annotations", r.Name, copyMeta.Namespace, copyMeta.Name)
+			r.infof(sourceKey, targetKey, "installing %s %s/%s: updating replication-allowed annotations", r.Name, copyMeta.Namespace, copyMeta.Name)
 			// install it with the original data
-			return r.install(&r.replicatorProps, copyMeta, sourceObject, targetObject)
+			return r.track(targetKey, sourceKey, outcome, r.install(&r.replicatorProps, copyMeta, sourceObject, targetObject))
 		}
 	}
+	// the dependencies of the target must be updated first
+	if targetMeta == nil {
+	} else if ok, err := r.dependenciesReady(sourceMeta, sourceKey, targetKey); !ok {
+		r.infof(sourceKey, targetKey, "replication of %s %s/%s is delayed: %s",
+			r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
+		return err
+	}
+	// the new data must be approved first
+	if targetMeta == nil {
+	} else if ok, err := r.isApproved(targetMeta, sourceMeta); !ok {
+		r.infof(sourceKey, targetKey, "replication of %s %s/%s is delayed: %s",
+			r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
+		if r.awaitApproval(targetKey, sourceMeta.ResourceVersion) {
+			r.recordEvent(targetObject, v1.EventTypeNormal, "ApprovalRequired", "%s", err)
+		}
+		return err
+	} else {
+		r.approvalDone(targetKey)
+	}
+	// the changes of the source are replicated on its schedule
+	if targetMeta == nil {
+	} else if ok, err := r.isSyncDue(sourceMeta, sourceKey); !ok {
+		r.infof(sourceKey, targetKey, "replication of %s %s/%s is delayed: %s",
+			r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
+		return err
+	}
+	// the labels to copy must be well formed
+	if _, err := r.sourceLabelPatterns(sourceMeta); err != nil {
+		r.errorf(sourceKey, targetKey, "replication of %s %s/%s is cancelled: %s",
+			r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
+		r.reportError(err)
+		return err
+	}
 	// create a new meta with all the annotations
 	copyMeta := metav1.ObjectMeta{
 		Namespace:   targetSplit[0],
@@ -434,28 +853,102 @@ func (r *objectReplicator) installObject(target string, targetObject interface{}
 		Annotations: map[string]string{},
 	}
 
-	copyMeta.Annotations[ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	copyMeta.Annotations[ReplicatedByAnnotation] = fmt.Sprintf("%s/%s",
+	copyMeta.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	copyMeta.Annotations[r.ReplicatedByAnnotation] = fmt.Sprintf("%s/%s",
 		sourceMeta.Namespace, sourceMeta.Name)
-	copyMeta.Annotations[ReplicatedFromVersionAnnotation] = sourceMeta.ResourceVersion
-	if val, ok := sourceMeta.Annotations[ReplicateOnceVersionAnnotation]; ok {
-		copyMeta.Annotations[ReplicateOnceVersionAnnotation] = val
+	copyMeta.Annotations[r.ReplicatedFromVersionAnnotation] = sourceMeta.ResourceVersion
+	r.setDataHash(&copyMeta, sourceObject)
+	r.setSynced(&copyMeta, sourceMeta.ResourceVersion)
+	if val, ok := sourceMeta.Annotations[r.ReplicateOnceVersionAnnotation]; ok {
+		copyMeta.Annotations[r.ReplicateOnceVersionAnnotation] = val
 	}
 	// replicate authorization annotations too
-	if val, ok := sourceMeta.Annotations[ReplicationAllowed]; ok {
-		copyMeta.Annotations[ReplicationAllowed] = val
+	if val, ok := sourceMeta.Annotations[r.ReplicationAllowed]; ok {
+		copyMeta.Annotations[r.ReplicationAllowed] = val
 	}
-	if val, ok := sourceMeta.Annotations[ReplicationAllowedNamespaces]; ok {
-		copyMeta.Annotations[ReplicationAllowedNamespaces] = val
+	if val, ok := sourceMeta.Annotations[r.ReplicationAllowedNamespaces]; ok {
+		copyMeta.Annotations[r.ReplicationAllowedNamespaces] = val
+	}
+	if val, ok := sourceMeta.Annotations[r.ReplicationAllowedNamespacesExclude]; ok {
+		copyMeta.Annotations[r.ReplicationAllowedNamespacesExclude] = val
+	}
+	if val, ok := sourceMeta.Annotations[r.ReplicationAllowedServiceAccounts]; ok {
+		copyMeta.Annotations[r.ReplicationAllowedServiceAccounts] = val
+	}
+	// keep the approval annotations and the original data of the target
+	if targetMeta != nil {
+		r.keepAnnotations(&copyMeta, targetMeta)
 	}
+	// replicate the configured annotations and labels
+	r.propagateAnnotations(&copyMeta, sourceMeta)
+	r.propagateLabels(&copyMeta, sourceMeta)
+	r.mergeTargetMetadata(&copyMeta, targetMeta, sourceMeta)
+	r.stampInstance(&copyMeta)
+	r.setOwner(&copyMeta, sourceMeta, sourceObject)
 	// Needs ResourceVersion for update
 	if targetMeta != nil {
 		copyMeta.ResourceVersion = targetMeta.ResourceVersion
 	}
+	// the target points to a hashed copy of the data
+	hashedName := ""
+	if ok, err := r.isHashSuffixed(sourceMeta); err != nil {
+		r.errorf(sourceKey, targetKey, "replication of %s %s/%s is cancelled: %s",
+			r.Name, sourceMeta.Namespace, sourceMeta.Name, err)
+		return err
+	} else if ok {
+		hash, err := dataHash(sourceObject)
+		if err != nil {
+			return err
+		}
+		hashedName = fmt.Sprintf("%s-%s", targetSplit[1], hash)
+		copyMeta.Annotations[r.ReplicatedHashedNameAnnotation] = hashedName
+	}
+
+	var err error
+	// the hashed copy must exist before the target points to it
+	if hashedName != "" && targetMeta != nil {
+		err = r.installHashed(targetMeta, hashedName, sourceObject)
+	}
+	if err == nil {
+		r.infof(sourceKey, targetKey, "installing %s %s/%s: updating data", r.Name, copyMeta.Namespace, copyMeta.Name)
+		// install it with the source data, over the latest version of the target after a conflict
+		err = r.writeTarget(targetKey, func() error {
+			return r.retryOnConflict(targetKey, func() error {
+				return r.install(&r.replicatorProps, &copyMeta, sourceObject, sourceObject)
+			}, func(latest interface{}) error {
+				copyMeta.ResourceVersion = ""
+				if latest != nil {
+					latestMeta := r.getMeta(latest)
+					copyMeta.ResourceVersion = latestMeta.ResourceVersion
+					r.keepAnnotations(&copyMeta, latestMeta)
+				}
+				return nil
+			})
+		})
+	}
+	// a new target must exist before its hashed copy, which it owns
+	if err == nil && hashedName != "" && targetMeta == nil {
+		var created *metav1.ObjectMeta
+		if _, created, err = r.objectFromStore(targetKey); err == nil {
+			err = r.installHashed(created, hashedName, sourceObject)
+		}
+	}
+	// the former hashed copies are deleted once the target points to the new one
+	if err == nil && hashedName != "" {
+		err = r.deleteFormerHashed(&copyMeta, hashedName)
+	}
+	return r.track(targetKey, sourceKey, outcome, err)
+}
 
-	log.Printf("installing %s %s/%s: updating data", r.Name, copyMeta.Namespace, copyMeta.Name)
-	// install it with the source data
-	return r.install(&r.replicatorProps, &copyMeta, sourceObject, sourceObject)
+// Copies the annotations of the target which are not replaced by the replication: the approvals and the original data
+func (r *objectReplicator) keepAnnotations(copyMeta *metav1.ObjectMeta, targetMeta *metav1.ObjectMeta) {
+	for _, annotation := range []string{r.ReplicateApprovalRequiredAnnotation, r.ReplicateApprovedVersionAnnotation, r.ReplicatedOriginalDataAnnotation} {
+		if val, ok := targetMeta.Annotations[annotation]; ok {
+			copyMeta.Annotations[annotation] = val
+		} else {
+			delete(copyMeta.Annotations, annotation)
+		}
+	}
 }
 
 func (r *objectReplicator) objectFromStore(key string) (interface{}, *metav1.ObjectMeta, error) {
@@ -469,152 +962,242 @@ func (r *objectReplicator) objectFromStore(key string) (interface{}, *metav1.Obj
 }
 
 func (r *objectReplicator) updateDependents(object interface{}, replicas []string) error {
+	// the data of the source may not be cached
+	object = r.unstripped(object)
 	meta := r.getMeta(object)
 	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
 
-	sort.Strings(replicas)
-	updatedReplicas := make([]string, 0, 0)
-	var previous string
-
-	for _, dependentKey := range replicas {
-		// get rid of dupplicates in replicas
-		if previous == dependentKey {
-			continue
-		}
-		previous = dependentKey
-
+	// the dupplicates in replicas are updated once
+	r.forEachTarget(replicas, func(dependentKey string) {
 		targetObject, targetMeta, err := r.objectFromStore(dependentKey)
 		if err != nil {
-			log.Printf("could not load dependent %s: %s", r.Name, err)
-			continue
+			r.errorf(key, dependentKey, "could not load dependent %s: %s", r.Name, err)
+			return
 		}
 
-		if val, ok := resolveAnnotation(targetMeta, ReplicateFromAnnotation); !ok || val != key {
-			log.Printf("annotation of dependent %s %s changed", r.Name, dependentKey)
-			continue
+		// the dependent merges this object with other sources
+		if sources := r.mergedSources(targetMeta); hasSource(sources, key) {
+			r.replicateFromSources(targetObject, sources)
+			return
 		}
 
-		updatedReplicas = append(updatedReplicas, dependentKey)
+		if val, ok := resolveAnnotation(targetMeta, r.ReplicateFromAnnotation); !ok || val != key {
+			r.infof(key, dependentKey, "annotation of dependent %s %s changed", r.Name, dependentKey)
+			return
+		}
 
 		r.replicateObject(targetObject, object)
-	}
-
-	if len(updatedReplicas) > 0 {
-		r.targetsFrom[key] = updatedReplicas
-	} else {
-		delete(r.targetsFrom, key)
-	}
+	})
 
 	return nil
 }
 
 func (r *objectReplicator) ObjectDeleted(object interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
 	meta := r.getMeta(object)
 	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
-	// delete targets of replicate-to annotations
-	if targets, ok := r.targetsTo[key]; ok {
-		for _, t := range targets {
-			r.deleteObject(t, object)
+	policy := r.sourceDeletionPolicy(meta)
+	// delete targets of replicate-to annotations, unless the policy keeps them
+	// the targets of the sources of the other instances are deleted by them
+	if r.isManaged(meta) {
+		for _, t := range r.targetsOf(key) {
+			r.removeTarget(t, object, policy)
 		}
 	}
-	delete(r.targetsTo, key)
-	delete(r.watchedTargets, key)
-	delete(r.watchedPatterns, key)
-	// clear targets of replicate-from annotations
-	if replicas, ok := r.targetsFrom[key]; ok {
-		sort.Strings(replicas)
-		updatedReplicas := make([]string, 0, 0)
-		var previous string
-
+	r.deleteAliases(key)
+	r.resolveCollisions(key, "")
+	r.resolveCollisions("", key)
+	r.forgetRetry(key)
+	r.approvalDone(key)
+	delete(r.syncs, key)
+	notifyBundles(r, meta)
+	notifyProjections(r, meta)
+	notifyConversions(r, object, true)
+	r.updateBundles(meta)
+	// delete its copies from the other clusters
+	r.syncClusters(object, nil)
+	r.pushToExternal(object, true)
+	// the source has been renamed, migrate targets of replicate-from annotations
+	if aliasObject, ok := r.aliasSource(key); !ok {
+	} else if replicas := r.dependentsOf(key); len(replicas) > 0 {
+		r.migrateDependents(key, aliasObject, replicas)
+	}
+	// clear targets of replicate-from annotations, unless the policy keeps their data
+	// they stay indexed by the source, and are updated if it is created again
+	if replicas := r.dependentsOf(key); len(replicas) == 0 {
+	} else if policy == DeletionPolicyRetain {
+		r.infof(key, "", "dependents of %s %s are retained after its deletion", r.Name, key)
+	} else {
 		for _, dependentKey := range replicas {
-			// get rid of dupplicates in replicas
-			if previous == dependentKey {
-				continue
-			}
-			previous = dependentKey
-
-			if ok, _ := r.clearObject(dependentKey, object); ok {
-				updatedReplicas = append(updatedReplicas, dependentKey)
-			}
-		}
-
-		if len(updatedReplicas) > 0 {
-			r.targetsFrom[key] = updatedReplicas
-		} else {
-			delete(r.targetsFrom, key)
+			r.clearObject(dependentKey, object)
 		}
 	}
 	// find which source want to replicate into this object, now that they can
 	todo := map[string]bool{}
 
-	for source, watched := range r.watchedTargets {
-		for _, t := range watched {
+	for source, watched := range r.watchedSources() {
+		for _, t := range watched.targets {
 			if key == t {
 				todo[source] = true
 				break
 			}
 		}
-	}
-
-	for source, patterns := range r.watchedPatterns {
 		if todo[source] {
 			continue
 		}
 
-		for _, p := range patterns {
+		for _, p := range watched.patterns {
 			if p.Match(meta) {
 				todo[source] = true
 				break
 			}
 		}
 	}
+	// the object was deleted with its namespace
+	if !r.namespaceActive(meta.Namespace) {
+		return
+	}
 	// find the first source that still wants to replicate
 	for source := range todo {
 		if sourceObject, exists, err := r.objectStore.GetByKey(source); err != nil {
-			log.Printf("could not get %s %s: %s", r.Name, source, err)
-		// it should not happen, but maybe `ObjectDeleted` hasn't been called yet
-		// just clean watched targets to avoid this to happen again
+			r.errorf(source, key, "could not get %s %s: %s", r.Name, source, err)
+			// it should not happen, as the sources are found in the store
 		} else if !exists {
-			log.Printf("%s %s not found", r.Name, source)
-			delete(r.watchedTargets, source)
-			delete(r.watchedPatterns, source)
+			r.infof(source, key, "%s %s not found", r.Name, source)
 
 		} else if ok, err := r.isReplicatedTo(r.getMeta(sourceObject), meta); err != nil {
-			log.Printf("could not parse %s %s: %s", r.Name, source, err)
-		// the source sitll want to be replicated, so let's do it
-		} else if ok {
+			r.errorf(source, key, "could not parse %s %s: %s", r.Name, source, err)
+			// the source sitll want to be replicated, so let's do it
+		} else if !ok {
+			// the target was deleted out-of-band, it is recreated later if it keeps being deleted
+		} else if delay := r.recreateDelay(meta, source); delay > 0 {
+			r.infof(source, key, "%s %s was deleted again: recreating it in %s", r.Name, key, delay)
+			r.queue.AddAfter(source, delay)
+			break
+		} else {
 			r.installObject(key, nil, sourceObject)
 			break
 		}
 	}
 }
 
+// Registers the aliases of the object, and migrates the dependents of
+// the aliases which do not exist anymore
+func (r *objectReplicator) updateAliases(object interface{}) {
+	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+
+	r.deleteAliases(key)
+	aliases, err := r.getAliases(meta)
+	if err != nil {
+		r.errorf(key, "", "could not parse %s %s: %s", r.Name, key, err)
+		return
+	}
+
+	for _, alias := range aliases {
+		if source, ok := r.aliases[alias]; ok {
+			r.infof(key, "", "alias %s of %s %s is already used by %s", alias, r.Name, key, source)
+			continue
+		}
+		r.aliases[alias] = key
+		// the former source still exists, its dependents will be migrated once it is deleted
+		if _, exists, err := r.objectStore.GetByKey(alias); err != nil || exists {
+		} else if replicas := r.dependentsOf(alias); len(replicas) > 0 {
+			r.migrateDependents(alias, object, replicas)
+		}
+	}
+}
+
+// Removes all the aliases registered by the object
+func (r *objectReplicator) deleteAliases(key string) {
+	for alias, source := range r.aliases {
+		if source == key {
+			delete(r.aliases, alias)
+		}
+	}
+}
+
+// Returns the source which declares the given path as alias, if it exists
+func (r *objectReplicator) aliasSource(alias string) (interface{}, bool) {
+	source, ok := r.aliases[alias]
+	if !ok {
+		return nil, false
+	}
+
+	if sourceObject, exists, err := r.objectStore.GetByKey(source); err != nil {
+		r.errorf(source, "", "could not get %s %s: %s", r.Name, source, err)
+		return nil, false
+	} else {
+		return sourceObject, exists
+	}
+}
+
+func (r *objectReplicator) migrateDependents(alias string, sourceObject interface{}, replicas []string) {
+	// the dependents are indexed by the new source once their annotation is rewritten
+	for _, dependentKey := range replicas {
+		r.migrateObject(dependentKey, alias, sourceObject)
+	}
+}
+
+// Rewrites the replicate-from annotation of a target refering to an alias of the source
+// The data of the target is kept, and will be updated once the target is processed again
+func (r *objectReplicator) migrateObject(key string, alias string, sourceObject interface{}) (bool, error) {
+	sourceMeta := r.getMeta(sourceObject)
+
+	targetObject, targetMeta, err := r.objectFromStore(key)
+	if err != nil {
+		r.errorf(alias, key, "could not load dependent %s: %s", r.Name, err)
+		return false, err
+	}
+
+	if val, ok := resolveAnnotation(targetMeta, r.ReplicateFromAnnotation); !ok || val != alias {
+		r.infof(alias, key, "annotation of dependent %s %s changed", r.Name, key)
+		return false, nil
+	}
+
+	copyMeta := targetMeta.DeepCopy()
+	copyMeta.Annotations[r.ReplicateFromAnnotation] = fmt.Sprintf("%s/%s",
+		sourceMeta.Namespace, sourceMeta.Name)
+
+	r.infof(alias, key, "migrating %s %s: source %s was renamed to %s/%s",
+		r.Name, key, alias, sourceMeta.Namespace, sourceMeta.Name)
+	// install it, but keeps the original data
+	return true, r.track(key, key, "updated", r.install(&r.replicatorProps, copyMeta, targetObject, targetObject))
+}
+
 func (r *objectReplicator) clearObject(key string, sourceObject interface{}) (bool, error) {
 	sourceMeta := r.getMeta(sourceObject)
 
 	targetObject, targetMeta, err := r.objectFromStore(key)
 	if err != nil {
-		log.Printf("could not load dependent %s: %s", r.Name, err)
+		r.errorf("", key, "could not load dependent %s: %s", r.Name, err)
 		return false, err
 	}
 
-	if !annotationRefersTo(targetMeta, ReplicateFromAnnotation, sourceMeta) {
-		log.Printf("annotation of dependent %s %s changed", r.Name, key)
+	if !annotationRefersTo(targetMeta, r.ReplicateFromAnnotation, sourceMeta) {
+		r.infof("", key, "annotation of dependent %s %s changed", r.Name, key)
 		return false, nil
 	}
+	// the dependent keeps the data of its other sources
+	if sources := r.mergedSources(targetMeta); sources != nil {
+		return true, r.replicateFromSources(targetObject, sources)
+	}
 
 	return true, r.doClearObject(targetObject)
 }
 
 func (r *objectReplicator) doClearObject(object interface{}) error {
 	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
 
-	if _, ok := meta.Annotations[ReplicatedFromVersionAnnotation]; !ok {
-		log.Printf("%s %s/%s is already up-to-date", r.Name, meta.Namespace, meta.Name)
+	if _, ok := meta.Annotations[r.ReplicatedFromVersionAnnotation]; !ok {
+		r.debugf("", key, "%s %s/%s is already up-to-date", r.Name, meta.Namespace, meta.Name)
 		return nil
 	}
 
-	return r.clear(&r.replicatorProps, object)
+	return r.track(key, key, "cleared", r.clear(&r.replicatorProps, object))
 }
 
 func (r *objectReplicator) deleteObject(key string, sourceObject interface{}) (bool, error) {
@@ -622,20 +1205,26 @@ func (r *objectReplicator) deleteObject(key string, sourceObject interface{}) (b
 
 	object, meta, err := r.objectFromStore(key)
 	if err != nil {
-		log.Printf("could not get %s %s: %s", r.Name, key, err)
+		r.errorf("", key, "could not get %s %s: %s", r.Name, key, err)
 		return false, err
 	}
 
 	// make sure replication is allowed
 	if ok, err := r.isReplicatedBy(meta, sourceMeta); !ok {
-		log.Printf("deletion of %s %s is cancelled: %s", r.Name, key, err)
+		r.infof("", key, "deletion of %s %s is cancelled: %s", r.Name, key, err)
 		return false, err
-	// delete the object
-	} else {
-		return true, r.doDeleteObject(object)
+		// the garbage collector deletes the objects owned by a deleted source
+	} else if !isOwnedBy(meta, sourceMeta) {
+	} else if _, exists, err := r.objectStore.GetByKey(fmt.Sprintf("%s/%s", sourceMeta.Namespace, sourceMeta.Name)); err == nil && !exists {
+		r.debugf("", key, "deletion of %s %s is left to the garbage collector", r.Name, key)
+		return true, nil
 	}
+	// delete the object
+	return true, r.doDeleteObject(object)
 }
 
 func (r *objectReplicator) doDeleteObject(object interface{}) error {
-	return r.delete(&r.replicatorProps, object)
+	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+	return r.track(key, key, "deleted", r.delete(&r.replicatorProps, object))
 }
@@ -0,0 +1,127 @@
+package replicate
+
+import (
+	"fmt"
+)
+
+// StartupReport summarizes what the replicator found and changed during its initial synchronization
+type StartupReport struct {
+	Kind string `json:"kind"`
+	// the number of sources with targets
+	Sources int `json:"sources"`
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Cleared int `json:"cleared"`
+	Deleted int `json:"deleted"`
+	// the number of targets already up-to-date
+	Skipped int      `json:"skipped"`
+	Failed  int      `json:"failed"`
+	Orphans []Orphan `json:"orphans"`
+	// the errors of the annotations
+	Errors []string `json:"errors"`
+}
+
+// Counts an outcome of the initial synchronization
+func (r *replicatorProps) reportOutcome(outcome string) {
+	report := r.startupReport
+	if report == nil || r.startupDone {
+		return
+	}
+
+	switch outcome {
+	case "created":
+		report.Created++
+	case "updated":
+		report.Updated++
+	case "cleared":
+		report.Cleared++
+	case "deleted":
+		report.Deleted++
+	case "skipped":
+		report.Skipped++
+	case "failed":
+		report.Failed++
+	}
+}
+
+// Records an annotation error of the initial synchronization
+func (r *replicatorProps) reportError(err error) {
+	if r.startupReport != nil && !r.startupDone {
+		r.startupReport.Errors = append(r.startupReport.Errors, err.Error())
+	}
+}
+
+// Logs the report of the initial synchronization, once the objects listed at startup are processed
+func (r *objectReplicator) reportStartup() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	report := r.startupReport
+	sources := map[string]bool{}
+	for source := range r.targets() {
+		sources[source] = true
+	}
+	for source := range r.dependents() {
+		sources[source] = true
+	}
+	report.Sources = len(sources)
+
+	if orphans, err := r.orphans(OrphanReport); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not list orphans: %s", err))
+	} else {
+		report.Orphans = r.collectOrphans(orphans)
+	}
+	r.startupDone = true
+
+	logf("startup report of %s replicator: %d sources, %d targets created, %d updated, %d cleared, %d deleted, %d up-to-date, %d failed, %d orphans, %d annotation errors",
+		r.Name, report.Sources, report.Created, report.Updated, report.Cleared, report.Deleted,
+		report.Skipped, report.Failed, len(report.Orphans), len(report.Errors))
+	for _, o := range report.Orphans {
+		if o.Error != "" {
+			logf("startup report of %s replicator: orphan %s: %s (%s failed: %s)", r.Name, o.Object, o.Reason, r.startupOrphans, o.Error)
+		} else if o.Collected {
+			logf("startup report of %s replicator: orphan %s: %s (%s)", r.Name, o.Object, o.Reason, r.startupOrphans)
+		} else {
+			logf("startup report of %s replicator: orphan %s: %s", r.Name, o.Object, o.Reason)
+		}
+	}
+	for _, e := range report.Errors {
+		logf("startup report of %s replicator: %s", r.Name, e)
+	}
+}
+
+// Applies the startup action to the orphans created by the replicator, returned with the outcome of the action
+// The objects pulling their data with replicate-from are only reported, as they are created by the users
+func (r *objectReplicator) collectOrphans(orphans []Orphan) []Orphan {
+	if r.startupOrphans == OrphanReport {
+		return orphans
+	}
+	for index := range orphans {
+		orphan := &orphans[index]
+		object, exists, err := r.objectStore.GetByKey(orphan.Object)
+		if err != nil || !exists {
+			continue
+		} else if _, ok := r.getMeta(object).Annotations[r.ReplicatedByAnnotation]; !ok {
+			continue
+		}
+		if err := r.resolveOrphan(object, r.startupOrphans); err != nil {
+			orphan.Error = err.Error()
+		} else {
+			orphan.Collected = true
+		}
+	}
+	return orphans
+}
+
+// StartupReport returns the report of the initial synchronization, or nil if it is not done yet
+func (r *objectReplicator) StartupReport() *StartupReport {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if !r.startupDone {
+		return nil
+	}
+
+	report := *r.startupReport
+	return &report
+}
@@ -0,0 +1,110 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestCollectOrphans(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+			startupOrphans:  OrphanDelete,
+		},
+		// the client is not set, any write would panic
+		replicatorActions: &dryRunActions{ConfigMapActions},
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.objectController = syncedController{}
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "replicated",
+		Annotations: map[string]string{r.ReplicatedByAnnotation: "default/gone"}}})
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "pulled",
+		Annotations: map[string]string{r.ReplicateFromAnnotation: "default/gone"}}})
+
+	orphans, err := r.orphans(OrphanReport)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(orphans) != 2 {
+		t.Fatalf("expected 2 orphans, got %v", orphans)
+	}
+	orphans = r.collectOrphans(orphans)
+	if orphans[0].Object != "team-a/pulled" || orphans[0].Collected {
+		t.Errorf("expected the pulling orphan to be only reported, got %v", orphans[0])
+	}
+	if orphans[1].Object != "team-a/replicated" || !orphans[1].Collected {
+		t.Errorf("expected the replicated orphan to be deleted, got %v", orphans[1])
+	}
+	if actions := DryRunActions(); len(actions) != 1 || actions[0].Action != "delete" || actions[0].Target != "team-a/replicated" {
+		t.Errorf("expected the deletion of the replicated orphan, got %v", actions)
+	}
+
+	r.startupOrphans = OrphanReport
+	if orphans := r.collectOrphans([]Orphan{{Object: "team-a/replicated"}}); orphans[0].Collected {
+		t.Errorf("expected the orphans to be only reported by default")
+	}
+}
+
+func TestOrphansIllformedSource(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+			allowAll:        true,
+		},
+		replicatorActions: ConfigMapActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.objectController = syncedController{}
+
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source",
+		Annotations: map[string]string{r.ReplicateToAnnotation: "team-(/source"}}})
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "source",
+		Annotations: map[string]string{r.ReplicatedByAnnotation: "default/source"}}})
+
+	// a typo in the annotations of the source does not make orphans of its targets
+	if orphans, err := r.orphans(OrphanReport); err != nil || len(orphans) != 0 {
+		t.Errorf("expected the targets of an illformed source not to be orphans, got %v %v", orphans, err)
+	}
+}
+
+func TestOrphansPulling(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+		},
+		// the client is not set, any write would panic
+		replicatorActions: &dryRunActions{ConfigMapActions},
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.objectController = syncedController{}
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source",
+		Annotations: map[string]string{r.ReplicationAllowedNamespaces: "team-b"}}})
+	r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "pulled",
+		Annotations: map[string]string{r.ReplicateFromAnnotation: "default/source", r.ReplicatedFromVersionAnnotation: "1"}}})
+
+	// the source exists, but does not allow the replication anymore
+	orphans, err := r.orphans(OrphanDelete)
+	if err != nil || len(orphans) != 1 || orphans[0].Object != "team-a/pulled" {
+		t.Fatalf("expected the denied object to be an orphan, got %v %v", orphans, err)
+	}
+	if actions := DryRunActions(); len(actions) != 0 {
+		t.Errorf("expected the object created by the user to be only reported, got %v", actions)
+	}
+}
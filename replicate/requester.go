@@ -0,0 +1,128 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the prefix of the usernames of the service accounts
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// the user the replicator writes as, whose requests are not stamped
+var replicatorUsername string
+
+// SetUsername sets the user the replicator writes as, as seen by the admission webhook
+// The objects it writes are not stamped with their requester, the ones written by the other users always are
+func SetUsername(username string) {
+	replicatorUsername = username
+}
+
+// Returns the user which requested the replication to the target, as stamped by the admission webhook
+func (r *replicatorProps) requester(object *metav1.ObjectMeta) string {
+	if val, ok := object.Annotations[r.ReplicationRequestedByAnnotation]; ok && val != "" {
+		return val
+	}
+	return "an unknown user"
+}
+
+// Checks that the service account which requested the replication to the target is allowed by the source
+// The allowed service accounts are a comma separated list of namespace/name paths, names in the namespace of
+// the target, or patterns of paths
+// Returns false if the requester is not stamped on the target, or is not a service account
+func (r *replicatorProps) isRequesterAllowed(object *metav1.ObjectMeta, sourceObject *metav1.ObjectMeta) (bool, error) {
+	requester := ""
+	if val := object.Annotations[r.ReplicationRequestedByAnnotation]; strings.HasPrefix(val, serviceAccountPrefix) {
+		if parts := strings.SplitN(strings.TrimPrefix(val, serviceAccountPrefix), ":", 2); len(parts) == 2 {
+			requester = parts[0] + "/" + parts[1]
+		}
+	}
+
+	allowed := false
+	for _, account := range strings.Split(sourceObject.Annotations[r.ReplicationAllowedServiceAccounts], ",") {
+		if account == "" {
+		} else if validName.MatchString(account) {
+			if requester == object.Namespace+"/"+account {
+				allowed = true
+			}
+		} else if validPath.MatchString(account) {
+			if requester == account {
+				allowed = true
+			}
+		} else if pattern, err := r.compilePattern(sourceObject, account); err == nil {
+			if requester != "" && pattern.MatchString(requester) {
+				allowed = true
+			}
+		} else {
+			return false, fmt.Errorf("source %s/%s has compilation error on annotation %s (%s): %s",
+				sourceObject.Namespace, sourceObject.Name, r.ReplicationAllowedServiceAccounts, account, err)
+		}
+	}
+	return allowed, nil
+}
+
+// Stamp writes the user creating an object with a "replicate-from" annotation in its requested-by annotation,
+// from the JSON of the object and of its former version, which is empty at its creation
+// The annotation is kept on the updates, unless the "replicate-from" annotation changes, so that it cannot be spoofed
+// The writes of the replicator itself are not stamped, whatever the annotations of the object
+// Returns false when the object is not of the kind of this replicator, or is left unchanged
+func (r *objectReplicator) Stamp(kind string, raw []byte, oldRaw []byte, username string) ([]byte, bool, error) {
+	if strings.ToLower(kind) != r.kind() {
+		return nil, false, nil
+	}
+	// the copies are written by the replicator
+	if replicatorUsername != "" && username == replicatorUsername {
+		return nil, false, nil
+	}
+
+	_, empty := r.listWatch(r.client)
+	object := reflect.New(reflect.TypeOf(empty).Elem()).Interface()
+	if err := json.Unmarshal(raw, object); err != nil {
+		return nil, false, err
+	}
+	meta := r.getMeta(object)
+
+	from, fromOk := meta.Annotations[r.ReplicateFromAnnotation]
+	requester, ok := "", false
+	if fromOk {
+		requester, ok = username, true
+	}
+	if len(oldRaw) > 0 {
+		oldObject := reflect.New(reflect.TypeOf(empty).Elem()).Interface()
+		if err := json.Unmarshal(oldRaw, oldObject); err != nil {
+			return nil, false, err
+		}
+		oldMeta := r.getMeta(oldObject)
+		// the former requester is kept while the requested sources do not change
+		if val, oldOk := oldMeta.Annotations[r.ReplicateFromAnnotation]; !fromOk || oldOk && val == from {
+			requester, ok = oldMeta.Annotations[r.ReplicationRequestedByAnnotation]
+		}
+	}
+
+	if val, exists := meta.Annotations[r.ReplicationRequestedByAnnotation]; exists == ok && val == requester {
+		return nil, false, nil
+	} else if ok {
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		meta.Annotations[r.ReplicationRequestedByAnnotation] = requester
+	} else {
+		delete(meta.Annotations, r.ReplicationRequestedByAnnotation)
+	}
+
+	// only the metadata is replaced, the other fields are kept as sent
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false, err
+	}
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return nil, false, err
+	}
+	fields["metadata"] = metadata
+	stamped, err := json.Marshal(fields)
+	return stamped, err == nil, err
+}
@@ -0,0 +1,101 @@
+package replicate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReplicationAllowedServiceAccounts(t *testing.T) {
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix(""), allowAll: true}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", Annotations: map[string]string{
+		r.ReplicationAllowedServiceAccounts: "deployer,ci/builder,tools/.*",
+	}}
+
+	for requester, expected := range map[string]bool{
+		"system:serviceaccount:team-a:deployer": true,
+		"system:serviceaccount:team-b:deployer": false,
+		"system:serviceaccount:ci:builder":      true,
+		"system:serviceaccount:tools:anything":  true,
+		"jane@example.com":                      false,
+		"":                                      false,
+	} {
+		target := &metav1.ObjectMeta{Namespace: "team-a", Name: "target", Annotations: map[string]string{}}
+		if requester != "" {
+			target.Annotations[r.ReplicationRequestedByAnnotation] = requester
+		}
+		if ok, err := r.isReplicationAllowed(target, source); ok != expected {
+			t.Errorf("expected replication requested by %q allowed: %v, got %v (%v)", requester, expected, ok, err)
+		}
+	}
+}
+
+func TestStamp(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps:   replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")},
+		replicatorActions: SecretActions,
+	}
+	stamp := func(target *v1.Secret, old *v1.Secret) (string, bool) {
+		raw, _ := json.Marshal(target)
+		oldRaw := []byte{}
+		if old != nil {
+			oldRaw, _ = json.Marshal(old)
+		}
+		stamped, ok, err := r.Stamp("Secret", raw, oldRaw, "system:serviceaccount:team:deployer")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		} else if !ok {
+			return target.Annotations[r.ReplicationRequestedByAnnotation], false
+		}
+		result := &v1.Secret{}
+		if err := json.Unmarshal(stamped, result); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return result.Annotations[r.ReplicationRequestedByAnnotation], true
+	}
+
+	// the creator is stamped, whatever the object claims
+	target := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "target", Annotations: map[string]string{
+		r.ReplicateFromAnnotation:          "default/source",
+		r.ReplicationRequestedByAnnotation: "system:serviceaccount:ci:builder",
+	}}}
+	if val, ok := stamp(target, nil); !ok || val != "system:serviceaccount:team:deployer" {
+		t.Errorf("expected the creator to be stamped, got %q", val)
+	}
+
+	// the former requester is kept while the source does not change
+	old := target.DeepCopy()
+	old.Annotations[r.ReplicationRequestedByAnnotation] = "system:serviceaccount:ci:builder"
+	target.Annotations[r.ReplicationRequestedByAnnotation] = "system:serviceaccount:other:admin"
+	if val, ok := stamp(target, old); !ok || val != "system:serviceaccount:ci:builder" {
+		t.Errorf("expected the former requester to be kept, got %q", val)
+	}
+	target.Annotations[r.ReplicationRequestedByAnnotation] = "system:serviceaccount:ci:builder"
+	if _, ok := stamp(target, old); ok {
+		t.Errorf("expected the object to be left unchanged")
+	}
+
+	// changing the source stamps the user requesting it
+	target.Annotations[r.ReplicateFromAnnotation] = "default/other"
+	if val, ok := stamp(target, old); !ok || val != "system:serviceaccount:team:deployer" {
+		t.Errorf("expected the updater to be stamped, got %q", val)
+	}
+
+	// a copy claimed by the user is stamped, only the writes of the replicator are not
+	SetUsername("system:serviceaccount:kube-system:replicator")
+	defer SetUsername("")
+	target.Annotations[r.ReplicatedByAnnotation] = "default/other"
+	if val, ok := stamp(target, nil); !ok || val != "system:serviceaccount:team:deployer" {
+		t.Errorf("expected the claimed copy to be stamped, got %q", val)
+	}
+	raw, _ := json.Marshal(target)
+	if _, ok, _ := r.Stamp("Secret", raw, nil, "system:serviceaccount:kube-system:replicator"); ok {
+		t.Errorf("expected the writes of the replicator not to be stamped")
+	}
+
+	if _, ok, _ := r.Stamp("ConfigMap", []byte(`{}`), nil, "jane"); ok {
+		t.Errorf("expected the other kinds to be ignored")
+	}
+}
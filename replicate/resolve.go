@@ -0,0 +1,82 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// implemented by the actions whose targets can be filled before their creation
+type resolvableActions interface {
+	replicated(r *replicatorProps, object interface{}, sourceObject interface{}) (interface{}, error)
+}
+
+// Resolve fills the data of an object being created with a "replicate-from" annotation, from its JSON
+// Returns false when the object is not of the kind of this replicator, or cannot be filled yet
+// The replication is processed again once the object is created, this only avoids the window without data
+func (r *objectReplicator) Resolve(kind string, raw []byte) ([]byte, bool, error) {
+	actions, ok := baseActions(r.replicatorActions).(resolvableActions)
+	// nothing is filled in dry-run mode
+	if !ok || dryRun || strings.ToLower(kind) != r.kind() || !r.Synced() {
+		return nil, false, nil
+	}
+
+	_, empty := r.listWatch(r.client)
+	object := reflect.New(reflect.TypeOf(empty).Elem()).Interface()
+	if err := json.Unmarshal(raw, object); err != nil {
+		return nil, false, err
+	}
+	meta := r.getMeta(object)
+	val, ok := resolveAnnotation(meta, r.ReplicateFromAnnotation)
+	if !ok || !r.isManaged(meta) {
+		return nil, false, nil
+	}
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+	if err := checkTargetNamespace(meta.Namespace); err != nil {
+		return nil, false, nil
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	sourceObjects := []interface{}{}
+	for _, source := range strings.Split(val, ",") {
+		sourceObject, exists, err := r.getSource(source)
+		if err != nil {
+			return nil, false, err
+		} else if !exists {
+			continue
+		}
+		// the denials and approvals are left to the replication
+		sourceMeta := r.getMeta(sourceObject)
+		if ok, _ := r.isReplicationAllowed(meta, sourceMeta); !ok {
+			return nil, false, nil
+		} else if ok, _ := r.isApproved(meta, sourceMeta); !ok {
+			return nil, false, nil
+		}
+		sourceObjects = append(sourceObjects, sourceObject)
+	}
+
+	var sourceObject interface{}
+	switch len(sourceObjects) {
+	case 0:
+		return nil, false, nil
+	case 1:
+		sourceObject = sourceObjects[0]
+	default:
+		merged, err := r.mergeSources(sourceObjects)
+		if err != nil {
+			return nil, false, err
+		}
+		sourceObject = merged
+	}
+
+	replicated, err := actions.replicated(&r.replicatorProps, object, sourceObject)
+	if err != nil {
+		return nil, false, err
+	}
+	r.infof(val, key, "%s %s is filled from %s at its creation", r.Name, key, val)
+	resolved, err := json.Marshal(replicated)
+	return resolved, err == nil, err
+}
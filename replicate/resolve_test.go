@@ -0,0 +1,67 @@
+package replicate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// a controller which is always synced
+type syncedController struct{}
+
+func (syncedController) Run(stopCh <-chan struct{})      {}
+func (syncedController) HasSynced() bool                 { return true }
+func (syncedController) LastSyncResourceVersion() string { return "" }
+
+func TestResolve(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:                "secret",
+			annotationNames:     annotationsWithPrefix(""),
+			allowAll:            true,
+			objectStore:         cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+			objectController:    syncedController{},
+			namespaceStore:      cache.NewStore(cache.MetaNamespaceKeyFunc),
+			namespaceController: syncedController{},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore.Add(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "10"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	})
+
+	target := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "target", Annotations: map[string]string{
+		r.ReplicateFromAnnotation: "default/source",
+	}}}
+	raw, _ := json.Marshal(target)
+
+	resolved, ok, err := r.Resolve("Secret", raw)
+	if err != nil || !ok {
+		t.Fatalf("expected the target to be filled, got %v, %v", ok, err)
+	}
+	secret := &v1.Secret{}
+	if err := json.Unmarshal(resolved, secret); err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["password"]) != "secret" {
+		t.Errorf("expected the data of the source, got %v", secret.Data)
+	}
+	if secret.Annotations[r.ReplicatedFromVersionAnnotation] != "10" {
+		t.Errorf("expected the version of the source, got %s", secret.Annotations[r.ReplicatedFromVersionAnnotation])
+	}
+
+	// the other kinds are left to their own replicator
+	if _, ok, _ := r.Resolve("ConfigMap", raw); ok {
+		t.Errorf("expected a config map not to be filled by the secret replicator")
+	}
+	// the missing sources are left to the replication
+	target.Annotations[r.ReplicateFromAnnotation] = "default/missing"
+	raw, _ = json.Marshal(target)
+	if _, ok, _ := r.Resolve("Secret", raw); ok {
+		t.Errorf("expected the target of a missing source not to be filled")
+	}
+}
@@ -0,0 +1,121 @@
+package replicate
+
+import (
+	"encoding/json"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the delays between the retries of a failing target
+const (
+	retryInitialDelay = 5 * time.Second
+	retryMaxDelay     = 5 * time.Minute
+)
+
+// the backoff state of a failing target, written in the replication-retry annotation
+type retryState struct {
+	Attempts  int       `json:"attempts"`
+	NextRetry time.Time `json:"nextRetry"`
+	LastError string    `json:"lastError"`
+	// the version of the target once the state is written on it, changed only by the other writers
+	version string
+}
+
+// Tracks the result of an action on a target, described by its outcome on success
+// On failure, the object with retryKey is queued again after a backoff delay
+// Returns the error
+func (r *objectReplicator) track(target string, retryKey string, outcome string, err error) error {
+	// the paused writes are made once the source is processed again after the resume
+	if isPaused(err) {
+		r.debugf(retryKey, target, "%s %s is not %s: %s", r.Name, target, outcome, err)
+		return nil
+	}
+	state, ok := r.retries[target]
+	if err != nil {
+		r.reportOutcome("failed")
+		r.metrics.add("replicator_failures_total", 1, "kind", r.Name, "operation", outcome)
+	} else {
+		r.reportOutcome(outcome)
+		r.metrics.add("replicator_operations_total", 1, "kind", r.Name, "operation", outcome)
+	}
+
+	if err == nil {
+		if ok {
+			r.forgetRetry(target)
+			r.annotateRetry(target, nil)
+		}
+		return nil
+	}
+
+	if !ok {
+		state = &retryState{}
+		r.retries[target] = state
+	}
+
+	delay := r.rateLimiter.When(target)
+	state.Attempts = r.rateLimiter.NumRequeues(target)
+	state.NextRetry = time.Now().Add(delay).Truncate(time.Second)
+	state.LastError = err.Error()
+	r.queue.AddAfter(retryKey, delay)
+
+	logf("replication of %s %s failed %d times: retrying at %s",
+		r.Name, target, state.Attempts, state.NextRetry.Format(time.RFC3339))
+	r.annotateRetry(target, state)
+	return err
+}
+
+// Stops retrying the target
+func (r *objectReplicator) forgetRetry(target string) {
+	if _, ok := r.retries[target]; ok {
+		r.rateLimiter.Forget(target)
+		delete(r.retries, target)
+	}
+}
+
+// Returns true if the object is failing, and should not be processed before its next retry
+// The backoff is reset once the object is changed by another writer, so that a fix is processed at once
+func (r *objectReplicator) isBackingOff(meta *metav1.ObjectMeta) bool {
+	key := objectKey(meta)
+	state, ok := r.retries[key]
+	if !ok || !time.Now().Before(state.NextRetry) {
+		return false
+	}
+	if state.version != meta.ResourceVersion {
+		r.debugf(key, "", "%s %s changed since it failed: retrying at once", r.Name, key)
+		r.rateLimiter.Forget(key)
+		state.NextRetry = time.Time{}
+		return false
+	}
+	return true
+}
+
+// Queues the object to be processed again
+func (r *objectReplicator) retry(key string) {
+	r.queue.Add(key)
+}
+
+// Writes the backoff state on the target if it exists, or removes it if state is nil
+func (r *objectReplicator) annotateRetry(target string, state *retryState) {
+	object, exists, err := r.objectStore.GetByKey(target)
+	if err != nil || !exists {
+		return
+	}
+	if state != nil {
+		state.version = r.getMeta(object).ResourceVersion
+	}
+
+	value := ""
+	if state != nil {
+		data, err := json.Marshal(state)
+		if err != nil {
+			return
+		}
+		value = string(data)
+	}
+
+	// the write of the state is not a change which resets the backoff
+	if updated, err := r.setStatusAnnotation(object, r.ReplicationRetryAnnotation, value); err == nil && state != nil {
+		state.version = r.getMeta(updated).ResourceVersion
+	}
+}
@@ -0,0 +1,73 @@
+package replicate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestIsBackingOff(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "secret"}}
+	_, r.rateLimiter = newQueue("secret")
+	r.retries = map[string]*retryState{"team-a/target": {NextRetry: time.Now().Add(time.Hour), version: "2"}}
+
+	// the events of the failing version, or of the write of its state, wait for the next retry
+	if !r.isBackingOff(&metav1.ObjectMeta{Namespace: "team-a", Name: "target", ResourceVersion: "2"}) {
+		t.Errorf("expected the unchanged target to wait for its next retry")
+	}
+	if r.isBackingOff(&metav1.ObjectMeta{Namespace: "team-a", Name: "other", ResourceVersion: "2"}) {
+		t.Errorf("expected the other objects not to wait")
+	}
+
+	// a change made by another writer is processed at once, the state being cleared once it succeeds
+	if r.isBackingOff(&metav1.ObjectMeta{Namespace: "team-a", Name: "target", ResourceVersion: "3"}) {
+		t.Errorf("expected the changed target to be processed at once")
+	}
+	if _, ok := r.retries["team-a/target"]; !ok {
+		t.Errorf("expected the retry state to be kept until the target succeeds")
+	}
+	if r.isBackingOff(&metav1.ObjectMeta{Namespace: "team-a", Name: "target", ResourceVersion: "2"}) {
+		t.Errorf("expected the backoff to be reset")
+	}
+}
+
+func TestTrackBackoff(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{
+		Name:        "secret",
+		objectStore: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		retries:     map[string]*retryState{},
+	}}
+	r.queue, r.rateLimiter = newQueue("secret")
+	defer r.queue.ShutDown()
+
+	// each failure doubles the delay before the next retry
+	var delays []time.Duration
+	for attempt := 1; attempt <= 3; attempt++ {
+		start := time.Now()
+		if err := r.track("team-a/target", "default/source", "updated", fmt.Errorf("forbidden")); err == nil {
+			t.Fatalf("expected the error to be returned")
+		}
+		state := r.retries["team-a/target"]
+		if state == nil || state.Attempts != attempt || state.LastError != "forbidden" {
+			t.Fatalf("expected the failure %d to be tracked, got %+v", attempt, state)
+		}
+		delays = append(delays, state.NextRetry.Sub(start.Truncate(time.Second)))
+	}
+	if delays[0] < retryInitialDelay-time.Second || delays[1] <= delays[0] || delays[2] <= delays[1] {
+		t.Errorf("expected growing delays from %s, got %v", retryInitialDelay, delays)
+	}
+
+	// a success forgets the failures
+	if err := r.track("team-a/target", "default/source", "updated", nil); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if _, ok := r.retries["team-a/target"]; ok || r.rateLimiter.NumRequeues("team-a/target") != 0 {
+		t.Errorf("expected the backoff to be reset once the target succeeds")
+	}
+	if r.track("team-a/target", "default/source", "updated", fmt.Errorf("forbidden")); r.retries["team-a/target"].Attempts != 1 {
+		t.Errorf("expected a new failure to start again from the first attempt")
+	}
+}
@@ -0,0 +1,267 @@
+package replicate
+
+import (
+	"time"
+
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+var RoleBindingActions *roleBindingActions = &roleBindingActions{}
+
+// NewRoleBindingReplicator creates a new role binding replicator
+func NewRoleBindingReplicator(client kubernetes.Interface, options ReplicatorOptions) Replicator {
+	repl := objectReplicator{
+		replicatorProps:   newReplicatorProps("role binding", client, options),
+		replicatorActions: RoleBindingActions,
+	}
+	repl.queue, repl.rateLimiter = newQueue(repl.Name)
+	repl.logger = options.logger().WithValues("kind", repl.kind())
+
+	namespaces := repl.readLegacy(namespaceListWatch(client))
+	namespaceLister := &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := namespaces.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.NamespaceList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.namespaceStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return namespaces.Watch(lo)
+		},
+	}
+	namespaceStore, namespaceController := cache.NewInformer(
+		namespaceLister,
+		&v1.Namespace{},
+		options.ResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    repl.NamespaceAdded,
+			UpdateFunc: repl.NamespaceUpdated,
+			DeleteFunc: repl.NamespaceDeleted,
+		},
+	)
+
+	repl.namespaceStore = namespaceStore
+	repl.namespaceController = namespaceController
+	repl.namespaceLister = namespaceLister
+
+	objects, _ := RoleBindingActions.listWatch(client)
+	objects = repl.readLegacy(repl.pageLists(objects))
+	objects = repl.transformCache(objects)
+	objectListWatch := &cache.ListWatch{
+		// the pages are merged by pageLists, keeping the type of the list
+		DisableChunking: true,
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := objects.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*rbacv1.RoleBindingList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.objectStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return objects.Watch(lo)
+		},
+	}
+
+	objectStore, objectController := cache.NewIndexerInformer(
+		objectListWatch,
+		&rbacv1.RoleBinding{},
+		options.ResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    repl.enqueueAdded,
+			UpdateFunc: repl.enqueueUpdated,
+			DeleteFunc: repl.enqueueDeleted,
+		},
+		repl.indexers(),
+	)
+
+	repl.objectStore = repl.stripStore(objectStore)
+	repl.objectController = objectController
+	repl.objectLister = objectListWatch
+
+	registerReplicator(&repl)
+	options.Metrics.addCollector(repl.collectStaleness)
+	if options.Shards != nil {
+		options.Shards.onChange(repl.resync)
+	}
+
+	return &repl
+}
+
+type roleBindingActions struct{}
+
+func (*roleBindingActions) getMeta(object interface{}) *metav1.ObjectMeta {
+	return &object.(*rbacv1.RoleBinding).ObjectMeta
+}
+
+// Watches the objects of the watched namespaces, of this cluster or of another one
+func (*roleBindingActions) listWatch(client kubernetes.Interface) (cache.ListerWatcher, runtime.Object) {
+	return scopedListWatch(func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			DisableChunking: true,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.RbacV1().RoleBindings(namespace).List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.RbacV1().RoleBindings(namespace).Watch(lo)
+			},
+		}
+	}), &rbacv1.RoleBinding{}
+}
+
+// Copies the role and the subjects of the source role binding
+// The subjects in the namespace of the source are moved to the namespace of the target
+func copyRoleBindingSubjects(roleBinding *rbacv1.RoleBinding, sourceRoleBinding *rbacv1.RoleBinding) {
+	roleBinding.RoleRef = sourceRoleBinding.RoleRef
+	if sourceRoleBinding.Subjects != nil {
+		roleBinding.Subjects = make([]rbacv1.Subject, len(sourceRoleBinding.Subjects))
+		copy(roleBinding.Subjects, sourceRoleBinding.Subjects)
+		for index := range roleBinding.Subjects {
+			if subject := &roleBinding.Subjects[index]; subject.Namespace == sourceRoleBinding.Namespace {
+				subject.Namespace = roleBinding.Namespace
+			}
+		}
+	} else {
+		roleBinding.Subjects = nil
+	}
+}
+
+func (*roleBindingActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	sourceRoleBinding := sourceObject.(*rbacv1.RoleBinding)
+	roleBinding := object.(*rbacv1.RoleBinding).DeepCopy()
+	// the role of a role binding cannot be updated, it has to be recreated
+	recreate := roleBinding.RoleRef != sourceRoleBinding.RoleRef
+
+	copyRoleBindingSubjects(roleBinding, sourceRoleBinding)
+
+	r.infof(objectKey(sourceRoleBinding), objectKey(roleBinding), "updating role binding %s/%s", roleBinding.Namespace, roleBinding.Name)
+
+	roleBinding.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	roleBinding.Annotations[r.ReplicatedFromVersionAnnotation] = sourceRoleBinding.ResourceVersion
+	r.setDataHash(&roleBinding.ObjectMeta, sourceRoleBinding)
+	r.setSynced(&roleBinding.ObjectMeta, sourceRoleBinding.ResourceVersion)
+	if val, ok := sourceRoleBinding.Annotations[r.ReplicateOnceVersionAnnotation]; ok {
+		roleBinding.Annotations[r.ReplicateOnceVersionAnnotation] = val
+	} else {
+		delete(roleBinding.Annotations, r.ReplicateOnceVersionAnnotation)
+	}
+	r.propagateAnnotations(&roleBinding.ObjectMeta, &sourceRoleBinding.ObjectMeta)
+	r.propagateLabels(&roleBinding.ObjectMeta, &sourceRoleBinding.ObjectMeta)
+
+	var s *rbacv1.RoleBinding
+	var err error
+	if recreate {
+		r.infof(objectKey(sourceRoleBinding), objectKey(roleBinding), "role of role binding %s/%s changed: recreating it", roleBinding.Namespace, roleBinding.Name)
+		err = r.writer(&roleBinding.ObjectMeta).RbacV1().RoleBindings(roleBinding.Namespace).Delete(roleBinding.Name, &metav1.DeleteOptions{})
+		if err == nil {
+			roleBinding.ResourceVersion = ""
+			s, err = r.writer(&roleBinding.ObjectMeta).RbacV1().RoleBindings(roleBinding.Namespace).Create(roleBinding)
+		}
+	} else {
+		s, err = r.writer(&roleBinding.ObjectMeta).RbacV1().RoleBindings(roleBinding.Namespace).Update(roleBinding)
+	}
+	if err != nil {
+		r.errorf(objectKey(sourceRoleBinding), objectKey(roleBinding), "error while updating role binding %s/%s: %s", roleBinding.Namespace, roleBinding.Name, err)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*roleBindingActions) clear(r *replicatorProps, object interface{}) error {
+	roleBinding := object.(*rbacv1.RoleBinding).DeepCopy()
+	roleBinding.Subjects = nil
+
+	r.infof("", objectKey(roleBinding), "clearing role binding %s/%s", roleBinding.Namespace, roleBinding.Name)
+
+	roleBinding.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	delete(roleBinding.Annotations, r.ReplicatedFromVersionAnnotation)
+	delete(roleBinding.Annotations, r.ReplicatedDataHashAnnotation)
+	delete(roleBinding.Annotations, r.ReplicateOnceVersionAnnotation)
+
+	s, err := r.writer(&roleBinding.ObjectMeta).RbacV1().RoleBindings(roleBinding.Namespace).Update(roleBinding)
+	if err != nil {
+		r.errorf("", objectKey(roleBinding), "error while clearing role binding %s/%s", roleBinding.Namespace, roleBinding.Name)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*roleBindingActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	sourceRoleBinding := sourceObject.(*rbacv1.RoleBinding)
+	roleBinding := rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       sourceRoleBinding.Kind,
+			APIVersion: sourceRoleBinding.APIVersion,
+		},
+		ObjectMeta: *meta,
+	}
+
+	if dataObject != nil {
+		copyRoleBindingSubjects(&roleBinding, dataObject.(*rbacv1.RoleBinding))
+	} else {
+		// a role binding always needs a role
+		roleBinding.RoleRef = sourceRoleBinding.RoleRef
+	}
+
+	r.infof(objectKey(sourceRoleBinding), objectKey(&roleBinding), "installing role binding %s/%s", roleBinding.Namespace, roleBinding.Name)
+
+	var s *rbacv1.RoleBinding
+	var err error
+	if roleBinding.ResourceVersion == "" {
+		s, err = r.writer(&roleBinding.ObjectMeta).RbacV1().RoleBindings(roleBinding.Namespace).Create(&roleBinding)
+	} else {
+		s, err = r.writer(&roleBinding.ObjectMeta).RbacV1().RoleBindings(roleBinding.Namespace).Update(&roleBinding)
+	}
+
+	if err != nil {
+		r.errorf(objectKey(sourceRoleBinding), objectKey(&roleBinding), "error while installing role binding %s/%s: %s", roleBinding.Namespace, roleBinding.Name, err)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*roleBindingActions) delete(r *replicatorProps, object interface{}) error {
+	roleBinding := object.(*rbacv1.RoleBinding)
+	r.infof("", objectKey(roleBinding), "deleting role binding %s/%s", roleBinding.Namespace, roleBinding.Name)
+
+	options := metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &roleBinding.ResourceVersion,
+		},
+	}
+
+	err := r.writer(&roleBinding.ObjectMeta).RbacV1().RoleBindings(roleBinding.Namespace).Delete(roleBinding.Name, &options)
+	if err != nil {
+		r.errorf("", objectKey(roleBinding), "error while deleting role binding %s/%s: %s", roleBinding.Namespace, roleBinding.Name, err)
+		return err
+	}
+
+	r.objectStore.Delete(roleBinding)
+	return nil
+}
@@ -0,0 +1,37 @@
+package replicate
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCopyRoleBindingSubjects(t *testing.T) {
+	source := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "deployer"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "deployer"},
+		Subjects: []rbacv1.Subject{
+			{Kind: "ServiceAccount", Namespace: "default", Name: "deployer"},
+			{Kind: "ServiceAccount", Namespace: "ci", Name: "runner"},
+			{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "developers"},
+		},
+	}
+	target := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "deployer"},
+	}
+
+	copyRoleBindingSubjects(target, source)
+	if target.RoleRef != source.RoleRef {
+		t.Errorf("expected role %v, got %v", source.RoleRef, target.RoleRef)
+	}
+	expected := []string{"tenant-a", "ci", ""}
+	for index, subject := range target.Subjects {
+		if subject.Namespace != expected[index] {
+			t.Errorf("subject %s: expected namespace %q, got %q", subject.Name, expected[index], subject.Namespace)
+		}
+	}
+	if source.Subjects[0].Namespace != "default" {
+		t.Errorf("expected the subjects of the source to be unchanged")
+	}
+}
@@ -0,0 +1,246 @@
+package replicate
+
+import (
+	"time"
+
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+var RoleActions *roleActions = &roleActions{}
+
+// NewRoleReplicator creates a new role replicator
+func NewRoleReplicator(client kubernetes.Interface, options ReplicatorOptions) Replicator {
+	repl := objectReplicator{
+		replicatorProps:   newReplicatorProps("role", client, options),
+		replicatorActions: RoleActions,
+	}
+	repl.queue, repl.rateLimiter = newQueue(repl.Name)
+	repl.logger = options.logger().WithValues("kind", repl.kind())
+
+	namespaces := repl.readLegacy(namespaceListWatch(client))
+	namespaceLister := &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := namespaces.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.NamespaceList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.namespaceStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return namespaces.Watch(lo)
+		},
+	}
+	namespaceStore, namespaceController := cache.NewInformer(
+		namespaceLister,
+		&v1.Namespace{},
+		options.ResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    repl.NamespaceAdded,
+			UpdateFunc: repl.NamespaceUpdated,
+			DeleteFunc: repl.NamespaceDeleted,
+		},
+	)
+
+	repl.namespaceStore = namespaceStore
+	repl.namespaceController = namespaceController
+	repl.namespaceLister = namespaceLister
+
+	objects, _ := RoleActions.listWatch(client)
+	objects = repl.readLegacy(repl.pageLists(objects))
+	objects = repl.transformCache(objects)
+	objectListWatch := &cache.ListWatch{
+		// the pages are merged by pageLists, keeping the type of the list
+		DisableChunking: true,
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := objects.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*rbacv1.RoleList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.objectStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return objects.Watch(lo)
+		},
+	}
+
+	objectStore, objectController := cache.NewIndexerInformer(
+		objectListWatch,
+		&rbacv1.Role{},
+		options.ResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    repl.enqueueAdded,
+			UpdateFunc: repl.enqueueUpdated,
+			DeleteFunc: repl.enqueueDeleted,
+		},
+		repl.indexers(),
+	)
+
+	repl.objectStore = repl.stripStore(objectStore)
+	repl.objectController = objectController
+	repl.objectLister = objectListWatch
+
+	registerReplicator(&repl)
+	options.Metrics.addCollector(repl.collectStaleness)
+	if options.Shards != nil {
+		options.Shards.onChange(repl.resync)
+	}
+
+	return &repl
+}
+
+type roleActions struct{}
+
+func (*roleActions) getMeta(object interface{}) *metav1.ObjectMeta {
+	return &object.(*rbacv1.Role).ObjectMeta
+}
+
+// Watches the objects of the watched namespaces, of this cluster or of another one
+func (*roleActions) listWatch(client kubernetes.Interface) (cache.ListerWatcher, runtime.Object) {
+	return scopedListWatch(func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			DisableChunking: true,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.RbacV1().Roles(namespace).List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.RbacV1().Roles(namespace).Watch(lo)
+			},
+		}
+	}), &rbacv1.Role{}
+}
+
+// Copies the rules of the source role
+func copyRoleRules(role *rbacv1.Role, sourceRole *rbacv1.Role) {
+	if sourceRole.Rules != nil {
+		role.Rules = make([]rbacv1.PolicyRule, len(sourceRole.Rules))
+		for index := range sourceRole.Rules {
+			sourceRole.Rules[index].DeepCopyInto(&role.Rules[index])
+		}
+	} else {
+		role.Rules = nil
+	}
+}
+
+func (*roleActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	sourceRole := sourceObject.(*rbacv1.Role)
+	role := object.(*rbacv1.Role).DeepCopy()
+
+	copyRoleRules(role, sourceRole)
+
+	r.infof(objectKey(sourceRole), objectKey(role), "updating role %s/%s", role.Namespace, role.Name)
+
+	role.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	role.Annotations[r.ReplicatedFromVersionAnnotation] = sourceRole.ResourceVersion
+	r.setDataHash(&role.ObjectMeta, sourceRole)
+	r.setSynced(&role.ObjectMeta, sourceRole.ResourceVersion)
+	if val, ok := sourceRole.Annotations[r.ReplicateOnceVersionAnnotation]; ok {
+		role.Annotations[r.ReplicateOnceVersionAnnotation] = val
+	} else {
+		delete(role.Annotations, r.ReplicateOnceVersionAnnotation)
+	}
+	r.propagateAnnotations(&role.ObjectMeta, &sourceRole.ObjectMeta)
+	r.propagateLabels(&role.ObjectMeta, &sourceRole.ObjectMeta)
+
+	s, err := r.writer(&role.ObjectMeta).RbacV1().Roles(role.Namespace).Update(role)
+	if err != nil {
+		r.errorf(objectKey(sourceRole), objectKey(role), "error while updating role %s/%s: %s", role.Namespace, role.Name, err)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*roleActions) clear(r *replicatorProps, object interface{}) error {
+	role := object.(*rbacv1.Role).DeepCopy()
+	role.Rules = nil
+
+	r.infof("", objectKey(role), "clearing role %s/%s", role.Namespace, role.Name)
+
+	role.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	delete(role.Annotations, r.ReplicatedFromVersionAnnotation)
+	delete(role.Annotations, r.ReplicatedDataHashAnnotation)
+	delete(role.Annotations, r.ReplicateOnceVersionAnnotation)
+
+	s, err := r.writer(&role.ObjectMeta).RbacV1().Roles(role.Namespace).Update(role)
+	if err != nil {
+		r.errorf("", objectKey(role), "error while clearing role %s/%s", role.Namespace, role.Name)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*roleActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	sourceRole := sourceObject.(*rbacv1.Role)
+	role := rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       sourceRole.Kind,
+			APIVersion: sourceRole.APIVersion,
+		},
+		ObjectMeta: *meta,
+	}
+
+	if dataObject != nil {
+		copyRoleRules(&role, dataObject.(*rbacv1.Role))
+	}
+
+	r.infof(objectKey(sourceRole), objectKey(&role), "installing role %s/%s", role.Namespace, role.Name)
+
+	var s *rbacv1.Role
+	var err error
+	if role.ResourceVersion == "" {
+		s, err = r.writer(&role.ObjectMeta).RbacV1().Roles(role.Namespace).Create(&role)
+	} else {
+		s, err = r.writer(&role.ObjectMeta).RbacV1().Roles(role.Namespace).Update(&role)
+	}
+
+	if err != nil {
+		r.errorf(objectKey(sourceRole), objectKey(&role), "error while installing role %s/%s: %s", role.Namespace, role.Name, err)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*roleActions) delete(r *replicatorProps, object interface{}) error {
+	role := object.(*rbacv1.Role)
+	r.infof("", objectKey(role), "deleting role %s/%s", role.Namespace, role.Name)
+
+	options := metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &role.ResourceVersion,
+		},
+	}
+
+	err := r.writer(&role.ObjectMeta).RbacV1().Roles(role.Namespace).Delete(role.Name, &options)
+	if err != nil {
+		r.errorf("", objectKey(role), "error while deleting role %s/%s: %s", role.Namespace, role.Name, err)
+		return err
+	}
+
+	r.objectStore.Delete(role)
+	return nil
+}
@@ -0,0 +1,241 @@
+package replicate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/yaml"
+)
+
+// FileRule is a rule of the rules file, replicating the sources it selects as if they had the replicate-to-namespaces
+// or replicate-to-namespaces-selector annotation, for the sources which cannot be annotated
+type FileRule struct {
+	// the kinds of the sources, as "secret" or "configmap", all the kinds when empty
+	Kinds []string `json:"kinds,omitempty"`
+	// the pattern of the namespaces of the sources
+	Namespace string `json:"namespace"`
+	// the pattern of the names of the sources, all the names when empty
+	Name string `json:"name,omitempty"`
+	// the label selector of the sources
+	Selector string `json:"selector,omitempty"`
+	// the namespaces of the targets, as for replicate-to-namespaces
+	TargetNamespaces string `json:"targetNamespaces,omitempty"`
+	// the label selector of the namespaces of the targets, as for replicate-to-namespaces-selector
+	TargetSelector string `json:"targetSelector,omitempty"`
+	// the {source key => target key} map of the renamed keys, as for replicate-key-map
+	KeyMap map[string]string `json:"keyMap,omitempty"`
+	// "replace" or "merge", as for replication-strategy
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// a rule with its compiled patterns
+type compiledRule struct {
+	FileRule
+	// where the rule is defined, for the logs
+	origin    string
+	namespace *regexp.Regexp
+	name      *regexp.Regexp
+	selector  labels.Selector
+	// the namespaces the targets are allowed in
+	targetNamespaces []*regexp.Regexp
+	targetSelector   labels.Selector
+}
+
+// the rules selecting the sources, the ones of the rules file first
+var replicationRules = struct {
+	sync.RWMutex
+	// the rules of the rules file, read again when it changes
+	file []compiledRule
+	// the content the rules of the file were parsed from
+	raw []byte
+	// the rules of the ReplicationRule custom resources, sorted
+	resources []compiledRule
+}{}
+
+// Compiles the patterns and selectors of the rule
+func compileRule(rule FileRule, origin string) (compiledRule, error) {
+	compiled := compiledRule{FileRule: rule, origin: origin, selector: labels.Everything()}
+	var err error
+	if rule.Namespace == "" {
+		return compiled, fmt.Errorf("%s: missing namespace", origin)
+	} else if rule.TargetNamespaces == "" && rule.TargetSelector == "" {
+		return compiled, fmt.Errorf("%s: missing target namespaces or selector", origin)
+	} else if compiled.namespace, err = defaultPatternOptions.compile(rule.Namespace); err != nil {
+		return compiled, fmt.Errorf("%s: invalid namespace (%s): %s", origin, rule.Namespace, err)
+	}
+	if rule.Name != "" {
+		if compiled.name, err = defaultPatternOptions.compile(rule.Name); err != nil {
+			return compiled, fmt.Errorf("%s: invalid name (%s): %s", origin, rule.Name, err)
+		}
+	}
+	if rule.Selector != "" {
+		if compiled.selector, err = labels.Parse(rule.Selector); err != nil {
+			return compiled, fmt.Errorf("%s: invalid selector (%s): %s", origin, rule.Selector, err)
+		}
+	}
+	if rule.TargetSelector != "" {
+		if compiled.targetSelector, err = labels.Parse(rule.TargetSelector); err != nil {
+			return compiled, fmt.Errorf("%s: invalid target selector (%s): %s", origin, rule.TargetSelector, err)
+		}
+	}
+	for _, ns := range strings.Split(rule.TargetNamespaces, ",") {
+		if ns == "" {
+		} else if pattern, err := defaultPatternOptions.compile(ns); err != nil || strings.Contains(ns, "/") {
+			return compiled, fmt.Errorf("%s: invalid target namespace (%s)", origin, ns)
+		} else {
+			compiled.targetNamespaces = append(compiled.targetNamespaces, pattern)
+		}
+	}
+	for key, target := range rule.KeyMap {
+		if key == "" || target == "" {
+			return compiled, fmt.Errorf("%s: invalid key map (%s=%s)", origin, key, target)
+		}
+	}
+	if rule.Strategy != "" && rule.Strategy != "replace" && rule.Strategy != "merge" {
+		return compiled, fmt.Errorf("%s: invalid strategy (%s): expected replace or merge", origin, rule.Strategy)
+	}
+	return compiled, nil
+}
+
+// Parses the rules of a rules file, formatted as YAML with a "rules" list
+func parseRules(raw []byte) ([]compiledRule, error) {
+	file := struct {
+		Rules []FileRule `json:"rules"`
+	}{}
+	if err := yaml.UnmarshalStrict(raw, &file); err != nil {
+		return nil, fmt.Errorf("invalid rules: %s", err)
+	}
+
+	rules := []compiledRule{}
+	for index, rule := range file.Rules {
+		compiled, err := compileRule(rule, fmt.Sprintf("rule %d", index))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+// LoadRules reads the replication rules of the file, and reads them again every period when the file changes,
+// processing again all the objects (never when the period is zero)
+// The rules are kept when the file becomes invalid
+func LoadRules(path string, period time.Duration) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rules, err := parseRules(raw)
+	if err != nil {
+		return err
+	}
+	replicationRules.Lock()
+	replicationRules.file, replicationRules.raw = rules, raw
+	replicationRules.Unlock()
+	if period <= 0 {
+		return nil
+	}
+
+	go wait.Until(func() {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			logf("could not read rules file %s: %s", path, err)
+			return
+		}
+		replicationRules.RLock()
+		changed := !bytes.Equal(raw, replicationRules.raw)
+		replicationRules.RUnlock()
+		if !changed {
+			return
+		}
+		rules, err := parseRules(raw)
+		if err != nil {
+			logf("rules file %s is not reloaded: %s", path, err)
+			return
+		}
+		logf("rules file %s changed: processing all the objects again", path)
+		replicationRules.Lock()
+		replicationRules.file, replicationRules.raw = rules, raw
+		replicationRules.Unlock()
+		resyncReplicators()
+	}, period, wait.NeverStop)
+	return nil
+}
+
+// Returns the first rule selecting the object as a source, nil if none does
+// The copies and the objects with replication annotations are never selected
+func (r *replicatorProps) matchRule(object *metav1.ObjectMeta) *compiledRule {
+	for _, annotation := range []string{r.ReplicatedByAnnotation, r.ReplicateFromAnnotation, r.ReplicateToAnnotation,
+		r.ReplicateToNamespacesAnnotation, r.ReplicateToNamespacesSelectorAnnotation} {
+		if _, ok := object.Annotations[annotation]; ok {
+			return nil
+		}
+	}
+
+	replicationRules.RLock()
+	defer replicationRules.RUnlock()
+	for _, rules := range [][]compiledRule{replicationRules.file, replicationRules.resources} {
+		for index := range rules {
+			rule := &rules[index]
+			if len(rule.Kinds) > 0 && !matchKind(rule.Kinds, r.kind()) {
+			} else if !rule.namespace.MatchString(object.Namespace) {
+			} else if rule.name != nil && !rule.name.MatchString(object.Name) {
+			} else if rule.selector.Matches(labels.Set(object.Labels)) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// Returns true if a rule may select the sources of the namespace
+func (r *replicatorProps) ruleSelectsNamespace(namespace string) bool {
+	replicationRules.RLock()
+	defer replicationRules.RUnlock()
+	for _, rules := range [][]compiledRule{replicationRules.file, replicationRules.resources} {
+		for index := range rules {
+			rule := &rules[index]
+			if (len(rule.Kinds) == 0 || matchKind(rule.Kinds, r.kind())) && rule.namespace.MatchString(namespace) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Processes again all the objects of all the replicators, after the rules changed
+func resyncReplicators() {
+	for _, r := range registeredReplicators() {
+		r.resync()
+	}
+}
+
+// Returns true if a rule selects the source and allows its replication to the namespace
+// The rules allow the replication to the namespaces of their targets only
+func (r *replicatorProps) ruleAllows(sourceObject *metav1.ObjectMeta, namespace string) bool {
+	rule := r.matchRule(sourceObject)
+	if rule == nil {
+		return false
+	} else if rule.targetSelector != nil && rule.targetSelector.Matches(r.namespaceLabels(namespace)) {
+		return true
+	}
+	return matchPatterns(rule.targetNamespaces, namespace)
+}
+
+// Returns true if the kind is one of the kinds
+func matchKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if strings.Replace(strings.ToLower(k), " ", "", -1) == kind {
+			return true
+		}
+	}
+	return false
+}
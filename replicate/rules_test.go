@@ -0,0 +1,148 @@
+package replicate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestReplicationRules(t *testing.T) {
+	if _, err := parseRules([]byte("rules:\n- namespace: cert-manager\n")); err == nil {
+		t.Errorf("expected an error for a rule without targets")
+	}
+	if _, err := parseRules([]byte("rules:\n- namespace: cert-manager\n  targetNamespace: team-.*\n")); err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules.yaml")
+	ioutil.WriteFile(path, []byte(`rules:
+- kinds: [secret]
+  namespace: cert-manager
+  selector: app=wildcard
+  targetNamespaces: team-.*
+`), 0644)
+	if err := LoadRules(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { replicationRules.file = nil }()
+
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix(""),
+		namespaceStore: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	source := &metav1.ObjectMeta{Namespace: "cert-manager", Name: "wildcard-tls", Labels: map[string]string{"app": "wildcard"}}
+	if _, patterns, err := r.getReplicationTargets(source); err != nil || len(patterns) != 1 || patterns[0].MatchNamespace("team-a") != "team-a/wildcard-tls" {
+		t.Errorf("expected the targets of the rule, got %v %v", patterns, err)
+	}
+	if ok, _ := r.isReplicationAllowed(&metav1.ObjectMeta{Namespace: "team-a", Name: "wildcard-tls"}, source); !ok {
+		t.Errorf("expected the rule to allow the replication to its targets")
+	}
+	if ok, _ := r.isReplicationAllowed(&metav1.ObjectMeta{Namespace: "other", Name: "wildcard-tls"}, source); ok {
+		t.Errorf("expected the rule not to allow the replication to other namespaces")
+	}
+
+	// the annotations win over the rules
+	source.Annotations = map[string]string{r.ReplicateToNamespacesAnnotation: "other"}
+	if targets, patterns, _ := r.getReplicationTargets(source); len(targets) != 1 || len(patterns) != 0 {
+		t.Errorf("expected the targets of the annotation, got %v %v", targets, patterns)
+	}
+	// the rules are restricted to their kinds
+	configMaps := &replicatorProps{Name: "config map", annotationNames: r.annotationNames, namespaceStore: r.namespaceStore}
+	source.Annotations = nil
+	if configMaps.matchRule(source) != nil {
+		t.Errorf("expected the rule not to select the config maps")
+	}
+}
+
+func TestReplicationRuleResources(t *testing.T) {
+	c := &RuleController{store: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	c.store.Add(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "cert-manager", "name": "wildcard"},
+		"spec": map[string]interface{}{
+			"kinds":      []interface{}{"secret"},
+			"name":       "wildcard-tls",
+			"namespaces": []interface{}{"team-.*"},
+			"keyMap":     map[string]interface{}{"tls.crt": "ca.crt"},
+			"strategy":   "merge",
+		},
+	}})
+	// the rules without source are ignored
+	c.store.Add(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "cert-manager", "name": "everything"},
+		"spec":     map[string]interface{}{"namespaces": []interface{}{".*"}},
+	}})
+	c.rulesChanged()
+	defer func() { replicationRules.resources = nil }()
+	if len(replicationRules.resources) != 1 {
+		t.Fatalf("expected a single valid rule, got %d", len(replicationRules.resources))
+	}
+
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix(""),
+		namespaceStore: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	source := &metav1.ObjectMeta{Namespace: "cert-manager", Name: "wildcard-tls"}
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "wildcard-tls"}
+	if ok, _ := r.isReplicationAllowed(target, source); !ok {
+		t.Errorf("expected the rule to allow the replication to its targets")
+	}
+	// the rules only select the sources of their namespace
+	if r.matchRule(&metav1.ObjectMeta{Namespace: "other", Name: "wildcard-tls"}) != nil {
+		t.Errorf("expected the rule not to select the sources of other namespaces")
+	}
+	if keyMap, _ := r.getKeyMap(target, source); keyMap["tls.crt"] != "ca.crt" {
+		t.Errorf("expected the key map of the rule, got %v", keyMap)
+	}
+	if merged, _ := r.isMerged(target, source); !merged {
+		t.Errorf("expected the strategy of the rule")
+	}
+}
+
+func TestReplicationRuleStatus(t *testing.T) {
+	rule := &ReplicationRule{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "wildcard", Generation: 2},
+		Spec:       RuleSpec{Name: "wildcard-tls", Namespaces: []string{"team-.*"}},
+	}
+	file, _ := rule.fileRule()
+	compiled, _ := compileRule(file, "rule cert-manager/wildcard")
+	replicationRules.resources = []compiledRule{compiled}
+	defer func() { replicationRules.resources = nil }()
+
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			retries:         map[string]*retryState{"team-b/wildcard-tls": {LastError: "forbidden"}},
+			collisions:      map[collisionKey]string{{"cert-manager/wildcard-tls", "team-c/wildcard-tls"}: "team-c/other"},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.namespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, namespace := range []string{"cert-manager", "team-a", "team-b", "team-c"} {
+		r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	}
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "wildcard-tls", ResourceVersion: "3"}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "wildcard-tls", Annotations: map[string]string{
+		r.ReplicatedByAnnotation: "cert-manager/wildcard-tls", r.ReplicatedFromVersionAnnotation: "3"}}})
+
+	status := ruleStatus(rule, []*objectReplicator{r})
+	if status.Synced != 1 || status.Failed != 1 || status.Blocked != 1 || status.ObservedGeneration != 2 {
+		t.Errorf("expected a synced, a failed and a blocked target, got %+v", status)
+	}
+	if len(status.Targets) != 3 || status.Targets[0].Condition != ConditionFailed || status.Targets[0].Reason != "forbidden" {
+		t.Errorf("expected the failed target first, got %+v", status.Targets)
+	}
+
+	rule.Spec.Name = ""
+	if status := ruleStatus(rule, []*objectReplicator{r}); status.Message == "" {
+		t.Errorf("expected the error of an invalid rule")
+	}
+}
@@ -0,0 +1,177 @@
+package replicate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the version of a scheduled source last released to its targets
+type sourceSync struct {
+	// the version of the source which can be replicated
+	version string
+	// the time the version was released
+	at time.Time
+}
+
+// a daily window of time, in UTC, during which the changes of a source are replicated
+type syncWindow struct {
+	// the days of the window, all the days if nil
+	days map[time.Weekday]bool
+	// the start and end of the window, since midnight
+	start time.Duration
+	end   time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parses a time of the day, ex: "02:30"
+func parseTimeOfDay(val string) (time.Duration, error) {
+	t, err := time.Parse("15:04", val)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Parses a window of time, ex: "02:00-04:00", "Mon-Fri 22:00-02:00" or "Sat,Sun 00:00-23:59"
+func parseSyncWindow(val string) (*syncWindow, error) {
+	window := &syncWindow{}
+	fields := strings.Fields(val)
+	if len(fields) == 2 {
+		window.days = map[time.Weekday]bool{}
+		for _, days := range strings.Split(fields[0], ",") {
+			bounds := strings.SplitN(strings.ToLower(days), "-", 2)
+			first, ok := weekdays[bounds[0]]
+			last := first
+			if ok && len(bounds) == 2 {
+				last, ok = weekdays[bounds[1]]
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid days %s", days)
+			}
+			for day := first; ; day = (day + 1) % 7 {
+				window.days[day] = true
+				if day == last {
+					break
+				}
+			}
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return nil, fmt.Errorf("expected [days] hh:mm-hh:mm")
+	}
+
+	bounds := strings.SplitN(fields[0], "-", 2)
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("expected [days] hh:mm-hh:mm")
+	}
+	var err error
+	if window.start, err = parseTimeOfDay(bounds[0]); err != nil {
+		return nil, err
+	} else if window.end, err = parseTimeOfDay(bounds[1]); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// Returns the first time of the window after the given time, which is itself when it is in the window
+func (w *syncWindow) next(now time.Time) time.Time {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	length := w.end - w.start
+	// the window goes past midnight
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+
+	for day := -1; day <= 7; day++ {
+		start := midnight.AddDate(0, 0, day).Add(w.start)
+		if w.days != nil && !w.days[start.Weekday()] {
+			continue
+		}
+		if !now.Before(start) && now.Before(start.Add(length)) {
+			return now
+		} else if start.After(now) {
+			return start
+		}
+	}
+	return now
+}
+
+// Returns the schedule of the replication of the changes of the source, if any
+func (r *replicatorProps) syncSchedule(sourceMeta *metav1.ObjectMeta) (time.Duration, *syncWindow, error) {
+	var period time.Duration
+	var window *syncWindow
+	var err error
+
+	if val, ok := sourceMeta.Annotations[r.ReplicateSyncPeriodAnnotation]; ok {
+		if period, err = time.ParseDuration(val); err != nil || period < 0 {
+			return 0, nil, fmt.Errorf("%s/%s has illformed annotation %s (%s): expected a duration",
+				sourceMeta.Namespace, sourceMeta.Name, r.ReplicateSyncPeriodAnnotation, val)
+		}
+	}
+	if val, ok := sourceMeta.Annotations[r.ReplicateSyncWindowAnnotation]; ok {
+		if window, err = parseSyncWindow(val); err != nil {
+			return 0, nil, fmt.Errorf("%s/%s has illformed annotation %s (%s): %s",
+				sourceMeta.Namespace, sourceMeta.Name, r.ReplicateSyncWindowAnnotation, val, err)
+		}
+	}
+	return period, window, nil
+}
+
+// Returns how long the current version of the source must wait before being replicated to its existing targets
+// A new version is released at most once per sync period, and only during the sync window
+func (r *objectReplicator) syncDelay(sourceMeta *metav1.ObjectMeta, now time.Time) (time.Duration, error) {
+	period, window, err := r.syncSchedule(sourceMeta)
+	if err != nil || period == 0 && window == nil {
+		return 0, err
+	}
+
+	key := objectKey(sourceMeta)
+	last, ok := r.syncs[key]
+	// this version was released already
+	if ok && r.isSourceVersion(sourceMeta, last.version) {
+		return 0, nil
+	}
+
+	due := now
+	if ok && last.at.Add(period).After(due) {
+		due = last.at.Add(period)
+	}
+	if window != nil {
+		due = window.next(due)
+	}
+	if due.After(now) {
+		return due.Sub(now), nil
+	}
+
+	if r.syncs == nil {
+		r.syncs = map[string]sourceSync{}
+	}
+	r.syncs[key] = sourceSync{version: sourceMeta.ResourceVersion, at: now}
+	return 0, nil
+}
+
+// Returns false if the changes of the source cannot be replicated to an existing target yet,
+// in which case the object with retryKey is queued again once they can
+func (r *objectReplicator) isSyncDue(sourceMeta *metav1.ObjectMeta, retryKey string) (bool, error) {
+	delay, err := r.syncDelay(sourceMeta, time.Now())
+	// the changes are replicated right away, until the schedule is fixed
+	if err != nil {
+		r.errorf(objectKey(sourceMeta), "", "could not parse %s %s: %s", r.Name, objectKey(sourceMeta), err)
+		r.reportError(err)
+		return true, nil
+	} else if delay > 0 {
+		r.queue.AddAfter(retryKey, delay)
+		return false, fmt.Errorf("the changes of %s are replicated on its schedule, next at %s",
+			objectKey(sourceMeta), time.Now().Add(delay).UTC().Format(time.RFC3339))
+	}
+	return true, nil
+}
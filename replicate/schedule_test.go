@@ -0,0 +1,77 @@
+package replicate
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSyncWindow(t *testing.T) {
+	// a monday
+	monday := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		window string
+		now    time.Time
+		next   time.Time
+	}{
+		{"02:00-04:00", monday, time.Date(2019, 7, 2, 2, 0, 0, 0, time.UTC)},
+		{"11:00-13:00", monday, monday},
+		{"22:00-02:00", monday.Add(13 * time.Hour), monday.Add(13 * time.Hour)},
+		{"22:00-02:00", monday.Add(15 * time.Hour), time.Date(2019, 7, 2, 22, 0, 0, 0, time.UTC)},
+		{"Sat,Sun 10:00-14:00", monday, time.Date(2019, 7, 6, 10, 0, 0, 0, time.UTC)},
+		{"Fri-Mon 10:00-14:00", monday, monday},
+		{"Tue-Thu 10:00-14:00", monday, time.Date(2019, 7, 2, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		window, err := parseSyncWindow(c.window)
+		if err != nil {
+			t.Errorf("could not parse %s: %s", c.window, err)
+		} else if next := window.next(c.now); !next.Equal(c.next) {
+			t.Errorf("expected window %s after %s to be %s, got %s", c.window, c.now, c.next, next)
+		}
+	}
+
+	for _, val := range []string{"", "2:00", "02:00-25:00", "Someday 02:00-04:00", "Mon 02:00-04:00 UTC"} {
+		if _, err := parseSyncWindow(val); err == nil {
+			t.Errorf("expected an error for window %q", val)
+		}
+	}
+}
+
+func TestSyncDelay(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix("")}}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "10", Annotations: map[string]string{
+		r.ReplicateSyncPeriodAnnotation: "1h",
+	}}
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	// the first change is replicated right away
+	if delay, err := r.syncDelay(source, now); err != nil || delay != 0 {
+		t.Errorf("expected no delay, got %s, %v", delay, err)
+	}
+	// the next one waits for the period
+	source.ResourceVersion = "11"
+	if delay, err := r.syncDelay(source, now.Add(10*time.Minute)); err != nil || delay != 50*time.Minute {
+		t.Errorf("expected a delay of 50m, got %s, %v", delay, err)
+	}
+	if delay, err := r.syncDelay(source, now.Add(time.Hour)); err != nil || delay != 0 {
+		t.Errorf("expected no delay after the period, got %s, %v", delay, err)
+	}
+	// the released version is replicated to all the targets
+	if delay, err := r.syncDelay(source, now.Add(time.Hour+time.Minute)); err != nil || delay != 0 {
+		t.Errorf("expected no delay for the released version, got %s, %v", delay, err)
+	}
+
+	// the changes wait for the window
+	source.ResourceVersion = "12"
+	source.Annotations[r.ReplicateSyncWindowAnnotation] = "02:00-04:00"
+	if delay, err := r.syncDelay(source, now.Add(3*time.Hour)); err != nil || delay != 11*time.Hour {
+		t.Errorf("expected a delay of 11h, got %s, %v", delay, err)
+	}
+
+	source.Annotations[r.ReplicateSyncPeriodAnnotation] = "hourly"
+	if _, err := r.syncDelay(source, now); err == nil {
+		t.Errorf("expected an error for an illformed period")
+	}
+}
@@ -0,0 +1,178 @@
+package replicate
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// the only namespaces the objects are watched in, all the namespaces when empty
+var watchedNamespaces []string
+
+// WatchNamespaces restricts the replicators to the objects of the namespaces, each namespace being listed and watched
+// on its own, so that no cluster-wide permission is needed on the replicated objects
+// Must be called before the replicators are created
+func WatchNamespaces(namespaces []string) {
+	watchedNamespaces = namespaces
+}
+
+// Returns the lister watcher of the objects of the watched namespaces, from the one of a single namespace
+// The objects of all the namespaces are listed and watched at once when no namespace is watched
+func scopedListWatch(listWatch func(namespace string) cache.ListerWatcher) cache.ListerWatcher {
+	if len(watchedNamespaces) == 0 {
+		return listWatch("")
+	} else if len(watchedNamespaces) == 1 {
+		return listWatch(watchedNamespaces[0])
+	}
+	lw := &namespacesListWatch{listWatches: map[string]cache.ListerWatcher{}, versions: map[string]string{}}
+	for _, namespace := range watchedNamespaces {
+		lw.listWatches[namespace] = listWatch(namespace)
+	}
+	return lw
+}
+
+// Returns the lister watcher of the namespaces, only the watched ones if any
+func namespaceListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return scopedListWatch(func(namespace string) cache.ListerWatcher {
+		restrict := func(lo metav1.ListOptions) metav1.ListOptions {
+			if namespace != "" {
+				lo.FieldSelector = fields.OneTermEqualSelector("metadata.name", namespace).String()
+			}
+			return lo
+		}
+		return namespaceMetadataListWatch(client, restrict)
+	})
+}
+
+// a lister watcher merging the lists and the watches of several namespaces
+// The resource versions are tracked by namespace, each namespace being watched again from its own version
+type namespacesListWatch struct {
+	listWatches map[string]cache.ListerWatcher
+	lock        sync.Mutex
+	// the resource version of each namespace, of its last list or its last event
+	versions map[string]string
+}
+
+// Lists the objects of each namespace, returned as a single list
+func (lw *namespacesListWatch) List(lo metav1.ListOptions) (runtime.Object, error) {
+	var merged runtime.Object
+	items := []runtime.Object{}
+	versions := map[string]string{}
+	for _, namespace := range watchedNamespaces {
+		// each namespace is listed whole, with its own pages
+		list, err := listPages(lw.listWatches[namespace].List, lo)
+		if err != nil {
+			return nil, fmt.Errorf("could not list namespace %s: %s", namespace, err)
+		}
+		objects, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		accessor, err := meta.ListAccessor(list)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, objects...)
+		versions[namespace] = accessor.GetResourceVersion()
+		merged = list
+	}
+	if err := meta.SetList(merged, items); err != nil {
+		return nil, err
+	}
+
+	lw.lock.Lock()
+	lw.versions = versions
+	lw.lock.Unlock()
+	return merged, nil
+}
+
+// Watches each namespace from its own resource version, the events of all the namespaces being merged
+// The merged watch stops as soon as the watch of a namespace stops, so that all of them are watched again
+func (lw *namespacesListWatch) Watch(lo metav1.ListOptions) (watch.Interface, error) {
+	merged := &namespacesWatch{result: make(chan watch.Event), stop: make(chan struct{})}
+	watches := map[string]watch.Interface{}
+	for _, namespace := range watchedNamespaces {
+		options := lo
+		lw.lock.Lock()
+		if version, ok := lw.versions[namespace]; ok {
+			options.ResourceVersion = version
+		}
+		lw.lock.Unlock()
+		w, err := lw.listWatches[namespace].Watch(options)
+		if err != nil {
+			for _, w := range watches {
+				w.Stop()
+			}
+			return nil, fmt.Errorf("could not watch namespace %s: %s", namespace, err)
+		}
+		watches[namespace] = w
+	}
+
+	var wg sync.WaitGroup
+	for namespace, w := range watches {
+		wg.Add(1)
+		go func(namespace string, w watch.Interface) {
+			defer wg.Done()
+			defer merged.Stop()
+			lw.forward(namespace, w, merged)
+		}(namespace, w)
+	}
+	go func() {
+		<-merged.stop
+		for _, w := range watches {
+			w.Stop()
+		}
+		wg.Wait()
+		close(merged.result)
+	}()
+	return merged, nil
+}
+
+// Forwards the events of the watch of the namespace, until either watch stops
+func (lw *namespacesListWatch) forward(namespace string, w watch.Interface, merged *namespacesWatch) {
+	for {
+		select {
+		case <-merged.stop:
+			return
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			select {
+			case merged.result <- event:
+			case <-merged.stop:
+				return
+			}
+			if event.Type == watch.Error {
+				continue
+			} else if accessor, err := meta.Accessor(event.Object); err == nil {
+				lw.lock.Lock()
+				lw.versions[namespace] = accessor.GetResourceVersion()
+				lw.lock.Unlock()
+			}
+		}
+	}
+}
+
+// the merged watch of several namespaces
+type namespacesWatch struct {
+	result chan watch.Event
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// Stops the watches of all the namespaces
+func (w *namespacesWatch) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+func (w *namespacesWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
@@ -0,0 +1,65 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWatchNamespaces(t *testing.T) {
+	WatchNamespaces([]string{"team-a", "team-b"})
+	defer WatchNamespaces(nil)
+
+	versions := map[string]string{"team-a": "10", "team-b": "20"}
+	watched := map[string]string{}
+	watches := map[string]*watch.FakeWatcher{}
+	lw := scopedListWatch(func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				list := &v1.SecretList{Items: []v1.Secret{{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "tls"}}}}
+				list.ResourceVersion = versions[namespace]
+				return list, nil
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				watched[namespace] = lo.ResourceVersion
+				watches[namespace] = watch.NewFake()
+				return watches[namespace], nil
+			},
+		}
+	})
+
+	object, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := object.(*v1.SecretList); len(list.Items) != 2 || list.Items[0].Namespace != "team-a" || list.Items[1].Namespace != "team-b" {
+		t.Errorf("expected the secrets of both namespaces, got %v", list.Items)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{ResourceVersion: "20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watched["team-a"] != "10" || watched["team-b"] != "20" {
+		t.Errorf("expected each namespace to be watched from its list, got %v", watched)
+	}
+	go watches["team-b"].Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "db", ResourceVersion: "25"}})
+	if event := <-w.ResultChan(); event.Object.(*v1.Secret).Name != "db" {
+		t.Errorf("expected the event of team-b, got %v", event)
+	}
+
+	// a stopped namespace stops the merged watch
+	watches["team-a"].Stop()
+	for range w.ResultChan() {
+	}
+	if _, err := lw.Watch(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if watched["team-a"] != "10" || watched["team-b"] != "25" {
+		t.Errorf("expected each namespace to be watched again from its last event, got %v", watched)
+	}
+}
@@ -0,0 +1,162 @@
+package replicate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// the public key of the sealed-secrets controller, the secrets replicated to the sealed namespaces are encrypted with
+// nil to write them as secrets
+var sealingKey *rsa.PublicKey
+
+// SealSecrets sets the PEM encoded certificate of the sealed-secrets controller, as fetched by kubeseal --fetch-cert
+// The secrets replicated to the namespaces annotated with replicate-sealed are written as sealed secrets
+func SealSecrets(certificate []byte) error {
+	block, _ := pem.Decode(certificate)
+	if block == nil {
+		return fmt.Errorf("invalid sealing certificate: no PEM data")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid sealing certificate: %s", err)
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("invalid sealing certificate: expected an RSA public key")
+	}
+	sealingKey = key
+	return nil
+}
+
+// Returns true if the secrets replicated to the namespace are written as sealed secrets, from its replicate-sealed
+// annotation
+func (r *replicatorProps) isSealed(namespace string) bool {
+	if sealingKey == nil || r.kind() != "secret" {
+		return false
+	}
+	object, exists, err := r.namespaceStore.GetByKey(namespace)
+	if err != nil || !exists {
+		return false
+	}
+	sealed, _ := strconv.ParseBool(object.(*v1.Namespace).Annotations[r.ReplicateSealedAnnotation])
+	return sealed
+}
+
+// Encrypts the value for the sealed-secrets controller, as kubeseal does: the value is encrypted with a random
+// session key, encrypted with the public key and the label
+func sealValue(key *rsa.PublicKey, label []byte, value []byte) (string, error) {
+	sessionKey := make([]byte, 32)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, sessionKey, label)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := make([]byte, 2, 2+len(encryptedKey)+len(value)+aead.Overhead())
+	binary.BigEndian.PutUint16(sealed, uint16(len(encryptedKey)))
+	sealed = append(sealed, encryptedKey...)
+	// each session key encrypts a single value
+	sealed = aead.Seal(sealed, make([]byte, aead.NonceSize()), value, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Returns the sealed secret with the encrypted data of the secret, whose template has the metadata of the secret
+// The data is encrypted for the namespace and the name of the secret, the strict scope of kubeseal
+func sealedSecret(secret *v1.Secret) (map[string]interface{}, error) {
+	label := []byte(fmt.Sprintf("%s/%s", secret.Namespace, secret.Name))
+	encrypted := map[string]string{}
+	for key, value := range secret.Data {
+		sealed, err := sealValue(sealingKey, label, value)
+		if err != nil {
+			return nil, err
+		}
+		encrypted[key] = sealed
+	}
+
+	template := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        secret.Name,
+			"namespace":   secret.Namespace,
+			"labels":      secret.Labels,
+			"annotations": secret.Annotations,
+		},
+	}
+	if secret.Type != "" {
+		template["type"] = secret.Type
+	}
+	return map[string]interface{}{
+		"apiVersion": "bitnami.com/v1alpha1",
+		"kind":       "SealedSecret",
+		"metadata": map[string]interface{}{
+			"name":      secret.Name,
+			"namespace": secret.Namespace,
+		},
+		"spec": map[string]interface{}{
+			"encryptedData": encrypted,
+			"template":      template,
+		},
+	}, nil
+}
+
+// Writes the sealed secret of the secret instead of the secret, applied so that it is created or updated
+// The secret is unsealed by the controller, and is returned as it will be
+func (r *replicatorProps) writeSealed(secret *v1.Secret) (*v1.Secret, error) {
+	sealed, err := sealedSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	r.infof("", objectKey(secret), "sealing secret %s/%s", secret.Namespace, secret.Name)
+	err = r.writer(&secret.ObjectMeta).CoreV1().RESTClient().Patch(types.ApplyPatchType).
+		AbsPath("/apis/bitnami.com/v1alpha1/namespaces", secret.Namespace, "sealedsecrets", secret.Name).
+		Param("fieldManager", fieldManager).
+		Body(body).
+		Do().
+		Error()
+	if err != nil {
+		return nil, applyError(err)
+	}
+	return secret, nil
+}
+
+// Deletes the sealed secret of the secret, the controller deleting the secret
+func (r *replicatorProps) deleteSealed(secret *v1.Secret) error {
+	err := r.writer(&secret.ObjectMeta).CoreV1().RESTClient().Delete().
+		AbsPath("/apis/bitnami.com/v1alpha1/namespaces", secret.Namespace, "sealedsecrets", secret.Name).
+		Body(&metav1.DeleteOptions{}).
+		Do().
+		Error()
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
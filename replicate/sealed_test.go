@@ -0,0 +1,96 @@
+package replicate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Decrypts the value as the sealed-secrets controller
+func unsealValue(t *testing.T, key *rsa.PrivateKey, label string, value string) string {
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	length := int(binary.BigEndian.Uint16(sealed))
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, sealed[2:2+length], []byte(label))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(sessionKey)
+	aead, _ := cipher.NewGCM(block)
+	plain, err := aead.Open(nil, make([]byte, aead.NonceSize()), sealed[2+length:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(plain)
+}
+
+func TestSealedSecret(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "sealed-secret"},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SealSecrets([]byte("not a certificate")); err == nil {
+		t.Errorf("expected an error on an invalid certificate")
+	}
+	if err := SealSecrets(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { sealingKey = nil }()
+
+	r := &replicatorProps{Name: "secret", annotationNames: annotationsWithPrefix(""),
+		namespaceStore: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "gitops",
+		Annotations: map[string]string{r.ReplicateSealedAnnotation: "true"}}})
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	if !r.isSealed("gitops") || r.isSealed("team-a") {
+		t.Errorf("expected only the annotated namespace to be sealed")
+	}
+	if configMaps := (&replicatorProps{Name: "config map", annotationNames: r.annotationNames, namespaceStore: r.namespaceStore}); configMaps.isSealed("gitops") {
+		t.Errorf("expected the config maps not to be sealed")
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "gitops", Name: "database",
+			Annotations: map[string]string{r.ReplicatedByAnnotation: "default/database"}},
+		Type: v1.SecretTypeBasicAuth,
+		Data: map[string][]byte{"password": []byte("secret")},
+	}
+	sealed, err := sealedSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := sealed["spec"].(map[string]interface{})
+	encrypted := spec["encryptedData"].(map[string]string)
+	if password := unsealValue(t, key, "gitops/database", encrypted["password"]); password != "secret" {
+		t.Errorf("expected the sealed password, got %s", password)
+	}
+	metadata := spec["template"].(map[string]interface{})["metadata"].(map[string]interface{})
+	if annotations := metadata["annotations"].(map[string]string); annotations[r.ReplicatedByAnnotation] != "default/database" {
+		t.Errorf("expected the annotations of the secret in the template, got %v", annotations)
+	}
+	if secretType := spec["template"].(map[string]interface{})["type"]; secretType != v1.SecretTypeBasicAuth {
+		t.Errorf("expected the type of the secret, got %v", secretType)
+	}
+}
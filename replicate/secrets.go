@@ -1,7 +1,6 @@
 package replicate
 
 import (
-	"log"
 	"time"
 
 	"k8s.io/api/core/v1"
@@ -15,149 +14,247 @@ import (
 var SecretActions *secretActions = &secretActions{}
 
 // NewSecretReplicator creates a new secret replicator
-func NewSecretReplicator(client kubernetes.Interface, resyncPeriod time.Duration, allowAll bool) Replicator {
+func NewSecretReplicator(client kubernetes.Interface, options ReplicatorOptions) Replicator {
 	repl := objectReplicator{
-		replicatorProps: replicatorProps{
-			Name:            "secret",
-			allowAll:        allowAll,
-			client:          client,
-
-			targetsFrom:     make(map[string][]string),
-			targetsTo:       make(map[string][]string),
-
-			watchedTargets:  make(map[string][]string),
-			watchedPatterns: make(map[string][]targetPattern),
-		},
+		replicatorProps:   newReplicatorProps("secret", client, options),
 		replicatorActions: SecretActions,
 	}
-
-	namespaceStore, namespaceController := cache.NewInformer(
-		&cache.ListWatch{
-			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
-				list, err := client.CoreV1().Namespaces().List(lo)
-				if err != nil {
-					return list, err
-				}
-				// populate the store already, to avoid believing some items are deleted
-				copy := make([]interface{}, len(list.Items))
-				for index := range list.Items {
-					copy[index] = &list.Items[index]
-				}
-				repl.namespaceStore.Replace(copy, "init")
-				return list, err
-			},
-			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
-				return client.CoreV1().Namespaces().Watch(lo)
-			},
+	repl.queue, repl.rateLimiter = newQueue(repl.Name)
+	repl.logger = options.logger().WithValues("kind", repl.kind())
+
+	namespaces := repl.readLegacy(namespaceListWatch(client))
+	namespaceLister := &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := namespaces.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.NamespaceList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.namespaceStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return namespaces.Watch(lo)
 		},
+	}
+	namespaceStore, namespaceController := cache.NewInformer(
+		namespaceLister,
 		&v1.Namespace{},
-		resyncPeriod,
+		options.ResyncPeriod,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc:    repl.NamespaceAdded,
-			UpdateFunc: func(old interface{}, new interface{}) {},
-			DeleteFunc: func(obj interface{}) {},
+			UpdateFunc: repl.NamespaceUpdated,
+			DeleteFunc: repl.NamespaceDeleted,
 		},
 	)
 
 	repl.namespaceStore = namespaceStore
 	repl.namespaceController = namespaceController
-
-	objectStore, objectController := cache.NewInformer(
-		&cache.ListWatch{
-			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
-				list, err := client.CoreV1().Secrets("").List(lo)
-				if err != nil {
-					return list, err
-				}
-				// populate the store already, to avoid believing some items are deleted
-				copy := make([]interface{}, len(list.Items))
-				for index := range list.Items {
-					copy[index] = &list.Items[index]
-				}
-				repl.objectStore.Replace(copy, "init")
-				return list, err
-			},
-			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
-				return client.CoreV1().Secrets("").Watch(lo)
-			},
+	repl.namespaceLister = namespaceLister
+
+	objects, _ := SecretActions.listWatch(client)
+	objects = repl.readLegacy(repl.pageLists(objects))
+	objects = repl.transformCache(objects)
+	objectListWatch := &cache.ListWatch{
+		// the pages are merged by pageLists, keeping the type of the list
+		DisableChunking: true,
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := objects.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.SecretList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.objectStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return objects.Watch(lo)
 		},
+	}
+
+	objectStore, objectController := cache.NewIndexerInformer(
+		objectListWatch,
 		&v1.Secret{},
-		resyncPeriod,
+		options.ResyncPeriod,
 		cache.ResourceEventHandlerFuncs{
-			AddFunc:    repl.ObjectAdded,
-			UpdateFunc: func(old interface{}, new interface{}) { repl.ObjectAdded(new) },
-			DeleteFunc: repl.ObjectDeleted,
+			AddFunc:    repl.enqueueAdded,
+			UpdateFunc: repl.enqueueUpdated,
+			DeleteFunc: repl.enqueueDeleted,
 		},
+		repl.indexers(),
 	)
 
-	repl.objectStore = objectStore
+	repl.objectStore = repl.stripStore(objectStore)
 	repl.objectController = objectController
+	repl.objectLister = objectListWatch
+
+	registerReplicator(&repl)
+	options.Metrics.addCollector(repl.collectStaleness)
+	options.Metrics.addCollector(repl.collectCertificates)
+	if options.Shards != nil {
+		options.Shards.onChange(repl.resync)
+	}
 
 	return &repl
 }
 
-type secretActions struct {}
+type secretActions struct{}
 
 func (*secretActions) getMeta(object interface{}) *metav1.ObjectMeta {
 	return &object.(*v1.Secret).ObjectMeta
 }
 
-func (*secretActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+// Watches the objects of the watched namespaces, of this cluster or of another one
+func (*secretActions) listWatch(client kubernetes.Interface) (cache.ListerWatcher, runtime.Object) {
+	return scopedListWatch(func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			DisableChunking: true,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Secrets(namespace).List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Secrets(namespace).Watch(lo)
+			},
+		}
+	}), &v1.Secret{}
+}
+
+func (a *secretActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	sourceSecret := sourceObject.(*v1.Secret)
+	replicated, err := a.replicated(r, object, sourceObject)
+	if err != nil {
+		return err
+	}
+	secret := replicated.(*v1.Secret)
+
+	r.infof(objectKey(sourceSecret), objectKey(secret), "updating secret %s/%s", secret.Namespace, secret.Name)
+
+	var s *v1.Secret
+	if serverSideApply && !r.isImmutable(&secret.ObjectMeta) && !a.typeChanged(r, secret) && !r.isSealed(secret.Namespace) {
+		s = &v1.Secret{}
+		err = r.apply("secrets", secret, r.appliedMeta(&secret.ObjectMeta, &sourceSecret.ObjectMeta), "v1", "Secret", s)
+	} else {
+		s, err = a.write(r, secret)
+	}
+	if err != nil {
+		r.errorf(objectKey(sourceSecret), objectKey(secret), "error while updating secret %s/%s: %s", secret.Namespace, secret.Name, err)
+		return err
+	}
+	r.logDiff(objectKey(sourceSecret), objectKey(secret), secretDiff(object.(*v1.Secret), secret))
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+// Returns a copy of the target with the data of the source, without writing it
+func (*secretActions) replicated(r *replicatorProps, object interface{}, sourceObject interface{}) (interface{}, error) {
 	sourceSecret := sourceObject.(*v1.Secret)
 	secret := object.(*v1.Secret).DeepCopy()
 
+	if err := r.validateTLS(sourceSecret); err != nil {
+		return nil, err
+	}
+	keyMap, err := r.getKeyMap(&secret.ObjectMeta, &sourceSecret.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	transform, err := r.getTransform(&secret.ObjectMeta, &sourceSecret.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := r.isMerged(&secret.ObjectMeta, &sourceSecret.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	existing := secret.Data
+	// the merged targets keep their own type
+	if !merged {
+		secret.Type = sourceSecret.Type
+	}
+
 	if sourceSecret.Data != nil {
 		secret.Data = make(map[string][]byte)
 		for key, value := range sourceSecret.Data {
 			newValue := make([]byte, len(value))
 			copy(newValue, value)
-			secret.Data[key] = newValue
+			if newValue, err = transformValue(transform, key, newValue); err != nil {
+				return nil, err
+			}
+			secret.Data[mapKey(keyMap, key)] = newValue
 		}
 	} else {
 		secret.Data = nil
 	}
-
-	log.Printf("updating secret %s/%s", secret.Namespace, secret.Name)
-
-	secret.Annotations[ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	secret.Annotations[ReplicatedFromVersionAnnotation] = sourceSecret.ResourceVersion
-	if val, ok := sourceSecret.Annotations[ReplicateOnceVersionAnnotation]; ok {
-		secret.Annotations[ReplicateOnceVersionAnnotation] = val
+	// the replicated keys are merged into the other keys of the target
+	if merged {
+		keys := map[string]bool{}
+		secret.Data = mergeBytes(existing, secret.Data, r.replicatedKeys(&secret.ObjectMeta), keys)
+		r.setReplicatedKeys(&secret.ObjectMeta, keys)
 	} else {
-		delete(secret.Annotations, ReplicateOnceVersionAnnotation)
+		delete(secret.Annotations, r.ReplicatedKeysAnnotation)
 	}
 
-	s, err := r.client.CoreV1().Secrets(secret.Namespace).Update(secret)
-	if err != nil {
-		log.Printf("error while updating secret %s/%s: %s", secret.Namespace, secret.Name, err)
-		return err
+	secret.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	secret.Annotations[r.ReplicatedFromVersionAnnotation] = sourceSecret.ResourceVersion
+	r.setDataHash(&secret.ObjectMeta, sourceSecret)
+	r.setSynced(&secret.ObjectMeta, sourceSecret.ResourceVersion)
+	if val, ok := sourceSecret.Annotations[r.ReplicateOnceVersionAnnotation]; ok {
+		secret.Annotations[r.ReplicateOnceVersionAnnotation] = val
+	} else {
+		delete(secret.Annotations, r.ReplicateOnceVersionAnnotation)
 	}
+	r.propagateAnnotations(&secret.ObjectMeta, &sourceSecret.ObjectMeta)
+	r.propagateLabels(&secret.ObjectMeta, &sourceSecret.ObjectMeta)
+	r.propagateImmutable(&secret.ObjectMeta, &sourceSecret.ObjectMeta)
 
-	r.objectStore.Update(s)
-	return nil
+	return secret, nil
 }
 
-func (*secretActions) clear(r *replicatorProps, object interface{}) error {
+func (a *secretActions) clear(r *replicatorProps, object interface{}) error {
 	secret := object.(*v1.Secret).DeepCopy()
-	secret.Data = nil
+	// only the replicated keys are removed from the merged data
+	if _, ok := secret.Annotations[r.ReplicatedKeysAnnotation]; ok {
+		secret.Data = mergeBytes(secret.Data, nil, r.replicatedKeys(&secret.ObjectMeta), map[string]bool{})
+		delete(secret.Annotations, r.ReplicatedKeysAnnotation)
+	} else {
+		secret.Data = nil
+	}
 
-	log.Printf("clearing secret %s/%s", secret.Namespace, secret.Name)
+	r.infof("", objectKey(secret), "clearing secret %s/%s", secret.Namespace, secret.Name)
 
-	secret.Annotations[ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	delete(secret.Annotations, ReplicatedFromVersionAnnotation)
-	delete(secret.Annotations, ReplicateOnceVersionAnnotation)
+	secret.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	delete(secret.Annotations, r.ReplicatedFromVersionAnnotation)
+	delete(secret.Annotations, r.ReplicatedDataHashAnnotation)
+	delete(secret.Annotations, r.ReplicateOnceVersionAnnotation)
 
-	s, err := r.client.CoreV1().Secrets(secret.Namespace).Update(secret)
+	// the cleared target is created again as mutable if it was immutable
+	delete(secret.Annotations, r.ReplicatedImmutableAnnotation)
+	// the cleared target cannot be pulled from anymore, it is detached once written
+	delete(secret.Annotations, r.ReplicatedAttachedToServiceAccountAnnotation)
+
+	s, err := a.write(r, secret)
 	if err != nil {
-		log.Printf("error while clearing secret %s/%s", secret.Namespace, secret.Name)
+		r.errorf("", objectKey(secret), "error while clearing secret %s/%s", secret.Namespace, secret.Name)
 		return err
 	}
+	r.logDiff("", objectKey(secret), secretDiff(object.(*v1.Secret), secret))
 
 	r.objectStore.Update(s)
+	r.detachFromServiceAccounts(object.(*v1.Secret))
 	return nil
 }
 
-func (*secretActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+func (a *secretActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
 	sourceSecret := sourceObject.(*v1.Secret)
 	secret := v1.Secret{
 		Type: sourceSecret.Type,
@@ -170,39 +267,177 @@ func (*secretActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourc
 
 	if dataObject != nil {
 		dataSecret := dataObject.(*v1.Secret)
+		// the keys are renamed and transformed only when the data comes from the source
+		var keyMap map[string]string
+		var transform valueTransform
+		var err error
+		if dataObject == sourceObject {
+			if err = r.validateTLS(sourceSecret); err != nil {
+				return err
+			}
+			if keyMap, err = r.getKeyMap(meta, &sourceSecret.ObjectMeta); err != nil {
+				return err
+			}
+			if transform, err = r.getTransform(meta, &sourceSecret.ObjectMeta); err != nil {
+				return err
+			}
+		}
 
 		if dataSecret.Data != nil {
 			secret.Data = make(map[string][]byte)
 			for key, value := range dataSecret.Data {
 				newValue := make([]byte, len(value))
 				copy(newValue, value)
-				secret.Data[key] = newValue
+				if newValue, err = transformValue(transform, key, newValue); err != nil {
+					return err
+				}
+				secret.Data[mapKey(keyMap, key)] = newValue
+			}
+		}
+
+		// the replicated keys are merged into the other keys of the existing target
+		if dataObject != sourceObject {
+		} else if merged, err := r.isMerged(meta, &sourceSecret.ObjectMeta); err != nil {
+			return err
+		} else if merged {
+			existing := &v1.Secret{}
+			if object, exists, err := r.objectStore.GetByKey(objectKey(meta)); err == nil && exists {
+				existing = object.(*v1.Secret)
+				// the merged targets keep their own type
+				secret.Type = existing.Type
 			}
+			keys := map[string]bool{}
+			secret.Data = mergeBytes(existing.Data, secret.Data, r.replicatedKeys(&existing.ObjectMeta), keys)
+			r.setReplicatedKeys(&secret.ObjectMeta, keys)
 		}
 	}
 
-	log.Printf("installing secret %s/%s", secret.Namespace, secret.Name)
+	r.propagateImmutable(&secret.ObjectMeta, &sourceSecret.ObjectMeta)
+
+	r.infof(objectKey(sourceSecret), objectKey(&secret), "installing secret %s/%s", secret.Namespace, secret.Name)
+
+	// the service accounts named by the source, recorded on the copy
+	attach := dataObject == sourceObject
+	var former *v1.Secret
+	if attach {
+		r.recordServiceAccounts(&secret, sourceSecret)
+		if object, exists, err := r.objectStore.GetByKey(objectKey(&secret)); err == nil && exists {
+			former = object.(*v1.Secret)
+		}
+	}
+
+	// the data of the existing target, to log the diff once written
+	var existing *v1.Secret
+	if object, exists, err := r.objectStore.GetByKey(objectKey(&secret)); err == nil && exists && secret.ResourceVersion != "" {
+		existing = object.(*v1.Secret)
+	}
 
 	var s *v1.Secret
 	var err error
-	if secret.ResourceVersion == "" {
-		s, err = r.client.CoreV1().Secrets(secret.Namespace).Create(&secret)
+	// only the replications are applied, not the writes of the status
+	if serverSideApply && dataObject == sourceObject && !r.isImmutable(&secret.ObjectMeta) && !a.typeChanged(r, &secret) &&
+		!r.isSealed(secret.Namespace) {
+		s = &v1.Secret{}
+		err = r.apply("secrets", &secret, r.appliedMeta(&secret.ObjectMeta, &sourceSecret.ObjectMeta), "v1", "Secret", s)
 	} else {
-		s, err = r.client.CoreV1().Secrets(secret.Namespace).Update(&secret)
+		s, err = a.write(r, &secret)
 	}
 
 	if err != nil {
-		log.Printf("error while installing secret %s/%s: %s", secret.Namespace, secret.Name, err)
+		r.errorf(objectKey(sourceSecret), objectKey(&secret), "error while installing secret %s/%s: %s", secret.Namespace, secret.Name, err)
 		return err
 	}
+	if existing != nil {
+		r.logDiff(objectKey(sourceSecret), objectKey(&secret), secretDiff(existing, &secret))
+	}
 
 	r.objectStore.Update(s)
+
+	// attached once written, the secret is installed again until the service accounts are patched
+	if attach && pullSecrets.patchServiceAccounts && r.isPullSecret(sourceSecret) {
+		if err := r.attachPullSecret(&secret); err != nil {
+			r.errorf(objectKey(sourceSecret), objectKey(&secret), "%s", err)
+			return err
+		}
+	}
+	if attach {
+		if err := r.attachToServiceAccounts(&secret, former); err != nil {
+			r.errorf(objectKey(sourceSecret), objectKey(&secret), "%s", err)
+			return err
+		}
+	}
 	return nil
 }
 
+// Writes the secret, created if it has no version, with its immutable field if it is marked as immutable
+// An immutable secret which cannot be updated, or whose type changes, is deleted and created again
+// In the sealed namespaces, the sealed secret is written instead
+func (a *secretActions) write(r *replicatorProps, secret *v1.Secret) (*v1.Secret, error) {
+	if r.isSealed(secret.Namespace) {
+		return r.writeSealed(secret)
+	}
+	// the type of a secret cannot be updated
+	if a.typeChanged(r, secret) {
+		r.infof("", objectKey(secret), "type of secret %s/%s changed to %s: deleting it to create it again", secret.Namespace, secret.Name, secret.Type)
+		return a.recreate(r, secret)
+	}
+
+	client := r.writer(&secret.ObjectMeta).CoreV1().Secrets(secret.Namespace)
+	s := &v1.Secret{}
+	var err error
+	if r.isImmutable(&secret.ObjectMeta) {
+		err = r.writeImmutable("secrets", secret, &secret.ObjectMeta, s)
+	} else if secret.ResourceVersion == "" {
+		s, err = client.Create(secret)
+	} else {
+		s, err = client.Update(secret)
+	}
+	if secret.ResourceVersion == "" || !isImmutableError(err) {
+		return s, err
+	}
+
+	r.infof("", objectKey(secret), "secret %s/%s is immutable: deleting it to create it again", secret.Namespace, secret.Name)
+	return a.recreate(r, secret)
+}
+
+// Returns true if the existing secret has another type than the secret to write
+func (*secretActions) typeChanged(r *replicatorProps, secret *v1.Secret) bool {
+	if secret.ResourceVersion == "" {
+		return false
+	}
+	object, exists, err := r.objectStore.GetByKey(objectKey(secret))
+	if err != nil || !exists {
+		return false
+	}
+	// an empty type is defaulted to opaque by the API
+	existingType, secretType := object.(*v1.Secret).Type, secret.Type
+	if existingType == "" {
+		existingType = v1.SecretTypeOpaque
+	}
+	if secretType == "" {
+		secretType = v1.SecretTypeOpaque
+	}
+	return existingType != secretType
+}
+
+// Deletes the existing secret if it still has the version of the secret to write, and creates it again
+func (a *secretActions) recreate(r *replicatorProps, secret *v1.Secret) (*v1.Secret, error) {
+	options := metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &secret.ResourceVersion,
+		},
+	}
+	if err := r.writer(&secret.ObjectMeta).CoreV1().Secrets(secret.Namespace).Delete(secret.Name, &options); err != nil {
+		return nil, err
+	}
+	recreated := secret.DeepCopy()
+	recreated.ObjectMeta = recreatedMeta(&secret.ObjectMeta)
+	return a.write(r, recreated)
+}
+
 func (*secretActions) delete(r *replicatorProps, object interface{}) error {
 	secret := object.(*v1.Secret)
-	log.Printf("deleting secret %s/%s", secret.Namespace, secret.Name)
+	r.infof("", objectKey(secret), "deleting secret %s/%s", secret.Namespace, secret.Name)
 
 	options := metav1.DeleteOptions{
 		Preconditions: &metav1.Preconditions{
@@ -210,12 +445,19 @@ func (*secretActions) delete(r *replicatorProps, object interface{}) error {
 		},
 	}
 
-	err := r.client.CoreV1().Secrets(secret.Namespace).Delete(secret.Name, &options)
+	var err error
+	// the sealed secret owns the secret
+	if r.isSealed(secret.Namespace) {
+		err = r.deleteSealed(secret)
+	} else {
+		err = r.writer(&secret.ObjectMeta).CoreV1().Secrets(secret.Namespace).Delete(secret.Name, &options)
+	}
 	if err != nil {
-		log.Printf("error while deleting secret %s/%s: %s", secret.Namespace, secret.Name, err)
+		r.errorf("", objectKey(secret), "error while deleting secret %s/%s: %s", secret.Namespace, secret.Name, err)
 		return err
 	}
 
 	r.objectStore.Delete(secret)
+	r.detachFromServiceAccounts(secret)
 	return nil
 }
@@ -0,0 +1,251 @@
+package replicate
+
+import (
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+var ServiceAccountActions *serviceAccountActions = &serviceAccountActions{}
+
+// NewServiceAccountReplicator creates a new service account replicator
+func NewServiceAccountReplicator(client kubernetes.Interface, options ReplicatorOptions) Replicator {
+	repl := objectReplicator{
+		replicatorProps:   newReplicatorProps("service account", client, options),
+		replicatorActions: ServiceAccountActions,
+	}
+	repl.queue, repl.rateLimiter = newQueue(repl.Name)
+	repl.logger = options.logger().WithValues("kind", repl.kind())
+
+	namespaces := repl.readLegacy(namespaceListWatch(client))
+	namespaceLister := &cache.ListWatch{
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := namespaces.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.NamespaceList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.namespaceStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return namespaces.Watch(lo)
+		},
+	}
+	namespaceStore, namespaceController := cache.NewInformer(
+		namespaceLister,
+		&v1.Namespace{},
+		options.ResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    repl.NamespaceAdded,
+			UpdateFunc: repl.NamespaceUpdated,
+			DeleteFunc: repl.NamespaceDeleted,
+		},
+	)
+
+	repl.namespaceStore = namespaceStore
+	repl.namespaceController = namespaceController
+	repl.namespaceLister = namespaceLister
+
+	objects, _ := ServiceAccountActions.listWatch(client)
+	objects = repl.readLegacy(repl.pageLists(objects))
+	objects = repl.transformCache(objects)
+	objectListWatch := &cache.ListWatch{
+		// the pages are merged by pageLists, keeping the type of the list
+		DisableChunking: true,
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			object, err := objects.List(lo)
+			if err != nil {
+				return object, err
+			}
+			list := object.(*v1.ServiceAccountList)
+			// populate the store already, to avoid believing some items are deleted
+			copy := make([]interface{}, len(list.Items))
+			for index := range list.Items {
+				copy[index] = &list.Items[index]
+			}
+			repl.objectStore.Replace(copy, "init")
+			return list, err
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return objects.Watch(lo)
+		},
+	}
+
+	objectStore, objectController := cache.NewIndexerInformer(
+		objectListWatch,
+		&v1.ServiceAccount{},
+		options.ResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    repl.enqueueAdded,
+			UpdateFunc: repl.enqueueUpdated,
+			DeleteFunc: repl.enqueueDeleted,
+		},
+		repl.indexers(),
+	)
+
+	repl.objectStore = repl.stripStore(objectStore)
+	repl.objectController = objectController
+	repl.objectLister = objectListWatch
+
+	registerReplicator(&repl)
+	options.Metrics.addCollector(repl.collectStaleness)
+	if options.Shards != nil {
+		options.Shards.onChange(repl.resync)
+	}
+
+	return &repl
+}
+
+type serviceAccountActions struct{}
+
+func (*serviceAccountActions) getMeta(object interface{}) *metav1.ObjectMeta {
+	return &object.(*v1.ServiceAccount).ObjectMeta
+}
+
+// Watches the objects of the watched namespaces, of this cluster or of another one
+func (*serviceAccountActions) listWatch(client kubernetes.Interface) (cache.ListerWatcher, runtime.Object) {
+	return scopedListWatch(func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			DisableChunking: true,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().ServiceAccounts(namespace).List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().ServiceAccounts(namespace).Watch(lo)
+			},
+		}
+	}), &v1.ServiceAccount{}
+}
+
+// Copies the references to the secrets of the source service account
+func copyServiceAccountSecrets(serviceAccount *v1.ServiceAccount, sourceServiceAccount *v1.ServiceAccount) {
+	if sourceServiceAccount.ImagePullSecrets != nil {
+		serviceAccount.ImagePullSecrets = make([]v1.LocalObjectReference, len(sourceServiceAccount.ImagePullSecrets))
+		copy(serviceAccount.ImagePullSecrets, sourceServiceAccount.ImagePullSecrets)
+	} else {
+		serviceAccount.ImagePullSecrets = nil
+	}
+
+	if sourceServiceAccount.Secrets != nil {
+		serviceAccount.Secrets = make([]v1.ObjectReference, len(sourceServiceAccount.Secrets))
+		copy(serviceAccount.Secrets, sourceServiceAccount.Secrets)
+	} else {
+		serviceAccount.Secrets = nil
+	}
+}
+
+func (*serviceAccountActions) update(r *replicatorProps, object interface{}, sourceObject interface{}) error {
+	sourceServiceAccount := sourceObject.(*v1.ServiceAccount)
+	serviceAccount := object.(*v1.ServiceAccount).DeepCopy()
+
+	copyServiceAccountSecrets(serviceAccount, sourceServiceAccount)
+
+	r.infof(objectKey(sourceServiceAccount), objectKey(serviceAccount), "updating service account %s/%s", serviceAccount.Namespace, serviceAccount.Name)
+
+	serviceAccount.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	serviceAccount.Annotations[r.ReplicatedFromVersionAnnotation] = sourceServiceAccount.ResourceVersion
+	r.setDataHash(&serviceAccount.ObjectMeta, sourceServiceAccount)
+	r.setSynced(&serviceAccount.ObjectMeta, sourceServiceAccount.ResourceVersion)
+	if val, ok := sourceServiceAccount.Annotations[r.ReplicateOnceVersionAnnotation]; ok {
+		serviceAccount.Annotations[r.ReplicateOnceVersionAnnotation] = val
+	} else {
+		delete(serviceAccount.Annotations, r.ReplicateOnceVersionAnnotation)
+	}
+	r.propagateAnnotations(&serviceAccount.ObjectMeta, &sourceServiceAccount.ObjectMeta)
+	r.propagateLabels(&serviceAccount.ObjectMeta, &sourceServiceAccount.ObjectMeta)
+
+	s, err := r.writer(&serviceAccount.ObjectMeta).CoreV1().ServiceAccounts(serviceAccount.Namespace).Update(serviceAccount)
+	if err != nil {
+		r.errorf(objectKey(sourceServiceAccount), objectKey(serviceAccount), "error while updating service account %s/%s: %s", serviceAccount.Namespace, serviceAccount.Name, err)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*serviceAccountActions) clear(r *replicatorProps, object interface{}) error {
+	serviceAccount := object.(*v1.ServiceAccount).DeepCopy()
+	serviceAccount.ImagePullSecrets = nil
+	serviceAccount.Secrets = nil
+
+	r.infof("", objectKey(serviceAccount), "clearing service account %s/%s", serviceAccount.Namespace, serviceAccount.Name)
+
+	serviceAccount.Annotations[r.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	delete(serviceAccount.Annotations, r.ReplicatedFromVersionAnnotation)
+	delete(serviceAccount.Annotations, r.ReplicatedDataHashAnnotation)
+	delete(serviceAccount.Annotations, r.ReplicateOnceVersionAnnotation)
+
+	s, err := r.writer(&serviceAccount.ObjectMeta).CoreV1().ServiceAccounts(serviceAccount.Namespace).Update(serviceAccount)
+	if err != nil {
+		r.errorf("", objectKey(serviceAccount), "error while clearing service account %s/%s", serviceAccount.Namespace, serviceAccount.Name)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*serviceAccountActions) install(r *replicatorProps, meta *metav1.ObjectMeta, sourceObject interface{}, dataObject interface{}) error {
+	sourceServiceAccount := sourceObject.(*v1.ServiceAccount)
+	serviceAccount := v1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       sourceServiceAccount.Kind,
+			APIVersion: sourceServiceAccount.APIVersion,
+		},
+		ObjectMeta: *meta,
+	}
+
+	if dataObject != nil {
+		copyServiceAccountSecrets(&serviceAccount, dataObject.(*v1.ServiceAccount))
+	}
+
+	r.infof(objectKey(sourceServiceAccount), objectKey(&serviceAccount), "installing service account %s/%s", serviceAccount.Namespace, serviceAccount.Name)
+
+	var s *v1.ServiceAccount
+	var err error
+	if serviceAccount.ResourceVersion == "" {
+		s, err = r.writer(&serviceAccount.ObjectMeta).CoreV1().ServiceAccounts(serviceAccount.Namespace).Create(&serviceAccount)
+	} else {
+		s, err = r.writer(&serviceAccount.ObjectMeta).CoreV1().ServiceAccounts(serviceAccount.Namespace).Update(&serviceAccount)
+	}
+
+	if err != nil {
+		r.errorf(objectKey(sourceServiceAccount), objectKey(&serviceAccount), "error while installing service account %s/%s: %s", serviceAccount.Namespace, serviceAccount.Name, err)
+		return err
+	}
+
+	r.objectStore.Update(s)
+	return nil
+}
+
+func (*serviceAccountActions) delete(r *replicatorProps, object interface{}) error {
+	serviceAccount := object.(*v1.ServiceAccount)
+	r.infof("", objectKey(serviceAccount), "deleting service account %s/%s", serviceAccount.Namespace, serviceAccount.Name)
+
+	options := metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &serviceAccount.ResourceVersion,
+		},
+	}
+
+	err := r.writer(&serviceAccount.ObjectMeta).CoreV1().ServiceAccounts(serviceAccount.Namespace).Delete(serviceAccount.Name, &options)
+	if err != nil {
+		r.errorf("", objectKey(serviceAccount), "error while deleting service account %s/%s: %s", serviceAccount.Namespace, serviceAccount.Name, err)
+		return err
+	}
+
+	r.objectStore.Delete(serviceAccount)
+	return nil
+}
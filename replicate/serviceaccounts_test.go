@@ -0,0 +1,34 @@
+package replicate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestCopyServiceAccountSecrets(t *testing.T) {
+	source := &v1.ServiceAccount{
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry-credentials"}},
+		Secrets:          []v1.ObjectReference{{Name: "token"}},
+	}
+	target := &v1.ServiceAccount{
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "previous"}},
+		Secrets:          []v1.ObjectReference{{Name: "previous"}},
+	}
+
+	copyServiceAccountSecrets(target, source)
+	if !reflect.DeepEqual(target.ImagePullSecrets, source.ImagePullSecrets) || !reflect.DeepEqual(target.Secrets, source.Secrets) {
+		t.Errorf("expected the references of the source, got %v and %v", target.ImagePullSecrets, target.Secrets)
+	}
+
+	source.ImagePullSecrets[0].Name = "modified"
+	if target.ImagePullSecrets[0].Name != "registry-credentials" {
+		t.Errorf("expected the references to be copied, not shared")
+	}
+
+	copyServiceAccountSecrets(target, &v1.ServiceAccount{})
+	if target.ImagePullSecrets != nil || target.Secrets != nil {
+		t.Errorf("expected the references to be cleared, got %v and %v", target.ImagePullSecrets, target.Secrets)
+	}
+}
@@ -0,0 +1,167 @@
+package replicate
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the label identifying the leases of the instances
+const shardsLabel = "kubernetes-replicator.olli.com/shards"
+
+// Shards assigns the objects without instance annotation to the active instances of the replicator
+// Each instance registers itself with a Lease, and renews it while running
+type Shards struct {
+	client        kubernetes.Interface
+	namespace     string
+	instance      string
+	leaseDuration time.Duration
+
+	lock sync.RWMutex
+	// the sorted names of the active instances
+	instances []string
+	// called when the active instances change
+	listeners []func()
+}
+
+// NewShards creates the shards of the instance, with leases in the given namespace
+func NewShards(client kubernetes.Interface, namespace string, instance string, leaseDuration time.Duration) *Shards {
+	return &Shards{
+		client:        client,
+		namespace:     namespace,
+		instance:      instance,
+		leaseDuration: leaseDuration,
+	}
+}
+
+// Start registers the instance, and keeps the active instances up-to-date
+func (s *Shards) Start() {
+	s.update()
+	go func() {
+		for range time.Tick(s.leaseDuration / 3) {
+			s.update()
+		}
+	}()
+}
+
+// Load reads the active instances once, without registering the instance, ex: for a command
+func (s *Shards) Load() error {
+	return s.refresh()
+}
+
+// Registers a function called when the active instances change
+func (s *Shards) onChange(listener func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+// Owner returns the instance the object is assigned to
+// It uses rendezvous hashing, so that only the objects of a leaving or joining instance move
+func (s *Shards) Owner(key string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	owner := ""
+	var max uint64
+	for _, instance := range s.instances {
+		h := fnv.New64a()
+		h.Write([]byte(instance + "/" + key))
+		if sum := h.Sum64(); owner == "" || sum > max {
+			owner = instance
+			max = sum
+		}
+	}
+
+	return owner
+}
+
+func (s *Shards) update() {
+	if err := s.renew(); err != nil {
+		logWith(logger, true, fmt.Sprintf("could not renew lease of instance %s: %s", s.instance, err))
+	}
+	if err := s.refresh(); err != nil {
+		logWith(logger, true, fmt.Sprintf("could not list instances: %s", err))
+	}
+}
+
+// Creates or renews the lease of this instance
+func (s *Shards) renew() error {
+	name := "kubernetes-replicator-" + s.instance
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(s.leaseDuration.Seconds())
+
+	lease, err := s.client.CoordinationV1().Leases(s.namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: s.namespace,
+				Labels:    map[string]string{shardsLabel: "true"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.instance,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = s.client.CoordinationV1().Leases(s.namespace).Create(lease)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	lease = lease.DeepCopy()
+	lease.Spec.HolderIdentity = &s.instance
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	_, err = s.client.CoordinationV1().Leases(s.namespace).Update(lease)
+	return err
+}
+
+// Lists the instances with a valid lease, and notifies the listeners of changes
+func (s *Shards) refresh() error {
+	list, err := s.client.CoordinationV1().Leases(s.namespace).List(metav1.ListOptions{
+		LabelSelector: shardsLabel + "=true",
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	instances := []string{}
+	for _, lease := range list.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		} else if spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second).After(now) {
+			instances = append(instances, *spec.HolderIdentity)
+		}
+	}
+	sort.Strings(instances)
+
+	s.lock.Lock()
+	changed := len(instances) != len(s.instances)
+	for i := 0; !changed && i < len(instances); i++ {
+		changed = instances[i] != s.instances[i]
+	}
+	s.instances = instances
+	listeners := s.listeners
+	s.lock.Unlock()
+
+	if changed {
+		logf("active instances: %v", instances)
+		for _, listener := range listeners {
+			listener()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package replicate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Returns the lease of another instance, renewed at the given time
+func instanceLease(instance string, renewed time.Time) *coordinationv1.Lease {
+	seconds := int32(30)
+	renewTime := metav1.NewMicroTime(renewed)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "replicator",
+			Name:      "kubernetes-replicator-" + instance,
+			Labels:    map[string]string{shardsLabel: "true"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &instance,
+			LeaseDurationSeconds: &seconds,
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+func TestShardsReassignExpiredLease(t *testing.T) {
+	client := fake.NewSimpleClientset(instanceLease("b", time.Now()))
+	s := NewShards(client, "replicator", "a", 30*time.Second)
+	changes := 0
+	s.onChange(func() { changes++ })
+
+	// the instance registers itself next to the active one
+	s.update()
+	if _, err := client.CoordinationV1().Leases("replicator").Get("kubernetes-replicator-a", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected the lease of the instance to be created, got %s", err)
+	}
+	if len(s.instances) != 2 || changes != 1 {
+		t.Fatalf("expected both instances to be active, got %v after %d changes", s.instances, changes)
+	}
+
+	owned := map[string]string{}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("default/object-%d", i)
+		owned[key] = s.Owner(key)
+	}
+
+	// the lease of the other instance is not renewed anymore
+	if _, err := client.CoordinationV1().Leases("replicator").Update(instanceLease("b", time.Now().Add(-time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	s.update()
+	if len(s.instances) != 1 || s.instances[0] != "a" || changes != 2 {
+		t.Fatalf("expected only the instance to be active, got %v after %d changes", s.instances, changes)
+	}
+	moved := 0
+	for key, owner := range owned {
+		if s.Owner(key) != "a" {
+			t.Errorf("expected %s to be reassigned to the remaining instance, got %s", key, s.Owner(key))
+		} else if owner == "b" {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Errorf("expected some objects of the expired instance to be reassigned")
+	}
+
+	// renewing its own lease does not change anything
+	s.update()
+	if changes != 2 {
+		t.Errorf("expected no change when the instances are the same, got %d changes", changes)
+	}
+}
@@ -0,0 +1,71 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/api/core/v1"
+)
+
+// SimulatedTarget describes a target which would be created in a new namespace
+type SimulatedTarget struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Source string `json:"source"`
+	// the objects which must exist in the namespace before the target is created
+	After []string `json:"after,omitempty"`
+	// the reason why the target would not be created, if any
+	Error string `json:"error,omitempty"`
+}
+
+// SimulateNamespace returns the targets which would be created if the namespace was created
+func (r *objectReplicator) SimulateNamespace(namespace *v1.Namespace) []SimulatedTarget {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// the labels of the namespace are used by the selectors
+	r.simulatedNamespace = namespace
+	defer func() { r.simulatedNamespace = nil }()
+
+	simulated := []SimulatedTarget{}
+	for source := range r.sourcesWatching(namespace.Name) {
+		sourceObject, exists, err := r.objectStore.GetByKey(source)
+		if err != nil || !exists {
+			continue
+		}
+
+		meta := r.getMeta(sourceObject)
+		// those annotations have priority
+		if _, ok := meta.Annotations[r.ReplicatedByAnnotation]; ok {
+			continue
+		}
+
+		targets, err := r.targetsInNamespace(meta, namespace.Name)
+		if err != nil {
+			simulated = append(simulated, SimulatedTarget{Kind: r.Name, Source: source, Error: err.Error()})
+			continue
+		}
+
+		for target := range targets {
+			s := SimulatedTarget{Kind: r.Name, Target: target, Source: source}
+			if deps, err := r.getDependencies(meta, target); err != nil {
+				s.Error = err.Error()
+			} else {
+				s.After = deps
+			}
+			// the target can exist already if the namespace does
+			if _, exists, err := r.objectStore.GetByKey(target); err == nil && exists {
+				s.Error = fmt.Sprintf("target %s already exists", target)
+			}
+			simulated = append(simulated, s)
+		}
+	}
+
+	sort.Slice(simulated, func(i, j int) bool {
+		if simulated[i].Target != simulated[j].Target {
+			return simulated[i].Target < simulated[j].Target
+		}
+		return simulated[i].Source < simulated[j].Source
+	})
+	return simulated
+}
@@ -0,0 +1,180 @@
+package replicate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// the versions of a source whose status annotation was written, which did not change its data
+type statusVersion struct {
+	// the version of the last change of the source, other than its status
+	data string
+	// the version written by the last write of its status
+	latest string
+	// true until the informer notifies the write of the status
+	pending bool
+}
+
+// Returns the version of the data of the source, before the writes of its status annotation
+func (r *replicatorProps) dataVersion(source *metav1.ObjectMeta) string {
+	r.statusLock.Lock()
+	defer r.statusLock.Unlock()
+
+	if version, ok := r.statusVersions[objectKey(source)]; ok && version.latest == source.ResourceVersion {
+		return version.data
+	}
+	return source.ResourceVersion
+}
+
+// Returns true if the version replicated on a target is the current version of the source,
+// ignoring the writes of its status annotation
+func (r *replicatorProps) isSourceVersion(source *metav1.ObjectMeta, version string) bool {
+	return version == source.ResourceVersion || version == r.dataVersion(source)
+}
+
+// Queues an object updated by the informer, unless the update is the write of its status annotation,
+// or changes nothing to replicate
+func (r *objectReplicator) enqueueUpdated(old interface{}, new interface{}) {
+	meta := r.getMeta(new)
+	key := objectKey(meta)
+	oldVersion := r.dataVersion(r.getMeta(old))
+
+	r.statusLock.Lock()
+	if version, ok := r.statusVersions[key]; !ok {
+	} else if version.latest != meta.ResourceVersion {
+		// the source changed since its status was written
+		delete(r.statusVersions, key)
+	} else if version.pending {
+		version.pending = false
+		r.statusVersions[key] = version
+		r.statusLock.Unlock()
+		return
+	}
+	// the new version has the same data as the former one, for its targets
+	if r.isNoopUpdate(old, new) {
+		if r.statusVersions == nil {
+			r.statusVersions = map[string]statusVersion{}
+		}
+		r.statusVersions[key] = statusVersion{data: oldVersion, latest: meta.ResourceVersion}
+		r.statusLock.Unlock()
+		r.debugf(key, "", "update of %s %s is skipped: nothing to replicate changed", r.Name, key)
+		r.countNoop("event")
+		return
+	}
+	// a target modified by hand is reverted if its source is enforced
+	r.markEdited(old, new)
+	r.statusLock.Unlock()
+
+	r.enqueueModified(old, new)
+}
+
+// Writes the status of the targets on their sources periodically
+func (r *objectReplicator) runSourceStatus() {
+	if r.sourceStatusPeriod <= 0 {
+		return
+	}
+
+	go wait.Until(func() {
+		if r.Synced() {
+			r.writeSourceStatuses()
+		}
+	}, r.sourceStatusPeriod, wait.NeverStop)
+}
+
+// Returns the summary of the targets of each source, ex: "12 synced, 1 failed (ns-x: forbidden)"
+func (r *objectReplicator) sourceStatuses() map[string]string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	// a {source => targets} map of the targets of the sources
+	sources := map[string]map[string]bool{}
+	for _, m := range []map[string][]string{r.targets(), r.dependents()} {
+		for source, targets := range m {
+			// the sources of the other clusters are not written
+			if strings.Contains(source, ":") {
+				continue
+			}
+			if _, ok := sources[source]; !ok {
+				sources[source] = map[string]bool{}
+			}
+			for _, target := range targets {
+				sources[source][target] = true
+			}
+		}
+	}
+
+	statuses := map[string]string{}
+	for source, targets := range sources {
+		synced, pending := 0, 0
+		failed := []string{}
+		for target := range targets {
+			if _, ok := r.retries[target]; ok {
+				failed = append(failed, target)
+			} else if object, exists, err := r.objectStore.GetByKey(target); err == nil && exists && r.isUpToDate(r.getMeta(object)) {
+				synced++
+			} else {
+				pending++
+			}
+		}
+
+		status := fmt.Sprintf("%d synced", synced)
+		if pending > 0 {
+			status += fmt.Sprintf(", %d pending", pending)
+		}
+		// the first failure explains the others
+		if len(failed) > 0 {
+			sort.Strings(failed)
+			namespace := strings.SplitN(failed[0], "/", 2)[0]
+			status += fmt.Sprintf(", %d failed (%s: %s)", len(failed), namespace, r.retries[failed[0]].LastError)
+		}
+		statuses[source] = status
+	}
+	return statuses
+}
+
+// Writes the status of the targets on the sources whose status changed,
+// and removes it from the sources without targets anymore
+func (r *objectReplicator) writeSourceStatuses() {
+	statuses := r.sourceStatuses()
+	for _, object := range r.objectStore.List() {
+		meta := r.getMeta(object)
+		key := objectKey(meta)
+		status, ok := statuses[key]
+		if val, exists := meta.Annotations[r.ReplicationTargetsStatusAnnotation]; !ok && !exists || ok && val == status {
+			continue
+		} else if !r.isManaged(meta) {
+			continue
+		}
+		r.writeSourceStatus(object, status)
+	}
+}
+
+// Writes the status of the targets on the source, recording its new version as the same data
+func (r *objectReplicator) writeSourceStatus(object interface{}, status string) {
+	// the informer waits for the new version to be recorded
+	r.statusLock.Lock()
+	defer r.statusLock.Unlock()
+
+	meta := r.getMeta(object)
+	data := meta.ResourceVersion
+	if version, ok := r.statusVersions[objectKey(meta)]; ok && version.latest == meta.ResourceVersion {
+		data = version.data
+	}
+
+	object, err := r.setStatusAnnotation(object, r.ReplicationTargetsStatusAnnotation, status)
+	if err != nil || r.getMeta(object).ResourceVersion == meta.ResourceVersion {
+		return
+	}
+	if r.statusVersions == nil {
+		r.statusVersions = map[string]statusVersion{}
+	}
+	r.statusVersions[objectKey(meta)] = statusVersion{
+		data:    data,
+		latest:  r.getMeta(object).ResourceVersion,
+		pending: true,
+	}
+}
@@ -0,0 +1,91 @@
+package replicate
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStatusVersions(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			statusVersions:  map[string]statusVersion{},
+			updated:         map[string]interface{}{},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.queue, r.rateLimiter = newQueue("test")
+	defer r.queue.ShutDown()
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "11"}}
+	r.statusVersions["default/source"] = statusVersion{data: "10", latest: "11", pending: true}
+
+	// the targets of the version before the status are up to date
+	if !r.isSourceVersion(&source.ObjectMeta, "10") || !r.isSourceVersion(&source.ObjectMeta, "11") {
+		t.Errorf("expected versions 10 and 11 to be the same data")
+	}
+	if r.isSourceVersion(&source.ObjectMeta, "9") {
+		t.Errorf("expected version 9 to be older")
+	}
+
+	// the notification of the write of the status is not processed
+	r.enqueueUpdated(source, source)
+	if r.queue.Len() != 0 {
+		t.Errorf("expected the write of the status not to be queued")
+	}
+	// but the next resyncs are
+	r.enqueueUpdated(source, source)
+	if r.queue.Len() != 1 {
+		t.Errorf("expected the resync to be queued")
+	}
+
+	// a new change of the source forgets the previous versions
+	changed := source.DeepCopy()
+	changed.ResourceVersion = "12"
+	changed.Data = map[string][]byte{"key": []byte("value")}
+	r.enqueueUpdated(source, changed)
+	if _, ok := r.statusVersions["default/source"]; ok {
+		t.Errorf("expected the versions of the status to be forgotten")
+	}
+	if r.isSourceVersion(&changed.ObjectMeta, "10") {
+		t.Errorf("expected version 10 to be older")
+	}
+}
+
+func TestSourceStatuses(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			retries:         map[string]*retryState{"ns-x/source": {Attempts: 1, LastError: "forbidden"}},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "remote", Annotations: map[string]string{
+		r.ReplicateFromAnnotation: "other:default/remote",
+	}}})
+	r.namespaceStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, namespace := range []string{"default", "ns-a", "ns-b", "ns-x"} {
+		r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	}
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "10",
+		Annotations: map[string]string{r.ReplicateToNamespacesAnnotation: "ns-a,ns-b,ns-x"}}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns-a", Name: "source", Annotations: map[string]string{
+		r.ReplicatedByAnnotation:          "default/source",
+		r.ReplicatedFromVersionAnnotation: "10",
+	}}})
+
+	statuses := r.sourceStatuses()
+	if expected := "1 synced, 1 pending, 1 failed (ns-x: forbidden)"; statuses["default/source"] != expected {
+		t.Errorf("expected status %s, got %s", expected, statuses["default/source"])
+	}
+	// the sources of the other clusters are not written
+	if _, ok := statuses["other:default/remote"]; ok {
+		t.Errorf("expected no status for the source of another cluster")
+	}
+}
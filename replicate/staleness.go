@@ -0,0 +1,58 @@
+package replicate
+
+import (
+	"time"
+)
+
+// Updates the staleness metrics of the sources, and the number of sources and targets
+// A target is stale if it does not exist yet, or was not updated since its source changed
+func (r *objectReplicator) collectStaleness() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	// a {source => targets} map of the targets of the sources
+	sources := map[string]map[string]bool{}
+	for _, m := range []map[string][]string{r.targets(), r.dependents()} {
+		for source, targets := range m {
+			if _, ok := sources[source]; !ok {
+				sources[source] = map[string]bool{}
+			}
+			for _, target := range targets {
+				sources[source][target] = true
+			}
+		}
+	}
+
+	staleTargets := 0
+	allTargets := map[string]bool{}
+	for source, targets := range sources {
+		stale := false
+		for target := range targets {
+			allTargets[target] = true
+			if object, exists, err := r.objectStore.GetByKey(target); err != nil {
+			} else if !exists || !r.isUpToDate(r.getMeta(object)) {
+				staleTargets++
+				stale = true
+			}
+		}
+
+		if _, ok := r.syncedAt[source]; !ok || !stale {
+			r.syncedAt[source] = now
+		}
+		r.metrics.set("replicator_source_staleness_seconds", now.Sub(r.syncedAt[source]).Seconds(),
+			"kind", r.Name, "source", source)
+	}
+
+	// forget the sources without targets anymore
+	for source := range r.syncedAt {
+		if _, ok := sources[source]; !ok {
+			delete(r.syncedAt, source)
+			r.metrics.delete("replicator_source_staleness_seconds", "kind", r.Name, "source", source)
+		}
+	}
+
+	r.metrics.set("replicator_stale_targets", float64(staleTargets), "kind", r.Name)
+	r.metrics.set("replicator_targets", float64(len(allTargets)), "kind", r.Name)
+	r.metrics.set("replicator_sources", float64(len(sources)), "kind", r.Name)
+}
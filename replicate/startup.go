@@ -0,0 +1,50 @@
+package replicate
+
+import (
+	"k8s.io/client-go/tools/cache"
+)
+
+// Processes the objects once the namespaces and the objects are all listed, so that no target is installed from
+// an incomplete cache: the events received meanwhile are only queued
+// Once the objects listed at startup are processed, the startup is reported and the targets are reconciled
+func (r *objectReplicator) runAfterSync(stop <-chan struct{}) {
+	if !cache.WaitForCacheSync(stop, r.namespaceController.HasSynced, r.objectController.HasSynced) {
+		return
+	}
+	// all the objects listed at startup are queued by the informer
+	r.startupLock.Lock()
+	r.startupPending = map[string]bool{}
+	for _, key := range r.objectStore.ListKeys() {
+		r.startupPending[key] = true
+	}
+	r.startupProcessed = make(chan struct{})
+	if len(r.startupPending) == 0 {
+		close(r.startupProcessed)
+	}
+	processed := r.startupProcessed
+	r.infof("", "", "caches of %s replicator synced: processing %d objects", r.Name, len(r.startupPending))
+	r.startupLock.Unlock()
+	r.runWorkers()
+
+	select {
+	case <-processed:
+	case <-stop:
+		return
+	}
+	r.reportStartup()
+	r.runReconcile()
+}
+
+// Marks the object as processed by a worker, once all the objects listed at startup are, the startup is over
+func (r *objectReplicator) processed(key string) {
+	r.startupLock.Lock()
+	defer r.startupLock.Unlock()
+
+	if !r.startupPending[key] {
+		return
+	}
+	delete(r.startupPending, key)
+	if len(r.startupPending) == 0 {
+		close(r.startupProcessed)
+	}
+}
@@ -0,0 +1,74 @@
+package replicate
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+// a controller synced once told so, counting how many times it is checked
+type syncingController struct {
+	syncedController
+	synced *int32
+	checks *int32
+}
+
+func (c syncingController) HasSynced() bool {
+	atomic.AddInt32(c.checks, 1)
+	return atomic.LoadInt32(c.synced) == 1
+}
+
+func TestRunAfterSync(t *testing.T) {
+	var synced, checks int32
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:                "secret",
+			annotationNames:     annotationsWithPrefix(""),
+			objectController:    syncedController{},
+			namespaceStore:      cache.NewStore(cache.MetaNamespaceKeyFunc),
+			namespaceController: syncingController{synced: &synced, checks: &checks},
+			deleted:             map[string]interface{}{},
+			updated:             map[string]interface{}{},
+			startupReport:       &StartupReport{},
+		},
+		replicatorActions: SecretActions,
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	r.queue, r.rateLimiter = newQueue("secret")
+	defer r.queue.ShutDown()
+	r.namespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	r.objectStore.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	r.queue.Add("default/a")
+
+	stop := make(chan struct{})
+	defer close(stop)
+	done := make(chan struct{})
+	go func() {
+		r.runAfterSync(stop)
+		close(done)
+	}()
+
+	// the namespaces are checked again and again, the objects waiting for them to be listed
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return atomic.LoadInt32(&checks) > 2, nil
+	})
+	if err != nil || r.queue.Len() != 1 {
+		t.Fatalf("expected the objects to wait for the namespaces to be listed, got %d queued (%v)", r.queue.Len(), err)
+	}
+
+	// once listed, the objects are processed and the startup is over
+	atomic.StoreInt32(&synced, 1)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the startup to be over once the objects are processed")
+	}
+	if r.queue.Len() != 0 || len(r.startupPending) != 0 {
+		t.Errorf("expected the objects listed at startup to be processed, got %v", r.startupPending)
+	}
+}
@@ -0,0 +1,132 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// the states of the replication, in the status annotation of the targets
+const (
+	StateSynced        = "Synced"
+	StateSourceMissing = "SourceMissing"
+	StateDenied        = "Denied"
+)
+
+// ReplicationStatus is the status of the replication of a target, written as JSON in its "replication-status" annotation
+type ReplicationStatus struct {
+	// the last time the data of the source was written on the target
+	LastSync string `json:"lastSync,omitempty"`
+	// the version of the source at the last synchronization
+	SourceVersion string `json:"sourceVersion,omitempty"`
+	State         string `json:"state"`
+}
+
+// Returns the status of the replication written on the target, empty if missing or illformed
+func (r *replicatorProps) replicationStatus(object *metav1.ObjectMeta) ReplicationStatus {
+	status := ReplicationStatus{}
+	if val, ok := object.Annotations[r.ReplicationStatusAnnotation]; ok {
+		json.Unmarshal([]byte(val), &status)
+	}
+	return status
+}
+
+// Returns the status annotation of the target in a new state, keeping its last synchronization
+func (r *replicatorProps) statusWithState(object *metav1.ObjectMeta, state string) string {
+	status := r.replicationStatus(object)
+	status.State = state
+	raw, _ := json.Marshal(status)
+	return string(raw)
+}
+
+// Records the synchronization of the target in its status, along with its new data
+func (r *replicatorProps) setSynced(object *metav1.ObjectMeta, sourceVersion string) {
+	raw, _ := json.Marshal(ReplicationStatus{
+		LastSync:      time.Now().Format(time.RFC3339),
+		SourceVersion: sourceVersion,
+		State:         StateSynced,
+	})
+	object.Annotations[r.ReplicationStatusAnnotation] = string(raw)
+}
+
+// Returns the latest version of the object from the store
+func (r *objectReplicator) latestObject(object interface{}) interface{} {
+	meta := r.getMeta(object)
+	if latest, exists, err := r.objectStore.GetByKey(fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)); err == nil && exists {
+		return latest
+	}
+	return object
+}
+
+// Sets a status annotation on the object, or removes it if the value is empty
+func (r *objectReplicator) setStatusAnnotation(object interface{}, annotation string, value string) (interface{}, error) {
+	return r.setStatusAnnotations(object, map[string]string{annotation: value})
+}
+
+// Sets status annotations on the object, or removes those whose value is empty
+// The latest version of the object is taken from the store, and returned once updated
+func (r *objectReplicator) setStatusAnnotations(object interface{}, values map[string]string) (interface{}, error) {
+	object = r.latestObject(object)
+	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+
+	changed := false
+	for annotation, value := range values {
+		if val, ok := meta.Annotations[annotation]; value == "" && ok || value != "" && val != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return object, nil
+	}
+
+	copyMeta := meta.DeepCopy()
+	if copyMeta.Annotations == nil {
+		copyMeta.Annotations = map[string]string{}
+	}
+	for annotation, value := range values {
+		if value == "" {
+			delete(copyMeta.Annotations, annotation)
+		} else {
+			copyMeta.Annotations[annotation] = value
+		}
+	}
+
+	// install it, but keeps the original data, not transformed as the data of a source
+	data := object.(runtime.Object).DeepCopyObject()
+	if err := r.install(&r.replicatorProps, copyMeta, object, data); isPaused(err) {
+		return object, nil
+	} else if err != nil {
+		logf("could not write the status annotations of %s %s: %s", r.Name, key, err)
+		return object, err
+	}
+
+	return r.latestObject(object), nil
+}
+
+// Writes the reason why the replication of the target is denied, or removes it if empty
+// Returns the object as updated
+func (r *objectReplicator) setDenial(object interface{}, reason string) interface{} {
+	return r.setFailure(object, StateDenied, reason)
+}
+
+// Writes that the source of the target does not exist, as the reason why it is not replicated
+// Returns the object as updated
+func (r *objectReplicator) setSourceMissing(object interface{}, reason string) interface{} {
+	return r.setFailure(object, StateSourceMissing, reason)
+}
+
+// Writes the reason why the target is not replicated and the state of its replication
+// An empty reason only removes the previous reason, the state being updated by the next synchronization
+func (r *objectReplicator) setFailure(object interface{}, state string, reason string) interface{} {
+	object = r.latestObject(object)
+	values := map[string]string{r.ReplicationDeniedAnnotation: reason}
+	if reason != "" {
+		values[r.ReplicationStatusAnnotation] = r.statusWithState(r.getMeta(object), state)
+	}
+	object, _ = r.setStatusAnnotations(object, values)
+	return object
+}
@@ -0,0 +1,33 @@
+package replicate
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReplicationStatus(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	target := &metav1.ObjectMeta{Namespace: "default", Name: "target", Annotations: map[string]string{}}
+
+	if status := r.replicationStatus(target); status.State != "" {
+		t.Errorf("expected no status, got %v", status)
+	}
+
+	r.setSynced(target, "10")
+	status := r.replicationStatus(target)
+	if status.State != StateSynced || status.SourceVersion != "10" || status.LastSync == "" {
+		t.Errorf("expected a synchronized status, got %v", status)
+	}
+
+	// the last synchronization is kept by the other states
+	target.Annotations[r.ReplicationStatusAnnotation] = r.statusWithState(target, StateSourceMissing)
+	if missing := r.replicationStatus(target); missing.State != StateSourceMissing || missing.SourceVersion != "10" || missing.LastSync != status.LastSync {
+		t.Errorf("expected a missing source status keeping the last synchronization, got %v", missing)
+	}
+
+	target.Annotations[r.ReplicationStatusAnnotation] = "{illformed"
+	if expected := `{"state":"Denied"}`; r.statusWithState(target, StateDenied) != expected {
+		t.Errorf("expected %s, got %s", expected, r.statusWithState(target, StateDenied))
+	}
+}
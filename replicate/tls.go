@@ -0,0 +1,80 @@
+package replicate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+)
+
+// when true, the TLS secrets with an expired certificate are not replicated
+var rejectExpiredCerts bool
+
+// RejectExpiredCerts sets whether the TLS secrets with an expired certificate are replicated
+func RejectExpiredCerts(reject bool) {
+	rejectExpiredCerts = reject
+}
+
+// Returns the certificate of a TLS secret, or nil for the other types of secrets
+// Returns an error if the certificate or the key is missing or cannot be parsed
+func parseTLS(secret *v1.Secret) (*x509.Certificate, error) {
+	if secret.Type != v1.SecretTypeTLS {
+		return nil, nil
+	}
+
+	for _, key := range []string{v1.TLSCertKey, v1.TLSPrivateKeyKey} {
+		if len(secret.Data[key]) == 0 {
+			return nil, fmt.Errorf("TLS secret %s/%s has no %s", secret.Namespace, secret.Name, key)
+		}
+	}
+	pair, err := tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("TLS secret %s/%s is invalid: %s", secret.Namespace, secret.Name, err)
+	}
+	return x509.ParseCertificate(pair.Certificate[0])
+}
+
+// Checks that the TLS secret can be replicated, before installing its data in a target
+func (r *replicatorProps) validateTLS(secret *v1.Secret) error {
+	cert, err := parseTLS(secret)
+	if err != nil || cert == nil {
+		return err
+	}
+
+	if rejectExpiredCerts && time.Now().After(cert.NotAfter) {
+		return fmt.Errorf("certificate of TLS secret %s/%s expired at %s",
+			secret.Namespace, secret.Name, cert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Updates the expiry metrics of the certificates of the TLS sources
+func (r *objectReplicator) collectCertificates() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	certificates := map[string]bool{}
+	for _, m := range []map[string][]string{r.targets(), r.dependents()} {
+		for source := range m {
+			object, exists, err := r.objectStore.GetByKey(source)
+			if err != nil || !exists {
+				continue
+			}
+			if cert, err := parseTLS(object.(*v1.Secret)); err == nil && cert != nil {
+				certificates[source] = true
+				r.metrics.set("replicator_certificate_expiry_timestamp_seconds", float64(cert.NotAfter.Unix()),
+					"source", source)
+			}
+		}
+	}
+
+	// forget the sources without certificate or targets anymore
+	for source := range r.certificates {
+		if !certificates[source] {
+			r.metrics.delete("replicator_certificate_expiry_timestamp_seconds", "source", source)
+		}
+	}
+	r.certificates = certificates
+}
@@ -0,0 +1,80 @@
+package replicate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Returns a TLS secret with a self-signed certificate expiring at the time
+func tlsSecret(t *testing.T, notAfter time.Time) *v1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tls"},
+		Type:       v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}),
+			v1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+		},
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	defer RejectExpiredCerts(false)
+	r := &replicatorProps{}
+
+	valid := tlsSecret(t, time.Now().Add(time.Hour))
+	if cert, err := parseTLS(valid); err != nil || cert.Subject.CommonName != "example.com" {
+		t.Errorf("expected a valid certificate, got %v", err)
+	}
+
+	expired := tlsSecret(t, time.Now().Add(-time.Hour))
+	if err := r.validateTLS(expired); err != nil {
+		t.Errorf("expected the expired certificate to be accepted by default, got %s", err)
+	}
+	RejectExpiredCerts(true)
+	if err := r.validateTLS(expired); err == nil {
+		t.Errorf("expected the expired certificate to be rejected")
+	}
+	if err := r.validateTLS(valid); err != nil {
+		t.Errorf("expected the valid certificate to be accepted, got %s", err)
+	}
+
+	delete(valid.Data, v1.TLSPrivateKeyKey)
+	if err := r.validateTLS(valid); err == nil {
+		t.Errorf("expected an error for a missing key")
+	}
+
+	// the other types are not checked
+	valid.Type = v1.SecretTypeOpaque
+	if err := r.validateTLS(valid); err != nil {
+		t.Errorf("expected an opaque secret to be accepted, got %s", err)
+	}
+}
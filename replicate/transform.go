@@ -0,0 +1,133 @@
+package replicate
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// transforms the value of a key of the data replicated to a target
+type valueTransform func(key string, value []byte) ([]byte, error)
+
+// returns the transformation of the data replicated from the source to the target,
+// or nil if it does not apply
+type transformHook func(r *replicatorProps, target *metav1.ObjectMeta, source *metav1.ObjectMeta) (valueTransform, error)
+
+// the hooks transforming the replicated data, applied in order
+var transformHooks = []transformHook{
+	templateTransform,
+}
+
+// Returns the transformation of the data replicated from the source to the target,
+// combining all the hooks, or nil if none applies
+func (r *replicatorProps) getTransform(target *metav1.ObjectMeta, source *metav1.ObjectMeta) (valueTransform, error) {
+	transforms := []valueTransform{}
+	for _, hook := range transformHooks {
+		if transform, err := hook(r, target, source); err != nil {
+			return nil, err
+		} else if transform != nil {
+			transforms = append(transforms, transform)
+		}
+	}
+
+	if len(transforms) == 0 {
+		return nil, nil
+	}
+	return func(key string, value []byte) ([]byte, error) {
+		var err error
+		for _, transform := range transforms {
+			if value, err = transform(key, value); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}, nil
+}
+
+// Applies the transformation to a value, if any
+func transformValue(transform valueTransform, key string, value []byte) ([]byte, error) {
+	if transform == nil {
+		return value, nil
+	}
+	return transform(key, value)
+}
+
+// the data available to the templates
+type templateData struct {
+	Namespace       string
+	Name            string
+	SourceNamespace string
+	SourceName      string
+}
+
+// Renders the values as go templates, when the source has the replicate-template annotation
+func templateTransform(r *replicatorProps, target *metav1.ObjectMeta, source *metav1.ObjectMeta) (valueTransform, error) {
+	val, ok := source.Annotations[r.ReplicateTemplateAnnotation]
+	if !ok {
+		return nil, nil
+	} else if b, err := strconv.ParseBool(val); err != nil {
+		return nil, fmt.Errorf("source %s/%s has illformed annotation %s (%s): %s",
+			source.Namespace, source.Name, r.ReplicateTemplateAnnotation, val, err)
+	} else if !b {
+		return nil, nil
+	}
+
+	data := templateData{
+		Namespace:       target.Namespace,
+		Name:            target.Name,
+		SourceNamespace: source.Namespace,
+		SourceName:      source.Name,
+	}
+	return func(key string, value []byte) ([]byte, error) {
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(string(value))
+		if err != nil {
+			return nil, fmt.Errorf("source %s/%s has invalid template in key %s: %s",
+				source.Namespace, source.Name, key, err)
+		}
+
+		var buffer bytes.Buffer
+		if err := tmpl.Execute(&buffer, data); err != nil {
+			return nil, fmt.Errorf("source %s/%s has invalid template in key %s: %s",
+				source.Namespace, source.Name, key, err)
+		}
+		return buffer.Bytes(), nil
+	}, nil
+}
+
+// Returns the template of the names of the targets, from the replicate-to-name annotation of the source, or nil
+func (r *replicatorProps) targetNameTemplate(source *metav1.ObjectMeta) (*template.Template, error) {
+	val, ok := source.Annotations[r.ReplicateToNameAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(val)
+	if err != nil {
+		return nil, fmt.Errorf("source %s/%s has invalid template on annotation %s (%s): %s",
+			source.Namespace, source.Name, r.ReplicateToNameAnnotation, val, err)
+	}
+	return tmpl, nil
+}
+
+// Renders the name of the target in the namespace, from the name given by the source at the path
+func renderTargetName(tmpl *template.Template, source string, namespace string, name string) (string, error) {
+	parts := strings.SplitN(source, "/", 2)
+	data := templateData{
+		Namespace:       namespace,
+		Name:            name,
+		SourceNamespace: parts[0],
+		SourceName:      parts[len(parts)-1],
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	} else if rendered := buffer.String(); !validName.MatchString(rendered) {
+		return "", fmt.Errorf("invalid name %q", rendered)
+	} else {
+		return rendered, nil
+	}
+}
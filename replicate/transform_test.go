@@ -0,0 +1,83 @@
+package replicate
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTemplateTransform(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "endpoints", Annotations: map[string]string{}}
+	target := &metav1.ObjectMeta{Namespace: "team-a", Name: "endpoints"}
+
+	if transform, err := r.getTransform(target, source); err != nil || transform != nil {
+		t.Errorf("expected no transformation without annotation, got %v", err)
+	}
+
+	source.Annotations[r.ReplicateTemplateAnnotation] = "true"
+	transform, err := r.getTransform(target, source)
+	if err != nil || transform == nil {
+		t.Fatalf("expected a transformation, got %v", err)
+	}
+	value, err := transformValue(transform, "endpoint", []byte("svc.{{ .Namespace }}.svc from {{ .SourceNamespace }}/{{ .SourceName }}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := "svc.team-a.svc from default/endpoints"; string(value) != expected {
+		t.Errorf("expected %q, got %q", expected, value)
+	}
+
+	if _, err := transformValue(transform, "endpoint", []byte("{{ .Unknown }}")); err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+
+	source.Annotations[r.ReplicateTemplateAnnotation] = "yes"
+	if _, err := r.getTransform(target, source); err == nil {
+		t.Errorf("expected an error for an illformed annotation")
+	}
+}
+
+func TestTargetNameTemplate(t *testing.T) {
+	r := &replicatorProps{annotationNames: annotationsWithPrefix("")}
+	source := &metav1.ObjectMeta{Namespace: "default", Name: "database", Annotations: map[string]string{
+		r.ReplicateToAnnotation:           "credentials,other/secret",
+		r.ReplicateToNamespacesAnnotation: "team-a,team-[0-9]+",
+		r.ReplicateToNameAnnotation:       "{{ .Name }}-{{ .SourceName }}-{{ .Namespace }}",
+	}}
+
+	targets, patterns, err := r.getReplicationTargets(source)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]bool{
+		"team-a/credentials-database-team-a": true,
+		"other/secret-database-other":        true,
+	}
+	if len(targets) != len(expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+	for _, target := range targets {
+		if !expected[target] {
+			t.Errorf("unexpected target %s", target)
+		}
+	}
+	if len(patterns) != 1 {
+		t.Fatalf("expected a single pattern, got %v", patterns)
+	}
+	if target := patterns[0].MatchNamespace("team-1"); target != "team-1/credentials-database-team-1" {
+		t.Errorf("expected the name rendered in the namespace, got %s", target)
+	}
+	if !patterns[0].MatchString("team-2/credentials-database-team-2") || patterns[0].MatchString("team-2/credentials") {
+		t.Errorf("expected only the rendered name to match")
+	}
+
+	source.Annotations[r.ReplicateToNameAnnotation] = "{{ .Name | upper }}"
+	if _, _, err := r.getReplicationTargets(source); err == nil {
+		t.Errorf("expected an error for an invalid template")
+	}
+	source.Annotations[r.ReplicateToNameAnnotation] = "{{ .Name }}_copy"
+	if _, _, err := r.getReplicationTargets(source); err == nil {
+		t.Errorf("expected an error for an invalid name")
+	}
+}
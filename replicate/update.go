@@ -0,0 +1,80 @@
+package replicate
+
+import (
+	"reflect"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// Queues an object updated by the informer
+// Its former version is kept until processed: the first one, if the object is updated several times meanwhile
+func (r *objectReplicator) enqueueModified(old interface{}, new interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(new)
+	if err != nil {
+		logf("could not get key of %s: %s", r.Name, err)
+		return
+	}
+
+	r.deletedLock.Lock()
+	if _, ok := r.updated[key]; !ok {
+		r.updated[key] = old
+	}
+	r.deletedLock.Unlock()
+	r.queue.Add(key)
+}
+
+// Processes an object updated by the informer, knowing its former version
+// The targets of the former annotations which are not targeted anymore are deleted first, even the ones
+// which are not tracked, as the targets installed by another instance, or before a restart, and even if the
+// source is waiting for its next retry
+func (r *objectReplicator) ObjectUpdated(old interface{}, object interface{}) {
+	r.deleteFormerTargets(old, object)
+	r.ObjectAdded(object)
+}
+
+// Deletes the targets replicated by the former version of the source, which the new version does not target anymore
+func (r *objectReplicator) deleteFormerTargets(old interface{}, object interface{}) {
+	oldMeta, meta := r.getMeta(old), r.getMeta(object)
+	// the targets depend only on the annotations
+	if reflect.DeepEqual(oldMeta.Annotations, meta.Annotations) {
+		return
+	} else if !r.isManaged(oldMeta) || !r.isManaged(meta) || meta.DeletionTimestamp != nil {
+		return
+	}
+	if targets, patterns, err := r.getReplicationTargets(oldMeta); err != nil || len(targets)+len(patterns) == 0 {
+		return
+	}
+	targets, patterns, err := r.getReplicationTargets(meta)
+	if err != nil {
+		// the invalid annotations are reported as the source is processed
+		return
+	}
+	removed := len(targets)+len(patterns) == 0
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := objectKey(meta)
+	replicas, err := r.objectStore.ByIndex(replicatedByIndex, key)
+	if err != nil {
+		r.errorf(key, "", "could not get targets of %s %s: %s", r.Name, key, err)
+		return
+	}
+	for _, item := range replicas {
+		targetMeta := r.getMeta(item)
+		target := objectKey(targetMeta)
+		if ok, _ := r.isReplicatedTo(oldMeta, targetMeta); !ok {
+			continue
+		} else if ok, _ := r.isReplicatedTo(meta, targetMeta); ok {
+			continue
+		}
+
+		if removed {
+			r.infof(key, target, "replication annotations of source %s %s removed: deleting target %s", r.Name, key, target)
+		} else {
+			r.infof(key, target, "annotation of source %s %s changed: deleting former target %s", r.Name, key, target)
+		}
+		r.resolveCollisions(key, target)
+		r.deleteObject(target, object)
+	}
+}
@@ -0,0 +1,111 @@
+package replicate
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestEnqueueModified(t *testing.T) {
+	r := &objectReplicator{replicatorProps: replicatorProps{Name: "config map", annotationNames: annotationsWithPrefix(""),
+		updated: map[string]interface{}{}}}
+	r.queue, r.rateLimiter = newQueue("test")
+	defer r.queue.ShutDown()
+
+	first := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "1"}}
+	second := first.DeepCopy()
+	second.ResourceVersion = "2"
+	third := first.DeepCopy()
+	third.ResourceVersion = "3"
+	r.enqueueModified(first, second)
+	r.enqueueModified(second, third)
+
+	if r.queue.Len() != 1 {
+		t.Errorf("expected the object to be queued once, got %d", r.queue.Len())
+	}
+	if old := r.updated["default/source"]; old != first {
+		t.Errorf("expected the first former version to be kept, got %v", old)
+	}
+}
+
+func TestDeleteFormerTargets(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+		},
+		// the client is not set, any write would panic
+		replicatorActions: &dryRunActions{ConfigMapActions},
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+
+	old := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "1",
+		Annotations: map[string]string{r.ReplicateToAnnotation: "team-a/source,team-b/source"}}}
+	source := old.DeepCopy()
+	source.ResourceVersion = "2"
+	source.Annotations[r.ReplicateToAnnotation] = "team-a/source"
+	r.objectStore.Add(source)
+	for _, namespace := range []string{"team-a", "team-b"} {
+		r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "source",
+			Annotations: map[string]string{r.ReplicatedByAnnotation: "default/source"}}})
+	}
+
+	// the targets of the former annotations are deleted, even if they are not tracked
+	r.deleteFormerTargets(old, source)
+	if actions := DryRunActions(); len(actions) != 1 || actions[0].Action != "delete" || actions[0].Target != "team-b/source" {
+		t.Errorf("expected the deletion of the former target, got %v", actions)
+	}
+
+	// nothing is listed when the annotations did not change
+	dryRunRecords.Lock()
+	dryRunRecords.actions = map[string]DryRunAction{}
+	dryRunRecords.Unlock()
+	r.deleteFormerTargets(source, source)
+	if actions := DryRunActions(); len(actions) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", actions)
+	}
+}
+
+func TestDeleteFormerTargetsRemoved(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "config map",
+			annotationNames: annotationsWithPrefix(""),
+			namespaceStore:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+		},
+		// the client is not set, any write would panic
+		replicatorActions: &dryRunActions{ConfigMapActions},
+	}
+	r.objectStore = cache.NewIndexer(cache.MetaNamespaceKeyFunc, r.indexers())
+	defer func() {
+		dryRunRecords.Lock()
+		dryRunRecords.actions = map[string]DryRunAction{}
+		dryRunRecords.Unlock()
+	}()
+
+	old := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "source", ResourceVersion: "1",
+		Annotations: map[string]string{r.ReplicateToNamespacesAnnotation: "team-a,team-b"}}}
+	source := old.DeepCopy()
+	source.ResourceVersion = "2"
+	delete(source.Annotations, r.ReplicateToNamespacesAnnotation)
+	r.objectStore.Add(source)
+	for _, namespace := range []string{"team-a", "team-b"} {
+		r.objectStore.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "source",
+			Annotations: map[string]string{r.ReplicatedByAnnotation: "default/source"}}})
+	}
+
+	// the targets are deleted right away
+	r.retries = map[string]*retryState{"default/source": {NextRetry: time.Now().Add(time.Hour), version: "2"}}
+	r.ObjectUpdated(old, source)
+	if actions := DryRunActions(); len(actions) != 2 {
+		t.Errorf("expected the deletion of both targets, got %v", actions)
+	}
+}
@@ -0,0 +1,81 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Validate checks the syntax of the replicator annotations of an object being created or updated, from its JSON
+// Returns nil when the object is not of the kind of this replicator
+func (r *objectReplicator) Validate(kind string, raw []byte) error {
+	if strings.ToLower(kind) != r.kind() {
+		return nil
+	}
+
+	_, empty := r.listWatch(r.client)
+	object := reflect.New(reflect.TypeOf(empty).Elem()).Interface()
+	if err := json.Unmarshal(raw, object); err != nil {
+		return err
+	}
+	meta := r.getMeta(object)
+	key := fmt.Sprintf("%s/%s", meta.Namespace, meta.Name)
+	// the copies are written by the replicator
+	if _, ok := meta.Annotations[r.ReplicatedByAnnotation]; ok {
+		return nil
+	}
+
+	for _, annotation := range []string{r.ReplicateOnceAnnotation, r.ReplicationAllowed, r.ReplicateImmutableAnnotation} {
+		if val, ok := meta.Annotations[annotation]; ok {
+			if _, err := strconv.ParseBool(val); err != nil {
+				return fmt.Errorf("%s has illformed annotation %s (%s): expected a boolean", key, annotation, val)
+			}
+		}
+	}
+	if val, ok := resolveAnnotation(meta, r.ReplicateFromAnnotation); ok && !validSourcePaths(val) {
+		return fmt.Errorf("%s has illformed annotation %s (%s): expected [cluster:]namespace/name",
+			key, r.ReplicateFromAnnotation, meta.Annotations[r.ReplicateFromAnnotation])
+	}
+	if _, err := r.getKeyMap(meta, meta); err != nil {
+		return err
+	}
+	if _, err := r.isMerged(meta, meta); err != nil {
+		return err
+	}
+	if _, err := r.sourceLabelPatterns(meta); err != nil {
+		return err
+	}
+	if _, err := r.deletionPolicy(meta); err != nil {
+		return err
+	}
+	if _, _, err := r.syncSchedule(meta); err != nil {
+		return err
+	}
+	if _, err := r.isSourcePaused(meta); err != nil {
+		return err
+	}
+	if _, err := r.maxTargets(meta); err != nil {
+		return err
+	}
+	if _, err := r.isRequesterAllowed(meta, meta); err != nil {
+		return err
+	}
+	if _, err := r.sourcePriority(meta); err != nil {
+		return err
+	}
+	if _, _, ok, err := r.bundleSelector(meta); err != nil {
+		return err
+	} else if _, from := meta.Annotations[r.ReplicateFromAnnotation]; ok && from {
+		return fmt.Errorf("%s has both annotations %s and %s: expected only one", key, r.ReplicateBundleFromAnnotation, r.ReplicateFromAnnotation)
+	}
+	if val, ok := meta.Annotations[r.ReplicateAsAnnotation]; ok && !validName.MatchString(val) {
+		return fmt.Errorf("%s has illformed annotation %s (%s): expected the name of a service account", key, r.ReplicateAsAnnotation, val)
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	_, _, err := r.getReplicationTargets(meta)
+	return err
+}
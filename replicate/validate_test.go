@@ -0,0 +1,52 @@
+package replicate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestValidate(t *testing.T) {
+	r := &objectReplicator{
+		replicatorProps: replicatorProps{
+			Name:            "secret",
+			annotationNames: annotationsWithPrefix(""),
+			namespaceStore:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+		},
+		replicatorActions: SecretActions,
+	}
+
+	for _, test := range []struct {
+		annotations map[string]string
+		valid       bool
+	}{
+		{map[string]string{r.ReplicateToNamespacesAnnotation: "team-.*"}, true},
+		{map[string]string{r.ReplicateToNamespacesAnnotation: "team-(.*"}, false},
+		{map[string]string{r.ReplicateToAnnotation: "other/target", r.ReplicateOnceAnnotation: "true"}, true},
+		{map[string]string{r.ReplicateToAnnotation: "other/target", r.ReplicateOnceAnnotation: "sometimes"}, false},
+		{map[string]string{r.ReplicateToAnnotation: "a/b/c"}, false},
+		{map[string]string{r.ReplicateFromAnnotation: "other/source"}, true},
+		{map[string]string{r.ReplicateFromAnnotation: "other/source/name"}, false},
+		{map[string]string{r.ReplicationAllowed: "yes"}, false},
+		// the copies are not validated
+		{map[string]string{r.ReplicatedByAnnotation: "other/source", r.ReplicateOnceAnnotation: "sometimes"}, true},
+	} {
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "object", Annotations: test.annotations}}
+		raw, _ := json.Marshal(secret)
+		if err := r.Validate("Secret", raw); test.valid && err != nil {
+			t.Errorf("expected %v to be valid, got %s", test.annotations, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("expected %v to be invalid", test.annotations)
+		}
+	}
+
+	// the other kinds are left to their own replicator
+	raw, _ := json.Marshal(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "object",
+		Annotations: map[string]string{r.ReplicateOnceAnnotation: "sometimes"}}})
+	if err := r.Validate("ConfigMap", raw); err != nil {
+		t.Errorf("expected a config map not to be validated by the secret replicator, got %s", err)
+	}
+}
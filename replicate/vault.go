@@ -0,0 +1,147 @@
+package replicate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// VaultSource reads the secrets of HashiCorp Vault, from the KV secrets engines
+// The path is the path of the API, without the /v1 prefix, ex: "secret/data/foo" for the version 2 of the engine
+type VaultSource struct {
+	address string
+	// returns the token of the requests, read again for each request so that it can be renewed
+	token  func() (string, error)
+	client *http.Client
+}
+
+// NewVaultSource creates the source reading the secrets of the Vault server at the address with the token
+func NewVaultSource(address string, token func() (string, error)) *VaultSource {
+	return &VaultSource{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// the response of the KV secrets engines, whose data is nested with its metadata for the version 2
+type vaultResponse struct {
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+// Get returns the data of the secret at the path, and its version
+func (v *VaultSource) Get(path string) (*ExternalData, error) {
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/%s", v.address, strings.TrimPrefix(path, "/")), nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := v.token()
+	if err != nil {
+		return nil, fmt.Errorf("could not get the vault token: %s", err)
+	}
+	request.Header.Set("X-Vault-Token", token)
+
+	response, err := v.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not read vault path %s: %s", path, response.Status)
+	}
+
+	result := vaultResponse{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("could not read vault path %s: %s", path, err)
+	}
+	fields := result.Data
+	onceVersion := ""
+	// the version 2 of the engine nests the data, and a deleted version has no data
+	if nested, ok := result.Data["data"]; ok && result.Data["metadata"] != nil {
+		fields = map[string]json.RawMessage{}
+		metadata := struct {
+			Version int `json:"version"`
+		}{}
+		if string(nested) == "null" {
+			return nil, nil
+		} else if err := json.Unmarshal(nested, &fields); err != nil {
+			return nil, fmt.Errorf("could not read vault path %s: %s", path, err)
+		} else if err := json.Unmarshal(result.Data["metadata"], &metadata); err == nil && metadata.Version > 0 {
+			onceVersion = strconv.Itoa(metadata.Version)
+		}
+	}
+
+	data := externalFields(fields)
+	return &ExternalData{Data: data, Version: externalVersion(data), OnceVersion: onceVersion}, nil
+}
+
+// Sends the request with the token, returning an error unless it succeeded
+func (v *VaultSource) do(method string, path string, body interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	request, err := http.NewRequest(method, fmt.Sprintf("%s/v1/%s", v.address, strings.TrimPrefix(path, "/")), reader)
+	if err != nil {
+		return err
+	}
+	token, err := v.token()
+	if err != nil {
+		return fmt.Errorf("could not get the vault token: %s", err)
+	}
+	request.Header.Set("X-Vault-Token", token)
+
+	response, err := v.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("could not write vault path %s: %s", path, response.Status)
+	}
+	return nil
+}
+
+// Returns true if the path is in the version 2 of the KV secrets engine, as "mount/data/path"
+func vaultVersioned(path string) bool {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	return len(parts) > 2 && parts[1] == "data"
+}
+
+// Put writes the data at the path, as a new version for the version 2 of the engine
+// The values of Vault are strings: data with values which are not valid UTF-8 is rejected, as it would not be read back
+func (v *VaultSource) Put(path string, data map[string][]byte) error {
+	fields := map[string]string{}
+	for key, value := range data {
+		if !utf8.Valid(value) {
+			return fmt.Errorf("could not write vault path %s: the value of %s is not valid UTF-8", path, key)
+		}
+		fields[key] = string(value)
+	}
+	if vaultVersioned(path) {
+		return v.do(http.MethodPost, path, map[string]interface{}{"data": fields})
+	}
+	return v.do(http.MethodPost, path, fields)
+}
+
+// Delete deletes the data at the path, only its latest version for the version 2 of the engine
+func (v *VaultSource) Delete(path string) error {
+	return v.do(http.MethodDelete, path, nil)
+}
@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"github.com/mittwald/kubernetes-replicator/replicate"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// Handler implements the admission webhooks of the replicator:
+//   - /mutate: fills the data of the objects created with a "replicate-from" annotation, so that they are never seen empty,
+//     stamps the user requesting the replication on them, and renames their annotations under the legacy prefixes
+//   - /validate: rejects the objects with illformed replicator annotations
+//
+// The creations are always allowed by /mutate: the objects which cannot be filled are replicated later, as usual
+type Handler struct {
+	Replicators []replicate.Replicator
+	Logger      logr.Logger
+}
+
+// NewHandler creates the routes of the webhooks, logging the objects which cannot be mutated with the logger
+func NewHandler(replicators []replicate.Replicator, logger logr.Logger) http.Handler {
+	h := &Handler{Replicators: replicators, Logger: logger}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mutate", func(res http.ResponseWriter, req *http.Request) { serve(res, req, h.mutate) })
+	mux.HandleFunc("/validate", func(res http.ResponseWriter, req *http.Request) { serve(res, req, h.validate) })
+	return mux
+}
+
+// a JSON patch operation
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Answers an admission review with the response of admit
+func serve(res http.ResponseWriter, req *http.Request, admit func(*admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse) {
+	review := admissionv1beta1.AdmissionReview{}
+	if err := json.NewDecoder(req.Body).Decode(&review); err != nil || review.Request == nil {
+		http.Error(res, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = admit(review.Request)
+	review.Request = nil
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(review)
+}
+
+// Returns the response to the request, with the patch stamping the requester and filling the object if needed
+func (h *Handler) mutate(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	response := &admissionv1beta1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return response
+	}
+
+	object := req.Object.Raw
+	// the legacy annotations are renamed first, so that the object is read as the replicator reads it
+	for _, replicator := range h.Replicators {
+		renamed, ok, err := replicator.RenameLegacy(req.Kind.Kind, object)
+		if err != nil {
+			h.Logger.Error(err, "could not rename the legacy annotations", "kind", req.Kind.Kind, "object", req.Namespace+"/"+req.Name)
+			return response
+		} else if ok {
+			object = renamed
+			break
+		}
+	}
+
+	// the requester is stamped, as the source may only allow some service accounts
+	for _, replicator := range h.Replicators {
+		stamped, ok, err := replicator.Stamp(req.Kind.Kind, object, req.OldObject.Raw, req.UserInfo.Username)
+		if err != nil {
+			h.Logger.Error(err, "could not stamp the requester", "kind", req.Kind.Kind, "object", req.Namespace+"/"+req.Name)
+			return response
+		} else if ok {
+			object = stamped
+			break
+		}
+	}
+
+	for _, replicator := range h.Replicators {
+		if req.Operation != admissionv1beta1.Create {
+			break
+		}
+		resolved, ok, err := replicator.Resolve(req.Kind.Kind, object)
+		if err != nil {
+			h.Logger.Error(err, "could not fill the object at its creation", "kind", req.Kind.Kind, "object", req.Namespace+"/"+req.Name)
+			break
+		} else if ok {
+			object = resolved
+			break
+		}
+	}
+
+	if bytes.Equal(object, req.Object.Raw) {
+		return response
+	}
+	patch, err := createPatch(req.Object.Raw, object)
+	if err != nil {
+		h.Logger.Error(err, "could not mutate the object", "kind", req.Kind.Kind, "object", req.Namespace+"/"+req.Name)
+		return response
+	}
+	patchType := admissionv1beta1.PatchTypeJSONPatch
+	response.Patch = patch
+	response.PatchType = &patchType
+	return response
+}
+
+// Returns the JSON patch replacing the top-level fields of the original object by the ones of the resolved object
+func createPatch(original []byte, resolved []byte) ([]byte, error) {
+	var originalFields, resolvedFields map[string]json.RawMessage
+	if err := json.Unmarshal(original, &originalFields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(resolved, &resolvedFields); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range originalFields {
+		names = append(names, name)
+	}
+	for name := range resolvedFields {
+		if _, ok := originalFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	operations := []operation{}
+	for _, name := range names {
+		value, ok := resolvedFields[name]
+		if !ok {
+			operations = append(operations, operation{Op: "remove", Path: "/" + name})
+		} else if !bytes.Equal(value, originalFields[name]) {
+			// "add" replaces the existing members
+			operations = append(operations, operation{Op: "add", Path: "/" + name, Value: value})
+		}
+	}
+	return json.Marshal(operations)
+}
@@ -0,0 +1,35 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCreatePatch(t *testing.T) {
+	original := []byte(`{"kind":"Secret","metadata":{"name":"target"},"type":"Opaque"}`)
+	resolved := []byte(`{"kind":"Secret","metadata":{"name":"target","annotations":{"a":"b"}},"data":{"key":"dmFsdWU="}}`)
+
+	patch, err := createPatch(original, resolved)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	operations := []operation{}
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []operation{
+		{Op: "add", Path: "/data", Value: json.RawMessage(`{"key":"dmFsdWU="}`)},
+		{Op: "add", Path: "/metadata", Value: json.RawMessage(`{"name":"target","annotations":{"a":"b"}}`)},
+		{Op: "remove", Path: "/type"},
+	}
+	if len(operations) != len(expected) {
+		t.Fatalf("expected %d operations, got %s", len(expected), patch)
+	}
+	for i, op := range expected {
+		if operations[i].Op != op.Op || operations[i].Path != op.Path || string(operations[i].Value) != string(op.Value) {
+			t.Errorf("expected operation %d to be %s %s %s, got %s %s %s", i,
+				op.Op, op.Path, op.Value, operations[i].Op, operations[i].Path, operations[i].Value)
+		}
+	}
+}
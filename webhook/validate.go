@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Returns the response to the request, rejecting the object if its replicator annotations are illformed
+func (h *Handler) validate(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	response := &admissionv1beta1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return response
+	}
+
+	for _, replicator := range h.Replicators {
+		if err := replicator.Validate(req.Kind.Kind, req.Object.Raw); err != nil {
+			response.Allowed = false
+			response.Result = &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Reason:  metav1.StatusReasonInvalid,
+				Message: err.Error(),
+				Code:    http.StatusUnprocessableEntity,
+			}
+			return response
+		}
+	}
+	return response
+}